@@ -0,0 +1,80 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"ad-targeting-engine/internal/auth"
+	"ad-targeting-engine/internal/storage"
+)
+
+func runAPIKey(ctx context.Context, store *storage.Store, args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("missing apikey command\n%s", usage)
+	}
+	switch args[0] {
+	case "create":
+		return createAPIKey(ctx, store, args[1:])
+	case "list":
+		keys, err := store.LoadAPIKeys(ctx)
+		if err != nil {
+			return err
+		}
+		tw := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(tw, "ID\tNAME\tSCOPES\tRATE\tBURST\tCREATED AT")
+		for _, k := range keys {
+			fmt.Fprintf(tw, "%s\t%s\t%s\t%g\t%d\t%s\n", k.ID, k.Name, strings.Join(k.Scopes, ","),
+				k.RatePerSecond, k.Burst, k.CreatedAt.Format("2006-01-02 15:04:05Z07:00"))
+		}
+		return tw.Flush()
+	case "revoke":
+		if len(args) != 2 {
+			return fmt.Errorf("apikey revoke needs a key id\n%s", usage)
+		}
+		return store.RevokeAPIKey(ctx, args[1])
+	default:
+		return fmt.Errorf("unknown apikey command %q\n%s", args[0], usage)
+	}
+}
+
+// createAPIKey stores a new key and prints it; it cannot be shown again.
+func createAPIKey(ctx context.Context, store *storage.Store, args []string) error {
+	if len(args) < 2 || len(args) > 4 {
+		return fmt.Errorf("apikey create needs a name and scopes\n%s", usage)
+	}
+	var scopes []auth.Scope
+	for _, s := range strings.Split(args[1], ",") {
+		sc, ok := auth.ParseScope(strings.TrimSpace(s))
+		if !ok {
+			return fmt.Errorf("unknown scope %q", s)
+		}
+		scopes = append(scopes, sc)
+	}
+	var rate float64
+	var burst int
+	var err error
+	if len(args) > 2 {
+		if rate, err = strconv.ParseFloat(args[2], 64); err != nil || rate < 0 {
+			return fmt.Errorf("invalid rate %q", args[2])
+		}
+	}
+	if len(args) > 3 {
+		if burst, err = strconv.Atoi(args[3]); err != nil || burst < 0 {
+			return fmt.Errorf("invalid burst %q", args[3])
+		}
+	}
+
+	key, row, err := auth.NewKey(args[0], scopes, rate, burst)
+	if err != nil {
+		return err
+	}
+	if err := store.CreateAPIKey(ctx, row); err != nil {
+		return err
+	}
+	fmt.Printf("id:  %s\nkey: %s\n", row.ID, key)
+	return nil
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"context"
+	"errors"
+
+	"github.com/rs/zerolog/log"
+
+	"ad-targeting-engine/internal/api"
+	"ad-targeting-engine/internal/budget"
+	"ad-targeting-engine/internal/config"
+	"ad-targeting-engine/internal/engine"
+	"ad-targeting-engine/internal/events"
+	"ad-targeting-engine/internal/follower"
+)
+
+// follow runs a delivery-only node that never talks to Postgres: its
+// snapshot is replicated from a builder's /internal/v1/snapshot.
+func follow(ctx context.Context, cfg config.Config) error {
+	if cfg.Distribution.FollowURL == "" {
+		return errors.New("distribution.follow_url is required in follower mode")
+	}
+	if cfg.Auth.Enabled {
+		return errors.New("auth.enabled needs the api_keys table and is not supported in follower mode")
+	}
+	eng := engine.NewEngine()
+	eng.ReportSnapshots(ctx)
+	// followers cannot count spend, so capped campaigns are left to the
+	// builder's fleet, which can
+	eng.SetLimiter(budget.Unmetered{})
+
+	f := follower.New(cfg.Distribution.FollowURL, cfg.Distribution.Token, cfg.PollWait(), eng)
+	go f.Run(ctx)
+
+	signer, err := trackingSigner(cfg)
+	if err != nil {
+		return err
+	}
+	auc, err := newAuction(cfg)
+	if err != nil {
+		return err
+	}
+	auc.SetFloors(eng)
+	sink, closeSink, err := eventSink(cfg)
+	if err != nil {
+		return err
+	}
+	// no database, so no rollups or spend: followers' traffic is missing from
+	// reports, and they serve no capped campaign
+	decisions := events.NewSampler(sink, cfg.Events.DecisionSampleRate, nil)
+	delivery := api.NewDeliveryHandler(eng)
+	delivery.Signer, delivery.TrackingBase, delivery.Decisions, delivery.Auction = signer, cfg.Tracking.BaseURL, decisions, auc
+	openRTB := api.NewOpenRTBHandler(eng)
+	openRTB.Decisions, openRTB.Auction, openRTB.Signer, openRTB.TrackingBase = decisions, auc, signer, cfg.Tracking.BaseURL
+	router := api.Router(api.Handlers{
+		Delivery: delivery,
+		OpenRTB:  openRTB,
+		Tracking: api.NewTrackingHandler(signer, sink, eng),
+		Health:   []api.HealthCheck{followerHealth(f, cfg), snapshotHealth(eng)},
+	})
+	grpcSrv, err := serveGRPC(cfg, eng, decisions, auc, signer)
+	if err != nil {
+		return err
+	}
+	log.Info().Str("addr", cfg.Server.Addr).Str("builder", cfg.Distribution.FollowURL).Msg("follower starting")
+	return listenAndServe(ctx, cfg.Server.Addr, router, grpcSrv, closeSink)
+}
+
+// followerHealth reports the node down once it has been out of sync with
+// its builder for longer than distribution.max_age_seconds.
+func followerHealth(f *follower.Follower, cfg config.Config) api.HealthCheck {
+	return func() api.Health {
+		age := f.Age()
+		status := api.HealthOK
+		if age > cfg.SnapshotMaxAge() {
+			status = api.HealthDown
+		}
+		return api.Health{Name: "follower", Status: status, Detail: map[string]any{
+			"age_seconds":     int(age.Seconds()),
+			"max_age_seconds": cfg.Distribution.MaxAgeSeconds,
+		}}
+	}
+}
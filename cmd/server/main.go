@@ -2,15 +2,71 @@ package main
 
 import (
 	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
 
-	"ad-targeting-engine/internal/app/server"
+	"github.com/rs/zerolog/log"
+	"google.golang.org/grpc"
+
+	"ad-targeting-engine/internal/api"
+	"ad-targeting-engine/internal/auction"
+	"ad-targeting-engine/internal/auth"
+	"ad-targeting-engine/internal/budget"
 	"ad-targeting-engine/internal/config"
+	"ad-targeting-engine/internal/engine"
+	"ad-targeting-engine/internal/events"
+	"ad-targeting-engine/internal/listener"
+	"ad-targeting-engine/internal/rollup"
+	"ad-targeting-engine/internal/rpc"
 	"ad-targeting-engine/internal/storage"
+	"ad-targeting-engine/internal/tracking"
+	"ad-targeting-engine/internal/validate"
 )
 
+const usage = `usage:
+  server                      run the delivery server (default)
+  server migrate up           apply all pending migrations
+  server migrate down         revert the last applied migration
+  server migrate to N         migrate up or down to version N (0 reverts all)
+  server migrate status       list migrations and when they were applied
+  server migrate baseline N   record versions 1..N as applied without running them
+  server seed                 load demo campaigns (optional, idempotent)
+  server apikey create NAME SCOPES [RATE [BURST]]
+                              create an API key; SCOPES is a comma list of delivery,admin,metrics
+                              and RATE is requests per second (0 = unlimited)
+  server apikey list          list active API keys
+  server apikey revoke ID     revoke an API key
+  server follow               run a database-less delivery node fed by distribution.follow_url`
+
+// shutdownTimeout bounds draining requests and flushing events on SIGTERM.
+const shutdownTimeout = 15 * time.Second
+
 func main() {
-	ctx := context.Background()
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
 	cfg := config.Load()
+	config.SetupLogging(cfg.Server.LogLevel)
+
+	args := os.Args[1:]
+	if len(args) == 0 {
+		args = []string{"serve"}
+	}
+	if args[0] == "follow" {
+		if err := follow(ctx, cfg); err != nil {
+			log.Error().Err(err).Msg("follow")
+			os.Exit(1)
+		}
+		return
+	}
 
 	store, err := storage.New(ctx, cfg)
 	if err != nil {
@@ -18,13 +74,287 @@ func main() {
 	}
 	defer store.Close()
 
-	cache := storage.NewCache()
+	switch args[0] {
+	case "serve":
+		err = serve(ctx, cfg, store)
+	case "migrate":
+		err = runMigrate(ctx, store, args[1:])
+	case "seed":
+		err = runSeed(ctx, store)
+	case "apikey":
+		err = runAPIKey(ctx, store, args[1:])
+	default:
+		err = fmt.Errorf("unknown command %q\n%s", args[0], usage)
+	}
+	if err != nil {
+		log.Error().Err(err).Msg(args[0])
+		store.Close()
+		os.Exit(1)
+	}
+}
+
+func serve(ctx context.Context, cfg config.Config, store *storage.Store) error {
+	eng := engine.NewEngine()
+	eng.ReportSnapshots(ctx)
+
+	// while the breaker is open, DB calls fail fast and the engine keeps
+	// serving the last good snapshot
+	breaker := storage.NewBreaker("postgres", cfg.Postgres.Breaker.FailureThreshold, cfg.BreakerOpenFor())
+	repo := storage.NewBreakerRepository(store, breaker)
+	source := nodeSource()
+
+	// caps are enforced from the first request, with the spend known so far
+	pacer := budget.New(repo, source)
+	if err := pacer.Reconcile(ctx); err != nil {
+		log.Error().Err(err).Msg("load campaign spend")
+	}
+	eng.SetLimiter(pacer)
+	go pacer.Run(ctx, cfg.BudgetsReconcile())
+
+	// warmup snapshot; the listener resyncs and then follows change_events
+	if err := eng.BuildSnapshot(ctx, repo); err != nil {
+		log.Error().Err(err).Msg("warmup snapshot")
+	}
+	// keys reload on every snapshot swap, so subscribe before the listener
+	// starts publishing
+	var keys *auth.Keyring
+	if cfg.Auth.Enabled {
+		keys = auth.NewKeyring(cfg.Auth.Header)
+		if err := keys.Reload(ctx, repo); err != nil {
+			log.Error().Err(err).Msg("load api keys")
+		}
+		go keys.ReloadOn(ctx, eng.Watch(ctx), repo)
+	}
+	go listener.ListenAndRefresh(ctx, repo, eng, cfg.Resync(), cfg.OutboxRetention())
+
+	signer, err := trackingSigner(cfg)
+	if err != nil {
+		return err
+	}
+	auc, err := newAuction(cfg)
+	if err != nil {
+		return err
+	}
+	auc.SetFloors(eng)
+	sink, closeSink, err := eventSink(cfg)
+	if err != nil {
+		return err
+	}
+	// the rollups see every decision and tracking event, whatever the sampling
+	rollups := rollup.New(repo, source)
+	go rollups.Run(ctx, cfg.ReportsFlush())
+	decisions := events.NewSampler(sink, cfg.Events.DecisionSampleRate, rollups)
+	delivery := api.NewDeliveryHandler(eng)
+	delivery.Signer, delivery.TrackingBase, delivery.Decisions, delivery.Auction = signer, cfg.Tracking.BaseURL, decisions, auc
+	openRTB := api.NewOpenRTBHandler(eng)
+	openRTB.Decisions, openRTB.Auction, openRTB.Signer, openRTB.TrackingBase = decisions, auc, signer, cfg.Tracking.BaseURL
+	admin := api.NewAdminHandler(repo, cfg.Admin.Token)
+	admin.Stats, admin.Publishers, admin.Advertisers, admin.Engine = repo, repo, repo, eng
+	hs := api.Handlers{
+		Delivery: delivery,
+		Admin:    admin,
+		OpenRTB:  openRTB,
+		Tracking: api.NewTrackingHandler(signer, events.Tee{sink, rollups, pacer}, eng),
+		Health:   []api.HealthCheck{databaseHealth(breaker, store), snapshotHealth(eng)},
+		Keys:     keys,
+	}
+	if cfg.Distribution.Token != "" {
+		hs.Snapshot = api.NewSnapshotHandler(eng, cfg.Distribution.Token, cfg.PollWait())
+	}
+	grpcSrv, err := serveGRPC(cfg, eng, decisions, auc, signer)
+	if err != nil {
+		return err
+	}
+	log.Info().Str("addr", cfg.Server.Addr).Msg("http server starting")
+	return listenAndServe(ctx, cfg.Server.Addr, api.Router(hs), grpcSrv, func(ctx context.Context) error {
+		return errors.Join(closeSink(ctx), rollups.Close(ctx), pacer.Close(ctx))
+	})
+}
+
+// nodeSource names this process in campaign_stats_hourly and
+// campaign_spend: the host name and a random suffix, so restarts never
+// overwrite earlier totals.
+func nodeSource() string {
+	host, err := os.Hostname()
+	if err != nil {
+		host = "unknown"
+	}
+	var b [4]byte
+	_, _ = rand.Read(b[:])
+	return host + "-" + hex.EncodeToString(b[:])
+}
+
+// listenAndServe serves HTTP until ctx is done, then stops taking requests,
+// lets those in flight finish and flushes the event sink, all within
+// shutdownTimeout. grpcSrv, if not nil, is stopped alongside.
+func listenAndServe(ctx context.Context, addr string, h http.Handler, grpcSrv *grpc.Server,
+	closeSink func(context.Context) error) error {
+	srv := &http.Server{Addr: addr, Handler: h}
+	errc := make(chan error, 1)
+	go func() { errc <- srv.ListenAndServe() }()
+
+	var err error
+	select {
+	case err = <-errc:
+	case <-ctx.Done():
+		log.Info().Msg("shutting down")
+	}
+	sctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	if grpcSrv != nil {
+		stopped := make(chan struct{})
+		go func() {
+			grpcSrv.GracefulStop()
+			close(stopped)
+		}()
+		select {
+		case <-stopped:
+		case <-sctx.Done():
+			grpcSrv.Stop()
+		}
+	}
+	if err == nil {
+		if serr := srv.Shutdown(sctx); serr != nil {
+			err = fmt.Errorf("shutdown http: %w", serr)
+		}
+	}
+	if cerr := closeSink(sctx); cerr != nil {
+		log.Error().Err(cerr).Msg("flush events")
+	}
+	return err
+}
+
+// newAuction builds the auction from cfg.Auction.
+func newAuction(cfg config.Config) (*auction.Auction, error) {
+	switch cfg.Auction.Type {
+	case auction.FirstPrice, auction.SecondPrice:
+	default:
+		return nil, fmt.Errorf("unknown auction.type %q, want %s or %s", cfg.Auction.Type, auction.FirstPrice, auction.SecondPrice)
+	}
+	floors := make(map[string]float64, len(cfg.Auction.Floors))
+	for _, f := range cfg.Auction.Floors {
+		floors[f.App] = f.CPM
+	}
+	exclusions := make([][]string, 0, len(cfg.Auction.Exclusions))
+	for i, g := range cfg.Auction.Exclusions {
+		group := make([]string, 0, len(g))
+		for _, c := range g {
+			c = strings.ToUpper(strings.TrimSpace(c))
+			if !validate.IABCategory.MatchString(c) {
+				return nil, fmt.Errorf("auction.exclusions[%d]: %q is not an IAB category", i, c)
+			}
+			group = append(group, c)
+		}
+		exclusions = append(exclusions, group)
+	}
+	return auction.New(auction.Config{
+		Type:       cfg.Auction.Type,
+		Increment:  cfg.Auction.Increment,
+		Slots:      cfg.Auction.Slots,
+		Floor:      cfg.Auction.Floor,
+		Floors:     floors,
+		Exclusions: exclusions,
+	}, nil), nil
+}
 
-	// warmup cache
-	campaigns, _ := store.LoadActiveCampaigns(ctx)
-	cache.UpdateCampaigns(campaigns)
+// eventSink builds the sink configured under events, and the function that
+// flushes and closes it on shutdown.
+func eventSink(cfg config.Config) (events.Sink, func(context.Context) error, error) {
+	switch cfg.Events.Sink {
+	case "log":
+		return events.LogSink{}, func(context.Context) error { return nil }, nil
+	case "file":
+		w, err := events.NewFileWriter(cfg.Events.Dir, "events", int64(cfg.Events.MaxFileMB)<<20, cfg.EventsRotate())
+		if err != nil {
+			return nil, nil, err
+		}
+		b := events.NewBatcher(w, events.BatchConfig{
+			QueueSize:     cfg.Events.QueueSize,
+			BatchSize:     cfg.Events.BatchSize,
+			FlushInterval: cfg.EventsFlush(),
+			Block:         cfg.EventsBlock(),
+		})
+		log.Info().Str("dir", cfg.Events.Dir).Msg("writing events to files")
+		return b, b.Close, nil
+	default:
+		return nil, nil, fmt.Errorf("unknown events.sink %q, want log or file", cfg.Events.Sink)
+	}
+}
 
-	srv := server.New(store, cache)
-	srv.StartCacheRefresher(ctx)
-	srv.Start(cfg.Server.Addr)
+// trackingSigner signs tracking links with the configured keys, or with a
+// random key of this process when there are none.
+func trackingSigner(cfg config.Config) (*tracking.Signer, error) {
+	keys := make([]tracking.Key, len(cfg.Tracking.Keys))
+	for i, k := range cfg.Tracking.Keys {
+		keys[i] = tracking.Key{ID: k.ID, Secret: k.Secret}
+	}
+	if len(keys) == 0 {
+		k, err := tracking.EphemeralKey()
+		if err != nil {
+			return nil, err
+		}
+		log.Warn().Msg("no tracking.keys configured: tracking links only verify on this node until it restarts")
+		keys = []tracking.Key{k}
+	}
+	signer, err := tracking.NewSigner(keys, cfg.LinkTTL())
+	if err != nil {
+		return nil, fmt.Errorf("tracking signer: %w", err)
+	}
+	return signer, nil
+}
+
+// serveGRPC starts DeliveryService in the background when grpc.addr is set,
+// returning nil otherwise.
+func serveGRPC(cfg config.Config, eng *engine.DeliveryEngine, decisions *events.Sampler, auc *auction.Auction,
+	signer *tracking.Signer) (*grpc.Server, error) {
+	if cfg.GRPC.Addr == "" {
+		return nil, nil
+	}
+	lis, err := net.Listen("tcp", cfg.GRPC.Addr)
+	if err != nil {
+		return nil, fmt.Errorf("listen grpc: %w", err)
+	}
+	tr := rpc.Tracking{Signer: signer, Base: cfg.Tracking.BaseURL}
+	srv := rpc.NewServer(eng, cfg.GRPCTimeout(), cfg.GRPC.MaxBatch, decisions, auc, tr)
+	log.Info().Str("addr", cfg.GRPC.Addr).Msg("grpc server starting")
+	go func() {
+		if err := srv.Serve(lis); err != nil {
+			log.Error().Err(err).Msg("grpc server")
+		}
+	}()
+	return srv, nil
+}
+
+// databaseHealth reports the database as degraded while the breaker is not
+// closed: delivery continues from the in-memory snapshot, but it goes stale
+// and admin writes fail.
+func databaseHealth(b *storage.Breaker, store *storage.Store) api.HealthCheck {
+	return func() api.Health {
+		state := b.State()
+		status := api.HealthOK
+		if state != storage.BreakerClosed {
+			status = api.HealthDegraded
+		}
+		return api.Health{
+			Name:   "database",
+			Status: status,
+			Detail: map[string]any{"breaker": state.String(), "read_endpoint": store.ReadEndpoint()},
+		}
+	}
+}
+
+// snapshotHealth reports which snapshot is served. Before the first
+// successful build the engine matches nothing, which counts as degraded.
+func snapshotHealth(eng *engine.DeliveryEngine) api.HealthCheck {
+	return func() api.Health {
+		meta := eng.Snapshot()
+		if meta.Version == 0 {
+			return api.Health{Name: "snapshot", Status: api.HealthDegraded, Detail: map[string]any{"version": 0}}
+		}
+		return api.Health{Name: "snapshot", Status: api.HealthOK, Detail: map[string]any{
+			"version":  meta.Version,
+			"built_at": meta.BuiltAt.UTC().Format(time.RFC3339),
+			"hash":     fmt.Sprintf("%016x", meta.Hash),
+		}}
+	}
 }
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strconv"
+	"text/tabwriter"
+
+	"github.com/rs/zerolog/log"
+
+	"ad-targeting-engine/db/migrations"
+	"ad-targeting-engine/db/seed"
+	"ad-targeting-engine/internal/migrate"
+	"ad-targeting-engine/internal/storage"
+)
+
+func runMigrate(ctx context.Context, store *storage.Store, args []string) error {
+	ms, err := migrate.Load(migrations.FS)
+	if err != nil {
+		return err
+	}
+	r := migrate.NewRunner(store.PgxPool(), ms)
+
+	if len(args) == 0 {
+		return fmt.Errorf("missing migrate command\n%s", usage)
+	}
+	switch args[0] {
+	case "up":
+		return r.Up(ctx)
+	case "down":
+		return r.Down(ctx)
+	case "to":
+		if len(args) != 2 {
+			return fmt.Errorf("migrate to needs a version\n%s", usage)
+		}
+		v, err := strconv.Atoi(args[1])
+		if err != nil || v < 0 {
+			return fmt.Errorf("invalid version %q", args[1])
+		}
+		return r.To(ctx, v)
+	case "baseline":
+		if len(args) != 2 {
+			return fmt.Errorf("migrate baseline needs a version\n%s", usage)
+		}
+		v, err := strconv.Atoi(args[1])
+		if err != nil || v <= 0 {
+			return fmt.Errorf("invalid version %q", args[1])
+		}
+		return r.Baseline(ctx, v)
+	case "status":
+		sts, err := r.Status(ctx)
+		if err != nil {
+			return err
+		}
+		tw := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(tw, "VERSION\tNAME\tAPPLIED AT")
+		for _, st := range sts {
+			applied := "pending"
+			if st.AppliedAt != nil {
+				applied = st.AppliedAt.Format("2006-01-02 15:04:05Z07:00")
+			}
+			fmt.Fprintf(tw, "%03d\t%s\t%s\n", st.Version, st.Name, applied)
+		}
+		return tw.Flush()
+	default:
+		return fmt.Errorf("unknown migrate command %q\n%s", args[0], usage)
+	}
+}
+
+func runSeed(ctx context.Context, store *storage.Store) error {
+	if _, err := store.PgxPool().Exec(ctx, seed.SQL); err != nil {
+		return fmt.Errorf("seed: %w", err)
+	}
+	log.Info().Msg("seed data loaded")
+	return nil
+}
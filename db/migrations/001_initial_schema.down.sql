@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS targeting_rules;
+DROP TABLE IF EXISTS campaigns;
+DROP FUNCTION IF EXISTS notify_data_change();
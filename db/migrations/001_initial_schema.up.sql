@@ -30,15 +30,3 @@ FOR EACH ROW EXECUTE PROCEDURE notify_data_change();
 CREATE TRIGGER targeting_rules_notify_change
 AFTER INSERT OR UPDATE OR DELETE ON targeting_rules
 FOR EACH ROW EXECUTE PROCEDURE notify_data_change();
-
-INSERT INTO campaigns (id, name, image_url, cta, status) VALUES
-    ('spotify', 'Spotify - Music for everyone', 'https://somelink', 'Download', 'ACTIVE'),
-    ('duolingo', 'Duolingo: Best way to learn', 'https://somelink2', 'Install', 'ACTIVE'),
-    ('subwaysurfer', 'Subway Surfer', 'https://somelink3', 'Play', 'ACTIVE');
-
-INSERT INTO targeting_rules (campaign_id, dimension, is_inclusion, values) VALUES
-    ('spotify', 'Country', true, ARRAY['US', 'Canada']),
-    ('duolingo', 'OS', true, ARRAY['Android', 'iOS']),
-    ('duolingo', 'Country', false, ARRAY['US']),
-    ('subwaysurfer', 'OS', true, ARRAY['Android']),
-    ('subwaysurfer', 'AppID', true, ARRAY['com.gametion.ludokinggame']);
\ No newline at end of file
@@ -0,0 +1,21 @@
+DROP TRIGGER IF EXISTS campaigns_change_event ON campaigns;
+DROP TRIGGER IF EXISTS targeting_rules_change_event ON targeting_rules;
+DROP FUNCTION IF EXISTS record_change_event();
+DROP TABLE IF EXISTS change_events;
+
+-- restore the per-row notifications from 001
+CREATE OR REPLACE FUNCTION notify_data_change()
+RETURNS TRIGGER AS $$
+BEGIN
+    PERFORM pg_notify('data_changed', TG_TABLE_NAME || ', id: ' || COALESCE(NEW.id, OLD.id)::text);
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER campaigns_notify_change
+AFTER INSERT OR UPDATE OR DELETE ON campaigns
+FOR EACH ROW EXECUTE PROCEDURE notify_data_change();
+
+CREATE TRIGGER targeting_rules_notify_change
+AFTER INSERT OR UPDATE OR DELETE ON targeting_rules
+FOR EACH ROW EXECUTE PROCEDURE notify_data_change();
@@ -0,0 +1,51 @@
+-- Transactional outbox: every campaign/rule mutation writes one row here in
+-- the same transaction as the change. The listener replays rows after its
+-- last processed seq, so a bulk edit is applied as one ordered batch.
+CREATE TABLE change_events (
+    seq BIGSERIAL PRIMARY KEY,
+    entity_type TEXT NOT NULL CHECK (entity_type IN ('campaign', 'targeting_rule')),
+    entity_id TEXT NOT NULL,
+    campaign_id VARCHAR(50) NOT NULL,
+    op TEXT NOT NULL CHECK (op IN ('INSERT', 'UPDATE', 'DELETE')),
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX change_events_created_at_idx ON change_events (created_at);
+
+DROP TRIGGER IF EXISTS campaigns_notify_change ON campaigns;
+DROP TRIGGER IF EXISTS targeting_rules_notify_change ON targeting_rules;
+DROP FUNCTION IF EXISTS notify_data_change();
+
+CREATE OR REPLACE FUNCTION record_change_event()
+RETURNS TRIGGER AS $$
+DECLARE
+    row_data RECORD;
+BEGIN
+    IF TG_OP = 'DELETE' THEN
+        row_data := OLD;
+    ELSE
+        row_data := NEW;
+    END IF;
+
+    IF TG_TABLE_NAME = 'campaigns' THEN
+        INSERT INTO change_events (entity_type, entity_id, campaign_id, op)
+        VALUES ('campaign', row_data.id::text, row_data.id, TG_OP);
+    ELSE
+        INSERT INTO change_events (entity_type, entity_id, campaign_id, op)
+        VALUES ('targeting_rule', row_data.id::text, row_data.campaign_id, TG_OP);
+    END IF;
+
+    -- Identical payloads are collapsed by Postgres within a transaction,
+    -- so a bulk edit produces a single wake-up for the listener.
+    PERFORM pg_notify('tg_data_change', 'change_events');
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER campaigns_change_event
+AFTER INSERT OR UPDATE OR DELETE ON campaigns
+FOR EACH ROW EXECUTE PROCEDURE record_change_event();
+
+CREATE TRIGGER targeting_rules_change_event
+AFTER INSERT OR UPDATE OR DELETE ON targeting_rules
+FOR EACH ROW EXECUTE PROCEDURE record_change_event();
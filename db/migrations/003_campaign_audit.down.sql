@@ -0,0 +1,4 @@
+DROP TRIGGER IF EXISTS campaigns_audit ON campaigns;
+DROP TRIGGER IF EXISTS targeting_rules_audit ON targeting_rules;
+DROP FUNCTION IF EXISTS record_audit();
+DROP TABLE IF EXISTS campaign_audit;
@@ -0,0 +1,65 @@
+-- Audit trail of every campaign and targeting rule mutation. The actor comes
+-- from the transaction-local setting app.actor (set by the admin API) and
+-- falls back to the database user for ad-hoc SQL.
+CREATE TABLE campaign_audit (
+    id BIGSERIAL PRIMARY KEY,
+    entity_type TEXT NOT NULL CHECK (entity_type IN ('campaign', 'targeting_rule')),
+    entity_id TEXT NOT NULL,
+    campaign_id VARCHAR(50) NOT NULL,
+    op TEXT NOT NULL CHECK (op IN ('INSERT', 'UPDATE', 'DELETE')),
+    actor TEXT NOT NULL,
+    before JSONB,
+    after JSONB,
+    changed_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX campaign_audit_campaign_idx ON campaign_audit (campaign_id, changed_at);
+CREATE INDEX campaign_audit_changed_at_idx ON campaign_audit (changed_at);
+
+CREATE OR REPLACE FUNCTION record_audit()
+RETURNS TRIGGER AS $$
+DECLARE
+    change_actor TEXT := COALESCE(NULLIF(current_setting('app.actor', true), ''), current_user);
+    before_data JSONB;
+    after_data JSONB;
+    row_id TEXT;
+    row_campaign TEXT;
+BEGIN
+    IF TG_OP <> 'INSERT' THEN
+        before_data := to_jsonb(OLD);
+    END IF;
+    IF TG_OP <> 'DELETE' THEN
+        after_data := to_jsonb(NEW);
+    END IF;
+
+    row_id := COALESCE(after_data, before_data)->>'id';
+    IF TG_TABLE_NAME = 'campaigns' THEN
+        row_campaign := row_id;
+    ELSE
+        row_campaign := COALESCE(after_data, before_data)->>'campaign_id';
+    END IF;
+
+    INSERT INTO campaign_audit (entity_type, entity_id, campaign_id, op, actor, before, after)
+    VALUES (
+        CASE WHEN TG_TABLE_NAME = 'campaigns' THEN 'campaign' ELSE 'targeting_rule' END,
+        row_id, row_campaign, TG_OP, change_actor, before_data, after_data
+    );
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER campaigns_audit
+AFTER INSERT OR UPDATE OR DELETE ON campaigns
+FOR EACH ROW EXECUTE PROCEDURE record_audit();
+
+CREATE TRIGGER targeting_rules_audit
+AFTER INSERT OR UPDATE OR DELETE ON targeting_rules
+FOR EACH ROW EXECUTE PROCEDURE record_audit();
+
+-- Baseline so that point-in-time reconstruction sees rows that existed
+-- before auditing was enabled.
+INSERT INTO campaign_audit (entity_type, entity_id, campaign_id, op, actor, after)
+SELECT 'campaign', c.id, c.id, 'INSERT', 'migration', to_jsonb(c) FROM campaigns c;
+
+INSERT INTO campaign_audit (entity_type, entity_id, campaign_id, op, actor, after)
+SELECT 'targeting_rule', r.id::text, r.campaign_id, 'INSERT', 'migration', to_jsonb(r) FROM targeting_rules r;
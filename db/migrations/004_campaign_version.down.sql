@@ -0,0 +1,3 @@
+DROP TRIGGER IF EXISTS campaigns_bump_version ON campaigns;
+DROP FUNCTION IF EXISTS bump_campaign_version();
+ALTER TABLE campaigns DROP COLUMN IF EXISTS version;
@@ -0,0 +1,17 @@
+-- Monotonic revision per campaign, used for optimistic concurrency by the
+-- admin API and carried into the delivery snapshot. The trigger makes every
+-- UPDATE bump it, including ad-hoc SQL; rule writes through the admin API
+-- touch the campaign row so they bump it too.
+ALTER TABLE campaigns ADD COLUMN version BIGINT NOT NULL DEFAULT 1;
+
+CREATE OR REPLACE FUNCTION bump_campaign_version()
+RETURNS TRIGGER AS $$
+BEGIN
+    NEW.version := OLD.version + 1;
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER campaigns_bump_version
+BEFORE UPDATE ON campaigns
+FOR EACH ROW EXECUTE PROCEDURE bump_campaign_version();
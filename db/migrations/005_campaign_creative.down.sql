@@ -0,0 +1,3 @@
+ALTER TABLE campaigns
+    DROP COLUMN IF EXISTS markup,
+    DROP COLUMN IF EXISTS bid_price;
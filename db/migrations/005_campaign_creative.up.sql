@@ -0,0 +1,6 @@
+-- What a campaign pays and shows when it wins programmatic traffic. The bid
+-- price is a CPM in USD; markup is the creative's ad markup (HTML or VAST),
+-- generated from image_url and cta when empty.
+ALTER TABLE campaigns
+    ADD COLUMN bid_price NUMERIC(12, 4) NOT NULL DEFAULT 0 CHECK (bid_price >= 0),
+    ADD COLUMN markup TEXT;
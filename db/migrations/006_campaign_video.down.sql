@@ -0,0 +1,8 @@
+ALTER TABLE campaigns
+    DROP CONSTRAINT IF EXISTS campaigns_video_complete,
+    DROP COLUMN IF EXISTS video_height,
+    DROP COLUMN IF EXISTS video_width,
+    DROP COLUMN IF EXISTS video_duration,
+    DROP COLUMN IF EXISTS video_mime,
+    DROP COLUMN IF EXISTS video_url,
+    DROP COLUMN IF EXISTS creative_type;
@@ -0,0 +1,13 @@
+-- Video creatives. A video campaign carries one progressive media file,
+-- rendered as a VAST linear ad; duration is in seconds.
+ALTER TABLE campaigns
+    ADD COLUMN creative_type TEXT NOT NULL DEFAULT 'banner' CHECK (creative_type IN ('banner', 'video')),
+    ADD COLUMN video_url TEXT,
+    ADD COLUMN video_mime TEXT,
+    ADD COLUMN video_duration INT CHECK (video_duration > 0),
+    ADD COLUMN video_width INT CHECK (video_width > 0),
+    ADD COLUMN video_height INT CHECK (video_height > 0),
+    ADD CONSTRAINT campaigns_video_complete CHECK (
+        creative_type <> 'video'
+        OR (video_url IS NOT NULL AND video_mime IS NOT NULL AND video_duration IS NOT NULL)
+    );
@@ -0,0 +1,14 @@
+-- API keys for the delivery, admin and metrics endpoints. Only a SHA-256 of
+-- the key is stored; the key itself is shown once, when it is created.
+-- rate_per_second = 0 means unlimited; burst = 0 means one second's worth.
+CREATE TABLE api_keys (
+    id              TEXT PRIMARY KEY,
+    name            TEXT NOT NULL,
+    key_hash        TEXT NOT NULL UNIQUE,
+    scopes          TEXT[] NOT NULL
+        CHECK (cardinality(scopes) > 0 AND scopes <@ ARRAY['delivery', 'admin', 'metrics']),
+    rate_per_second DOUBLE PRECISION NOT NULL DEFAULT 0 CHECK (rate_per_second >= 0),
+    burst           INT NOT NULL DEFAULT 0 CHECK (burst >= 0),
+    created_at      TIMESTAMPTZ NOT NULL DEFAULT now(),
+    revoked_at      TIMESTAMPTZ
+);
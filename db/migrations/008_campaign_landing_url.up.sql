@@ -0,0 +1,4 @@
+-- Where a click on the campaign's creative takes the user. The click
+-- tracker redirects here after recording the click.
+ALTER TABLE campaigns
+    ADD COLUMN landing_url TEXT CHECK (landing_url ~ '^https?://');
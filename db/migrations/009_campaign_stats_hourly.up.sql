@@ -0,0 +1,19 @@
+-- Hourly delivery counters per campaign and request dimensions. Each node
+-- (source) upserts its own running totals for the hour, so re-sending a
+-- flush is harmless and reports sum over sources. No foreign key: stats
+-- outlive deleted campaigns.
+CREATE TABLE campaign_stats_hourly (
+    hour        TIMESTAMPTZ NOT NULL CHECK (date_trunc('hour', hour) = hour),
+    campaign_id TEXT NOT NULL,
+    country     TEXT NOT NULL DEFAULT '',
+    os          TEXT NOT NULL DEFAULT '',
+    app         TEXT NOT NULL DEFAULT '',
+    source      TEXT NOT NULL,
+    matches     BIGINT NOT NULL DEFAULT 0 CHECK (matches >= 0),
+    impressions BIGINT NOT NULL DEFAULT 0 CHECK (impressions >= 0),
+    clicks      BIGINT NOT NULL DEFAULT 0 CHECK (clicks >= 0),
+    updated_at  TIMESTAMPTZ NOT NULL DEFAULT now(),
+    PRIMARY KEY (hour, campaign_id, country, os, app, source)
+);
+
+CREATE INDEX campaign_stats_hourly_campaign_idx ON campaign_stats_hourly (campaign_id, hour);
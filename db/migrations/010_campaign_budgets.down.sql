@@ -0,0 +1,8 @@
+DROP TABLE IF EXISTS campaign_spend;
+
+ALTER TABLE campaigns
+    DROP COLUMN IF EXISTS pacing,
+    DROP COLUMN IF EXISTS lifetime_budget,
+    DROP COLUMN IF EXISTS daily_budget,
+    DROP COLUMN IF EXISTS lifetime_impression_cap,
+    DROP COLUMN IF EXISTS daily_impression_cap;
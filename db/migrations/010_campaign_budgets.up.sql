@@ -0,0 +1,24 @@
+-- Delivery caps. NULL means uncapped. Budgets are in USD, spent at the
+-- campaign's CPM per impression; days are UTC. Even pacing spreads the
+-- daily caps over the day.
+ALTER TABLE campaigns
+    ADD COLUMN daily_impression_cap BIGINT CHECK (daily_impression_cap > 0),
+    ADD COLUMN lifetime_impression_cap BIGINT CHECK (lifetime_impression_cap > 0),
+    ADD COLUMN daily_budget NUMERIC(14, 4) CHECK (daily_budget > 0),
+    ADD COLUMN lifetime_budget NUMERIC(14, 4) CHECK (lifetime_budget > 0),
+    ADD COLUMN pacing TEXT NOT NULL DEFAULT 'asap' CHECK (pacing IN ('asap', 'even'));
+
+-- Impressions and spend per campaign and UTC day. Like
+-- campaign_stats_hourly, each node (source) upserts its own running
+-- totals, so the caps are checked against the sum over sources.
+CREATE TABLE campaign_spend (
+    day          DATE NOT NULL,
+    campaign_id  TEXT NOT NULL,
+    source       TEXT NOT NULL,
+    impressions  BIGINT NOT NULL DEFAULT 0 CHECK (impressions >= 0),
+    spend_micros BIGINT NOT NULL DEFAULT 0 CHECK (spend_micros >= 0),
+    updated_at   TIMESTAMPTZ NOT NULL DEFAULT now(),
+    PRIMARY KEY (day, campaign_id, source)
+);
+
+CREATE INDEX campaign_spend_campaign_idx ON campaign_spend (campaign_id);
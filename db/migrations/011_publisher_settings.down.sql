@@ -0,0 +1,36 @@
+DROP TRIGGER IF EXISTS publisher_settings_change_event ON publisher_settings;
+DROP TABLE IF EXISTS publisher_settings;
+
+DELETE FROM change_events WHERE entity_type = 'publisher';
+ALTER TABLE change_events DROP CONSTRAINT change_events_entity_type_check;
+ALTER TABLE change_events ADD CONSTRAINT change_events_entity_type_check
+    CHECK (entity_type IN ('campaign', 'targeting_rule'));
+
+-- restore the function from 002
+CREATE OR REPLACE FUNCTION record_change_event()
+RETURNS TRIGGER AS $$
+DECLARE
+    row_data RECORD;
+BEGIN
+    IF TG_OP = 'DELETE' THEN
+        row_data := OLD;
+    ELSE
+        row_data := NEW;
+    END IF;
+
+    IF TG_TABLE_NAME = 'campaigns' THEN
+        INSERT INTO change_events (entity_type, entity_id, campaign_id, op)
+        VALUES ('campaign', row_data.id::text, row_data.id, TG_OP);
+    ELSE
+        INSERT INTO change_events (entity_type, entity_id, campaign_id, op)
+        VALUES ('targeting_rule', row_data.id::text, row_data.campaign_id, TG_OP);
+    END IF;
+
+    PERFORM pg_notify('tg_data_change', 'change_events');
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+ALTER TABLE campaigns
+    DROP COLUMN IF EXISTS category,
+    DROP COLUMN IF EXISTS advertiser_id;
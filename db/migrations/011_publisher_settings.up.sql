@@ -0,0 +1,54 @@
+-- Who a campaign advertises for and what it is about, so publishers can
+-- block them. category is an IAB content category such as IAB9-30.
+ALTER TABLE campaigns
+    ADD COLUMN advertiser_id TEXT,
+    ADD COLUMN category TEXT CHECK (category ~ '^IAB[0-9]+(-[0-9]+)?$');
+
+-- Per-app publisher settings: a floor CPM in USD and block lists. Blocking
+-- a tier-1 category (IAB9) also blocks its subcategories (IAB9-30).
+CREATE TABLE publisher_settings (
+    app_id              TEXT PRIMARY KEY,
+    floor               NUMERIC(12, 4) NOT NULL DEFAULT 0 CHECK (floor >= 0),
+    blocked_advertisers TEXT[] NOT NULL DEFAULT '{}',
+    blocked_categories  TEXT[] NOT NULL DEFAULT '{}',
+    blocked_campaigns   TEXT[] NOT NULL DEFAULT '{}',
+    version             BIGINT NOT NULL DEFAULT 1,
+    updated_at          TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+-- Publisher changes reach the snapshot through the outbox like campaign
+-- changes. They belong to no campaign, so campaign_id is empty.
+ALTER TABLE change_events DROP CONSTRAINT change_events_entity_type_check;
+ALTER TABLE change_events ADD CONSTRAINT change_events_entity_type_check
+    CHECK (entity_type IN ('campaign', 'targeting_rule', 'publisher'));
+
+CREATE OR REPLACE FUNCTION record_change_event()
+RETURNS TRIGGER AS $$
+DECLARE
+    row_data RECORD;
+BEGIN
+    IF TG_OP = 'DELETE' THEN
+        row_data := OLD;
+    ELSE
+        row_data := NEW;
+    END IF;
+
+    IF TG_TABLE_NAME = 'campaigns' THEN
+        INSERT INTO change_events (entity_type, entity_id, campaign_id, op)
+        VALUES ('campaign', row_data.id::text, row_data.id, TG_OP);
+    ELSIF TG_TABLE_NAME = 'publisher_settings' THEN
+        INSERT INTO change_events (entity_type, entity_id, campaign_id, op)
+        VALUES ('publisher', row_data.app_id, '', TG_OP);
+    ELSE
+        INSERT INTO change_events (entity_type, entity_id, campaign_id, op)
+        VALUES ('targeting_rule', row_data.id::text, row_data.campaign_id, TG_OP);
+    END IF;
+
+    PERFORM pg_notify('tg_data_change', 'change_events');
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER publisher_settings_change_event
+AFTER INSERT OR UPDATE OR DELETE ON publisher_settings
+FOR EACH ROW EXECUTE PROCEDURE record_change_event();
@@ -0,0 +1,38 @@
+DROP TRIGGER IF EXISTS advertisers_change_event ON advertisers;
+
+DELETE FROM change_events WHERE entity_type = 'advertiser';
+ALTER TABLE change_events DROP CONSTRAINT change_events_entity_type_check;
+ALTER TABLE change_events ADD CONSTRAINT change_events_entity_type_check
+    CHECK (entity_type IN ('campaign', 'targeting_rule', 'publisher'));
+
+-- restore the function from 011
+CREATE OR REPLACE FUNCTION record_change_event()
+RETURNS TRIGGER AS $$
+DECLARE
+    row_data RECORD;
+BEGIN
+    IF TG_OP = 'DELETE' THEN
+        row_data := OLD;
+    ELSE
+        row_data := NEW;
+    END IF;
+
+    IF TG_TABLE_NAME = 'campaigns' THEN
+        INSERT INTO change_events (entity_type, entity_id, campaign_id, op)
+        VALUES ('campaign', row_data.id::text, row_data.id, TG_OP);
+    ELSIF TG_TABLE_NAME = 'publisher_settings' THEN
+        INSERT INTO change_events (entity_type, entity_id, campaign_id, op)
+        VALUES ('publisher', row_data.app_id, '', TG_OP);
+    ELSE
+        INSERT INTO change_events (entity_type, entity_id, campaign_id, op)
+        VALUES ('targeting_rule', row_data.id::text, row_data.campaign_id, TG_OP);
+    END IF;
+
+    PERFORM pg_notify('tg_data_change', 'change_events');
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP INDEX IF EXISTS campaigns_advertiser_id_idx;
+ALTER TABLE campaigns DROP CONSTRAINT IF EXISTS campaigns_advertiser_id_fkey;
+DROP TABLE IF EXISTS advertisers;
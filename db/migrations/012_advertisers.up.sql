@@ -0,0 +1,57 @@
+-- Advertisers own campaigns. Their status and blocked apps apply to every
+-- campaign they own.
+CREATE TABLE advertisers (
+    id           TEXT PRIMARY KEY,
+    name         VARCHAR(255) NOT NULL,
+    status       TEXT NOT NULL DEFAULT 'ACTIVE' CHECK (status IN ('ACTIVE', 'INACTIVE')),
+    blocked_apps TEXT[] NOT NULL DEFAULT '{}',
+    version      BIGINT NOT NULL DEFAULT 1,
+    updated_at   TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+-- advertiser_id was free text until now: give every ID in use a row.
+INSERT INTO advertisers (id, name)
+SELECT DISTINCT advertiser_id, advertiser_id FROM campaigns WHERE advertiser_id IS NOT NULL;
+
+ALTER TABLE campaigns
+    ADD CONSTRAINT campaigns_advertiser_id_fkey
+    FOREIGN KEY (advertiser_id) REFERENCES advertisers (id) ON DELETE RESTRICT;
+CREATE INDEX campaigns_advertiser_id_idx ON campaigns (advertiser_id);
+
+ALTER TABLE change_events DROP CONSTRAINT change_events_entity_type_check;
+ALTER TABLE change_events ADD CONSTRAINT change_events_entity_type_check
+    CHECK (entity_type IN ('campaign', 'targeting_rule', 'publisher', 'advertiser'));
+
+CREATE OR REPLACE FUNCTION record_change_event()
+RETURNS TRIGGER AS $$
+DECLARE
+    row_data RECORD;
+BEGIN
+    IF TG_OP = 'DELETE' THEN
+        row_data := OLD;
+    ELSE
+        row_data := NEW;
+    END IF;
+
+    IF TG_TABLE_NAME = 'campaigns' THEN
+        INSERT INTO change_events (entity_type, entity_id, campaign_id, op)
+        VALUES ('campaign', row_data.id::text, row_data.id, TG_OP);
+    ELSIF TG_TABLE_NAME = 'publisher_settings' THEN
+        INSERT INTO change_events (entity_type, entity_id, campaign_id, op)
+        VALUES ('publisher', row_data.app_id, '', TG_OP);
+    ELSIF TG_TABLE_NAME = 'advertisers' THEN
+        INSERT INTO change_events (entity_type, entity_id, campaign_id, op)
+        VALUES ('advertiser', row_data.id, '', TG_OP);
+    ELSE
+        INSERT INTO change_events (entity_type, entity_id, campaign_id, op)
+        VALUES ('targeting_rule', row_data.id::text, row_data.campaign_id, TG_OP);
+    END IF;
+
+    PERFORM pg_notify('tg_data_change', 'change_events');
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER advertisers_change_event
+AFTER INSERT OR UPDATE OR DELETE ON advertisers
+FOR EACH ROW EXECUTE PROCEDURE record_change_event();
@@ -0,0 +1,4 @@
+-- The conversion to alpha-2 is not reverted: the old values were not
+-- matched by any request.
+DROP VIEW IF EXISTS invalid_country_rules;
+DROP TABLE IF EXISTS country_codes;
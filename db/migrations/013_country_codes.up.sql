@@ -0,0 +1,110 @@
+-- Country rule values used to be upper-cased and nothing more, so rules may
+-- hold names or alpha-3 codes that never match a request. country_codes maps
+-- every code geo.CountryAlpha2 accepts to the alpha-2 form requests carry.
+-- UK maps to GB; deleted codes (SU, YU, DD, ...) and exceptional ones
+-- (UN, AC, ...) are absent, so rules holding them are flagged below.
+CREATE TABLE country_codes (
+    code   TEXT PRIMARY KEY,
+    alpha2 CHAR(2) NOT NULL
+);
+
+INSERT INTO country_codes (code, alpha2) VALUES
+    ('AD','AD'), ('AE','AE'), ('AF','AF'), ('AG','AG'), ('AI','AI'), ('AL','AL'), ('AM','AM'), ('AO','AO'),
+    ('AQ','AQ'), ('AR','AR'), ('AS','AS'), ('AT','AT'), ('AU','AU'), ('AW','AW'), ('AX','AX'), ('AZ','AZ'),
+    ('BA','BA'), ('BB','BB'), ('BD','BD'), ('BE','BE'), ('BF','BF'), ('BG','BG'), ('BH','BH'), ('BI','BI'),
+    ('BJ','BJ'), ('BL','BL'), ('BM','BM'), ('BN','BN'), ('BO','BO'), ('BQ','BQ'), ('BR','BR'), ('BS','BS'),
+    ('BT','BT'), ('BV','BV'), ('BW','BW'), ('BY','BY'), ('BZ','BZ'), ('CA','CA'), ('CC','CC'), ('CD','CD'),
+    ('CF','CF'), ('CG','CG'), ('CH','CH'), ('CI','CI'), ('CK','CK'), ('CL','CL'), ('CM','CM'), ('CN','CN'),
+    ('CO','CO'), ('CR','CR'), ('CU','CU'), ('CV','CV'), ('CW','CW'), ('CX','CX'), ('CY','CY'), ('CZ','CZ'),
+    ('DE','DE'), ('DJ','DJ'), ('DK','DK'), ('DM','DM'), ('DO','DO'), ('DZ','DZ'), ('EC','EC'), ('EE','EE'),
+    ('EG','EG'), ('EH','EH'), ('ER','ER'), ('ES','ES'), ('ET','ET'), ('FI','FI'), ('FJ','FJ'), ('FK','FK'),
+    ('FM','FM'), ('FO','FO'), ('FR','FR'), ('GA','GA'), ('GB','GB'), ('GD','GD'), ('GE','GE'), ('GF','GF'),
+    ('GG','GG'), ('GH','GH'), ('GI','GI'), ('GL','GL'), ('GM','GM'), ('GN','GN'), ('GP','GP'), ('GQ','GQ'),
+    ('GR','GR'), ('GS','GS'), ('GT','GT'), ('GU','GU'), ('GW','GW'), ('GY','GY'), ('HK','HK'), ('HM','HM'),
+    ('HN','HN'), ('HR','HR'), ('HT','HT'), ('HU','HU'), ('ID','ID'), ('IE','IE'), ('IL','IL'), ('IM','IM'),
+    ('IN','IN'), ('IO','IO'), ('IQ','IQ'), ('IR','IR'), ('IS','IS'), ('IT','IT'), ('JE','JE'), ('JM','JM'),
+    ('JO','JO'), ('JP','JP'), ('KE','KE'), ('KG','KG'), ('KH','KH'), ('KI','KI'), ('KM','KM'), ('KN','KN'),
+    ('KP','KP'), ('KR','KR'), ('KW','KW'), ('KY','KY'), ('KZ','KZ'), ('LA','LA'), ('LB','LB'), ('LC','LC'),
+    ('LI','LI'), ('LK','LK'), ('LR','LR'), ('LS','LS'), ('LT','LT'), ('LU','LU'), ('LV','LV'), ('LY','LY'),
+    ('MA','MA'), ('MC','MC'), ('MD','MD'), ('ME','ME'), ('MF','MF'), ('MG','MG'), ('MH','MH'), ('MK','MK'),
+    ('ML','ML'), ('MM','MM'), ('MN','MN'), ('MO','MO'), ('MP','MP'), ('MQ','MQ'), ('MR','MR'), ('MS','MS'),
+    ('MT','MT'), ('MU','MU'), ('MV','MV'), ('MW','MW'), ('MX','MX'), ('MY','MY'), ('MZ','MZ'), ('NA','NA'),
+    ('NC','NC'), ('NE','NE'), ('NF','NF'), ('NG','NG'), ('NI','NI'), ('NL','NL'), ('NO','NO'), ('NP','NP'),
+    ('NR','NR'), ('NU','NU'), ('NZ','NZ'), ('OM','OM'), ('PA','PA'), ('PE','PE'), ('PF','PF'), ('PG','PG'),
+    ('PH','PH'), ('PK','PK'), ('PL','PL'), ('PM','PM'), ('PN','PN'), ('PR','PR'), ('PS','PS'), ('PT','PT'),
+    ('PW','PW'), ('PY','PY'), ('QA','QA'), ('RE','RE'), ('RO','RO'), ('RS','RS'), ('RU','RU'), ('RW','RW'),
+    ('SA','SA'), ('SB','SB'), ('SC','SC'), ('SD','SD'), ('SE','SE'), ('SG','SG'), ('SH','SH'), ('SI','SI'),
+    ('SJ','SJ'), ('SK','SK'), ('SL','SL'), ('SM','SM'), ('SN','SN'), ('SO','SO'), ('SR','SR'), ('SS','SS'),
+    ('ST','ST'), ('SV','SV'), ('SX','SX'), ('SY','SY'), ('SZ','SZ'), ('TC','TC'), ('TD','TD'), ('TF','TF'),
+    ('TG','TG'), ('TH','TH'), ('TJ','TJ'), ('TK','TK'), ('TL','TL'), ('TM','TM'), ('TN','TN'), ('TO','TO'),
+    ('TR','TR'), ('TT','TT'), ('TV','TV'), ('TW','TW'), ('TZ','TZ'), ('UA','UA'), ('UG','UG'), ('UK','GB'),
+    ('UM','UM'), ('US','US'), ('UY','UY'), ('UZ','UZ'), ('VA','VA'), ('VC','VC'), ('VE','VE'), ('VG','VG'),
+    ('VI','VI'), ('VN','VN'), ('VU','VU'), ('WF','WF'), ('WS','WS'), ('XK','XK'), ('YE','YE'), ('YT','YT'),
+    ('ZA','ZA'), ('ZM','ZM'), ('ZW','ZW'), ('ABW','AW'), ('AFG','AF'), ('AGO','AO'), ('AIA','AI'), ('ALA','AX'),
+    ('ALB','AL'), ('AND','AD'), ('ARE','AE'), ('ARG','AR'), ('ARM','AM'), ('ASM','AS'), ('ATA','AQ'), ('ATF','TF'),
+    ('ATG','AG'), ('AUS','AU'), ('AUT','AT'), ('AZE','AZ'), ('BDI','BI'), ('BEL','BE'), ('BEN','BJ'), ('BES','BQ'),
+    ('BFA','BF'), ('BGD','BD'), ('BGR','BG'), ('BHR','BH'), ('BHS','BS'), ('BIH','BA'), ('BLM','BL'), ('BLR','BY'),
+    ('BLZ','BZ'), ('BMU','BM'), ('BOL','BO'), ('BRA','BR'), ('BRB','BB'), ('BRN','BN'), ('BTN','BT'), ('BVT','BV'),
+    ('BWA','BW'), ('CAF','CF'), ('CAN','CA'), ('CCK','CC'), ('CHE','CH'), ('CHL','CL'), ('CHN','CN'), ('CIV','CI'),
+    ('CMR','CM'), ('COD','CD'), ('COG','CG'), ('COK','CK'), ('COL','CO'), ('COM','KM'), ('CPV','CV'), ('CRI','CR'),
+    ('CUB','CU'), ('CUW','CW'), ('CXR','CX'), ('CYM','KY'), ('CYP','CY'), ('CZE','CZ'), ('DEU','DE'), ('DJI','DJ'),
+    ('DMA','DM'), ('DNK','DK'), ('DOM','DO'), ('DZA','DZ'), ('ECU','EC'), ('EGY','EG'), ('ERI','ER'), ('ESH','EH'),
+    ('ESP','ES'), ('EST','EE'), ('ETH','ET'), ('FIN','FI'), ('FJI','FJ'), ('FLK','FK'), ('FRA','FR'), ('FRO','FO'),
+    ('FSM','FM'), ('GAB','GA'), ('GBR','GB'), ('GEO','GE'), ('GGY','GG'), ('GHA','GH'), ('GIB','GI'), ('GIN','GN'),
+    ('GLP','GP'), ('GMB','GM'), ('GNB','GW'), ('GNQ','GQ'), ('GRC','GR'), ('GRD','GD'), ('GRL','GL'), ('GTM','GT'),
+    ('GUF','GF'), ('GUM','GU'), ('GUY','GY'), ('HKG','HK'), ('HMD','HM'), ('HND','HN'), ('HRV','HR'), ('HTI','HT'),
+    ('HUN','HU'), ('IDN','ID'), ('IMN','IM'), ('IND','IN'), ('IOT','IO'), ('IRL','IE'), ('IRN','IR'), ('IRQ','IQ'),
+    ('ISL','IS'), ('ISR','IL'), ('ITA','IT'), ('JAM','JM'), ('JEY','JE'), ('JOR','JO'), ('JPN','JP'), ('KAZ','KZ'),
+    ('KEN','KE'), ('KGZ','KG'), ('KHM','KH'), ('KIR','KI'), ('KNA','KN'), ('KOR','KR'), ('KWT','KW'), ('LAO','LA'),
+    ('LBN','LB'), ('LBR','LR'), ('LBY','LY'), ('LCA','LC'), ('LIE','LI'), ('LKA','LK'), ('LSO','LS'), ('LTU','LT'),
+    ('LUX','LU'), ('LVA','LV'), ('MAC','MO'), ('MAF','MF'), ('MAR','MA'), ('MCO','MC'), ('MDA','MD'), ('MDG','MG'),
+    ('MDV','MV'), ('MEX','MX'), ('MHL','MH'), ('MKD','MK'), ('MLI','ML'), ('MLT','MT'), ('MMR','MM'), ('MNE','ME'),
+    ('MNG','MN'), ('MNP','MP'), ('MOZ','MZ'), ('MRT','MR'), ('MSR','MS'), ('MTQ','MQ'), ('MUS','MU'), ('MWI','MW'),
+    ('MYS','MY'), ('MYT','YT'), ('NAM','NA'), ('NCL','NC'), ('NER','NE'), ('NFK','NF'), ('NGA','NG'), ('NIC','NI'),
+    ('NIU','NU'), ('NLD','NL'), ('NOR','NO'), ('NPL','NP'), ('NRU','NR'), ('NZL','NZ'), ('OMN','OM'), ('PAK','PK'),
+    ('PAN','PA'), ('PCN','PN'), ('PER','PE'), ('PHL','PH'), ('PLW','PW'), ('PNG','PG'), ('POL','PL'), ('PRI','PR'),
+    ('PRK','KP'), ('PRT','PT'), ('PRY','PY'), ('PSE','PS'), ('PYF','PF'), ('QAT','QA'), ('REU','RE'), ('ROU','RO'),
+    ('RUS','RU'), ('RWA','RW'), ('SAU','SA'), ('SDN','SD'), ('SEN','SN'), ('SGP','SG'), ('SGS','GS'), ('SHN','SH'),
+    ('SJM','SJ'), ('SLB','SB'), ('SLE','SL'), ('SLV','SV'), ('SMR','SM'), ('SOM','SO'), ('SPM','PM'), ('SRB','RS'),
+    ('SSD','SS'), ('STP','ST'), ('SUR','SR'), ('SVK','SK'), ('SVN','SI'), ('SWE','SE'), ('SWZ','SZ'), ('SXM','SX'),
+    ('SYC','SC'), ('SYR','SY'), ('TCA','TC'), ('TCD','TD'), ('TGO','TG'), ('THA','TH'), ('TJK','TJ'), ('TKL','TK'),
+    ('TKM','TM'), ('TLS','TL'), ('TON','TO'), ('TTO','TT'), ('TUN','TN'), ('TUR','TR'), ('TUV','TV'), ('TWN','TW'),
+    ('TZA','TZ'), ('UGA','UG'), ('UKR','UA'), ('UMI','UM'), ('URY','UY'), ('USA','US'), ('UZB','UZ'), ('VAT','VA'),
+    ('VCT','VC'), ('VEN','VE'), ('VGB','VG'), ('VIR','VI'), ('VNM','VN'), ('VUT','VU'), ('WLF','WF'), ('WSM','WS'),
+    ('XKK','XK'), ('YEM','YE'), ('ZAF','ZA'), ('ZMB','ZM'), ('ZWE','ZW');
+
+-- Convert what can be converted: trim, upper-case, map alpha-3 to alpha-2 and
+-- drop the duplicates that leaves, keeping the first position of each.
+WITH fixed AS (
+    SELECT t.id, ARRAY(
+        SELECT COALESCE(cc.alpha2, upper(btrim(u.v)))
+        FROM unnest(t.values) WITH ORDINALITY AS u(v, i)
+        LEFT JOIN country_codes cc ON cc.code = upper(btrim(u.v))
+        GROUP BY 1
+        ORDER BY min(u.i)
+    ) AS vals
+    FROM targeting_rules t
+    WHERE t.dimension = 'Country'
+)
+UPDATE targeting_rules t SET values = f.vals
+FROM fixed f
+WHERE t.id = f.id AND t.values <> f.vals;
+
+-- Flag the rest. These values were never matched; review and fix them
+-- through the admin API, which now rejects them.
+CREATE VIEW invalid_country_rules AS
+SELECT t.campaign_id, u.v AS value
+FROM targeting_rules t, unnest(t.values) AS u(v)
+WHERE t.dimension = 'Country'
+  AND NOT EXISTS (SELECT 1 FROM country_codes cc WHERE cc.alpha2 = u.v);
+
+DO $$
+DECLARE
+    n INTEGER;
+BEGIN
+    SELECT count(DISTINCT campaign_id) INTO n FROM invalid_country_rules;
+    IF n > 0 THEN
+        RAISE WARNING '% campaign(s) have country rule values that are not ISO 3166-1 codes; see invalid_country_rules', n;
+    END IF;
+END;
+$$;
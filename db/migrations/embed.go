@@ -0,0 +1,9 @@
+// Package migrations embeds the versioned schema migrations. Files are named
+// NNN_description.up.sql / NNN_description.down.sql and applied in NNN order
+// by internal/migrate.
+package migrations
+
+import "embed"
+
+//go:embed *.sql
+var FS embed.FS
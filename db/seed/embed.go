@@ -0,0 +1,7 @@
+// Package seed embeds the optional demo data for local runs.
+package seed
+
+import _ "embed"
+
+//go:embed seed.sql
+var SQL string
@@ -0,0 +1,15 @@
+-- Demo campaigns for local runs. Applied by `server seed`, never by migrations;
+-- safe to re-run.
+INSERT INTO campaigns (id, name, image_url, cta, status, landing_url) VALUES
+    ('spotify', 'Spotify - Music for everyone', 'https://somelink', 'Download', 'ACTIVE', 'https://www.spotify.com'),
+    ('duolingo', 'Duolingo: Best way to learn', 'https://somelink2', 'Install', 'ACTIVE', 'https://www.duolingo.com'),
+    ('subwaysurfer', 'Subway Surfer', 'https://somelink3', 'Play', 'ACTIVE', 'https://subwaysurfers.com')
+ON CONFLICT (id) DO NOTHING;
+
+INSERT INTO targeting_rules (campaign_id, dimension, is_inclusion, values) VALUES
+    ('spotify', 'Country', true, ARRAY['US', 'CA']),
+    ('duolingo', 'OS', true, ARRAY['Android', 'iOS']),
+    ('duolingo', 'Country', false, ARRAY['US']),
+    ('subwaysurfer', 'OS', true, ARRAY['Android']),
+    ('subwaysurfer', 'AppID', true, ARRAY['com.gametion.ludokinggame'])
+ON CONFLICT (campaign_id, dimension) DO NOTHING;
@@ -1,17 +1,139 @@
 package tests
 
 import (
+	"context"
+	"fmt"
+	"runtime"
+	"runtime/metrics"
 	"testing"
+	"time"
 
 	"ad-targeting-engine/internal/engine"
+	"ad-targeting-engine/internal/storage"
 )
 
 func BenchmarkMatch(b *testing.B) {
+	var cs []storage.CampaignRow
+	for i := 0; i < 1000; i++ {
+		cs = append(cs, storage.CampaignRow{
+			ID:     fmt.Sprintf("c%04d", i),
+			Name:   "bench",
+			Status: "ACTIVE",
+			Rules: []storage.RuleRow{
+				{Dimension: "country", IsInclusion: true, Values: []string{[]string{"IN", "US", "DE"}[i%3]}},
+				{Dimension: "os", IsInclusion: true, Values: []string{[]string{"android", "ios"}[i%2]}},
+			},
+		})
+	}
 	eng := engine.NewEngine()
-	// Skipping snapshot build for brevity; in real test, set snapshot directly
+	if err := eng.BuildSnapshot(context.Background(), storage.NewMemoryStore(cs...)); err != nil {
+		b.Fatal(err)
+	}
 	req := engine.MatchRequest{AppID: "com.app", Country: "IN", OS: "android"}
 	b.ResetTimer()
 	for i := 0; i < b.N; i++ {
-		_ = eng.Match(nil, req)
+		_ = eng.Match(context.Background(), req)
+	}
+}
+
+// ruleStream generates campaigns on the fly, standing in for a paged
+// database load without holding every row.
+type ruleStream struct {
+	storage.CampaignReader
+	campaigns int
+}
+
+func (s ruleStream) row(i int) storage.CampaignRow {
+	return storage.CampaignRow{
+		ID:     fmt.Sprintf("c%07d", i),
+		Name:   "bench",
+		Status: "ACTIVE",
+		Rules: []storage.RuleRow{
+			{Dimension: "country", IsInclusion: true, Values: []string{[]string{"in", "us", "de"}[i%3], "gb"}},
+			{Dimension: "os", IsInclusion: true, Values: []string{[]string{"android", "ios"}[i%2]}},
+			{Dimension: "appid", IsInclusion: i%4 != 0, Values: []string{fmt.Sprintf("com.app%d", i%500)}},
+			{Dimension: "country", IsInclusion: false, Values: []string{"fr"}},
+		},
+	}
+}
+
+func (ruleStream) LoadPublishers(context.Context) ([]storage.PublisherRow, error) { return nil, nil }
+
+func (ruleStream) LoadAdvertisers(context.Context) ([]storage.AdvertiserRow, error) { return nil, nil }
+
+func (s ruleStream) StreamActiveCampaigns(_ context.Context, fn func(storage.CampaignRow) error) (int64, error) {
+	for i := 0; i < s.campaigns; i++ {
+		if err := fn(s.row(i)); err != nil {
+			return 0, err
+		}
+	}
+	return 0, nil
+}
+
+// peakHeap polls the live heap until stop is closed and returns the peak
+// growth over the heap at start.
+func peakHeap(stop <-chan struct{}) <-chan uint64 {
+	out := make(chan uint64, 1)
+	s := []metrics.Sample{{Name: "/memory/classes/heap/objects:bytes"}}
+	metrics.Read(s)
+	start, peak := s[0].Value.Uint64(), s[0].Value.Uint64()
+	go func() {
+		t := time.NewTicker(time.Millisecond)
+		defer t.Stop()
+		for {
+			metrics.Read(s)
+			peak = max(peak, s[0].Value.Uint64())
+			select {
+			case <-stop:
+				out <- peak - start
+				return
+			case <-t.C:
+			}
+		}
+	}()
+	return out
+}
+
+// BenchmarkBuildSnapshot_1MRules compares building 250k campaigns / 1M rules
+// from a stream against materializing every row first, which is what a
+// slice-returning load does. Compare the peak-heap-MB columns.
+func BenchmarkBuildSnapshot_1MRules(b *testing.B) {
+	src := ruleStream{campaigns: 250_000}
+	run := func(b *testing.B, build func(*engine.DeliveryEngine) error) {
+		var peak uint64
+		for i := 0; i < b.N; i++ {
+			b.StopTimer()
+			runtime.GC()
+			stop := make(chan struct{})
+			done := peakHeap(stop)
+			b.StartTimer()
+
+			if err := build(engine.NewEngine()); err != nil {
+				b.Fatal(err)
+			}
+
+			b.StopTimer()
+			close(stop)
+			peak = max(peak, <-done)
+			b.StartTimer()
+		}
+		b.ReportMetric(float64(peak)/(1<<20), "peak-heap-MB")
 	}
+
+	b.Run("streaming", func(b *testing.B) {
+		run(b, func(eng *engine.DeliveryEngine) error {
+			return eng.BuildSnapshot(context.Background(), src)
+		})
+	})
+	b.Run("materialized", func(b *testing.B) {
+		run(b, func(eng *engine.DeliveryEngine) error {
+			rows := make([]storage.CampaignRow, 0, src.campaigns)
+			_, _ = src.StreamActiveCampaigns(context.Background(), func(r storage.CampaignRow) error {
+				rows = append(rows, r)
+				return nil
+			})
+			eng.Load(rows)
+			return nil
+		})
+	})
 }
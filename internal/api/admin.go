@@ -0,0 +1,570 @@
+package api
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/rs/zerolog/log"
+
+	"ad-targeting-engine/internal/auth"
+	"ad-targeting-engine/internal/engine"
+	"ad-targeting-engine/internal/geo"
+	"ad-targeting-engine/internal/storage"
+	"ad-targeting-engine/internal/validate"
+)
+
+// CampaignStore is the write/read surface the admin API needs.
+type CampaignStore interface {
+	storage.CampaignReader
+	storage.CampaignWriter
+}
+
+// AdminHandler serves campaign CRUD under /admin/v1. Writes go straight to
+// the store; the change_events triggers propagate them to the delivery
+// snapshot. Updates and deletes are optimistic: the caller sends the version
+// it last read in If-Match (as returned in ETag) and gets 409 if someone
+// else wrote in between.
+type AdminHandler struct {
+	Store CampaignStore
+	Token string
+
+	// Stats serves /reports; nil leaves it unmounted.
+	Stats storage.StatsStore
+	// Publishers serves /publishers; nil leaves it unmounted.
+	Publishers storage.PublisherStore
+	// Advertisers serves /advertisers; nil leaves it unmounted.
+	Advertisers storage.AdvertiserStore
+	// Engine serves /explain from its current snapshot; nil leaves it
+	// unmounted.
+	Engine *engine.DeliveryEngine
+}
+
+func NewAdminHandler(st CampaignStore, token string) *AdminHandler {
+	return &AdminHandler{Store: st, Token: token}
+}
+
+type campaignPayload struct {
+	ID       string         `json:"id"`
+	Name     string         `json:"name"`
+	ImageURL string         `json:"image_url"`
+	CTA      string         `json:"cta"`
+	Status   string         `json:"status"`
+	Version  int64          `json:"version,omitempty"`
+	BidPrice float64        `json:"bid_price"`
+	Markup   string         `json:"markup,omitempty"`
+	Landing  string         `json:"landing_url,omitempty"`
+	Creative string         `json:"creative_type,omitempty"`
+	Video    *videoPayload  `json:"video,omitempty"`
+	Budget   *budgetPayload `json:"budget,omitempty"`
+	Rules    []rulePayload  `json:"rules"`
+
+	Advertiser string `json:"advertiser_id,omitempty"`
+	Category   string `json:"category,omitempty"`
+}
+
+// videoPayload is the media file of a video campaign; duration is in
+// seconds.
+type videoPayload struct {
+	URL      string `json:"url"`
+	MIME     string `json:"mime"`
+	Duration int    `json:"duration"`
+	Width    int    `json:"width"`
+	Height   int    `json:"height"`
+}
+
+// budgetPayload holds a campaign's caps; spend is in USD. Omitted or zero
+// caps are unlimited.
+type budgetPayload struct {
+	DailyImpressions    int64   `json:"daily_impressions,omitempty"`
+	LifetimeImpressions int64   `json:"lifetime_impressions,omitempty"`
+	DailySpend          float64 `json:"daily_spend,omitempty"`
+	LifetimeSpend       float64 `json:"lifetime_spend,omitempty"`
+	Pacing              string  `json:"pacing,omitempty"`
+}
+
+type rulePayload struct {
+	Dimension string   `json:"dimension"`
+	Include   *bool    `json:"include,omitempty"`
+	Values    []string `json:"values"`
+}
+
+type rulesPayload struct {
+	Rules []rulePayload `json:"rules"`
+}
+
+// fieldErrors maps a JSON path (e.g. "rules[0].dimension") to a message.
+type fieldErrors map[string]string
+
+func (h *AdminHandler) Routes() http.Handler {
+	r := chi.NewRouter()
+	r.Use(h.authenticate)
+
+	r.Get("/campaigns", h.listCampaigns)
+	r.Post("/campaigns", h.createCampaign)
+	r.Get("/campaigns/export", h.exportCampaigns)
+	r.Post("/campaigns/import", h.importCampaigns)
+	r.Get("/campaigns/{id}", h.getCampaign)
+	r.Put("/campaigns/{id}", h.updateCampaign)
+	r.Delete("/campaigns/{id}", h.deleteCampaign)
+	r.Put("/campaigns/{id}/rules", h.replaceRules)
+	r.Get("/campaigns/{id}/audit", h.listAudit)
+	r.Get("/history/campaigns", h.historyCampaigns)
+	r.Get("/history/delivery", h.historyDelivery)
+	if h.Stats != nil {
+		r.Get("/reports", h.reports)
+	}
+	if h.Publishers != nil {
+		r.Get("/publishers", h.listPublishers)
+		r.Get("/publishers/{app}", h.getPublisher)
+		r.Put("/publishers/{app}", h.putPublisher)
+		r.Delete("/publishers/{app}", h.deletePublisher)
+	}
+	if h.Advertisers != nil {
+		r.Get("/advertisers", h.listAdvertisers)
+		r.Get("/advertisers/{id}", h.getAdvertiser)
+		r.Put("/advertisers/{id}", h.putAdvertiser)
+		r.Delete("/advertisers/{id}", h.deleteAdvertiser)
+	}
+	if h.Engine != nil {
+		r.Get("/explain", h.explain)
+	}
+	return r
+}
+
+// authenticate requires "Authorization: Bearer <token>", unless the request
+// already carries an admin-scoped API key (see Handlers.Keys). An empty
+// configured token disables token access rather than leaving it open. With
+// a key the audit actor is the key name; X-Actor can only add to it. The
+// token is shared, so behind it X-Actor is advisory: whoever holds the token
+// can name any actor. Give callers keys when the audit trail must hold up.
+func (h *AdminHandler) authenticate(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		actor := "admin"
+		xActor := strings.TrimSpace(r.Header.Get("X-Actor"))
+		if key, ok := auth.FromContext(r.Context()); ok && key.Has(auth.ScopeAdmin) {
+			actor = key.Name
+			if xActor != "" {
+				actor = key.Name + " as " + xActor
+			}
+		} else {
+			got, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+			if h.Token == "" || !ok || subtle.ConstantTimeCompare([]byte(got), []byte(h.Token)) != 1 {
+				writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "unauthorized"})
+				return
+			}
+			if xActor != "" {
+				actor = xActor
+			}
+		}
+		next.ServeHTTP(w, r.WithContext(storage.WithActor(r.Context(), actor)))
+	})
+}
+
+func (h *AdminHandler) listCampaigns(w http.ResponseWriter, r *http.Request) {
+	rows, err := h.Store.ListCampaigns(r.Context())
+	if err != nil {
+		h.storeError(w, err)
+		return
+	}
+	out := make([]campaignPayload, 0, len(rows))
+	for _, c := range rows {
+		out = append(out, toPayload(c))
+	}
+	writeJSON(w, http.StatusOK, out)
+}
+
+func (h *AdminHandler) getCampaign(w http.ResponseWriter, r *http.Request) {
+	c, err := h.Store.GetCampaign(r.Context(), chi.URLParam(r, "id"))
+	if err != nil {
+		h.storeError(w, err)
+		return
+	}
+	setETag(w, c.Version)
+	writeJSON(w, http.StatusOK, toPayload(c))
+}
+
+func (h *AdminHandler) createCampaign(w http.ResponseWriter, r *http.Request) {
+	var p campaignPayload
+	if !decodeBody(w, r, &p) {
+		return
+	}
+	c, errs := validateCampaign(p)
+	if len(errs) > 0 {
+		writeValidation(w, errs)
+		return
+	}
+	if err := h.Store.CreateCampaign(r.Context(), c); err != nil {
+		h.storeError(w, err)
+		return
+	}
+	c.Version = 1
+	setETag(w, c.Version)
+	writeJSON(w, http.StatusCreated, toPayload(c))
+}
+
+func (h *AdminHandler) updateCampaign(w http.ResponseWriter, r *http.Request) {
+	expected, ok := ifMatch(w, r)
+	if !ok {
+		return
+	}
+	var p campaignPayload
+	if !decodeBody(w, r, &p) {
+		return
+	}
+	id := chi.URLParam(r, "id")
+	if p.ID == "" {
+		p.ID = id
+	}
+	c, errs := validateCampaign(p)
+	if p.ID != id {
+		errs["id"] = "must match the id in the path"
+	}
+	if len(errs) > 0 {
+		writeValidation(w, errs)
+		return
+	}
+	c.Version = expected
+	version, err := h.Store.UpdateCampaign(r.Context(), c, p.Rules != nil)
+	if err != nil {
+		h.storeError(w, err)
+		return
+	}
+	c.Version = version
+	if p.Rules == nil {
+		// The rules were kept, so the payload has none to echo; read the
+		// campaign back rather than answer with an empty list.
+		if c, err = h.Store.GetCampaign(r.Context(), id); err != nil {
+			h.storeError(w, err)
+			return
+		}
+	}
+	setETag(w, c.Version)
+	writeJSON(w, http.StatusOK, toPayload(c))
+}
+
+func (h *AdminHandler) replaceRules(w http.ResponseWriter, r *http.Request) {
+	expected, ok := ifMatch(w, r)
+	if !ok {
+		return
+	}
+	var p rulesPayload
+	if !decodeBody(w, r, &p) {
+		return
+	}
+	errs := fieldErrors{}
+	rules := validateRules(p.Rules, errs)
+	if len(errs) > 0 {
+		writeValidation(w, errs)
+		return
+	}
+	version, err := h.Store.ReplaceRules(r.Context(), chi.URLParam(r, "id"), rules, expected)
+	if err != nil {
+		h.storeError(w, err)
+		return
+	}
+	setETag(w, version)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *AdminHandler) deleteCampaign(w http.ResponseWriter, r *http.Request) {
+	expected, ok := ifMatch(w, r)
+	if !ok {
+		return
+	}
+	if err := h.Store.DeleteCampaign(r.Context(), chi.URLParam(r, "id"), expected); err != nil {
+		h.storeError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *AdminHandler) storeError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, storage.ErrNotFound):
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": "campaign not found"})
+	case errors.Is(err, storage.ErrConflict):
+		writeJSON(w, http.StatusConflict, map[string]string{"error": "campaign already exists"})
+	case errors.Is(err, storage.ErrVersionConflict):
+		writeJSON(w, http.StatusConflict, map[string]string{"error": "campaign was modified by someone else; re-read it and retry"})
+	case errors.Is(err, storage.ErrUnknownAdvertiser):
+		writeValidation(w, fieldErrors{"advertiser_id": "must name an existing advertiser"})
+	case errors.Is(err, storage.ErrAdvertiserInUse):
+		writeJSON(w, http.StatusConflict, map[string]string{"error": "advertiser still has campaigns"})
+	case errors.Is(err, storage.ErrBreakerOpen):
+		w.Header().Set("Retry-After", "5")
+		writeJSON(w, http.StatusServiceUnavailable, map[string]string{"error": "database unavailable; try again later"})
+	default:
+		log.Error().Err(err).Msg("admin store error")
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "internal error"})
+	}
+}
+
+// ifMatch reads the expected campaign version from If-Match. It accepts the
+// ETag form ("3", W/"3") as well as a bare number.
+func ifMatch(w http.ResponseWriter, r *http.Request) (int64, bool) {
+	raw := strings.TrimSpace(r.Header.Get("If-Match"))
+	if raw == "" {
+		writeJSON(w, http.StatusPreconditionRequired, map[string]string{"error": "If-Match with the campaign version is required"})
+		return 0, false
+	}
+	raw = strings.Trim(strings.TrimPrefix(raw, "W/"), `"`)
+	v, err := strconv.ParseInt(raw, 10, 64)
+	if err != nil || v <= 0 {
+		writeValidation(w, fieldErrors{"If-Match": "must be a campaign version"})
+		return 0, false
+	}
+	return v, true
+}
+
+func setETag(w http.ResponseWriter, version int64) {
+	w.Header().Set("ETag", `"`+strconv.FormatInt(version, 10)+`"`)
+}
+
+func decodeBody(w http.ResponseWriter, r *http.Request, v any) bool {
+	dec := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<20))
+	if err := dec.Decode(v); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid JSON body: " + err.Error()})
+		return false
+	}
+	return true
+}
+
+func writeValidation(w http.ResponseWriter, errs fieldErrors) {
+	writeJSON(w, http.StatusBadRequest, map[string]any{
+		"error":  "validation failed",
+		"fields": errs,
+	})
+}
+
+// validateCampaign checks p and returns the normalized storage row.
+func validateCampaign(p campaignPayload) (storage.CampaignRow, fieldErrors) {
+	errs := fieldErrors{}
+	c := storage.CampaignRow{
+		ID:       strings.TrimSpace(p.ID),
+		Name:     strings.TrimSpace(p.Name),
+		ImageURL: strings.TrimSpace(p.ImageURL),
+		CTA:      strings.TrimSpace(p.CTA),
+		Status:   strings.ToUpper(strings.TrimSpace(p.Status)),
+		BidPrice: p.BidPrice,
+		Markup:   strings.TrimSpace(p.Markup),
+		Landing:  strings.TrimSpace(p.Landing),
+
+		Advertiser: strings.TrimSpace(p.Advertiser),
+		Category:   strings.ToUpper(strings.TrimSpace(p.Category)),
+	}
+	switch {
+	case c.ID == "":
+		errs["id"] = "is required"
+	case len(c.ID) > 50:
+		errs["id"] = "must be at most 50 characters"
+	}
+	switch {
+	case c.Name == "":
+		errs["name"] = "is required"
+	case len(c.Name) > 255:
+		errs["name"] = "must be at most 255 characters"
+	}
+	if len(c.Advertiser) > 50 {
+		errs["advertiser_id"] = "must be at most 50 characters"
+	}
+	if c.Category != "" && !validate.IABCategory.MatchString(c.Category) {
+		errs["category"] = "must be an IAB category such as IAB9 or IAB9-30"
+	}
+	if c.Landing != "" && !isHTTPURL(c.Landing) {
+		errs["landing_url"] = "must be an absolute http(s) URL"
+	}
+	if c.BidPrice < 0 || c.BidPrice >= 1e8 || math.IsNaN(c.BidPrice) {
+		errs["bid_price"] = "must be a CPM between 0 and 99999999"
+	}
+	switch c.Status {
+	case "":
+		c.Status = "INACTIVE"
+	case "ACTIVE", "INACTIVE":
+	default:
+		errs["status"] = "must be ACTIVE or INACTIVE"
+	}
+	c.Creative, c.Video = validateCreative(p, errs)
+	c.Budget = validateBudget(p.Budget, errs)
+	c.Rules = validateRules(p.Rules, errs)
+	return c, errs
+}
+
+// validateCreative checks the creative type and, for video campaigns, the
+// media file VAST needs to render it.
+func validateCreative(p campaignPayload, errs fieldErrors) (string, storage.VideoCreative) {
+	creative := strings.ToLower(strings.TrimSpace(p.Creative))
+	switch creative {
+	case "", storage.CreativeBanner:
+		if p.Video != nil {
+			errs["video"] = "is only allowed for creative_type video"
+		}
+		return storage.CreativeBanner, storage.VideoCreative{}
+	case storage.CreativeVideo:
+	default:
+		errs["creative_type"] = "must be banner or video"
+		return creative, storage.VideoCreative{}
+	}
+	if p.Video == nil {
+		errs["video"] = "is required for creative_type video"
+		return creative, storage.VideoCreative{}
+	}
+
+	v := storage.VideoCreative{
+		URL:      strings.TrimSpace(p.Video.URL),
+		MIME:     strings.ToLower(strings.TrimSpace(p.Video.MIME)),
+		Duration: p.Video.Duration,
+		Width:    p.Video.Width,
+		Height:   p.Video.Height,
+	}
+	if !isHTTPURL(v.URL) {
+		errs["video.url"] = "must be an absolute http(s) URL"
+	}
+	if !strings.HasPrefix(v.MIME, "video/") {
+		errs["video.mime"] = "must be a video/* media type"
+	}
+	if v.Duration < 1 || v.Duration > 3600 {
+		errs["video.duration"] = "must be between 1 and 3600 seconds"
+	}
+	if v.Width < 1 || v.Height < 1 {
+		errs["video.width"] = "width and height must be positive"
+	}
+	return creative, v
+}
+
+// validateBudget checks caps and pacing. Even pacing spreads the daily
+// caps, so it needs one.
+func validateBudget(p *budgetPayload, errs fieldErrors) storage.Budget {
+	if p == nil {
+		return storage.Budget{Pacing: storage.PacingASAP}
+	}
+	b := storage.Budget{
+		DailyImpressions:    p.DailyImpressions,
+		LifetimeImpressions: p.LifetimeImpressions,
+		DailySpend:          p.DailySpend,
+		LifetimeSpend:       p.LifetimeSpend,
+		Pacing:              strings.ToLower(strings.TrimSpace(p.Pacing)),
+	}
+	if b.DailyImpressions < 0 {
+		errs["budget.daily_impressions"] = "must not be negative"
+	}
+	if b.LifetimeImpressions < 0 {
+		errs["budget.lifetime_impressions"] = "must not be negative"
+	}
+	for field, v := range map[string]float64{"budget.daily_spend": b.DailySpend, "budget.lifetime_spend": b.LifetimeSpend} {
+		if v < 0 || v >= 1e10 || math.IsNaN(v) {
+			errs[field] = "must be an amount between 0 and 9999999999"
+		}
+	}
+	switch b.Pacing {
+	case "":
+		b.Pacing = storage.PacingASAP
+	case storage.PacingASAP:
+	case storage.PacingEven:
+		if b.DailyImpressions == 0 && b.DailySpend == 0 {
+			errs["budget.pacing"] = "even pacing needs a daily cap"
+		}
+	default:
+		errs["budget.pacing"] = "must be asap or even"
+	}
+	return b
+}
+
+func isHTTPURL(s string) bool {
+	u, err := url.Parse(s)
+	return err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != ""
+}
+
+// validateRules normalizes values the same way the engine does at snapshot
+// time (country upper-case, everything else lower-case) and rejects unknown
+// or repeated dimensions, since the table allows one rule per dimension.
+// Countries must be ISO 3166-1 codes and are stored as alpha-2, the form
+// requests are matched in.
+func validateRules(rules []rulePayload, errs fieldErrors) []storage.RuleRow {
+	out := make([]storage.RuleRow, 0, len(rules))
+	seen := map[string]bool{}
+	for i, rp := range rules {
+		field := fmt.Sprintf("rules[%d]", i)
+		dim := strings.ToLower(strings.TrimSpace(rp.Dimension))
+		switch {
+		case !storage.IsDimension(dim):
+			errs[field+".dimension"] = "must be one of country, os, appid"
+			continue
+		case seen[dim]:
+			errs[field+".dimension"] = "duplicate dimension " + dim
+			continue
+		}
+		seen[dim] = true
+
+		var vals []string
+		dup := map[string]bool{}
+		for j, v := range rp.Values {
+			v = strings.TrimSpace(v)
+			if dim == "country" && v != "" {
+				cc, ok := geo.CountryAlpha2(v)
+				if !ok {
+					errs[fmt.Sprintf("%s.values[%d]", field, j)] = "must be an ISO 3166-1 alpha-2 or alpha-3 country code"
+					continue
+				}
+				v = cc
+			} else {
+				v = strings.ToLower(v)
+			}
+			if v == "" || dup[v] {
+				continue
+			}
+			dup[v] = true
+			vals = append(vals, v)
+		}
+		if len(vals) == 0 {
+			errs[field+".values"] = "must contain at least one non-empty value"
+			continue
+		}
+
+		include := true
+		if rp.Include != nil {
+			include = *rp.Include
+		}
+		out = append(out, storage.RuleRow{Dimension: dim, IsInclusion: include, Values: vals})
+	}
+	return out
+}
+
+func toPayload(c storage.CampaignRow) campaignPayload {
+	p := campaignPayload{
+		ID:       c.ID,
+		Name:     c.Name,
+		ImageURL: c.ImageURL,
+		CTA:      c.CTA,
+		Status:   c.Status,
+		Version:  c.Version,
+		BidPrice: c.BidPrice,
+		Markup:   c.Markup,
+		Landing:  c.Landing,
+		Creative: c.Creative,
+		Rules:    make([]rulePayload, 0, len(c.Rules)),
+
+		Advertiser: c.Advertiser,
+		Category:   c.Category,
+	}
+	if c.Creative == storage.CreativeVideo {
+		v := c.Video
+		p.Video = &videoPayload{URL: v.URL, MIME: v.MIME, Duration: v.Duration, Width: v.Width, Height: v.Height}
+	}
+	if b := c.Budget; b.Capped() || (b.Pacing != "" && b.Pacing != storage.PacingASAP) {
+		p.Budget = &budgetPayload{DailyImpressions: b.DailyImpressions, LifetimeImpressions: b.LifetimeImpressions,
+			DailySpend: b.DailySpend, LifetimeSpend: b.LifetimeSpend, Pacing: b.Pacing}
+	}
+	for _, r := range c.Rules {
+		include := r.IsInclusion
+		p.Rules = append(p.Rules, rulePayload{Dimension: r.Dimension, Include: &include, Values: r.Values})
+	}
+	return p
+}
@@ -0,0 +1,409 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"ad-targeting-engine/internal/auth"
+	"ad-targeting-engine/internal/storage"
+)
+
+func TestAdmin_CampaignsImport(t *testing.T) {
+	const csvOK = `id,name,image_url,cta,status,dimension,include,values
+spotify,Spotify,https://img,Download,ACTIVE,country,true,US|CA
+spotify,Spotify,https://img,Download,ACTIVE,os,false,ios
+duolingo,Duolingo,https://img2,Install,ACTIVE,,,
+`
+	const csvBudget = `id,name,image_url,cta,status,dimension,include,values,bid_price,markup,creative_type,video_url,video_mime,video_duration,video_width,video_height,landing_url,daily_impression_cap,lifetime_impression_cap,daily_budget,lifetime_budget,pacing
+spotify,Spotify,https://img,Download,ACTIVE,,,,2.5,,banner,,,,,,,1000,,25.5,,even
+duolingo,Duolingo,https://img2,Install,ACTIVE,,,,1,,banner,,,,,,,,,,,asap
+`
+	const csvBad = `id,name,image_url,cta,status,dimension,include,values
+spotify,Spotify,https://img,Download,ACTIVE,country,true,US
+spotify,Spotify,https://img,Download,ACTIVE,city,true,berlin
+,NoID,,,PAUSED,,,
+`
+	tests := []struct {
+		name        string
+		url         string
+		contentType string
+		body        string
+		wantStatus  int
+		wantErrRows []int
+		wantWritten int
+	}{
+		{"csv dry run", "/admin/v1/campaigns/import?dry_run=true", "text/csv", csvOK, http.StatusOK, nil, 0},
+		{"csv apply", "/admin/v1/campaigns/import", "text/csv", csvOK, http.StatusOK, nil, 2},
+		{"csv budgets", "/admin/v1/campaigns/import", "text/csv", csvBudget, http.StatusOK, nil, 2},
+		{"csv per-row errors", "/admin/v1/campaigns/import", "text/csv", csvBad, http.StatusBadRequest, []int{3, 4, 4}, 0},
+		{
+			name:        "json duplicate id",
+			url:         "/admin/v1/campaigns/import?format=json",
+			body:        `[{"id":"a","name":"A"},{"id":"a","name":"A again"}]`,
+			wantStatus:  http.StatusBadRequest,
+			wantErrRows: []int{2},
+		},
+		{"bad header", "/admin/v1/campaigns/import?format=csv", "", "cid,name\n", http.StatusBadRequest, nil, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			st := storage.NewMemoryStore()
+			router := Router(Handlers{Delivery: NewDeliveryHandler(nil), Admin: NewAdminHandler(st, "secret")})
+
+			req := httptest.NewRequest("POST", tt.url, strings.NewReader(tt.body))
+			req.Header.Set("Authorization", "Bearer secret")
+			if tt.contentType != "" {
+				req.Header.Set("Content-Type", tt.contentType)
+			}
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.wantStatus, w.Code, w.Body.String())
+			all, _ := st.ListCampaigns(context.Background())
+			assert.Len(t, all, tt.wantWritten)
+
+			var report importReport
+			_ = json.Unmarshal(w.Body.Bytes(), &report)
+			var rows []int
+			for _, e := range report.Errors {
+				rows = append(rows, e.Row)
+			}
+			assert.Equal(t, tt.wantErrRows, rows)
+		})
+	}
+}
+
+func TestAdmin_Campaigns(t *testing.T) {
+	tests := []struct {
+		name       string
+		method     string
+		url        string
+		token      string
+		body       string
+		wantStatus int
+		ifMatch    string
+	}{
+		{"missing token", "GET", "/admin/v1/campaigns", "", "", http.StatusUnauthorized, ""},
+		{"wrong token", "GET", "/admin/v1/campaigns", "nope", "", http.StatusUnauthorized, ""},
+		{"list", "GET", "/admin/v1/campaigns", "secret", "", http.StatusOK, ""},
+		{
+			name:       "create",
+			method:     "POST",
+			url:        "/admin/v1/campaigns",
+			token:      "secret",
+			body:       `{"id":"spotify","name":"Spotify","status":"active","rules":[{"dimension":"Country","values":[" us ","US"]}]}`,
+			wantStatus: http.StatusCreated,
+		},
+		{
+			name:       "create duplicate",
+			method:     "POST",
+			url:        "/admin/v1/campaigns",
+			token:      "secret",
+			body:       `{"id":"duolingo","name":"Duolingo"}`,
+			wantStatus: http.StatusConflict,
+		},
+		{
+			name:       "bad country",
+			method:     "POST",
+			url:        "/admin/v1/campaigns",
+			token:      "secret",
+			body:       `{"id":"x","name":"X","rules":[{"dimension":"country","values":["US","Germany"]}]}`,
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "bad category",
+			method:     "POST",
+			url:        "/admin/v1/campaigns",
+			token:      "secret",
+			body:       `{"id":"x","name":"X","advertiser_id":"acme","category":"music"}`,
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "unknown dimension",
+			method:     "POST",
+			url:        "/admin/v1/campaigns",
+			token:      "secret",
+			body:       `{"id":"x","name":"X","rules":[{"dimension":"city","values":["berlin"]}]}`,
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "bad status",
+			method:     "POST",
+			url:        "/admin/v1/campaigns",
+			token:      "secret",
+			body:       `{"id":"x","name":"X","status":"PAUSED"}`,
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "create video",
+			method:     "POST",
+			url:        "/admin/v1/campaigns",
+			token:      "secret",
+			body:       `{"id":"v","name":"V","creative_type":"video","video":{"url":"https://cdn/v.mp4","mime":"video/mp4","duration":30,"width":640,"height":360}}`,
+			wantStatus: http.StatusCreated,
+		},
+		{
+			name:       "video without media",
+			method:     "POST",
+			url:        "/admin/v1/campaigns",
+			token:      "secret",
+			body:       `{"id":"v","name":"V","creative_type":"video"}`,
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "bad video fields",
+			method:     "POST",
+			url:        "/admin/v1/campaigns",
+			token:      "secret",
+			body:       `{"id":"v","name":"V","creative_type":"video","video":{"url":"/v.mp4","mime":"image/png","duration":0}}`,
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "video on a banner",
+			method:     "POST",
+			url:        "/admin/v1/campaigns",
+			token:      "secret",
+			body:       `{"id":"v","name":"V","video":{"url":"https://cdn/v.mp4","mime":"video/mp4","duration":30}}`,
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "create with budget",
+			method:     "POST",
+			url:        "/admin/v1/campaigns",
+			token:      "secret",
+			body:       `{"id":"b","name":"B","budget":{"daily_spend":50,"lifetime_impressions":100000,"pacing":"even"}}`,
+			wantStatus: http.StatusCreated,
+		},
+		{
+			name:       "even pacing without a daily cap",
+			method:     "POST",
+			url:        "/admin/v1/campaigns",
+			token:      "secret",
+			body:       `{"id":"b","name":"B","budget":{"lifetime_spend":500,"pacing":"even"}}`,
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "negative cap",
+			method:     "POST",
+			url:        "/admin/v1/campaigns",
+			token:      "secret",
+			body:       `{"id":"b","name":"B","budget":{"daily_impressions":-1}}`,
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "update id mismatch",
+			method:     "PUT",
+			url:        "/admin/v1/campaigns/duolingo",
+			token:      "secret",
+			body:       `{"id":"other","name":"Duolingo"}`,
+			wantStatus: http.StatusBadRequest,
+			ifMatch:    `"1"`,
+		},
+		{
+			name:       "update without If-Match",
+			method:     "PUT",
+			url:        "/admin/v1/campaigns/duolingo",
+			token:      "secret",
+			body:       `{"name":"Duolingo 2"}`,
+			wantStatus: http.StatusPreconditionRequired,
+		},
+		{
+			name:       "update stale version",
+			method:     "PUT",
+			url:        "/admin/v1/campaigns/duolingo",
+			token:      "secret",
+			body:       `{"name":"Duolingo 2"}`,
+			wantStatus: http.StatusConflict,
+			ifMatch:    `"7"`,
+		},
+		{
+			name:       "update current version",
+			method:     "PUT",
+			url:        "/admin/v1/campaigns/duolingo",
+			token:      "secret",
+			body:       `{"name":"Duolingo 2","status":"ACTIVE"}`,
+			wantStatus: http.StatusOK,
+			ifMatch:    `"1"`,
+		},
+		{
+			name:       "replace rules",
+			method:     "PUT",
+			url:        "/admin/v1/campaigns/duolingo/rules",
+			token:      "secret",
+			body:       `{"rules":[{"dimension":"os","include":false,"values":["iOS"]}]}`,
+			wantStatus: http.StatusNoContent,
+			ifMatch:    "1",
+		},
+		{"get missing", "GET", "/admin/v1/campaigns/nope", "secret", "", http.StatusNotFound, ""},
+		{"delete", "DELETE", "/admin/v1/campaigns/duolingo", "secret", "", http.StatusNoContent, `W/"1"`},
+		{"delete stale", "DELETE", "/admin/v1/campaigns/duolingo", "secret", "", http.StatusConflict, `"7"`},
+		{"history bad at", "GET", "/admin/v1/history/delivery?at=yesterday", "secret", "", http.StatusBadRequest, ""},
+		{"history delivery", "GET", "/admin/v1/history/delivery?at=2026-10-17T14:00:00Z&app=x&os=android&country=us", "secret", "", http.StatusOK, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			st := storage.NewMemoryStore(storage.CampaignRow{ID: "duolingo", Name: "Duolingo", Status: "ACTIVE"})
+			router := Router(Handlers{Delivery: NewDeliveryHandler(nil), Admin: NewAdminHandler(st, "secret")})
+
+			req := httptest.NewRequest(tt.method, tt.url, strings.NewReader(tt.body))
+			if tt.token != "" {
+				req.Header.Set("Authorization", "Bearer "+tt.token)
+			}
+			if tt.ifMatch != "" {
+				req.Header.Set("If-Match", tt.ifMatch)
+			}
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.wantStatus, w.Code, w.Body.String())
+		})
+	}
+}
+
+func TestAdmin_CreateNormalizesRules(t *testing.T) {
+	st := storage.NewMemoryStore()
+	router := Router(Handlers{Delivery: NewDeliveryHandler(nil), Admin: NewAdminHandler(st, "secret")})
+
+	body := `{"id":"spotify","name":"Spotify","status":"active","rules":[
+		{"dimension":"Country","values":[" us ","USA","ca"]},
+		{"dimension":"AppID","include":false,"values":["Com.Foo"]}]}`
+	req := httptest.NewRequest("POST", "/admin/v1/campaigns", strings.NewReader(body))
+	req.Header.Set("Authorization", "Bearer secret")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+	got, err := st.GetCampaign(context.Background(), "spotify")
+	assert.NoError(t, err)
+	assert.Equal(t, "ACTIVE", got.Status)
+	assert.Equal(t, int64(1), got.Version)
+	// values are stored normalized and read back per dimension, as from
+	// Postgres: countries upper-case, app IDs lower-case
+	assert.Equal(t, []storage.RuleRow{
+		{Dimension: "country", IsInclusion: true, Values: []string{"US", "CA"}},
+		{Dimension: "appid", IsInclusion: false, Values: []string{"com.foo"}},
+	}, got.Rules)
+}
+
+func TestAdmin_UpdateKeepsRules(t *testing.T) {
+	st := storage.NewMemoryStore()
+	router := Router(Handlers{Delivery: NewDeliveryHandler(nil), Admin: NewAdminHandler(st, "secret")})
+	send := func(method, url, ifMatch, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, url, strings.NewReader(body))
+		req.Header.Set("Authorization", "Bearer secret")
+		if ifMatch != "" {
+			req.Header.Set("If-Match", ifMatch)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	w := send("POST", "/admin/v1/campaigns", "",
+		`{"id":"spotify","name":"Spotify","rules":[{"dimension":"country","values":["US"]}]}`)
+	assert.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+
+	// no rules in the body keeps them, and the response says so
+	w = send("PUT", "/admin/v1/campaigns/spotify", w.Header().Get("ETag"), `{"name":"Spotify Premium"}`)
+	assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var got campaignPayload
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &got))
+	assert.Equal(t, "Spotify Premium", got.Name)
+	assert.Equal(t, int64(2), got.Version)
+	assert.Equal(t, `"2"`, w.Header().Get("ETag"))
+	if assert.Len(t, got.Rules, 1) {
+		assert.Equal(t, []string{"US"}, got.Rules[0].Values)
+	}
+
+	w = send("PUT", "/admin/v1/campaigns/spotify", w.Header().Get("ETag"), `{"name":"Spotify","rules":[]}`)
+	assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	got = campaignPayload{}
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &got))
+	assert.Empty(t, got.Rules)
+}
+
+func TestRouter_APIKeys(t *testing.T) {
+	keys := auth.NewKeyring("X-API-Key")
+	keys.Load([]storage.APIKeyRow{
+		{ID: "ops", Name: "ops", Hash: auth.HashKey("ops-key"), Scopes: []string{"admin", "metrics"}},
+		{ID: "app", Name: "app", Hash: auth.HashKey("app-key"), Scopes: []string{"delivery"}},
+	})
+	router := Router(Handlers{Delivery: NewDeliveryHandler(nil), Admin: NewAdminHandler(storage.NewMemoryStore(), "secret"), Keys: keys})
+
+	tests := []struct {
+		name, path, key, bearer string
+		wantStatus              int
+	}{
+		{"admin key", "/admin/v1/campaigns", "ops-key", "", http.StatusOK},
+		{"delivery key on admin", "/admin/v1/campaigns", "app-key", "", http.StatusForbidden},
+		{"admin token alone", "/admin/v1/campaigns", "", "secret", http.StatusUnauthorized},
+		{"metrics key", "/metrics", "ops-key", "", http.StatusOK},
+		{"no key on metrics", "/metrics", "", "", http.StatusUnauthorized},
+		{"delivery without key", "/v1/delivery?app=com.x&os=ios&country=US", "", "", http.StatusUnauthorized},
+		{"health stays open", "/healthz", "", "", http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", tt.path, nil)
+			if tt.key != "" {
+				req.Header.Set("X-API-Key", tt.key)
+			}
+			if tt.bearer != "" {
+				req.Header.Set("Authorization", "Bearer "+tt.bearer)
+			}
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+			assert.Equal(t, tt.wantStatus, w.Code, w.Body.String())
+		})
+	}
+}
+
+func TestAdmin_AuditActor(t *testing.T) {
+	keys := auth.NewKeyring("X-API-Key")
+	keys.Load([]storage.APIKeyRow{{ID: "ops", Name: "ops", Hash: auth.HashKey("ops-key"), Scopes: []string{"admin"}}})
+
+	tests := []struct {
+		name, key, bearer, xActor, want string
+	}{
+		{"key", "ops-key", "", "", "ops"},
+		{"key keeps its name", "ops-key", "", "mallory", "ops as mallory"},
+		{"token", "", "secret", "", "admin"},
+		{"token names itself", "", "secret", "alice", "alice"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			st := storage.NewMemoryStore()
+			h := Handlers{Delivery: NewDeliveryHandler(nil), Admin: NewAdminHandler(st, "secret")}
+			if tt.key != "" {
+				h.Keys = keys
+			}
+			req := httptest.NewRequest("POST", "/admin/v1/campaigns", strings.NewReader(`{"id":"c","name":"C"}`))
+			if tt.key != "" {
+				req.Header.Set("X-API-Key", tt.key)
+			}
+			if tt.bearer != "" {
+				req.Header.Set("Authorization", "Bearer "+tt.bearer)
+			}
+			if tt.xActor != "" {
+				req.Header.Set("X-Actor", tt.xActor)
+			}
+			w := httptest.NewRecorder()
+			Router(h).ServeHTTP(w, req)
+			assert.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+
+			entries, err := st.ListAudit(context.Background(), "c", 10)
+			assert.NoError(t, err)
+			if assert.NotEmpty(t, entries) {
+				assert.Equal(t, tt.want, entries[0].Actor)
+			}
+		})
+	}
+}
@@ -0,0 +1,147 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+
+	"ad-targeting-engine/internal/storage"
+	"ad-targeting-engine/internal/validate"
+)
+
+// advertiserPayload is an advertiser. Its status and blocked apps apply to
+// all of its campaigns.
+type advertiserPayload struct {
+	ID          string     `json:"id"`
+	Name        string     `json:"name"`
+	Status      string     `json:"status"`
+	BlockedApps []string   `json:"blocked_apps"`
+	Version     int64      `json:"version,omitempty"`
+	UpdatedAt   *time.Time `json:"updated_at,omitempty"`
+}
+
+func (h *AdminHandler) listAdvertisers(w http.ResponseWriter, r *http.Request) {
+	rows, err := h.Advertisers.LoadAdvertisers(r.Context())
+	if err != nil {
+		h.storeError(w, err)
+		return
+	}
+	out := make([]advertiserPayload, 0, len(rows))
+	for _, a := range rows {
+		out = append(out, toAdvertiserPayload(a))
+	}
+	writeJSON(w, http.StatusOK, out)
+}
+
+func (h *AdminHandler) getAdvertiser(w http.ResponseWriter, r *http.Request) {
+	a, err := h.Advertisers.GetAdvertiser(r.Context(), chi.URLParam(r, "id"))
+	if err != nil {
+		h.advertiserError(w, err)
+		return
+	}
+	setETag(w, a.Version)
+	writeJSON(w, http.StatusOK, toAdvertiserPayload(a))
+}
+
+// putAdvertiser creates or replaces an advertiser; like publisher settings,
+// the last write wins.
+func (h *AdminHandler) putAdvertiser(w http.ResponseWriter, r *http.Request) {
+	var p advertiserPayload
+	if !decodeBody(w, r, &p) {
+		return
+	}
+	id := chi.URLParam(r, "id")
+	if p.ID == "" {
+		p.ID = id
+	}
+	row, errs := validateAdvertiser(p)
+	if row.ID != id {
+		errs["id"] = "must match the id in the path"
+	}
+	if len(errs) > 0 {
+		writeValidation(w, errs)
+		return
+	}
+	version, err := h.Advertisers.PutAdvertiser(r.Context(), row)
+	if err != nil {
+		h.advertiserError(w, err)
+		return
+	}
+	row.Version = version
+	setETag(w, version)
+	writeJSON(w, http.StatusOK, toAdvertiserPayload(row))
+}
+
+func (h *AdminHandler) deleteAdvertiser(w http.ResponseWriter, r *http.Request) {
+	if err := h.Advertisers.DeleteAdvertiser(r.Context(), chi.URLParam(r, "id")); err != nil {
+		h.advertiserError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *AdminHandler) advertiserError(w http.ResponseWriter, err error) {
+	if errors.Is(err, storage.ErrNotFound) {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": "advertiser not found"})
+		return
+	}
+	h.storeError(w, err)
+}
+
+// validateAdvertiser checks p and returns the normalized storage row.
+func validateAdvertiser(p advertiserPayload) (storage.AdvertiserRow, fieldErrors) {
+	errs := fieldErrors{}
+	a := storage.AdvertiserRow{
+		ID:     strings.TrimSpace(p.ID),
+		Name:   strings.TrimSpace(p.Name),
+		Status: strings.ToUpper(strings.TrimSpace(p.Status)),
+	}
+	switch {
+	case a.ID == "":
+		errs["id"] = "is required"
+	case len(a.ID) > 50:
+		errs["id"] = "must be at most 50 characters"
+	}
+	switch {
+	case a.Name == "":
+		errs["name"] = "is required"
+	case len(a.Name) > 255:
+		errs["name"] = "must be at most 255 characters"
+	}
+	switch a.Status {
+	case "":
+		a.Status = "ACTIVE"
+	case "ACTIVE", "INACTIVE":
+	default:
+		errs["status"] = "must be ACTIVE or INACTIVE"
+	}
+	var verrs validate.Errors
+	a.BlockedApps = blockList(p.BlockedApps, func(s string) string {
+		if s = strings.TrimSpace(s); s == "" {
+			return ""
+		}
+		return validate.App("blocked_apps", s, &verrs)
+	})
+	if len(verrs) > 0 {
+		errs["blocked_apps"] = verrs[0].Detail
+	}
+	return a, errs
+}
+
+func toAdvertiserPayload(a storage.AdvertiserRow) advertiserPayload {
+	out := advertiserPayload{
+		ID:          a.ID,
+		Name:        a.Name,
+		Status:      a.Status,
+		BlockedApps: blockList(a.BlockedApps, strings.TrimSpace),
+		Version:     a.Version,
+	}
+	if !a.UpdatedAt.IsZero() {
+		t := a.UpdatedAt.UTC()
+		out.UpdatedAt = &t
+	}
+	return out
+}
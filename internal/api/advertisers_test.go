@@ -0,0 +1,58 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"ad-targeting-engine/internal/storage"
+)
+
+func TestAdmin_Advertisers(t *testing.T) {
+	st := storage.NewMemoryStore()
+	admin := NewAdminHandler(st, "secret")
+	admin.Advertisers = st
+	router := Router(Handlers{Delivery: NewDeliveryHandler(nil), Admin: admin})
+	do := func(method, url, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, url, strings.NewReader(body))
+		req.Header.Set("Authorization", "Bearer secret")
+		req.Header.Set("If-Match", "1")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	tests := []struct {
+		name, method, url, body string
+		wantStatus              int
+	}{
+		{"campaign of unknown advertiser", "POST", "/admin/v1/campaigns", `{"id":"a","name":"A","advertiser_id":"acme"}`, http.StatusBadRequest},
+		{"create", "PUT", "/admin/v1/advertisers/acme", `{"name":"Acme","blocked_apps":["Com.X"," "]}`, http.StatusOK},
+		{"campaign", "POST", "/admin/v1/campaigns", `{"id":"a","name":"A","advertiser_id":"acme"}`, http.StatusCreated},
+		{"update", "PUT", "/admin/v1/advertisers/acme", `{"name":"Acme","status":"inactive"}`, http.StatusOK},
+		{"get", "GET", "/admin/v1/advertisers/acme", "", http.StatusOK},
+		{"list", "GET", "/admin/v1/advertisers", "", http.StatusOK},
+		{"get missing", "GET", "/admin/v1/advertisers/globex", "", http.StatusNotFound},
+		{"bad status", "PUT", "/admin/v1/advertisers/acme", `{"name":"Acme","status":"PAUSED"}`, http.StatusBadRequest},
+		{"bad blocked app", "PUT", "/admin/v1/advertisers/acme", `{"name":"Acme","blocked_apps":["my app"]}`, http.StatusBadRequest},
+		{"id mismatch", "PUT", "/admin/v1/advertisers/acme", `{"id":"globex","name":"Acme"}`, http.StatusBadRequest},
+		{"delete in use", "DELETE", "/admin/v1/advertisers/acme", "", http.StatusConflict},
+		{"delete campaign", "DELETE", "/admin/v1/campaigns/a", "", http.StatusNoContent},
+		{"delete", "DELETE", "/admin/v1/advertisers/acme", "", http.StatusNoContent},
+	}
+	for _, tt := range tests {
+		w := do(tt.method, tt.url, tt.body)
+		require.Equal(t, tt.wantStatus, w.Code, "%s: %s", tt.name, w.Body.String())
+		if tt.name == "create" {
+			a, err := st.GetAdvertiser(context.Background(), "acme")
+			require.NoError(t, err)
+			assert.Equal(t, "ACTIVE", a.Status)
+			assert.Equal(t, []string{"com.x"}, a.BlockedApps)
+		}
+	}
+}
@@ -0,0 +1,298 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"ad-targeting-engine/internal/storage"
+)
+
+const maxImportBytes = 32 << 20
+
+var ruleFieldRe = regexp.MustCompile(`^rules\[(\d+)\]`)
+
+// importItem is one campaign from an import file, with enough position
+// information to point errors at the offending row.
+type importItem struct {
+	row      int   // CSV line of the campaign's first row, or 1-based JSON index
+	ruleRows []int // CSV line of each rule; nil for JSON
+	payload  campaignPayload
+}
+
+type rowError struct {
+	Row     int    `json:"row"`
+	Field   string `json:"field,omitempty"`
+	Message string `json:"message"`
+}
+
+type importReport struct {
+	DryRun    bool                  `json:"dry_run"`
+	Campaigns int                   `json:"campaigns"`
+	Rules     int                   `json:"rules"`
+	Errors    []rowError            `json:"errors,omitempty"`
+	Result    *storage.ImportResult `json:"result,omitempty"`
+}
+
+// importCampaigns validates the whole file first and only writes when every
+// row is valid and dry_run is not set; the write is one transaction.
+func (h *AdminHandler) importCampaigns(w http.ResponseWriter, r *http.Request) {
+	dryRun, _ := strconv.ParseBool(r.URL.Query().Get("dry_run"))
+	body := http.MaxBytesReader(w, r.Body, maxImportBytes)
+
+	var (
+		items []importItem
+		err   error
+	)
+	switch bulkFormat(r) {
+	case "csv":
+		items, err = decodeImportCSV(body)
+	case "json":
+		items, err = decodeImportJSON(body)
+	default:
+		writeValidation(w, fieldErrors{"format": "must be csv or json"})
+		return
+	}
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+
+	report := importReport{DryRun: dryRun}
+	campaigns, errs := validateImport(items)
+	report.Errors = errs
+	report.Campaigns = len(campaigns)
+	for _, c := range campaigns {
+		report.Rules += len(c.Rules)
+	}
+	if len(report.Errors) > 0 {
+		writeJSON(w, http.StatusBadRequest, report)
+		return
+	}
+	if dryRun {
+		writeJSON(w, http.StatusOK, report)
+		return
+	}
+
+	res, err := h.Store.ImportCampaigns(r.Context(), campaigns)
+	if err != nil {
+		h.storeError(w, err)
+		return
+	}
+	report.Result = &res
+	writeJSON(w, http.StatusOK, report)
+}
+
+func (h *AdminHandler) exportCampaigns(w http.ResponseWriter, r *http.Request) {
+	format := bulkFormat(r)
+	if format != "csv" && format != "json" {
+		writeValidation(w, fieldErrors{"format": "must be csv or json"})
+		return
+	}
+	rows, err := h.Store.ListCampaigns(r.Context())
+	if err != nil {
+		h.storeError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="campaigns.%s"`, format))
+	if format == "csv" {
+		w.Header().Set("Content-Type", "text/csv")
+		w.WriteHeader(http.StatusOK)
+		_ = storage.WriteCampaignsCSV(w, rows)
+		return
+	}
+	out := make([]campaignPayload, 0, len(rows))
+	for _, c := range rows {
+		out = append(out, toPayload(c))
+	}
+	writeJSON(w, http.StatusOK, out)
+}
+
+// bulkFormat picks the format from ?format=, falling back to Content-Type
+// and then JSON.
+func bulkFormat(r *http.Request) string {
+	if f := r.URL.Query().Get("format"); f != "" {
+		return strings.ToLower(f)
+	}
+	if strings.HasPrefix(r.Header.Get("Content-Type"), "text/csv") {
+		return "csv"
+	}
+	return "json"
+}
+
+func decodeImportJSON(r io.Reader) ([]importItem, error) {
+	var ps []campaignPayload
+	if err := json.NewDecoder(r).Decode(&ps); err != nil {
+		return nil, fmt.Errorf("invalid JSON body: %w", err)
+	}
+	items := make([]importItem, len(ps))
+	for i, p := range ps {
+		items[i] = importItem{row: i + 1, payload: p}
+	}
+	return items, nil
+}
+
+// decodeImportCSV groups CSV rows by campaign id. Campaign fields are taken
+// from the first row of each id.
+func decodeImportCSV(r io.Reader) ([]importItem, error) {
+	rows, err := storage.ReadCampaignsCSV(r)
+	if err != nil {
+		return nil, err
+	}
+	var items []importItem
+	pos := map[string]int{}
+	for _, row := range rows {
+		id := strings.TrimSpace(row.ID)
+		i, ok := pos[id]
+		if !ok {
+			items = append(items, importItem{row: row.Line, payload: campaignPayload{
+				ID:       row.ID,
+				Name:     row.Name,
+				ImageURL: row.ImageURL,
+				CTA:      row.CTA,
+				Status:   row.Status,
+				Markup:   row.Markup,
+				Landing:  row.Landing,
+				Creative: row.Creative,
+
+				Advertiser: row.Advertiser,
+				Category:   row.Category,
+			}})
+			i = len(items) - 1
+			pos[id] = i
+			if p := strings.TrimSpace(row.BidPrice); p != "" {
+				price, err := strconv.ParseFloat(p, 64)
+				if err != nil {
+					return nil, fmt.Errorf("line %d: bid_price must be a number", row.Line)
+				}
+				items[i].payload.BidPrice = price
+			}
+			if row.Video != [5]string{} {
+				v, err := csvVideo(row)
+				if err != nil {
+					return nil, err
+				}
+				items[i].payload.Video = v
+			}
+			if row.Budget != [5]string{} {
+				b, err := csvBudget(row)
+				if err != nil {
+					return nil, err
+				}
+				items[i].payload.Budget = b
+			}
+		}
+		if strings.TrimSpace(row.Dimension) == "" {
+			continue
+		}
+		rp := rulePayload{Dimension: row.Dimension, Values: row.Values}
+		if inc := strings.TrimSpace(row.Include); inc != "" {
+			b, err := strconv.ParseBool(inc)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: include must be true or false", row.Line)
+			}
+			rp.Include = &b
+		}
+		items[i].payload.Rules = append(items[i].payload.Rules, rp)
+		items[i].ruleRows = append(items[i].ruleRows, row.Line)
+	}
+	return items, nil
+}
+
+// validateImport runs the regular campaign validation on every item and
+// attributes each failure to its source row.
+func validateImport(items []importItem) ([]storage.CampaignRow, []rowError) {
+	var (
+		out  []storage.CampaignRow
+		errs []rowError
+	)
+	if len(items) == 0 {
+		return nil, []rowError{{Row: 0, Message: "no campaigns in import"}}
+	}
+	seen := map[string]int{}
+	for _, it := range items {
+		c, fe := validateCampaign(it.payload)
+		if first, dup := seen[c.ID]; dup && c.ID != "" {
+			fe["id"] = fmt.Sprintf("duplicate campaign id, first seen in row %d", first)
+		} else {
+			seen[c.ID] = it.row
+		}
+
+		fields := make([]string, 0, len(fe))
+		for f := range fe {
+			fields = append(fields, f)
+		}
+		sort.Strings(fields)
+		for _, f := range fields {
+			errs = append(errs, rowError{Row: it.rowFor(f), Field: f, Message: fe[f]})
+		}
+		out = append(out, c)
+	}
+	return out, errs
+}
+
+// rowFor maps a validation field like "rules[2].values" to the CSV line that
+// holds that rule.
+func (it importItem) rowFor(field string) int {
+	m := ruleFieldRe.FindStringSubmatch(field)
+	if m == nil || it.ruleRows == nil {
+		return it.row
+	}
+	i, _ := strconv.Atoi(m[1])
+	if i < len(it.ruleRows) {
+		return it.ruleRows[i]
+	}
+	return it.row
+}
+
+// csvVideo reads the video columns of a CSV row; empty numbers are zero.
+func csvVideo(row storage.CSVRow) (*videoPayload, error) {
+	v := &videoPayload{URL: row.Video[0], MIME: row.Video[1]}
+	for i, dst := range []*int{&v.Duration, &v.Width, &v.Height} {
+		s := strings.TrimSpace(row.Video[2+i])
+		if s == "" {
+			continue
+		}
+		n, err := strconv.Atoi(s)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %s must be an integer", row.Line, storage.CSVHeader[13+i])
+		}
+		*dst = n
+	}
+	return v, nil
+}
+
+// csvBudget reads the budget columns. A row that only says asap pacing has
+// no budget.
+func csvBudget(row storage.CSVRow) (*budgetPayload, error) {
+	b := &budgetPayload{Pacing: row.Budget[4]}
+	for i, dst := range []*int64{&b.DailyImpressions, &b.LifetimeImpressions} {
+		if s := strings.TrimSpace(row.Budget[i]); s != "" {
+			n, err := strconv.ParseInt(s, 10, 64)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %s must be an integer", row.Line, storage.CSVHeader[17+i])
+			}
+			*dst = n
+		}
+	}
+	for i, dst := range []*float64{&b.DailySpend, &b.LifetimeSpend} {
+		if s := strings.TrimSpace(row.Budget[2+i]); s != "" {
+			f, err := strconv.ParseFloat(s, 64)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %s must be a number", row.Line, storage.CSVHeader[19+i])
+			}
+			*dst = f
+		}
+	}
+	if p := strings.ToLower(strings.TrimSpace(b.Pacing)); *b == (budgetPayload{Pacing: b.Pacing}) &&
+		(p == "" || p == storage.PacingASAP) {
+		return nil, nil
+	}
+	return b, nil
+}
@@ -0,0 +1,97 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/go-chi/chi/v5/middleware"
+
+	"ad-targeting-engine/internal/engine"
+	"ad-targeting-engine/internal/validate"
+)
+
+// deliveryRequest is the body of POST /v1/delivery. Unknown fields are
+// ignored so that clients can send richer payloads than we use.
+type deliveryRequest struct {
+	Device struct {
+		OS        string `json:"os"`
+		OSVersion string `json:"os_version"`
+		Make      string `json:"make"`
+		Model     string `json:"model"`
+	} `json:"device"`
+	Geo struct {
+		Country string   `json:"country"`
+		Region  string   `json:"region"`
+		City    string   `json:"city"`
+		Lat     *float64 `json:"lat"`
+		Lon     *float64 `json:"lon"`
+	} `json:"geo"`
+	App struct {
+		Bundle   string `json:"bundle"`
+		Category string `json:"category"`
+	} `json:"app"`
+	User struct {
+		ID      string `json:"id"`
+		Consent string `json:"consent"`
+	} `json:"user"`
+	Placement struct {
+		ID    string `json:"id"`
+		Slots int    `json:"slots"`
+	} `json:"placement"`
+	Debug bool `json:"debug"`
+}
+
+// DeliveryPOST is the JSON form of Delivery. app.bundle, device.os and
+// geo.country carry the same meaning as the app, os and country query
+// parameters, and the response is identical.
+func (h *DeliveryHandler) DeliveryPOST(w http.ResponseWriter, r *http.Request) {
+	format, ok := responseFormat(w, r)
+	if !ok {
+		return
+	}
+	var body deliveryRequest
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<20)).Decode(&body); err != nil {
+		validate.WriteProblem(w, r, validate.Errors{{Field: "body", Code: validate.CodeMalformedBody, Detail: err.Error()}})
+		return
+	}
+	req, errs := body.toMatchRequest()
+	if len(errs) > 0 {
+		validate.WriteProblem(w, r, errs)
+		return
+	}
+	h.respond(w, r, format, req, h.match(r, format, req))
+}
+
+// match answers req for GET and POST: it samples the decision and runs
+// the auction among the matches format can serve, so that a banner never
+// takes a VAST slot or a video a JSON one.
+func (h *DeliveryHandler) match(r *http.Request, format string, req engine.MatchRequest) []engine.Campaign {
+	ctx := r.Context()
+	campaigns, meta := h.Eng.MatchMeta(ctx, req)
+	h.Decisions.Decision(ctx, middleware.GetReqID(ctx), req, meta, campaigns)
+	return h.Auction.Run(req, servable(format, campaigns))
+}
+
+func (b deliveryRequest) toMatchRequest() (engine.MatchRequest, validate.Errors) {
+	var errs validate.Errors
+	req := engine.MatchRequest{
+		AppID:       validate.App("app.bundle", b.App.Bundle, &errs),
+		OS:          validate.OS("device.os", b.Device.OS, &errs),
+		Country:     validate.Country("geo.country", b.Geo.Country, &errs),
+		Debug:       b.Debug,
+		Slots:       validate.Slots("placement.slots", b.Placement.Slots, &errs),
+		OSVersion:   validate.Optional("device.os_version", b.Device.OSVersion, validate.MaxContextLen, &errs),
+		Make:        validate.Optional("device.make", b.Device.Make, validate.MaxContextLen, &errs),
+		Model:       validate.Optional("device.model", b.Device.Model, validate.MaxContextLen, &errs),
+		Region:      validate.Optional("geo.region", b.Geo.Region, validate.MaxContextLen, &errs),
+		City:        validate.Optional("geo.city", b.Geo.City, validate.MaxContextLen, &errs),
+		Lat:         b.Geo.Lat,
+		Lon:         b.Geo.Lon,
+		AppCategory: validate.Optional("app.category", b.App.Category, validate.MaxContextLen, &errs),
+		UserID:      validate.Optional("user.id", b.User.ID, validate.MaxContextLen, &errs),
+		Consent:     validate.Optional("user.consent", b.User.Consent, validate.MaxConsentLen, &errs),
+		PlacementID: validate.Optional("placement.id", b.Placement.ID, validate.MaxContextLen, &errs),
+	}
+	validate.Coordinates("geo.lat", "geo.lon", req.Lat, req.Lon, &errs)
+	return req, errs
+}
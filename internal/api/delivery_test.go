@@ -0,0 +1,253 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"encoding/xml"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"ad-targeting-engine/internal/auction"
+	"ad-targeting-engine/internal/engine"
+	"ad-targeting-engine/internal/events"
+	"ad-targeting-engine/internal/storage"
+	"ad-targeting-engine/internal/validate"
+	"ad-targeting-engine/internal/vast"
+)
+
+func deliveryRouter(t *testing.T) http.Handler {
+	t.Helper()
+	st := storage.NewMemoryStore(
+		storage.CampaignRow{ID: "spotify", Name: "Spotify", ImageURL: "https://img", CTA: "Download", Status: "ACTIVE",
+			Rules: []storage.RuleRow{{Dimension: "country", IsInclusion: true, Values: []string{"US"}}}},
+		storage.CampaignRow{ID: "subway", Name: "Subway", ImageURL: "https://img2", CTA: "Play", Status: "ACTIVE",
+			Rules: []storage.RuleRow{{Dimension: "os", IsInclusion: true, Values: []string{"android"}}}},
+	)
+	eng := engine.NewEngine()
+	require.NoError(t, eng.BuildSnapshot(context.Background(), st))
+	return Router(Handlers{Delivery: NewDeliveryHandler(eng)})
+}
+
+func TestDelivery_POST(t *testing.T) {
+	router := deliveryRouter(t)
+
+	tests := []struct {
+		name       string
+		body       string
+		wantStatus int
+		wantIDs    []string
+		wantCodes  map[string]string
+	}{
+		{
+			name: "full body with unknown fields",
+			body: `{"device":{"os":"Android","os_version":"14","make":"Google","model":"Pixel 8","ifa":"x"},
+				"geo":{"country":"us","region":"CA","city":"SF","lat":37.7,"lon":-122.4},
+				"app":{"bundle":"com.abc.xyz","category":"music"},
+				"user":{"id":"u1","consent":"CP..."},"placement":{"id":"home"},"ext":{"a":1}}`,
+			wantStatus: http.StatusOK,
+			wantIDs:    []string{"spotify", "subway"},
+		},
+		{"minimal", `{"device":{"os":"ios"},"geo":{"country":"US"},"app":{"bundle":"com.x"}}`, http.StatusOK, []string{"spotify"}, nil},
+		{"no match", `{"device":{"os":"ios"},"geo":{"country":"DE"},"app":{"bundle":"com.x"}}`, http.StatusNoContent, nil, nil},
+		{"missing required", `{"geo":{"lat":100,"lon":0}}`, http.StatusBadRequest, nil, map[string]string{
+			"app.bundle": "required", "device.os": "required", "geo.country": "required", "geo.lat": "invalid_coordinates"}},
+		{"half a coordinate", `{"device":{"os":"ios"},"geo":{"country":"US","lat":1},"app":{"bundle":"com.x"}}`,
+			http.StatusBadRequest, nil, map[string]string{"geo.lon": "invalid_coordinates"}},
+		{"bad formats", `{"device":{"os":"symbian"},"geo":{"country":"Germany"},"app":{"bundle":"my app"}}`,
+			http.StatusBadRequest, nil, map[string]string{
+				"app.bundle": "invalid_bundle_id", "device.os": "unknown_os", "geo.country": "invalid_country"}},
+		{"too long", `{"device":{"os":"ios","model":"` + strings.Repeat("x", 200) + `"},"geo":{"country":"US"},"app":{"bundle":"com.x"}}`,
+			http.StatusBadRequest, nil, map[string]string{"device.model": "too_long"}},
+		{"malformed", `{"device":`, http.StatusBadRequest, nil, map[string]string{"body": "malformed_body"}},
+		{"too many slots", `{"device":{"os":"ios"},"geo":{"country":"US"},"app":{"bundle":"com.x"},"placement":{"slots":11}}`,
+			http.StatusBadRequest, nil, map[string]string{"placement.slots": "invalid_slots"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, httptest.NewRequest("POST", "/v1/delivery", strings.NewReader(tt.body)))
+			require.Equal(t, tt.wantStatus, w.Code, w.Body.String())
+
+			switch w.Code {
+			case http.StatusOK:
+				var got []engine.Campaign
+				require.NoError(t, json.Unmarshal(w.Body.Bytes(), &got))
+				var ids []string
+				for _, c := range got {
+					ids = append(ids, c.ID)
+				}
+				assert.Equal(t, tt.wantIDs, ids)
+			case http.StatusBadRequest:
+				assert.Equal(t, validate.ProblemContentType, w.Header().Get("Content-Type"))
+				var p validate.Problem
+				require.NoError(t, json.Unmarshal(w.Body.Bytes(), &p))
+				assert.Equal(t, http.StatusBadRequest, p.Status)
+				assert.Equal(t, "/v1/delivery", p.Instance)
+				codes := map[string]string{}
+				for _, fe := range p.Errors {
+					codes[fe.Field] = fe.Code
+				}
+				assert.Equal(t, tt.wantCodes, codes)
+			}
+		})
+	}
+}
+
+func TestDelivery_GETAndPOSTAgree(t *testing.T) {
+	router := deliveryRouter(t)
+
+	get := httptest.NewRecorder()
+	router.ServeHTTP(get, httptest.NewRequest("GET", "/v1/delivery?app=com.x&os=android&country=us", nil))
+	post := httptest.NewRecorder()
+	router.ServeHTTP(post, httptest.NewRequest("POST", "/v1/delivery",
+		strings.NewReader(`{"device":{"os":"android"},"geo":{"country":"us"},"app":{"bundle":"com.x"}}`)))
+
+	assert.Equal(t, http.StatusOK, get.Code)
+	assert.Equal(t, get.Code, post.Code)
+	assert.JSONEq(t, get.Body.String(), post.Body.String())
+}
+
+func TestDelivery_Auction(t *testing.T) {
+	st := storage.NewMemoryStore(
+		storage.CampaignRow{ID: "low", ImageURL: "https://img", Status: "ACTIVE", BidPrice: 1},
+		storage.CampaignRow{ID: "high", ImageURL: "https://img", Status: "ACTIVE", BidPrice: 3},
+		storage.CampaignRow{ID: "mid", ImageURL: "https://img", Status: "ACTIVE", BidPrice: 2},
+	)
+	eng := engine.NewEngine()
+	require.NoError(t, eng.BuildSnapshot(context.Background(), st))
+	a := auction.New(auction.Config{Type: auction.SecondPrice, Increment: 0.01}, nil)
+	router := Router(Handlers{Delivery: &DeliveryHandler{Eng: eng, Auction: a}})
+
+	tests := []struct {
+		name, query string
+		wantStatus  int
+		want        string
+	}{
+		{"default slots", "", http.StatusOK, `[{"cid":"high","img":"https://img","cta":"","price":2.01}]`},
+		{"two slots", "&slots=2", http.StatusOK,
+			`[{"cid":"high","img":"https://img","cta":"","price":2.01},{"cid":"mid","img":"https://img","cta":"","price":1.01}]`},
+		{"zero slots", "&slots=0", http.StatusBadRequest, ""},
+		{"not a number", "&slots=two", http.StatusBadRequest, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, httptest.NewRequest("GET", "/v1/delivery?app=com.x&os=ios&country=us"+tt.query, nil))
+			require.Equal(t, tt.wantStatus, w.Code, w.Body.String())
+			if tt.want != "" {
+				assert.JSONEq(t, tt.want, w.Body.String())
+			}
+		})
+	}
+}
+
+func TestDelivery_AuctionPerFormat(t *testing.T) {
+	st := storage.NewMemoryStore(
+		storage.CampaignRow{ID: "banner", ImageURL: "https://img", Status: "ACTIVE", BidPrice: 1},
+		storage.CampaignRow{ID: "trailer", Status: "ACTIVE", BidPrice: 3, Creative: storage.CreativeVideo,
+			Video: storage.VideoCreative{URL: "https://cdn/t.mp4", MIME: "video/mp4", Duration: 15, Width: 640, Height: 360}},
+	)
+	eng := engine.NewEngine()
+	require.NoError(t, eng.BuildSnapshot(context.Background(), st))
+	a := auction.New(auction.Config{Type: auction.SecondPrice, Increment: 0.01}, nil)
+	router := Router(Handlers{Delivery: &DeliveryHandler{Eng: eng, Auction: a}})
+
+	// the video outbids the banner, but each format only auctions what it
+	// can serve
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/v1/delivery?app=com.a&os=ios&country=us", nil))
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var got []struct {
+		ID string `json:"cid"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &got))
+	if assert.Len(t, got, 1) {
+		assert.Equal(t, "banner", got[0].ID)
+	}
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/v1/delivery?app=com.a&os=ios&country=us&format=vast", nil))
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var doc vast.VAST
+	require.NoError(t, xml.Unmarshal(w.Body.Bytes(), &doc))
+	if assert.Len(t, doc.Ads, 1) {
+		assert.Equal(t, "trailer", doc.Ads[0].ID)
+	}
+}
+
+func TestDelivery_VAST(t *testing.T) {
+	st := storage.NewMemoryStore(
+		storage.CampaignRow{ID: "spotify", Name: "Spotify", ImageURL: "https://img", CTA: "Download", Status: "ACTIVE"},
+		storage.CampaignRow{ID: "trailer", Name: "Trailer", Status: "ACTIVE", Creative: storage.CreativeVideo,
+			Video: storage.VideoCreative{URL: "https://cdn/t.mp4", MIME: "video/mp4", Duration: 15, Width: 640, Height: 360},
+			Rules: []storage.RuleRow{{Dimension: "country", IsInclusion: true, Values: []string{"US"}}}},
+	)
+	eng := engine.NewEngine()
+	require.NoError(t, eng.BuildSnapshot(context.Background(), st))
+	router := Router(Handlers{Delivery: &DeliveryHandler{Eng: eng, Signer: testSigner(t), TrackingBase: "https://t.example"}})
+
+	tests := []struct {
+		name       string
+		req        *http.Request
+		wantStatus int
+		wantAds    []string
+	}{
+		{"video match", httptest.NewRequest("GET", "/v1/delivery?app=com.a&os=ios&country=us&format=vast", nil),
+			http.StatusOK, []string{"trailer"}},
+		{"post", httptest.NewRequest("POST", "/v1/delivery?format=vast",
+			strings.NewReader(`{"device":{"os":"ios"},"geo":{"country":"US"},"app":{"bundle":"com.a"}}`)),
+			http.StatusOK, []string{"trailer"}},
+		{"only banners match", httptest.NewRequest("GET", "/v1/delivery?app=com.a&os=ios&country=de&format=vast", nil),
+			http.StatusOK, nil},
+		{"unknown format", httptest.NewRequest("GET", "/v1/delivery?app=com.a&os=ios&country=us&format=xml", nil),
+			http.StatusBadRequest, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, tt.req)
+			require.Equal(t, tt.wantStatus, w.Code, w.Body.String())
+			if w.Code != http.StatusOK {
+				return
+			}
+			assert.Equal(t, vast.ContentType, w.Header().Get("Content-Type"))
+			var doc vast.VAST
+			require.NoError(t, xml.Unmarshal(w.Body.Bytes(), &doc))
+			var ids []string
+			for _, ad := range doc.Ads {
+				ids = append(ids, ad.ID)
+				assert.True(t, strings.HasPrefix(ad.InLine.Impressions[0].URL, "https://t.example/t/imp?"))
+			}
+			assert.Equal(t, tt.wantAds, ids)
+		})
+	}
+}
+
+func TestDelivery_Decisions(t *testing.T) {
+	st := storage.NewMemoryStore(
+		storage.CampaignRow{ID: "spotify", Name: "Spotify", ImageURL: "https://img", CTA: "Download", Status: "ACTIVE"})
+	eng := engine.NewEngine()
+	require.NoError(t, eng.BuildSnapshot(context.Background(), st))
+	ring := events.NewRing(10)
+	router := Router(Handlers{Delivery: &DeliveryHandler{Eng: eng, Decisions: events.NewSampler(ring, 1, nil)}})
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/v1/delivery?app=com.a&os=ios&country=us", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/v1/delivery?app=com.a&os=ios&country=zz", nil))
+	require.Equal(t, http.StatusBadRequest, w.Code)
+
+	got := ring.Events()
+	require.Len(t, got, 1, "invalid requests make no decision")
+	assert.Equal(t, events.Decision, got[0].Type)
+	assert.NotEmpty(t, got[0].RequestID)
+	assert.Equal(t, "US", got[0].Country)
+	assert.Equal(t, &events.DecisionDetail{SnapshotVersion: eng.Snapshot().Version, Matched: []string{"spotify"}},
+		got[0].Decision)
+}
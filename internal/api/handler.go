@@ -3,13 +3,32 @@ package api
 import (
 	"encoding/json"
 	"net/http"
-	"strings"
 
+	"ad-targeting-engine/internal/auction"
 	"ad-targeting-engine/internal/engine"
+	"ad-targeting-engine/internal/events"
+	"ad-targeting-engine/internal/tracking"
+	"ad-targeting-engine/internal/validate"
 )
 
 type DeliveryHandler struct {
 	Eng *engine.DeliveryEngine
+
+	// Signer signs the impression and click links of delivered campaigns;
+	// nil leaves them out.
+	Signer *tracking.Signer
+
+	// TrackingBase is the scheme and host of the tracking endpoints in
+	// tracking links; empty means the host of the request.
+	TrackingBase string
+
+	// Decisions samples delivery decisions into the event sink; nil
+	// records none.
+	Decisions *events.Sampler
+
+	// Auction picks the winners among matching campaigns and their price;
+	// nil lets every match win at its bid.
+	Auction *auction.Auction
 }
 
 func NewDeliveryHandler(eng *engine.DeliveryEngine) *DeliveryHandler {
@@ -23,16 +42,30 @@ func writeJSON(w http.ResponseWriter, status int, v any) {
 }
 
 func (h *DeliveryHandler) Delivery(w http.ResponseWriter, r *http.Request) {
+	format, ok := responseFormat(w, r)
+	if !ok {
+		return
+	}
+
 	q := r.URL.Query()
+	var errs validate.Errors
 	req := engine.MatchRequest{
-		AppID:   strings.ToLower(q.Get("app")),
-		OS:      strings.ToLower(q.Get("os")),
-		Country: strings.ToUpper(q.Get("country")),
+		AppID:   validate.App("app", q.Get("app"), &errs),
+		OS:      validate.OS("os", q.Get("os"), &errs),
+		Country: validate.Country("country", q.Get("country"), &errs),
+		Debug:   q.Get("debug") == "true" || q.Get("debug") == "1",
+		Slots:   validate.SlotsParam("slots", q.Get("slots"), &errs),
 	}
+	if len(errs) > 0 {
+		validate.WriteProblem(w, r, errs)
+		return
+	}
+	h.respond(w, r, format, req, h.match(r, format, req))
+}
 
-	ctx := r.Context()
-	campaigns := h.Eng.Match(ctx, req)
-
+// writeCampaigns is the delivery response shared by GET and POST: the
+// matches as JSON, or 204 when there are none.
+func writeCampaigns(w http.ResponseWriter, campaigns []trackedCampaign) {
 	if len(campaigns) == 0 {
 		w.WriteHeader(http.StatusNoContent)
 		return
@@ -0,0 +1,50 @@
+package api
+
+import "net/http"
+
+const (
+	HealthOK       = "ok"
+	HealthDegraded = "degraded"
+	HealthDown     = "down"
+)
+
+// Health is the state of one component as reported on /healthz.
+type Health struct {
+	Name   string         `json:"-"`
+	Status string         `json:"status"`
+	Detail map[string]any `json:"detail,omitempty"`
+}
+
+// HealthCheck reports one component. It must be cheap: /healthz calls every
+// check on each probe.
+type HealthCheck func() Health
+
+// healthz reports overall status as the worst component. A degraded
+// component still answers 200 so that the instance keeps receiving traffic
+// it can serve from memory; only a down component turns the probe into 503.
+func healthz(checks []HealthCheck) http.HandlerFunc {
+	return func(w http.ResponseWriter, _ *http.Request) {
+		status := HealthOK
+		components := make(map[string]Health, len(checks))
+		for _, check := range checks {
+			h := check()
+			components[h.Name] = h
+			switch {
+			case h.Status == HealthDown:
+				status = HealthDown
+			case h.Status == HealthDegraded && status == HealthOK:
+				status = HealthDegraded
+			}
+		}
+
+		code := http.StatusOK
+		if status == HealthDown {
+			code = http.StatusServiceUnavailable
+		}
+		body := map[string]any{"status": status}
+		if len(components) > 0 {
+			body["components"] = components
+		}
+		writeJSON(w, code, body)
+	}
+}
@@ -0,0 +1,87 @@
+package api
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+
+	"ad-targeting-engine/internal/engine"
+)
+
+const defaultAuditLimit = 100
+
+func (h *AdminHandler) listAudit(w http.ResponseWriter, r *http.Request) {
+	limit := defaultAuditLimit
+	if v := r.URL.Query().Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 || n > 1000 {
+			writeValidation(w, fieldErrors{"limit": "must be between 1 and 1000"})
+			return
+		}
+		limit = n
+	}
+	entries, err := h.Store.ListAudit(r.Context(), chi.URLParam(r, "id"), limit)
+	if err != nil {
+		h.storeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, entries)
+}
+
+// historyCampaigns returns the full campaign set as of ?at=<RFC3339>.
+func (h *AdminHandler) historyCampaigns(w http.ResponseWriter, r *http.Request) {
+	at, ok := parseAt(w, r)
+	if !ok {
+		return
+	}
+	rows, err := h.Store.LoadCampaignsAsOf(r.Context(), at)
+	if err != nil {
+		h.storeError(w, err)
+		return
+	}
+	out := make([]campaignPayload, 0, len(rows))
+	for _, c := range rows {
+		out = append(out, toPayload(c))
+	}
+	writeJSON(w, http.StatusOK, out)
+}
+
+// historyDelivery answers "what would this request have matched at ?at=":
+// it rebuilds the campaign set as of that instant into a throwaway engine and
+// runs the regular Match against it.
+func (h *AdminHandler) historyDelivery(w http.ResponseWriter, r *http.Request) {
+	at, ok := parseAt(w, r)
+	if !ok {
+		return
+	}
+	rows, err := h.Store.LoadCampaignsAsOf(r.Context(), at)
+	if err != nil {
+		h.storeError(w, err)
+		return
+	}
+	eng := engine.NewEngine()
+	eng.Load(rows)
+
+	q := r.URL.Query()
+	campaigns := eng.Match(r.Context(), engine.MatchRequest{
+		AppID:   strings.ToLower(q.Get("app")),
+		OS:      strings.ToLower(q.Get("os")),
+		Country: strings.ToUpper(q.Get("country")),
+	})
+	if campaigns == nil {
+		campaigns = []engine.Campaign{}
+	}
+	writeJSON(w, http.StatusOK, campaigns)
+}
+
+func parseAt(w http.ResponseWriter, r *http.Request) (time.Time, bool) {
+	at, err := time.Parse(time.RFC3339, r.URL.Query().Get("at"))
+	if err != nil {
+		writeValidation(w, fieldErrors{"at": "must be an RFC3339 timestamp"})
+		return time.Time{}, false
+	}
+	return at, true
+}
@@ -0,0 +1,94 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/rs/zerolog/log"
+
+	"ad-targeting-engine/internal/auction"
+	"ad-targeting-engine/internal/engine"
+	"ad-targeting-engine/internal/events"
+	"ad-targeting-engine/internal/observability"
+	"ad-targeting-engine/internal/openrtb"
+	"ad-targeting-engine/internal/tracking"
+	"ad-targeting-engine/internal/validate"
+)
+
+// OpenRTBHandler answers exchange bid requests from the delivery engine.
+type OpenRTBHandler struct {
+	Eng *engine.DeliveryEngine
+
+	// Decisions samples bid decisions into the event sink; nil records
+	// none.
+	Decisions *events.Sampler
+
+	// Auction applies floors and competitive separation to the matches,
+	// with one slot per imp; nil bids every match.
+	Auction *auction.Auction
+
+	// Signer signs the billing notice (burl) of each bid, an impression
+	// link the exchange calls once the bid is billed; nil leaves it out,
+	// and with it spend.
+	Signer *tracking.Signer
+
+	// TrackingBase is the scheme and host of the tracking endpoints in
+	// billing links; empty means the host of the request.
+	TrackingBase string
+}
+
+func NewOpenRTBHandler(eng *engine.DeliveryEngine) *OpenRTBHandler {
+	return &OpenRTBHandler{Eng: eng}
+}
+
+// Bid serves POST /openrtb2/bid. Requests we cannot serve get a 200 with
+// an nbr code, so the exchange can tell them apart from plain no-fill (204).
+func (h *OpenRTBHandler) Bid(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("x-openrtb-version", openrtb.Version)
+
+	var req openrtb.BidRequest
+	dec := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<20))
+	if err := dec.Decode(&req); err != nil {
+		h.noBid(w, &req, &openrtb.NoBidError{Reason: openrtb.NBRInvalidRequest, Msg: err.Error()})
+		return
+	}
+	m, nb := openrtb.ToMatchRequest(&req)
+	if nb != nil {
+		h.noBid(w, &req, nb)
+		return
+	}
+
+	matched, meta := h.Eng.MatchMeta(r.Context(), m)
+	h.Decisions.Decision(r.Context(), req.ID, m, meta, matched)
+	m.Slots = min(len(req.Imp), validate.MaxSlots)
+	resp := openrtb.BuildResponse(&req, h.Auction.Run(m, openrtb.Biddable(&req, matched)), h.billing(r, req.ID, m))
+	if resp == nil {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// billing returns the signed billing links of the bids for request id, or
+// nil without a signer. Each bid gets its own link, so billing one imp does
+// not make the other a replay. The price is the clearing price our auction
+// set, like the impression links of ad delivery, never the bid itself.
+func (h *OpenRTBHandler) billing(r *http.Request, id string, m engine.MatchRequest) func(engine.Campaign, string) string {
+	if h.Signer == nil {
+		return nil
+	}
+	base := h.TrackingBase
+	if base == "" {
+		base = requestOrigin(r)
+	}
+	return func(c engine.Campaign, impID string) string {
+		return h.Signer.Links(base, tracking.Claims{RequestID: id + "-" + impID, CampaignID: c.ID, Creative: c.Creative,
+			Price: c.ClearingPrice, App: m.AppID, OS: m.OS, Country: m.Country}).Impression()
+	}
+}
+
+func (h *OpenRTBHandler) noBid(w http.ResponseWriter, req *openrtb.BidRequest, nb *openrtb.NoBidError) {
+	observability.OpenRTBNoBids.WithLabelValues(nb.Reason.String()).Inc()
+	log.Debug().Str("request_id", req.ID).Str("reason", nb.Reason.String()).Msg(nb.Msg)
+	writeJSON(w, http.StatusOK, openrtb.NoBid(req.ID, nb.Reason))
+}
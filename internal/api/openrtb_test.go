@@ -0,0 +1,162 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"ad-targeting-engine/internal/auction"
+	"ad-targeting-engine/internal/engine"
+	"ad-targeting-engine/internal/events"
+	"ad-targeting-engine/internal/openrtb"
+	"ad-targeting-engine/internal/storage"
+	"ad-targeting-engine/internal/validate"
+)
+
+func TestOpenRTB_Bid(t *testing.T) {
+	st := storage.NewMemoryStore(storage.CampaignRow{ID: "spotify", Name: "Spotify", Status: "ACTIVE", BidPrice: 1.5,
+		Markup: "<b>ad</b>", Rules: []storage.RuleRow{{Dimension: "country", IsInclusion: true, Values: []string{"US"}}}})
+	eng := engine.NewEngine()
+	require.NoError(t, eng.BuildSnapshot(context.Background(), st))
+	router := Router(Handlers{Delivery: NewDeliveryHandler(eng), OpenRTB: NewOpenRTBHandler(eng)})
+
+	tests := []struct {
+		name       string
+		body       string
+		wantStatus int
+		wantNBR    *openrtb.NoBidReason
+		wantSeats  int
+	}{
+		{"bid", `{"id":"r1","imp":[{"id":"1","bidfloor":1}],"app":{"bundle":"com.x"},"device":{"os":"Android","geo":{"country":"USA"}}}`,
+			http.StatusOK, nil, 1},
+		{"no fill", `{"id":"r2","imp":[{"id":"1"}],"app":{"bundle":"com.x"},"device":{"os":"android","geo":{"country":"DEU"}}}`,
+			http.StatusNoContent, nil, 0},
+		{"unsupported device", `{"id":"r3","imp":[{"id":"1"}],"app":{"bundle":"com.x"}}`,
+			http.StatusOK, nbr(openrtb.NBRUnsupportedDevice), 0},
+		{"malformed", `{"id":`, http.StatusOK, nbr(openrtb.NBRInvalidRequest), 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, httptest.NewRequest("POST", "/openrtb2/bid", strings.NewReader(tt.body)))
+
+			assert.Equal(t, tt.wantStatus, w.Code, w.Body.String())
+			assert.Equal(t, openrtb.Version, w.Header().Get("x-openrtb-version"))
+			if w.Code == http.StatusNoContent {
+				return
+			}
+			var resp openrtb.BidResponse
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+			assert.Equal(t, tt.wantNBR, resp.NBR)
+			assert.Len(t, resp.SeatBid, tt.wantSeats)
+		})
+	}
+}
+
+func TestOpenRTB_Auction(t *testing.T) {
+	ctx := context.Background()
+	st := storage.NewMemoryStore()
+	_, err := st.PutAdvertiser(ctx, storage.AdvertiserRow{ID: "acme", Name: "Acme", Status: "ACTIVE"})
+	require.NoError(t, err)
+	for _, c := range []storage.CampaignRow{
+		{ID: "acme-1", Name: "A1", Status: "ACTIVE", BidPrice: 3, Advertiser: "acme"},
+		{ID: "acme-2", Name: "A2", Status: "ACTIVE", BidPrice: 2, Advertiser: "acme"},
+		{ID: "cheap", Name: "Cheap", Status: "ACTIVE", BidPrice: 1},
+	} {
+		require.NoError(t, st.CreateCampaign(ctx, c))
+	}
+	eng := engine.NewEngine()
+	require.NoError(t, eng.BuildSnapshot(ctx, st))
+	h := NewOpenRTBHandler(eng)
+	h.Auction = auction.New(auction.Config{Type: auction.SecondPrice, Increment: 0.01, Floor: 1.5}, nil)
+	router := Router(Handlers{Delivery: NewDeliveryHandler(eng), OpenRTB: h})
+
+	// two imps, so two slots: one advertiser takes one, and the other
+	// campaign is under the floor
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("POST", "/openrtb2/bid", strings.NewReader(
+		`{"id":"r1","imp":[{"id":"1"},{"id":"2"}],"app":{"bundle":"com.x"},"device":{"os":"ios","geo":{"country":"USA"}}}`)))
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var resp openrtb.BidResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	var seats []string
+	for _, sb := range resp.SeatBid {
+		seats = append(seats, sb.Seat)
+	}
+	assert.Equal(t, []string{"acme-1"}, seats)
+}
+
+func TestOpenRTB_SlotsCapped(t *testing.T) {
+	st := storage.NewMemoryStore()
+	imps := make([]string, validate.MaxSlots+2)
+	for i := range imps {
+		id := strconv.Itoa(i)
+		require.NoError(t, st.CreateCampaign(context.Background(),
+			storage.CampaignRow{ID: "c" + id, Name: id, Status: "ACTIVE", BidPrice: float64(i + 1)}))
+		imps[i] = `{"id":"` + id + `"}`
+	}
+	eng := engine.NewEngine()
+	require.NoError(t, eng.BuildSnapshot(context.Background(), st))
+	h := NewOpenRTBHandler(eng)
+	h.Auction = auction.New(auction.Config{Type: auction.FirstPrice}, nil)
+	router := Router(Handlers{Delivery: NewDeliveryHandler(eng), OpenRTB: h})
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("POST", "/openrtb2/bid", strings.NewReader(
+		`{"id":"r1","imp":[`+strings.Join(imps, ",")+`],"app":{"bundle":"com.x"},"device":{"os":"ios"}}`)))
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var resp openrtb.BidResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	assert.Len(t, resp.SeatBid, validate.MaxSlots)
+}
+
+func TestOpenRTB_BillingNotice(t *testing.T) {
+	st := storage.NewMemoryStore(
+		storage.CampaignRow{ID: "spotify", Name: "Spotify", Status: "ACTIVE", BidPrice: 1.5},
+		storage.CampaignRow{ID: "deezer", Name: "Deezer", Status: "ACTIVE", BidPrice: 1.2},
+	)
+	eng := engine.NewEngine()
+	require.NoError(t, eng.BuildSnapshot(context.Background(), st))
+	signer, ring := testSigner(t), events.NewRing(10)
+	h := NewOpenRTBHandler(eng)
+	h.Auction = auction.New(auction.Config{Type: auction.SecondPrice, Floor: 1}, nil)
+	h.Signer, h.TrackingBase = signer, "https://t.example"
+	router := Router(Handlers{Delivery: NewDeliveryHandler(eng), OpenRTB: h, Tracking: NewTrackingHandler(signer, ring, eng)})
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("POST", "/openrtb2/bid", strings.NewReader(
+		`{"id":"r1","imp":[{"id":"1"},{"id":"2"}],"app":{"bundle":"com.x"},"device":{"os":"ios"}}`)))
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var resp openrtb.BidResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	require.Len(t, resp.SeatBid, 2, "one winner per imp")
+
+	// the exchange calls each burl once the bid is billed; that is the
+	// impression budgets count
+	for _, sb := range resp.SeatBid {
+		require.Len(t, sb.Bid, 1)
+		b := sb.Bid[0]
+		require.True(t, strings.HasPrefix(b.BURL, "https://t.example/t/imp?"), b.BURL)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest("GET", strings.TrimPrefix(b.BURL, "https://t.example"), nil))
+		assert.Equal(t, http.StatusNoContent, w.Code, w.Body.String())
+	}
+	got := ring.Events()
+	require.Len(t, got, 2)
+	charged := map[string]float64{}
+	for _, e := range got {
+		assert.Equal(t, events.Impression, e.Type)
+		charged[e.RequestID+" "+e.CampaignID] = e.Price
+	}
+	// charged what they cleared at, not their bids
+	assert.Equal(t, map[string]float64{"r1-1 spotify": 1.2, "r1-2 deezer": 1}, charged)
+}
+
+func nbr(r openrtb.NoBidReason) *openrtb.NoBidReason { return &r }
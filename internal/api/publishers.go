@@ -0,0 +1,173 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+
+	"ad-targeting-engine/internal/engine"
+	"ad-targeting-engine/internal/storage"
+	"ad-targeting-engine/internal/validate"
+)
+
+// publisherPayload is an app's settings; floor is a CPM in USD.
+type publisherPayload struct {
+	AppID              string     `json:"app_id"`
+	Floor              float64    `json:"floor"`
+	BlockedAdvertisers []string   `json:"blocked_advertisers"`
+	BlockedCategories  []string   `json:"blocked_categories"`
+	BlockedCampaigns   []string   `json:"blocked_campaigns"`
+	Version            int64      `json:"version,omitempty"`
+	UpdatedAt          *time.Time `json:"updated_at,omitempty"`
+}
+
+func (h *AdminHandler) listPublishers(w http.ResponseWriter, r *http.Request) {
+	rows, err := h.Publishers.LoadPublishers(r.Context())
+	if err != nil {
+		h.storeError(w, err)
+		return
+	}
+	out := make([]publisherPayload, 0, len(rows))
+	for _, p := range rows {
+		out = append(out, toPublisherPayload(p))
+	}
+	writeJSON(w, http.StatusOK, out)
+}
+
+func (h *AdminHandler) getPublisher(w http.ResponseWriter, r *http.Request) {
+	p, err := h.Publishers.GetPublisher(r.Context(), strings.ToLower(chi.URLParam(r, "app")))
+	if err != nil {
+		h.publisherError(w, err)
+		return
+	}
+	setETag(w, p.Version)
+	writeJSON(w, http.StatusOK, toPublisherPayload(p))
+}
+
+// putPublisher creates or replaces an app's settings. The last write wins:
+// settings are small and edited whole, unlike campaigns.
+func (h *AdminHandler) putPublisher(w http.ResponseWriter, r *http.Request) {
+	var p publisherPayload
+	if !decodeBody(w, r, &p) {
+		return
+	}
+	app := chi.URLParam(r, "app")
+	if p.AppID == "" {
+		p.AppID = app
+	}
+	row, errs := validatePublisher(p)
+	if !strings.EqualFold(strings.TrimSpace(p.AppID), app) {
+		errs["app_id"] = "must match the app in the path"
+	}
+	if len(errs) > 0 {
+		writeValidation(w, errs)
+		return
+	}
+	version, err := h.Publishers.PutPublisher(r.Context(), row)
+	if err != nil {
+		h.publisherError(w, err)
+		return
+	}
+	row.Version = version
+	setETag(w, version)
+	writeJSON(w, http.StatusOK, toPublisherPayload(row))
+}
+
+func (h *AdminHandler) deletePublisher(w http.ResponseWriter, r *http.Request) {
+	if err := h.Publishers.DeletePublisher(r.Context(), strings.ToLower(chi.URLParam(r, "app"))); err != nil {
+		h.publisherError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *AdminHandler) publisherError(w http.ResponseWriter, err error) {
+	if errors.Is(err, storage.ErrNotFound) {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": "publisher not found"})
+		return
+	}
+	h.storeError(w, err)
+}
+
+// validatePublisher checks p and returns the normalized storage row:
+// categories upper-cased, duplicates and blanks dropped.
+func validatePublisher(p publisherPayload) (storage.PublisherRow, fieldErrors) {
+	errs := fieldErrors{}
+	var verrs validate.Errors
+	row := storage.PublisherRow{
+		AppID:              validate.App("app_id", p.AppID, &verrs),
+		Floor:              p.Floor,
+		BlockedAdvertisers: blockList(p.BlockedAdvertisers, strings.TrimSpace),
+		BlockedCategories:  blockList(p.BlockedCategories, func(s string) string { return strings.ToUpper(strings.TrimSpace(s)) }),
+		BlockedCampaigns:   blockList(p.BlockedCampaigns, strings.TrimSpace),
+	}
+	for _, fe := range verrs {
+		errs[fe.Field] = fe.Detail
+	}
+	if row.Floor < 0 || row.Floor >= 1e8 || math.IsNaN(row.Floor) {
+		errs["floor"] = "must be a CPM between 0 and 99999999"
+	}
+	for i, c := range row.BlockedCategories {
+		if !validate.IABCategory.MatchString(c) {
+			errs[fmt.Sprintf("blocked_categories[%d]", i)] = "must be an IAB category such as IAB9 or IAB9-30"
+		}
+	}
+	return row, errs
+}
+
+func blockList(vals []string, norm func(string) string) []string {
+	out := []string{}
+	seen := map[string]bool{}
+	for _, v := range vals {
+		v = norm(v)
+		if v == "" || seen[v] {
+			continue
+		}
+		seen[v] = true
+		out = append(out, v)
+	}
+	return out
+}
+
+func toPublisherPayload(p storage.PublisherRow) publisherPayload {
+	out := publisherPayload{
+		AppID:              p.AppID,
+		Floor:              p.Floor,
+		BlockedAdvertisers: blockList(p.BlockedAdvertisers, strings.TrimSpace),
+		BlockedCategories:  blockList(p.BlockedCategories, strings.TrimSpace),
+		BlockedCampaigns:   blockList(p.BlockedCampaigns, strings.TrimSpace),
+		Version:            p.Version,
+	}
+	if !p.UpdatedAt.IsZero() {
+		t := p.UpdatedAt.UTC()
+		out.UpdatedAt = &t
+	}
+	return out
+}
+
+// explain serves GET /admin/v1/explain: for every campaign in this
+// instance's snapshot, whether it serves app, os and country and, if not,
+// the first reason it does not.
+func (h *AdminHandler) explain(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	var verrs validate.Errors
+	req := engine.MatchRequest{
+		AppID:   validate.App("app", q.Get("app"), &verrs),
+		OS:      validate.OS("os", q.Get("os"), &verrs),
+		Country: validate.Country("country", q.Get("country"), &verrs),
+	}
+	if len(verrs) > 0 {
+		errs := fieldErrors{}
+		for _, fe := range verrs {
+			errs[fe.Field] = fe.Detail
+		}
+		writeValidation(w, errs)
+		return
+	}
+	writeJSON(w, http.StatusOK, h.Engine.Explain(r.Context(), req))
+}
@@ -0,0 +1,76 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"ad-targeting-engine/internal/engine"
+	"ad-targeting-engine/internal/storage"
+)
+
+func TestAdmin_Publishers(t *testing.T) {
+	ctx := context.Background()
+	st := storage.NewMemoryStore()
+	_, err := st.PutAdvertiser(ctx, storage.AdvertiserRow{ID: "acme", Name: "Acme", Status: "ACTIVE"})
+	require.NoError(t, err)
+	require.NoError(t, st.CreateCampaign(ctx,
+		storage.CampaignRow{ID: "a", Name: "A", Status: "ACTIVE", BidPrice: 3, Advertiser: "acme", Category: "IAB9-30"}))
+	require.NoError(t, st.CreateCampaign(ctx, storage.CampaignRow{ID: "b", Name: "B", Status: "ACTIVE", BidPrice: 1}))
+	eng := engine.NewEngine()
+	admin := NewAdminHandler(st, "secret")
+	admin.Publishers, admin.Engine = st, eng
+	router := Router(Handlers{Delivery: NewDeliveryHandler(nil), Admin: admin})
+	do := func(method, url, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, url, strings.NewReader(body))
+		req.Header.Set("Authorization", "Bearer secret")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	w := do("PUT", "/admin/v1/publishers/com.x", `{"floor":2,"blocked_categories":[" iab9 ","IAB9"],"blocked_advertisers":["globex",""]}`)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.Equal(t, `"1"`, w.Header().Get("ETag"))
+	p, err := st.GetPublisher(ctx, "com.x")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"IAB9"}, p.BlockedCategories)
+	assert.Equal(t, []string{"globex"}, p.BlockedAdvertisers)
+
+	tests := []struct {
+		name, method, url, body string
+		wantStatus              int
+		wantBody                string
+	}{
+		{"get", "GET", "/admin/v1/publishers/COM.X", "", http.StatusOK, ""},
+		{"get missing", "GET", "/admin/v1/publishers/com.y", "", http.StatusNotFound, ""},
+		{"list", "GET", "/admin/v1/publishers", "", http.StatusOK, ""},
+		{"negative floor", "PUT", "/admin/v1/publishers/com.x", `{"floor":-1}`, http.StatusBadRequest, ""},
+		{"bad category", "PUT", "/admin/v1/publishers/com.x", `{"blocked_categories":["music"]}`, http.StatusBadRequest, ""},
+		{"bad app", "PUT", "/admin/v1/publishers/my%20app", `{}`, http.StatusBadRequest, ""},
+		{"app mismatch", "PUT", "/admin/v1/publishers/com.x", `{"app_id":"com.y"}`, http.StatusBadRequest, ""},
+		{"explain", "GET", "/admin/v1/explain?app=com.x&os=ios&country=us", "", http.StatusOK,
+			`[{"cid":"a","matched":false,"reason":"blocked_category"},{"cid":"b","matched":false,"reason":"below_floor"}]`},
+		{"explain other app", "GET", "/admin/v1/explain?app=com.y&os=ios&country=us", "", http.StatusOK,
+			`[{"cid":"a","matched":true},{"cid":"b","matched":true}]`},
+		{"explain invalid", "GET", "/admin/v1/explain?app=com.x", "", http.StatusBadRequest, ""},
+	}
+	require.NoError(t, eng.BuildSnapshot(ctx, st))
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := do(tt.method, tt.url, tt.body)
+			assert.Equal(t, tt.wantStatus, w.Code, w.Body.String())
+			if tt.wantBody != "" {
+				assert.JSONEq(t, tt.wantBody, w.Body.String())
+			}
+		})
+	}
+
+	assert.Equal(t, http.StatusNoContent, do("DELETE", "/admin/v1/publishers/com.x", "").Code)
+	assert.Equal(t, http.StatusNotFound, do("DELETE", "/admin/v1/publishers/com.x", "").Code)
+}
@@ -0,0 +1,137 @@
+package api
+
+import (
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"ad-targeting-engine/internal/storage"
+)
+
+// maxReportRange bounds the hours a report may scan.
+const maxReportRange = 92 * 24 * time.Hour
+
+type reportRow struct {
+	Hour        *time.Time `json:"hour,omitempty"`
+	Day         string     `json:"day,omitempty"`
+	CampaignID  *string    `json:"campaign_id,omitempty"`
+	Country     *string    `json:"country,omitempty"`
+	OS          *string    `json:"os,omitempty"`
+	App         *string    `json:"app,omitempty"`
+	Matches     int64      `json:"matches"`
+	Impressions int64      `json:"impressions"`
+	Clicks      int64      `json:"clicks"`
+	CTR         float64    `json:"ctr"` // clicks per impression; 0 without impressions
+}
+
+type report struct {
+	From    time.Time   `json:"from"`
+	To      time.Time   `json:"to"`
+	GroupBy []string    `json:"group_by"`
+	Rows    []reportRow `json:"rows"`
+}
+
+// reports serves GET /admin/v1/reports: hourly stats in [from, to), filtered
+// by campaign (comma list), country, os and app, and summed by group_by
+// (comma list of storage.StatsGroups, default campaign).
+func (h *AdminHandler) reports(w http.ResponseWriter, r *http.Request) {
+	q, errs := parseReportQuery(r, time.Now())
+	if len(errs) > 0 {
+		writeValidation(w, errs)
+		return
+	}
+	rows, err := h.Stats.QueryStats(r.Context(), q)
+	if err != nil {
+		h.storeError(w, err)
+		return
+	}
+	out := report{From: q.From, To: q.To, GroupBy: q.GroupBy, Rows: make([]reportRow, 0, len(rows))}
+	for _, s := range rows {
+		out.Rows = append(out.Rows, toReportRow(s, q.GroupBy))
+	}
+	writeJSON(w, http.StatusOK, out)
+}
+
+func parseReportQuery(r *http.Request, now time.Time) (storage.StatsQuery, fieldErrors) {
+	errs := fieldErrors{}
+	v := r.URL.Query()
+	q := storage.StatsQuery{
+		To:      now.UTC().Truncate(time.Hour).Add(time.Hour),
+		Country: strings.ToUpper(strings.TrimSpace(v.Get("country"))),
+		OS:      strings.ToLower(strings.TrimSpace(v.Get("os"))),
+		App:     strings.ToLower(strings.TrimSpace(v.Get("app"))),
+		GroupBy: []string{storage.StatsByCampaign},
+	}
+	if s := v.Get("to"); s != "" {
+		t, err := time.Parse(time.RFC3339, s)
+		if err != nil {
+			errs["to"] = "must be an RFC3339 timestamp"
+		}
+		q.To = t.UTC()
+	}
+	q.From = q.To.Add(-24 * time.Hour)
+	if s := v.Get("from"); s != "" {
+		t, err := time.Parse(time.RFC3339, s)
+		if err != nil {
+			errs["from"] = "must be an RFC3339 timestamp"
+		}
+		q.From = t.UTC()
+	}
+	if len(errs) == 0 {
+		switch {
+		case !q.From.Before(q.To):
+			errs["from"] = "must be before to"
+		case q.To.Sub(q.From) > maxReportRange:
+			errs["from"] = "range must not exceed 92 days"
+		}
+	}
+	if s := v.Get("campaign"); s != "" {
+		for _, id := range strings.Split(s, ",") {
+			if id = strings.TrimSpace(id); id != "" {
+				q.CampaignIDs = append(q.CampaignIDs, id)
+			}
+		}
+	}
+	if v.Has("group_by") {
+		q.GroupBy = []string{}
+		for _, g := range strings.Split(v.Get("group_by"), ",") {
+			g = strings.ToLower(strings.TrimSpace(g))
+			switch {
+			case g == "":
+			case !slices.Contains(storage.StatsGroups, g):
+				errs["group_by"] = "must be a comma list of " + strings.Join(storage.StatsGroups, ", ")
+			case !slices.Contains(q.GroupBy, g):
+				q.GroupBy = append(q.GroupBy, g)
+			}
+		}
+		if slices.Contains(q.GroupBy, storage.StatsByHour) && slices.Contains(q.GroupBy, storage.StatsByDay) {
+			errs["group_by"] = "cannot group by both hour and day"
+		}
+	}
+	return q, errs
+}
+
+func toReportRow(s storage.StatsRow, groupBy []string) reportRow {
+	row := reportRow{Matches: s.Matches, Impressions: s.Impressions, Clicks: s.Clicks}
+	if s.Impressions > 0 {
+		row.CTR = float64(s.Clicks) / float64(s.Impressions)
+	}
+	for _, g := range groupBy {
+		switch g {
+		case storage.StatsByHour:
+			row.Hour = &s.Hour
+		case storage.StatsByDay:
+			row.Day = s.Hour.Format(time.DateOnly)
+		case storage.StatsByCampaign:
+			row.CampaignID = &s.CampaignID
+		case storage.StatsByCountry:
+			row.Country = &s.Country
+		case storage.StatsByOS:
+			row.OS = &s.OS
+		case storage.StatsByApp:
+			row.App = &s.App
+		}
+	}
+	return row
+}
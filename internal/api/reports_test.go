@@ -0,0 +1,72 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"ad-targeting-engine/internal/storage"
+)
+
+func TestAdmin_Reports(t *testing.T) {
+	st := storage.NewMemoryStore()
+	hour := time.Date(2026, 10, 18, 10, 0, 0, 0, time.UTC)
+	require.NoError(t, st.UpsertHourlyStats(context.Background(), []storage.HourlyStatRow{
+		{Hour: hour, CampaignID: "a", Country: "US", OS: "ios", App: "com.x", Source: "n1",
+			Counts: storage.Counts{Matches: 10, Impressions: 4, Clicks: 1}},
+		{Hour: hour, CampaignID: "a", Country: "US", OS: "ios", App: "com.x", Source: "n2",
+			Counts: storage.Counts{Matches: 10, Impressions: 4, Clicks: 1}},
+		{Hour: hour.Add(time.Hour), CampaignID: "a", Country: "CA", OS: "android", App: "com.y", Source: "n1",
+			Counts: storage.Counts{Matches: 5}},
+		{Hour: hour, CampaignID: "b", Country: "US", OS: "ios", App: "com.x", Source: "n1",
+			Counts: storage.Counts{Matches: 3, Impressions: 2}},
+	}))
+	admin := NewAdminHandler(st, "secret")
+	admin.Stats = st
+	router := Router(Handlers{Delivery: NewDeliveryHandler(nil), Admin: admin})
+	window := "from=2026-10-18T00:00:00Z&to=2026-10-19T00:00:00Z"
+
+	tests := []struct {
+		name       string
+		query      string
+		wantStatus int
+		wantRows   string
+	}{
+		{"by campaign", window, http.StatusOK,
+			`[{"campaign_id":"a","matches":25,"impressions":8,"clicks":2,"ctr":0.25},
+			  {"campaign_id":"b","matches":3,"impressions":2,"clicks":0,"ctr":0}]`},
+		{"totals", window + "&group_by=", http.StatusOK,
+			`[{"matches":28,"impressions":10,"clicks":2,"ctr":0.2}]`},
+		{"filtered by hour", window + "&campaign=a&country=us&group_by=hour,os", http.StatusOK,
+			`[{"hour":"2026-10-18T10:00:00Z","os":"ios","matches":20,"impressions":8,"clicks":2,"ctr":0.25}]`},
+		{"by day", window + "&group_by=day", http.StatusOK,
+			`[{"day":"2026-10-18","matches":28,"impressions":10,"clicks":2,"ctr":0.2}]`},
+		{"outside the window", "from=2026-10-17T00:00:00Z&to=2026-10-18T00:00:00Z", http.StatusOK, `[]`},
+		{"unknown group", window + "&group_by=region", http.StatusBadRequest, ""},
+		{"hour and day", window + "&group_by=hour,day", http.StatusBadRequest, ""},
+		{"bad time", "from=yesterday", http.StatusBadRequest, ""},
+		{"reversed", "from=2026-10-19T00:00:00Z&to=2026-10-18T00:00:00Z", http.StatusBadRequest, ""},
+		{"too long", "from=2026-01-01T00:00:00Z&to=2026-10-18T00:00:00Z", http.StatusBadRequest, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/admin/v1/reports?"+tt.query, nil)
+			req.Header.Set("Authorization", "Bearer secret")
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+			require.Equal(t, tt.wantStatus, w.Code, w.Body.String())
+			if tt.wantRows == "" {
+				return
+			}
+			var got struct{ Rows json.RawMessage }
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &got))
+			assert.JSONEq(t, tt.wantRows, string(got.Rows))
+		})
+	}
+}
@@ -1,7 +1,9 @@
 package api
 
 import (
+	"ad-targeting-engine/internal/auth"
 	"ad-targeting-engine/internal/observability"
+	"ad-targeting-engine/internal/tracking"
 	"net/http"
 	"time"
 
@@ -9,20 +11,65 @@ import (
 	"github.com/go-chi/chi/v5/middleware"
 )
 
-func Router(h *DeliveryHandler) http.Handler {
+// Handlers are the components Router serves. Delivery is required; nil
+// optional handlers leave their routes unmounted.
+type Handlers struct {
+	Delivery *DeliveryHandler
+	Admin    *AdminHandler
+	Snapshot *SnapshotHandler
+	OpenRTB  *OpenRTBHandler
+	Tracking *TrackingHandler
+	Health   []HealthCheck
+
+	// Keys, when set, guards delivery, admin and metrics routes with API
+	// keys of the matching scope.
+	Keys *auth.Keyring
+}
+
+// require is Keys.Require(scope), or a pass-through without Keys.
+func (hs Handlers) require(scope auth.Scope) func(http.Handler) http.Handler {
+	if hs.Keys == nil {
+		return func(next http.Handler) http.Handler { return next }
+	}
+	return hs.Keys.Require(scope)
+}
+
+func Router(hs Handlers) http.Handler {
 	r := chi.NewRouter()
 
-	r.Use(observability.Measure)
 	r.Use(middleware.RequestID)
 	r.Use(middleware.RealIP)
 	r.Use(middleware.Recoverer)
-	r.Use(middleware.Timeout(2 * time.Second))
 
-	r.Get("/v1/delivery", h.Delivery)
-	r.Get("/healthz", func(w http.ResponseWriter, _ *http.Request) {
-		w.WriteHeader(http.StatusOK)
-		_, _ = w.Write([]byte("ok"))
+	// long-polls outlive the request timeout and would skew latency metrics
+	if hs.Snapshot != nil {
+		r.Get("/internal/v1/snapshot", hs.Snapshot.ServeHTTP)
+	}
+
+	r.Group(func(r chi.Router) {
+		r.Use(observability.Measure)
+		r.Use(middleware.Timeout(2 * time.Second))
+
+		r.Group(func(r chi.Router) {
+			r.Use(hs.require(auth.ScopeDelivery))
+			r.Get("/v1/delivery", hs.Delivery.Delivery)
+			r.Post("/v1/delivery", hs.Delivery.DeliveryPOST)
+			if hs.OpenRTB != nil {
+				r.Post("/openrtb2/bid", hs.OpenRTB.Bid)
+			}
+		})
+		// tracking links are called by players and browsers without keys;
+		// their signatures authenticate them
+		if hs.Tracking != nil {
+			r.Get(tracking.ImpressionPath, hs.Tracking.Impression)
+			r.Get(tracking.ClickPath, hs.Tracking.Click)
+			r.Get(tracking.EventPath, hs.Tracking.Event)
+		}
+		if hs.Admin != nil {
+			r.With(hs.require(auth.ScopeAdmin)).Mount("/admin/v1", hs.Admin.Routes())
+		}
+		r.Get("/healthz", healthz(hs.Health))
+		r.With(hs.require(auth.ScopeMetrics)).Handle("/metrics", observability.MetricsHandler())
 	})
-	r.Handle("/metrics", observability.MetricsHandler())
 	return r
-}
\ No newline at end of file
+}
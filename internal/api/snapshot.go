@@ -0,0 +1,82 @@
+package api
+
+import (
+	"context"
+	"crypto/subtle"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog/log"
+
+	"ad-targeting-engine/internal/engine"
+)
+
+// SnapshotHandler serves the engine snapshot to followers. A request whose
+// If-None-Match names the current snapshot is held open until the next swap
+// or the wait expires (304), so followers learn about changes immediately
+// without polling in a tight loop.
+type SnapshotHandler struct {
+	Eng     *engine.DeliveryEngine
+	Token   string
+	MaxWait time.Duration
+}
+
+func NewSnapshotHandler(eng *engine.DeliveryEngine, token string, maxWait time.Duration) *SnapshotHandler {
+	return &SnapshotHandler{Eng: eng, Token: token, MaxWait: maxWait}
+}
+
+// SnapshotETag is the entity tag of a snapshot version.
+func SnapshotETag(version, hash uint64) string {
+	return fmt.Sprintf(`"%d-%016x"`, version, hash)
+}
+
+func (h *SnapshotHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	got, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if h.Token == "" || !ok || subtle.ConstantTimeCompare([]byte(got), []byte(h.Token)) != 1 {
+		writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "unauthorized"})
+		return
+	}
+
+	wait := h.MaxWait
+	if s := r.URL.Query().Get("wait"); s != "" {
+		secs, err := strconv.Atoi(s)
+		if err != nil || secs < 0 {
+			writeValidation(w, fieldErrors{"wait": "must be a number of seconds"})
+			return
+		}
+		wait = min(wait, time.Duration(secs)*time.Second)
+	}
+
+	have := r.Header.Get("If-None-Match")
+	ctx, cancel := context.WithTimeout(r.Context(), wait)
+	defer cancel()
+	swaps := h.Eng.Watch(ctx) // before reading, so a swap in between is not missed
+
+	meta := h.Eng.Snapshot()
+	for have != "" && have == SnapshotETag(meta.Version, meta.Hash) {
+		if _, ok := <-swaps; !ok { // closed once the wait is over
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		meta = h.Eng.Snapshot()
+	}
+	if meta.Version == 0 {
+		writeJSON(w, http.StatusServiceUnavailable, map[string]string{"error": "no snapshot built yet"})
+		return
+	}
+
+	data, meta, err := h.Eng.EncodedSnapshot()
+	if err != nil {
+		log.Error().Err(err).Msg("encode snapshot")
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "internal error"})
+		return
+	}
+	w.Header().Set("Content-Type", engine.SnapshotContentType)
+	w.Header().Set("Content-Length", strconv.Itoa(len(data)))
+	w.Header().Set("ETag", SnapshotETag(meta.Version, meta.Hash))
+	w.WriteHeader(http.StatusOK)
+	_, _ = w.Write(data)
+}
@@ -0,0 +1,123 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/rs/zerolog/log"
+
+	"ad-targeting-engine/internal/engine"
+	"ad-targeting-engine/internal/events"
+	"ad-targeting-engine/internal/observability"
+	"ad-targeting-engine/internal/tracking"
+)
+
+// Reasons a tracking link is not recorded, as the reason label of
+// TrackingRejected.
+const (
+	rejectMalformed    = "malformed"
+	rejectBadSignature = "bad_signature"
+	rejectExpired      = "expired"
+	rejectReplay       = "replay"
+	rejectSink         = "sink_error"
+)
+
+// TrackingHandler serves the signed links of delivery responses: it checks
+// them, records each one once through Sink, and redirects clicks to the
+// campaign's landing URL.
+type TrackingHandler struct {
+	Signer  *tracking.Signer
+	Replays *tracking.ReplayGuard
+	Sink    events.Sink
+	Eng     *engine.DeliveryEngine
+}
+
+func NewTrackingHandler(signer *tracking.Signer, sink events.Sink, eng *engine.DeliveryEngine) *TrackingHandler {
+	return &TrackingHandler{Signer: signer, Replays: tracking.NewReplayGuard(signer.TTL()), Sink: sink, Eng: eng}
+}
+
+// Impression serves GET /t/imp: 204 once recorded, 403 for forged or
+// malformed links, 410 for expired ones and 409 for replays.
+func (h *TrackingHandler) Impression(w http.ResponseWriter, r *http.Request) {
+	h.beacon(w, r, tracking.KindImpression, events.Impression)
+}
+
+// Event serves GET /t/event, the VAST progress beacons, like Impression.
+func (h *TrackingHandler) Event(w http.ResponseWriter, r *http.Request) {
+	h.beacon(w, r, tracking.KindEvent, events.Playback)
+}
+
+func (h *TrackingHandler) beacon(w http.ResponseWriter, r *http.Request, kind string, typ events.Type) {
+	c, reason := h.check(r, kind)
+	switch reason {
+	case "":
+	case rejectMalformed, rejectBadSignature:
+		writeJSON(w, http.StatusForbidden, map[string]string{"error": "invalid tracking link"})
+		return
+	case rejectExpired:
+		writeJSON(w, http.StatusGone, map[string]string{"error": "tracking link expired"})
+		return
+	case rejectReplay:
+		writeJSON(w, http.StatusConflict, map[string]string{"error": "already recorded"})
+		return
+	}
+	h.record(r, kind, typ, c)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Click serves GET /t/click with a 302 to the landing URL of the campaign.
+// Expired and replayed clicks still redirect, since a user is waiting on
+// them, but are not recorded. Forged links get 403 and campaigns without a
+// landing URL 404.
+func (h *TrackingHandler) Click(w http.ResponseWriter, r *http.Request) {
+	c, reason := h.check(r, tracking.KindClick)
+	if reason == rejectMalformed || reason == rejectBadSignature {
+		writeJSON(w, http.StatusForbidden, map[string]string{"error": "invalid tracking link"})
+		return
+	}
+	camp, ok := h.Eng.Lookup(c.CampaignID)
+	if !ok || camp.Landing == "" {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": "campaign has no landing url"})
+		return
+	}
+	if reason == "" {
+		h.record(r, tracking.KindClick, events.Click, c)
+	}
+	http.Redirect(w, r, camp.Landing, http.StatusFound)
+}
+
+// check verifies the link of r, returning its claims and the reason it must
+// not be recorded, if any. Only links that verify are checked for replays,
+// so expired ones do not take up room in the guard.
+func (h *TrackingHandler) check(r *http.Request, kind string) (tracking.Claims, string) {
+	c, err := h.Signer.Verify(kind, r.URL.Query())
+	reason := ""
+	switch {
+	case errors.Is(err, tracking.ErrExpired):
+		reason = rejectExpired
+	case errors.Is(err, tracking.ErrBadSignature):
+		reason = rejectBadSignature
+	case err != nil:
+		reason = rejectMalformed
+	case !h.Replays.First(c.Signature):
+		reason = rejectReplay
+	}
+	if reason != "" {
+		observability.TrackingRejected.WithLabelValues(kind, reason).Inc()
+	}
+	return c, reason
+}
+
+// record hands the event to the sink. Sink failures are logged and counted
+// but not reported to the caller, which has nothing to retry with.
+func (h *TrackingHandler) record(r *http.Request, kind string, typ events.Type, c tracking.Claims) {
+	e := events.Event{Type: typ, Time: time.Now().UTC(), RequestID: c.RequestID, CampaignID: c.CampaignID,
+		Creative: c.Creative, Name: c.Event, Price: c.Price, App: c.App, OS: c.OS, Country: c.Country}
+	if err := h.Sink.Record(r.Context(), e); err != nil {
+		log.Error().Err(err).Str("type", string(typ)).Str("campaign_id", c.CampaignID).Msg("record tracking event")
+		observability.TrackingRejected.WithLabelValues(kind, rejectSink).Inc()
+		return
+	}
+	observability.TrackingEvents.WithLabelValues(string(typ)).Inc()
+}
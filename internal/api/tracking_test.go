@@ -0,0 +1,137 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"ad-targeting-engine/internal/engine"
+	"ad-targeting-engine/internal/events"
+	"ad-targeting-engine/internal/storage"
+	"ad-targeting-engine/internal/tracking"
+)
+
+var testTrackingKey = tracking.Key{ID: "k1", Secret: "secret"}
+
+func testSigner(t *testing.T) *tracking.Signer {
+	t.Helper()
+	s, err := tracking.NewSigner([]tracking.Key{testTrackingKey}, time.Hour)
+	require.NoError(t, err)
+	return s
+}
+
+type memorySink struct {
+	mu     sync.Mutex
+	events []events.Event
+}
+
+func (s *memorySink) Record(_ context.Context, e events.Event) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.events = append(s.events, e)
+	return nil
+}
+
+func (s *memorySink) types() []events.Type {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var ts []events.Type
+	for _, e := range s.events {
+		ts = append(ts, e.Type)
+	}
+	return ts
+}
+
+func TestTracking(t *testing.T) {
+	st := storage.NewMemoryStore(
+		storage.CampaignRow{ID: "spotify", Name: "Spotify", ImageURL: "https://img", CTA: "Download", Status: "ACTIVE",
+			Landing: "https://spotify.com/x"},
+		storage.CampaignRow{ID: "subway", Name: "Subway", ImageURL: "https://img2", CTA: "Play", Status: "ACTIVE",
+			Rules: []storage.RuleRow{{Dimension: "country", IsInclusion: true, Values: []string{"DE"}}}},
+	)
+	eng := engine.NewEngine()
+	require.NoError(t, eng.BuildSnapshot(context.Background(), st))
+	signer := testSigner(t)
+	sink := &memorySink{}
+	router := Router(Handlers{
+		Delivery: &DeliveryHandler{Eng: eng, Signer: signer},
+		Tracking: NewTrackingHandler(signer, sink, eng),
+	})
+	get := func(target string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest("GET", target, nil))
+		return w
+	}
+	links := func(country string) map[string]trackedCampaign {
+		w := get("/v1/delivery?app=com.a&os=ios&country=" + country)
+		require.Equal(t, http.StatusOK, w.Code)
+		var got []trackedCampaign
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &got))
+		out := map[string]trackedCampaign{}
+		for _, c := range got {
+			out[c.ID] = c
+		}
+		return out
+	}
+
+	spotify := links("us")["spotify"]
+	require.True(t, strings.HasPrefix(spotify.ImpressionURL, "http://example.com/t/imp?"), spotify.ImpressionURL)
+	require.True(t, strings.HasPrefix(spotify.ClickURL, "http://example.com/t/click?"), spotify.ClickURL)
+
+	assert.Equal(t, http.StatusNoContent, get(spotify.ImpressionURL).Code)
+	assert.Equal(t, http.StatusConflict, get(spotify.ImpressionURL).Code, "replayed impression")
+
+	w := get(spotify.ClickURL)
+	assert.Equal(t, http.StatusFound, w.Code)
+	assert.Equal(t, "https://spotify.com/x", w.Header().Get("Location"))
+	w = get(spotify.ClickURL)
+	assert.Equal(t, http.StatusFound, w.Code, "replayed clicks still redirect")
+
+	assert.Equal(t, []events.Type{events.Impression, events.Click}, sink.types(), "replays are not recorded")
+	e := sink.events[0]
+	assert.Equal(t, "spotify", e.CampaignID)
+	assert.Equal(t, storage.CreativeBanner, e.Creative)
+	assert.NotEmpty(t, e.RequestID)
+
+	t.Run("forged", func(t *testing.T) {
+		u, _ := url.Parse(links("us")["spotify"].ImpressionURL)
+		q := u.Query()
+		q.Set("cid", "subway")
+		u.RawQuery = q.Encode()
+		assert.Equal(t, http.StatusForbidden, get(u.String()).Code, "tampered claims")
+
+		click, _ := url.Parse(links("us")["spotify"].ClickURL)
+		assert.Equal(t, http.StatusForbidden, get(tracking.ImpressionPath+"?"+click.RawQuery).Code,
+			"a click link is not an impression link")
+		assert.Equal(t, http.StatusForbidden, get(tracking.ImpressionPath+"?cid=spotify").Code, "malformed")
+	})
+
+	t.Run("no landing url", func(t *testing.T) {
+		assert.Equal(t, http.StatusNotFound, get(links("de")["subway"].ClickURL).Code)
+	})
+
+	t.Run("expired", func(t *testing.T) {
+		expired, err := tracking.NewSigner([]tracking.Key{testTrackingKey}, -time.Minute)
+		require.NoError(t, err)
+		h := Router(Handlers{Delivery: &DeliveryHandler{Eng: eng}, Tracking: NewTrackingHandler(expired, sink, eng)})
+		before := len(sink.types())
+		l := links("us")["spotify"]
+
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest("GET", l.ImpressionURL, nil))
+		assert.Equal(t, http.StatusGone, w.Code)
+		w = httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest("GET", l.ClickURL, nil))
+		assert.Equal(t, http.StatusFound, w.Code)
+		assert.Len(t, sink.types(), before, "expired links are not recorded")
+	})
+}
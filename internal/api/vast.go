@@ -0,0 +1,103 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/rs/zerolog/log"
+
+	"ad-targeting-engine/internal/engine"
+	"ad-targeting-engine/internal/storage"
+	"ad-targeting-engine/internal/tracking"
+	"ad-targeting-engine/internal/vast"
+)
+
+const (
+	formatJSON = "json"
+	formatVAST = "vast"
+)
+
+// responseFormat reads the format query parameter shared by GET and POST
+// delivery, answering 400 for unknown values.
+func responseFormat(w http.ResponseWriter, r *http.Request) (string, bool) {
+	switch f := r.URL.Query().Get("format"); f {
+	case "", formatJSON:
+		return formatJSON, true
+	case formatVAST:
+		return f, true
+	default:
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "format must be json or vast"})
+		return "", false
+	}
+}
+
+// servable keeps the campaigns format can render: videos for VAST, banners
+// for JSON.
+func servable(format string, cs []engine.Campaign) []engine.Campaign {
+	out := make([]engine.Campaign, 0, len(cs))
+	for _, c := range cs {
+		if (c.Creative == storage.CreativeVideo) == (format == formatVAST) {
+			out = append(out, c)
+		}
+	}
+	return out
+}
+
+// trackedCampaign is a campaign in the JSON delivery response, with its
+// tracking links when the handler signs them.
+type trackedCampaign struct {
+	engine.Campaign
+	ImpressionURL string `json:"imp_url,omitempty"`
+	ClickURL      string `json:"click_url,omitempty"`
+}
+
+func (h *DeliveryHandler) respond(w http.ResponseWriter, r *http.Request, format string, req engine.MatchRequest,
+	campaigns []engine.Campaign) {
+	links := h.links(r, req)
+	if format != formatVAST {
+		out := make([]trackedCampaign, len(campaigns))
+		for i, c := range campaigns {
+			out[i].Campaign = c
+			if links != nil {
+				l := links(c)
+				out[i].ImpressionURL, out[i].ClickURL = l.Impression(), l.Click()
+			}
+		}
+		writeCampaigns(w, out)
+		return
+	}
+	doc := vast.Document(middleware.GetReqID(r.Context()), campaigns, links)
+
+	// an empty document rather than 204: players expect VAST either way
+	w.Header().Set("Content-Type", vast.ContentType)
+	w.WriteHeader(http.StatusOK)
+	if err := doc.Write(w); err != nil {
+		log.Error().Err(err).Msg("write vast response")
+	}
+}
+
+// links returns the tracking links of the campaigns served for req, or nil
+// without a signer.
+func (h *DeliveryHandler) links(r *http.Request, req engine.MatchRequest) func(engine.Campaign) tracking.Links {
+	if h.Signer == nil {
+		return nil
+	}
+	base := h.TrackingBase
+	if base == "" {
+		base = requestOrigin(r)
+	}
+	rid := middleware.GetReqID(r.Context())
+	return func(c engine.Campaign) tracking.Links {
+		return h.Signer.Links(base, tracking.Claims{RequestID: rid, CampaignID: c.ID, Creative: c.Creative,
+			Price: c.ClearingPrice, App: req.AppID, OS: req.OS, Country: req.Country})
+	}
+}
+
+// requestOrigin is the scheme and host the request was made to.
+func requestOrigin(r *http.Request) string {
+	scheme := "http"
+	if r.TLS != nil || r.Header.Get("X-Forwarded-Proto") == "https" {
+		scheme = "https"
+	}
+	return scheme + "://" + r.Host
+}
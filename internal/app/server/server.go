@@ -9,6 +9,7 @@ import (
 	"time"
 
 	"ad-targeting-engine/internal/storage"
+	"ad-targeting-engine/internal/validate"
 )
 
 type StoreInterface interface {
@@ -46,19 +47,20 @@ func (s *Server) StartCacheRefresher(ctx context.Context) {
 }
 
 func (s *Server) handleDelivery(w http.ResponseWriter, r *http.Request) {
-	app := r.URL.Query().Get("app")
-	country := r.URL.Query().Get("country")
-	os := r.URL.Query().Get("os")
-
-	if app == "" || country == "" || os == "" {
-		http.Error(w, `{"error":"missing required param"}`, http.StatusBadRequest)
+	q := r.URL.Query()
+	var errs validate.Errors
+	app := validate.App("app", q.Get("app"), &errs)
+	os := validate.OS("os", q.Get("os"), &errs)
+	country := validate.Country("country", q.Get("country"), &errs)
+	if len(errs) > 0 {
+		validate.WriteProblem(w, r, errs)
 		return
 	}
 
 	req := map[string]string{
-		"appid":   strings.ToLower(r.URL.Query().Get("app")),
-		"country": strings.ToLower(r.URL.Query().Get("country")),
-		"os":      strings.ToLower(r.URL.Query().Get("os")),
+		"appid":   app,
+		"country": strings.ToLower(country),
+		"os":      os,
 	}
 
 	campaigns := s.cache.GetCampaigns()
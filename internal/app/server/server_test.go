@@ -11,6 +11,7 @@ import (
 	"github.com/stretchr/testify/assert"
 
 	"ad-targeting-engine/internal/storage"
+	"ad-targeting-engine/internal/validate"
 )
 
 type MockStore struct {
@@ -36,6 +37,8 @@ func TestDelivery_Scenarios(t *testing.T) {
 		{"missing app", nil, "/v1/delivery?country=us&os=android", http.StatusBadRequest, nil},
 		{"missing country", nil, "/v1/delivery?app=com.any&os=android", http.StatusBadRequest, nil},
 		{"missing os", nil, "/v1/delivery?app=com.any&country=us", http.StatusBadRequest, nil},
+		{"invalid country", nil, "/v1/delivery?app=com.any&country=canada&os=android", http.StatusBadRequest, nil},
+		{"unknown os", nil, "/v1/delivery?app=com.any&country=us&os=symbian", http.StatusBadRequest, nil},
 		{
 			name: "no match",
 			campaigns: []storage.CampaignRow{
@@ -117,6 +120,9 @@ func TestDelivery_Scenarios(t *testing.T) {
 			if w.Code != tt.wantStatus {
 				t.Fatalf("got status %d, want %d", w.Code, tt.wantStatus)
 			}
+			if tt.wantStatus == http.StatusBadRequest {
+				assert.Equal(t, validate.ProblemContentType, w.Header().Get("Content-Type"))
+			}
 
 			if tt.wantStatus == http.StatusOK {
 				var campaigns []storage.CampaignRow
@@ -155,7 +161,7 @@ func TestServer_Start_HTTPHandler(t *testing.T) {
 					Rules:  []storage.RuleRow{{Dimension: "country", IsInclusion: true, Values: []string{"us"}}},
 				},
 			},
-			url:        "/v1/delivery?app=x&country=us&os=android",
+			url:        "/v1/delivery?app=com.x&country=us&os=android",
 			wantStatus: http.StatusOK,
 		},
 		{
@@ -168,7 +174,7 @@ func TestServer_Start_HTTPHandler(t *testing.T) {
 					Rules:  []storage.RuleRow{{Dimension: "country", IsInclusion: true, Values: []string{"us"}}},
 				},
 			},
-			url:        "/v1/delivery?app=x&country=ca&os=android",
+			url:        "/v1/delivery?app=com.x&country=ca&os=android",
 			wantStatus: http.StatusNoContent,
 		},
 	}
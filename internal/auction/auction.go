@@ -0,0 +1,170 @@
+// Package auction picks which of the campaigns matching a delivery request
+// fill its ad slots, and at what price.
+package auction
+
+import (
+	"math/rand/v2"
+	"slices"
+	"strings"
+
+	"ad-targeting-engine/internal/engine"
+	"ad-targeting-engine/internal/observability"
+)
+
+// Auction types.
+const (
+	FirstPrice  = "first_price"
+	SecondPrice = "second_price"
+)
+
+// Config describes an auction. Prices are CPMs in USD.
+type Config struct {
+	Type      string             // FirstPrice or SecondPrice
+	Increment float64            // added to the next bid in second-price auctions
+	Slots     int                // slots filled when the request does not say
+	Floor     float64            // minimum bid, for apps without their own
+	Floors    map[string]float64 // minimum bid by app ID
+
+	// Exclusions are groups of mutually exclusive IAB categories: two
+	// campaigns whose categories fall in the same group never win slots of
+	// the same request. A tier-1 category (IAB2) covers its subcategories.
+	Exclusions [][]string
+}
+
+// Auction ranks campaigns by bid. A nil Auction lets every campaign win at
+// its bid.
+type Auction struct {
+	cfg    Config
+	intN   func(n int) int // tie-break RNG
+	floors FloorSource
+}
+
+// FloorSource supplies floors kept outside the config, such as publisher
+// settings. *engine.DeliveryEngine is one.
+type FloorSource interface {
+	Floor(app string) float64
+}
+
+// New returns an auction run by cfg. intN returns a random int in [0, n)
+// to order equal bids; nil uses math/rand.
+func New(cfg Config, intN func(n int) int) *Auction {
+	if intN == nil {
+		intN = rand.IntN
+	}
+	if cfg.Slots <= 0 {
+		cfg.Slots = 1
+	}
+	return &Auction{cfg: cfg, intN: intN}
+}
+
+// SetFloors adds src's floors to the configured ones; the higher wins.
+func (a *Auction) SetFloors(src FloorSource) { a.floors = src }
+
+// Floor is the minimum bid in app.
+func (a *Auction) Floor(app string) float64 {
+	f, ok := a.cfg.Floors[app]
+	if !ok {
+		f = a.cfg.Floor
+	}
+	if a.floors != nil {
+		f = max(f, a.floors.Floor(app))
+	}
+	return f
+}
+
+// Run returns the winners among cs for req.Slots slots (the configured
+// default when zero), highest bid first, with ClearingPrice set. Bids below
+// the app's floor do not take part. Winners are competitively separated: a
+// bid that shares an advertiser or an exclusion group with a higher winner
+// is passed over. In a second-price auction each winner pays the next bid
+// that could have taken its slot plus the increment, or the floor when no
+// bid is left, but never more than its own bid.
+func (a *Auction) Run(req engine.MatchRequest, cs []engine.Campaign) []engine.Campaign {
+	if a == nil {
+		out := slices.Clone(cs)
+		for i := range out {
+			out[i].ClearingPrice = out[i].Price
+		}
+		return out
+	}
+	if len(cs) == 0 {
+		return nil
+	}
+
+	floor := a.Floor(req.AppID)
+	bids := make([]engine.Campaign, 0, len(cs))
+	for _, c := range cs {
+		if c.Price >= floor {
+			bids = append(bids, c)
+		}
+	}
+	if len(bids) == 0 {
+		observability.Auctions.WithLabelValues("below_floor").Inc()
+		return nil
+	}
+	// shuffle, then sort stably: equal bids end up in random order
+	for i := len(bids) - 1; i > 0; i-- {
+		j := a.intN(i + 1)
+		bids[i], bids[j] = bids[j], bids[i]
+	}
+	slices.SortStableFunc(bids, func(x, y engine.Campaign) int {
+		switch {
+		case x.Price > y.Price:
+			return -1
+		case x.Price < y.Price:
+			return 1
+		}
+		return 0
+	})
+
+	slots := req.Slots
+	if slots <= 0 {
+		slots = a.cfg.Slots
+	}
+	var winners []engine.Campaign
+	for i := 0; i < len(bids) && len(winners) < slots; i++ {
+		if a.conflicts(bids[i], winners) {
+			continue
+		}
+		w := bids[i]
+		w.ClearingPrice = w.Price
+		if a.cfg.Type == SecondPrice {
+			next := floor
+			for _, b := range bids[i+1:] {
+				if !a.conflicts(b, winners) {
+					next = b.Price + a.cfg.Increment
+					break
+				}
+			}
+			w.ClearingPrice = min(w.Price, next)
+		}
+		winners = append(winners, w)
+	}
+	observability.Auctions.WithLabelValues("filled").Inc()
+	return winners
+}
+
+// conflicts reports whether c may not serve next to any of winners.
+func (a *Auction) conflicts(c engine.Campaign, winners []engine.Campaign) bool {
+	for _, w := range winners {
+		if c.Advertiser != "" && c.Advertiser == w.Advertiser {
+			return true
+		}
+		if c.Category == "" || w.Category == "" {
+			continue
+		}
+		for _, g := range a.cfg.Exclusions {
+			if inGroup(c.Category, g) && inGroup(w.Category, g) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// inGroup reports whether category is in g, directly or through its tier-1
+// parent.
+func inGroup(category string, g []string) bool {
+	tier1, _, _ := strings.Cut(category, "-")
+	return slices.Contains(g, category) || slices.Contains(g, tier1)
+}
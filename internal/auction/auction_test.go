@@ -0,0 +1,187 @@
+package auction
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"ad-targeting-engine/internal/engine"
+)
+
+// keep is a tie-break RNG that leaves bids in the order they matched.
+func keep(n int) int { return n - 1 }
+
+type result struct {
+	ID    string
+	Price float64
+}
+
+func results(cs []engine.Campaign) []result {
+	out := make([]result, 0, len(cs))
+	for _, c := range cs {
+		out = append(out, result{c.ID, c.ClearingPrice})
+	}
+	return out
+}
+
+func bids(prices ...float64) []engine.Campaign {
+	cs := make([]engine.Campaign, len(prices))
+	for i, p := range prices {
+		cs[i] = engine.Campaign{ID: string(rune('a' + i)), Price: p}
+	}
+	return cs
+}
+
+func TestRun(t *testing.T) {
+	second := Config{Type: SecondPrice, Increment: 0.01}
+	tests := []struct {
+		name string
+		cfg  Config
+		req  engine.MatchRequest
+		bids []engine.Campaign
+		want []result
+	}{
+		{
+			name: "second price clears at the runner-up plus the increment",
+			cfg:  second,
+			bids: bids(1, 3, 2),
+			want: []result{{"b", 2.01}},
+		},
+		{
+			name: "first price clears at the bid",
+			cfg:  Config{Type: FirstPrice, Increment: 0.01},
+			bids: bids(1, 3, 2),
+			want: []result{{"b", 3}},
+		},
+		{
+			name: "never above the winner's own bid",
+			cfg:  second,
+			bids: bids(2, 2),
+			want: []result{{"a", 2}},
+		},
+		{
+			name: "a single bid clears at the floor",
+			cfg:  Config{Type: SecondPrice, Increment: 0.01, Floor: 0.5},
+			bids: bids(3),
+			want: []result{{"a", 0.5}},
+		},
+		{
+			name: "bids below the floor do not take part",
+			cfg:  Config{Type: SecondPrice, Increment: 0.01, Floor: 1.5},
+			bids: bids(1, 3, 2),
+			want: []result{{"b", 2.01}},
+		},
+		{
+			name: "app floors override the default",
+			cfg:  Config{Type: SecondPrice, Increment: 0.01, Floor: 1.5, Floors: map[string]float64{"com.x": 2.5}},
+			req:  engine.MatchRequest{AppID: "com.x"},
+			bids: bids(1, 3, 2),
+			want: []result{{"b", 2.5}},
+		},
+		{
+			name: "no bid reaches the floor",
+			cfg:  Config{Type: SecondPrice, Floor: 5},
+			bids: bids(1, 3),
+			want: []result{},
+		},
+		{
+			name: "requested slots",
+			cfg:  second,
+			req:  engine.MatchRequest{Slots: 2},
+			bids: bids(1, 3, 2),
+			want: []result{{"b", 2.01}, {"c", 1.01}},
+		},
+		{
+			name: "default slots",
+			cfg:  Config{Type: SecondPrice, Increment: 0.01, Slots: 2},
+			bids: bids(1, 3, 2),
+			want: []result{{"b", 2.01}, {"c", 1.01}},
+		},
+		{
+			name: "more slots than bids",
+			cfg:  Config{Type: SecondPrice, Increment: 0.01, Floor: 0.2},
+			req:  engine.MatchRequest{Slots: 5},
+			bids: bids(1, 3),
+			want: []result{{"b", 1.01}, {"a", 0.2}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := New(tt.cfg, keep).Run(tt.req, tt.bids)
+			assert.Equal(t, tt.want, results(got))
+		})
+	}
+}
+
+// owned sets the advertiser and category of bids, in order.
+func owned(cs []engine.Campaign, owners ...[2]string) []engine.Campaign {
+	for i, o := range owners {
+		cs[i].Advertiser, cs[i].Category = o[0], o[1]
+	}
+	return cs
+}
+
+func TestRun_Separation(t *testing.T) {
+	cfg := Config{Type: SecondPrice, Increment: 0.01, Slots: 3, Exclusions: [][]string{{"IAB2"}, {"IAB8-5", "IAB8-18"}}}
+	tests := []struct {
+		name string
+		bids []engine.Campaign
+		want []result
+	}{
+		{
+			// a still pays for b, which would have won without it
+			name: "one campaign per advertiser",
+			bids: owned(bids(3, 2, 1), [2]string{"acme", ""}, [2]string{"acme", ""}, [2]string{"globex", ""}),
+			want: []result{{"a", 2.01}, {"c", 0}},
+		},
+		{
+			name: "a tier-1 group excludes its subcategories",
+			bids: owned(bids(3, 2, 1), [2]string{"acme", "IAB2-1"}, [2]string{"globex", "IAB2-7"}, [2]string{"initech", "IAB1"}),
+			want: []result{{"a", 2.01}, {"c", 0}},
+		},
+		{
+			name: "categories in one group",
+			bids: owned(bids(3, 2, 1), [2]string{"", "IAB8-5"}, [2]string{"", "IAB8-18"}, [2]string{"", "IAB8-9"}),
+			want: []result{{"a", 2.01}, {"c", 0}},
+		},
+		{
+			name: "unrelated campaigns",
+			bids: owned(bids(3, 2, 1), [2]string{"acme", "IAB1"}, [2]string{"globex", "IAB1"}, [2]string{"", ""}),
+			want: []result{{"a", 2.01}, {"b", 1.01}, {"c", 0}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, results(New(cfg, keep).Run(engine.MatchRequest{}, tt.bids)))
+		})
+	}
+}
+
+func TestRun_TieBreak(t *testing.T) {
+	a := New(Config{Type: FirstPrice}, keep)
+	assert.Equal(t, "a", a.Run(engine.MatchRequest{}, bids(2, 2))[0].ID)
+
+	// swapping the last bid with the first puts b ahead
+	a = New(Config{Type: FirstPrice}, func(int) int { return 0 })
+	assert.Equal(t, "b", a.Run(engine.MatchRequest{}, bids(2, 2))[0].ID)
+}
+
+func TestRun_Nil(t *testing.T) {
+	var a *Auction
+	cs := bids(1, 3)
+	got := a.Run(engine.MatchRequest{Slots: 1}, cs)
+	assert.Equal(t, []result{{"a", 1}, {"b", 3}}, results(got), "every match wins at its bid")
+	assert.Zero(t, cs[0].ClearingPrice, "the matches are not modified")
+}
+
+type floors map[string]float64
+
+func (f floors) Floor(app string) float64 { return f[app] }
+
+func TestFloor_Source(t *testing.T) {
+	a := New(Config{Floor: 1, Floors: map[string]float64{"com.x": 2}}, keep)
+	a.SetFloors(floors{"com.x": 1.5, "com.y": 3})
+	assert.Equal(t, 2.0, a.Floor("com.x"), "the config floor is higher")
+	assert.Equal(t, 3.0, a.Floor("com.y"), "the source floor is higher")
+	assert.Equal(t, 1.0, a.Floor("com.z"))
+}
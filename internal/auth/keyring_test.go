@@ -0,0 +1,137 @@
+package auth
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"ad-targeting-engine/internal/observability"
+	"ad-targeting-engine/internal/storage"
+)
+
+func TestBucket(t *testing.T) {
+	now := time.Unix(0, 0)
+	b := newBucket(limit{rate: 2, burst: 3}, now)
+
+	for i := 0; i < 3; i++ {
+		_, ok := b.take(now)
+		require.True(t, ok, "burst token %d", i)
+	}
+	wait, ok := b.take(now)
+	assert.False(t, ok)
+	assert.Equal(t, 500*time.Millisecond, wait)
+
+	_, ok = b.take(now.Add(500 * time.Millisecond))
+	assert.True(t, ok, "refilled at rate")
+	_, ok = b.take(now.Add(time.Hour))
+	assert.True(t, ok)
+	assert.Equal(t, 2.0, b.tokens, "refill is capped at burst")
+
+	assert.Nil(t, newBucket(limit{}, now), "rate 0 is unlimited")
+	assert.Equal(t, 1.0, newBucket(limit{rate: 0.5}, now).burst, "zero burst allows at least one request")
+}
+
+func TestKeyring_Load(t *testing.T) {
+	k := NewKeyring("X-API-Key")
+	rows := []storage.APIKeyRow{
+		{ID: "a", Name: "A", Hash: HashKey("key-a"), Scopes: []string{"delivery", "bogus"}, RatePerSecond: 1, Burst: 1},
+		{ID: "b", Name: "B", Hash: HashKey("key-b"), Scopes: []string{"admin"}},
+	}
+	k.Load(rows)
+
+	a, ok := k.Lookup("key-a")
+	require.True(t, ok)
+	assert.Equal(t, []Scope{ScopeDelivery}, a.Scopes)
+	assert.True(t, a.Has(ScopeDelivery))
+	assert.False(t, a.Has(ScopeAdmin))
+	_, ok = k.Lookup("key-c")
+	assert.False(t, ok)
+	_, ok = k.Lookup("")
+	assert.False(t, ok)
+
+	_, ok = a.bucket.take(k.now())
+	require.True(t, ok)
+	k.Load(rows)
+	a2, _ := k.Lookup("key-a")
+	assert.Same(t, a.bucket, a2.bucket, "unchanged limits keep their bucket")
+
+	rows[0].RatePerSecond = 5
+	k.Load(rows[:1])
+	a3, _ := k.Lookup("key-a")
+	assert.NotSame(t, a.bucket, a3.bucket, "changed limits get a fresh bucket")
+	_, ok = k.Lookup("key-b")
+	assert.False(t, ok, "removed keys stop working")
+}
+
+func TestKeyring_ReloadOn(t *testing.T) {
+	st := storage.NewMemoryStore()
+	k := NewKeyring("X-API-Key")
+	swaps := make(chan storage.SnapshotMeta)
+	done := make(chan struct{})
+	go func() {
+		k.ReloadOn(context.Background(), swaps, st)
+		close(done)
+	}()
+
+	key, row, err := NewKey("ci", []Scope{ScopeDelivery}, 0, 0)
+	require.NoError(t, err)
+	require.NoError(t, st.CreateAPIKey(context.Background(), row))
+	_, ok := k.Lookup(key)
+	assert.False(t, ok, "new keys wait for the next snapshot")
+
+	swaps <- storage.SnapshotMeta{Version: 1}
+	close(swaps)
+	<-done
+	got, ok := k.Lookup(key)
+	require.True(t, ok)
+	assert.Equal(t, row.ID, got.ID)
+}
+
+func TestRequire(t *testing.T) {
+	k := NewKeyring("X-API-Key")
+	now := time.Unix(100, 0)
+	k.now = func() time.Time { return now }
+	k.Load([]storage.APIKeyRow{
+		{ID: "limited", Name: "L", Hash: HashKey("limited"), Scopes: []string{"delivery"}, RatePerSecond: 0.5, Burst: 1},
+		{ID: "admin", Name: "A", Hash: HashKey("admin"), Scopes: []string{"admin"}},
+	})
+	var seen *Key
+	h := k.Require(ScopeDelivery)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		seen, _ = FromContext(r.Context())
+	}))
+	do := func(key string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", "/v1/delivery", nil)
+		if key != "" {
+			req.Header.Set("X-API-Key", key)
+		}
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, req)
+		return w
+	}
+	limited := func() float64 {
+		return testutil.ToFloat64(observability.APIKeyRequests.WithLabelValues("limited", OutcomeRateLimited))
+	}
+	before := limited()
+
+	assert.Equal(t, http.StatusUnauthorized, do("").Code)
+	assert.Equal(t, http.StatusUnauthorized, do("nope").Code)
+	assert.Equal(t, http.StatusForbidden, do("admin").Code)
+
+	require.Equal(t, http.StatusOK, do("limited").Code)
+	require.NotNil(t, seen)
+	assert.Equal(t, "limited", seen.ID)
+
+	w := do("limited")
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+	assert.Equal(t, "2", w.Header().Get("Retry-After"))
+	assert.Equal(t, before+1, limited())
+
+	now = now.Add(2 * time.Second)
+	assert.Equal(t, http.StatusOK, do("limited").Code)
+}
@@ -0,0 +1,69 @@
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"math"
+	"net/http"
+	"strconv"
+
+	"ad-targeting-engine/internal/observability"
+)
+
+// Outcomes of an authenticated request, as counted in APIKeyRequests.
+const (
+	OutcomeAllowed     = "allowed"
+	OutcomeForbidden   = "forbidden"
+	OutcomeRateLimited = "rate_limited"
+)
+
+type ctxKey struct{}
+
+// WithKey returns ctx carrying the authenticated key.
+func WithKey(ctx context.Context, k *Key) context.Context {
+	return context.WithValue(ctx, ctxKey{}, k)
+}
+
+// FromContext returns the key Require authenticated, if any.
+func FromContext(ctx context.Context) (*Key, bool) {
+	k, ok := ctx.Value(ctxKey{}).(*Key)
+	return k, ok
+}
+
+// Require lets a request through only with a key in k.Header that grants
+// scope and is within its rate: 401 without a valid key, 403 without the
+// scope and 429 with Retry-After when over the limit. The key is stored in
+// the request context.
+func (k *Keyring) Require(scope Scope) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			key, ok := k.Lookup(r.Header.Get(k.Header))
+			if !ok {
+				observability.RequestErrors.WithLabelValues("unauthorized").Inc()
+				writeError(w, http.StatusUnauthorized, "unauthorized")
+				return
+			}
+			if !key.Has(scope) {
+				observability.APIKeyRequests.WithLabelValues(key.ID, OutcomeForbidden).Inc()
+				writeError(w, http.StatusForbidden, "api key lacks the "+string(scope)+" scope")
+				return
+			}
+			if key.bucket != nil {
+				if wait, ok := key.bucket.take(k.now()); !ok {
+					observability.APIKeyRequests.WithLabelValues(key.ID, OutcomeRateLimited).Inc()
+					w.Header().Set("Retry-After", strconv.Itoa(int(math.Max(1, math.Ceil(wait.Seconds())))))
+					writeError(w, http.StatusTooManyRequests, "rate limit exceeded")
+					return
+				}
+			}
+			observability.APIKeyRequests.WithLabelValues(key.ID, OutcomeAllowed).Inc()
+			next.ServeHTTP(w, r.WithContext(WithKey(r.Context(), key)))
+		})
+	}
+}
+
+func writeError(w http.ResponseWriter, status int, msg string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(map[string]string{"error": msg})
+}
@@ -0,0 +1,211 @@
+// Package budget enforces campaign caps and pacing. Impressions are counted
+// with atomic counters on the tracking path and reconciled periodically with
+// campaign_spend, where every node keeps its own running totals.
+package budget
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/rs/zerolog/log"
+
+	"ad-targeting-engine/internal/events"
+	"ad-targeting-engine/internal/observability"
+	"ad-targeting-engine/internal/storage"
+)
+
+const day = 24 * time.Hour
+
+// paceAhead is how far even pacing may run ahead of the clock, so that a
+// campaign can start delivering right after midnight.
+const paceAhead = 15 * time.Minute
+
+type key struct {
+	day        time.Time
+	campaignID string
+}
+
+type counter struct {
+	impressions atomic.Int64
+	micros      atomic.Int64
+	flushed     storage.Spend // as of the last reconcile; guarded by Pacer.mu
+}
+
+func (c *counter) load() storage.Spend {
+	return storage.Spend{Impressions: c.impressions.Load(), Micros: c.micros.Load()}
+}
+
+// Pacer is an events.Sink counting impressions and their cost, and an
+// engine.Limiter holding back campaigns that reached a cap or are ahead of
+// even pacing. It sees other nodes' delivery as of the last Reconcile, so
+// a fleet can overshoot a cap by what it serves in one interval.
+type Pacer struct {
+	st     storage.SpendStore
+	source string
+	now    func() time.Time
+
+	mu        sync.RWMutex
+	own       map[key]*counter
+	totals    map[string]storage.SpendTotals // all sources, as of the last reconcile
+	totalsDay time.Time                      // the day of totals[].Day
+}
+
+// New returns a pacer reconciling with st. source names this process in
+// the table; it must differ between nodes and between restarts.
+func New(st storage.SpendStore, source string) *Pacer {
+	return &Pacer{st: st, source: source, now: time.Now, own: map[key]*counter{}, totals: map[string]storage.SpendTotals{}}
+}
+
+// Record counts impressions at the price they were served at.
+func (p *Pacer) Record(_ context.Context, e events.Event) error {
+	if e.Type != events.Impression || e.CampaignID == "" {
+		return nil
+	}
+	c := p.counter(key{day: p.now().UTC().Truncate(day), campaignID: e.CampaignID})
+	c.impressions.Add(1)
+	c.micros.Add(int64(math.Round(e.Price * 1000))) // CPM in USD to micros per impression
+	return nil
+}
+
+func (p *Pacer) counter(k key) *counter {
+	p.mu.RLock()
+	c := p.own[k]
+	p.mu.RUnlock()
+	if c != nil {
+		return c
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if c = p.own[k]; c == nil {
+		c = &counter{}
+		p.own[k] = c
+	}
+	return c
+}
+
+// Allow reports whether campaign id may serve now under b.
+func (p *Pacer) Allow(id string, b storage.Budget) bool {
+	if !b.Capped() {
+		return true
+	}
+	now := p.now().UTC()
+	today := now.Truncate(day)
+
+	p.mu.RLock()
+	t := p.totals[id]
+	if !p.totalsDay.Equal(today) {
+		t.Day = storage.Spend{}
+	}
+	if c := p.own[key{day: today, campaignID: id}]; c != nil {
+		unflushed := c.load().Sub(c.flushed)
+		t.Day, t.Lifetime = t.Day.Add(unflushed), t.Lifetime.Add(unflushed)
+	}
+	p.mu.RUnlock()
+
+	var reason string
+	switch {
+	case over(t.Lifetime, b.LifetimeImpressions, b.LifetimeSpend, 1):
+		reason = "lifetime_cap"
+	case over(t.Day, b.DailyImpressions, b.DailySpend, 1):
+		reason = "daily_cap"
+	case b.Pacing == storage.PacingEven && over(t.Day, b.DailyImpressions, b.DailySpend, pace(now.Sub(today))):
+		reason = "pacing"
+	default:
+		return true
+	}
+	observability.BudgetThrottled.WithLabelValues(reason).Inc()
+	return false
+}
+
+// over reports whether s reached share of the caps; zero caps are
+// unlimited.
+func over(s storage.Spend, impressions int64, spend, share float64) bool {
+	return (impressions > 0 && float64(s.Impressions) >= float64(impressions)*share) ||
+		(spend > 0 && float64(s.Micros) >= spend*1e6*share)
+}
+
+// pace is the share of the daily caps even pacing allows once elapsed of
+// the day has gone by.
+func pace(elapsed time.Duration) float64 {
+	return min(1, float64(elapsed+paceAhead)/float64(day))
+}
+
+// Reconcile upserts this node's totals and reloads everyone's. Counters of
+// past days are dropped once they are written.
+func (p *Pacer) Reconcile(ctx context.Context) error {
+	p.mu.RLock()
+	var rows []storage.SpendRow
+	for k, c := range p.own {
+		if s := c.load(); s != c.flushed {
+			rows = append(rows, storage.SpendRow{Day: k.day, CampaignID: k.campaignID, Source: p.source, Spend: s})
+		}
+	}
+	p.mu.RUnlock()
+
+	if err := p.st.UpsertSpend(ctx, rows); err != nil {
+		observability.SpendReconciles.WithLabelValues("error").Inc()
+		return fmt.Errorf("reconcile spend: %w", err)
+	}
+	// after the upsert, so the totals include every row just written
+	today := p.now().UTC().Truncate(day)
+	totals, err := p.st.LoadSpend(ctx, today)
+	if err != nil {
+		observability.SpendReconciles.WithLabelValues("error").Inc()
+		return fmt.Errorf("reconcile spend: %w", err)
+	}
+	observability.SpendReconciles.WithLabelValues("ok").Inc()
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for _, r := range rows {
+		if c := p.own[key{day: r.Day, campaignID: r.CampaignID}]; c != nil {
+			c.flushed = r.Spend
+		}
+	}
+	for k, c := range p.own {
+		if k.day.Before(today) && c.load() == c.flushed {
+			delete(p.own, k)
+		}
+	}
+	p.totals, p.totalsDay = totals, today
+	return nil
+}
+
+// Run reconciles every interval until ctx is done. Close does the last
+// reconcile.
+func (p *Pacer) Run(ctx context.Context, every time.Duration) {
+	t := time.NewTicker(every)
+	defer t.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+			if err := p.Reconcile(ctx); err != nil {
+				log.Error().Err(err).Msg("spend reconcile")
+			}
+		}
+	}
+}
+
+// Close writes out what is left, for shutdown.
+func (p *Pacer) Close(ctx context.Context) error {
+	return p.Reconcile(ctx)
+}
+
+// Unmetered is an engine.Limiter for nodes that cannot see spend, such as
+// followers, which have no database: it holds back every capped campaign,
+// since nothing there would stop it at its cap.
+type Unmetered struct{}
+
+func (Unmetered) Allow(_ string, b storage.Budget) bool {
+	if !b.Capped() {
+		return true
+	}
+	observability.BudgetThrottled.WithLabelValues("unmetered").Inc()
+	return false
+}
@@ -0,0 +1,110 @@
+package budget
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"ad-targeting-engine/internal/engine"
+	"ad-targeting-engine/internal/events"
+	"ad-targeting-engine/internal/storage"
+)
+
+func impression(id string, price float64) events.Event {
+	return events.Event{Type: events.Impression, CampaignID: id, Price: price}
+}
+
+func TestPacer_Caps(t *testing.T) {
+	ctx := context.Background()
+	st := storage.NewMemoryStore()
+	now := time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC)
+	p := New(st, "n1")
+	p.now = func() time.Time { return now }
+
+	daily := storage.Budget{DailyImpressions: 3}
+	assert.True(t, p.Allow("a", storage.Budget{}), "uncapped")
+	for range 2 {
+		require.NoError(t, p.Record(ctx, impression("a", 2)))
+	}
+	assert.True(t, p.Allow("a", daily))
+	require.NoError(t, p.Record(ctx, impression("a", 2)))
+	assert.False(t, p.Allow("a", daily), "the local count reaches the cap before any reconcile")
+	assert.True(t, p.Allow("b", daily), "caps are per campaign")
+	assert.False(t, p.Allow("a", storage.Budget{DailySpend: 0.006}), "3 impressions at a CPM of 2 cost $0.006")
+	assert.True(t, p.Allow("a", storage.Budget{DailySpend: 0.007}))
+
+	// another node delivered too
+	require.NoError(t, st.UpsertSpend(ctx, []storage.SpendRow{
+		{Day: now.Truncate(day), CampaignID: "b", Source: "n2", Spend: storage.Spend{Impressions: 5}},
+	}))
+	require.NoError(t, p.Reconcile(ctx))
+	require.NoError(t, p.Reconcile(ctx), "reconciling again does not double count")
+	assert.False(t, p.Allow("a", daily))
+	assert.False(t, p.Allow("b", storage.Budget{DailyImpressions: 5}), "other nodes count after a reconcile")
+
+	now = now.Add(day)
+	assert.True(t, p.Allow("a", daily), "daily caps reset at midnight UTC")
+	assert.False(t, p.Allow("a", storage.Budget{LifetimeImpressions: 3}), "lifetime caps do not")
+	require.NoError(t, p.Record(ctx, impression("a", 2)))
+	require.NoError(t, p.Reconcile(ctx))
+	assert.Len(t, p.own, 1, "yesterday's counters are dropped once written")
+
+	totals, err := st.LoadSpend(ctx, now)
+	require.NoError(t, err)
+	assert.Equal(t, storage.SpendTotals{Day: storage.Spend{Impressions: 1, Micros: 2000},
+		Lifetime: storage.Spend{Impressions: 4, Micros: 8000}}, totals["a"])
+}
+
+func TestPacer_EvenPacing(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2026, 10, 18, 0, 0, 0, 0, time.UTC)
+	p := New(storage.NewMemoryStore(), "n1")
+	p.now = func() time.Time { return now }
+	b := storage.Budget{DailyImpressions: 96, Pacing: storage.PacingEven}
+
+	// at midnight the first 15 minutes' share is available
+	assert.True(t, p.Allow("a", b))
+	require.NoError(t, p.Record(ctx, impression("a", 1)))
+	assert.False(t, p.Allow("a", b), "1 of 96 is a 15 minute share")
+
+	now = now.Add(6 * time.Hour)
+	assert.True(t, p.Allow("a", b))
+	for range 24 {
+		require.NoError(t, p.Record(ctx, impression("a", 1)))
+	}
+	assert.False(t, p.Allow("a", b), "25 of 96 by 06:00")
+	assert.True(t, p.Allow("a", storage.Budget{DailyImpressions: 96}), "asap pacing only stops at the cap")
+}
+
+func TestUnmetered(t *testing.T) {
+	var u Unmetered
+	assert.True(t, u.Allow("a", storage.Budget{}))
+	assert.True(t, u.Allow("a", storage.Budget{Pacing: storage.PacingEven}), "pacing alone is no cap")
+	assert.False(t, u.Allow("a", storage.Budget{DailyImpressions: 1000}))
+	assert.False(t, u.Allow("a", storage.Budget{LifetimeSpend: 50}))
+}
+
+func TestUnmetered_Follower(t *testing.T) {
+	ctx := context.Background()
+	st := storage.NewMemoryStore(
+		storage.CampaignRow{ID: "capped", Name: "Capped", Status: "ACTIVE", Budget: storage.Budget{DailyImpressions: 100}},
+		storage.CampaignRow{ID: "open", Name: "Open", Status: "ACTIVE"},
+	)
+	builder := engine.NewEngine()
+	require.NoError(t, builder.BuildSnapshot(ctx, st))
+	data, _, err := builder.EncodedSnapshot()
+	require.NoError(t, err)
+
+	follower := engine.NewEngine()
+	_, err = follower.LoadEncoded(data)
+	require.NoError(t, err)
+	follower.SetLimiter(Unmetered{})
+	req := engine.MatchRequest{AppID: "com.abc.xyz", Country: "US", OS: "ios"}
+	assert.Len(t, builder.Match(ctx, req), 2)
+	got := follower.Match(ctx, req)
+	require.Len(t, got, 1, "the capped campaign is held back once its budget crosses the wire")
+	assert.Equal(t, "open", got[0].ID)
+}
@@ -15,6 +15,13 @@ type Config struct {
 		LogLevel string `mapstructure:"log_level"`
 	} `mapstructure:"server"`
 
+	// GRPC serves DeliveryService on its own port; an empty addr disables it.
+	GRPC struct {
+		Addr          string `mapstructure:"addr"`
+		TimeoutMillis int    `mapstructure:"timeout_millis"`
+		MaxBatch      int    `mapstructure:"max_batch"`
+	} `mapstructure:"grpc"`
+
 	Postgres struct {
 		Host         string `mapstructure:"host"`
 		Port         int    `mapstructure:"port"`
@@ -24,12 +31,114 @@ type Config struct {
 		SSLMode      string `mapstructure:"ssl_mode"`
 		MaxOpenConns int    `mapstructure:"max_open_conns"`
 		MaxIdleConns int    `mapstructure:"max_idle_conns"`
+
+		// Replicas are full DSNs of read replicas used for snapshot loads.
+		Replicas                 []string `mapstructure:"replicas"`
+		MaxReplicationLagSeconds int      `mapstructure:"max_replication_lag_seconds"`
+		ReplicaCheckSeconds      int      `mapstructure:"replica_check_seconds"`
+
+		// LoadPageSize is how many campaigns a snapshot load reads per query.
+		LoadPageSize int `mapstructure:"load_page_size"`
+
+		Breaker struct {
+			FailureThreshold int `mapstructure:"failure_threshold"`
+			OpenSeconds      int `mapstructure:"open_seconds"`
+		} `mapstructure:"breaker"`
 	} `mapstructure:"postgres"`
 
 	Listener struct {
-		Channel          string `mapstructure:"channel"`
-		ReconnectSeconds int    `mapstructure:"reconnect_seconds"`
+		Channel              string `mapstructure:"channel"`
+		ReconnectSeconds     int    `mapstructure:"reconnect_seconds"`
+		ResyncSeconds        int    `mapstructure:"resync_seconds"`
+		OutboxRetentionHours int    `mapstructure:"outbox_retention_hours"`
 	} `mapstructure:"listener"`
+
+	Admin struct {
+		Token string `mapstructure:"token"`
+	} `mapstructure:"admin"`
+
+	// Auth requires API keys from the api_keys table on delivery, admin
+	// and metrics routes.
+	Auth struct {
+		Enabled bool   `mapstructure:"enabled"`
+		Header  string `mapstructure:"header"`
+	} `mapstructure:"auth"`
+
+	// Distribution ships snapshots from builder nodes to followers.
+	Distribution struct {
+		Token           string `mapstructure:"token"`
+		FollowURL       string `mapstructure:"follow_url"`
+		MaxAgeSeconds   int    `mapstructure:"max_age_seconds"`
+		PollWaitSeconds int    `mapstructure:"poll_wait_seconds"`
+	} `mapstructure:"distribution"`
+
+	Tracking struct {
+		// BaseURL is the scheme and host tracking links point at; empty
+		// uses the host of the delivery request.
+		BaseURL string `mapstructure:"base_url"`
+
+		// Keys sign tracking links. The first signs, all verify, so a key
+		// is rotated by putting its successor first. Without keys each
+		// node signs with a random key of its own.
+		Keys           []TrackingKey `mapstructure:"keys"`
+		LinkTTLSeconds int           `mapstructure:"link_ttl_seconds"`
+	} `mapstructure:"tracking"`
+
+	// Events is where tracking events and sampled delivery decisions go:
+	// sink "log" logs them, "file" batches them into rotating NDJSON files.
+	Events struct {
+		Sink          string `mapstructure:"sink"`
+		Dir           string `mapstructure:"dir"`
+		MaxFileMB     int    `mapstructure:"max_file_mb"`
+		RotateMinutes int    `mapstructure:"rotate_minutes"`
+		QueueSize     int    `mapstructure:"queue_size"`
+		BatchSize     int    `mapstructure:"batch_size"`
+		FlushMillis   int    `mapstructure:"flush_millis"`
+		BlockMillis   int    `mapstructure:"block_millis"`
+
+		// DecisionSampleRate is the fraction of delivery requests, 0 to 1,
+		// recorded as decision events.
+		DecisionSampleRate float64 `mapstructure:"decision_sample_rate"`
+	} `mapstructure:"events"`
+
+	// Reports counts matches, impressions and clicks into
+	// campaign_stats_hourly, flushing every flush_seconds.
+	Reports struct {
+		FlushSeconds int `mapstructure:"flush_seconds"`
+	} `mapstructure:"reports"`
+
+	// Budgets reconciles campaign spend with campaign_spend every
+	// reconcile_seconds; caps can be overshot by what the fleet serves in
+	// between.
+	Budgets struct {
+		ReconcileSeconds int `mapstructure:"reconcile_seconds"`
+	} `mapstructure:"budgets"`
+
+	// Auction picks the winners among matching campaigns: type is
+	// "first_price" or "second_price", prices are CPMs in USD, floors
+	// override floor for single apps, and exclusions are groups of IAB
+	// categories that never serve side by side.
+	Auction struct {
+		Type      string         `mapstructure:"type"`
+		Increment float64        `mapstructure:"increment"`
+		Slots     int            `mapstructure:"slots"`
+		Floor     float64        `mapstructure:"floor"`
+		Floors    []AuctionFloor `mapstructure:"floors"`
+
+		Exclusions [][]string `mapstructure:"exclusions"`
+	} `mapstructure:"auction"`
+}
+
+type TrackingKey struct {
+	ID     string `mapstructure:"id"`
+	Secret string `mapstructure:"secret"`
+}
+
+// AuctionFloor is an app's floor price. Floors are a list, not a map keyed
+// by app, because viper splits keys on the dots of bundle IDs.
+type AuctionFloor struct {
+	App string  `mapstructure:"app"`
+	CPM float64 `mapstructure:"cpm"`
 }
 
 func Load() Config {
@@ -55,6 +164,15 @@ func validate(c *Config) {
 	if c.Server.Addr == "" {
 		c.Server.Addr = ":8080"
 	}
+	if c.GRPC.TimeoutMillis <= 0 {
+		c.GRPC.TimeoutMillis = 2000
+	}
+	if c.GRPC.MaxBatch <= 0 {
+		c.GRPC.MaxBatch = 1000
+	}
+	if c.Auth.Header == "" {
+		c.Auth.Header = "X-API-Key"
+	}
 	if c.Postgres.Port == 0 {
 		c.Postgres.Port = 5432
 	}
@@ -67,9 +185,70 @@ func validate(c *Config) {
 	if c.Postgres.MaxIdleConns == 0 {
 		c.Postgres.MaxIdleConns = 10
 	}
+	if c.Postgres.MaxReplicationLagSeconds <= 0 {
+		c.Postgres.MaxReplicationLagSeconds = 10
+	}
+	if c.Postgres.ReplicaCheckSeconds <= 0 {
+		c.Postgres.ReplicaCheckSeconds = 5
+	}
+	if c.Postgres.LoadPageSize <= 0 {
+		c.Postgres.LoadPageSize = 1000
+	}
+	if c.Postgres.Breaker.FailureThreshold <= 0 {
+		c.Postgres.Breaker.FailureThreshold = 5
+	}
+	if c.Postgres.Breaker.OpenSeconds <= 0 {
+		c.Postgres.Breaker.OpenSeconds = 30
+	}
 	if c.Listener.ReconnectSeconds <= 0 {
 		c.Listener.ReconnectSeconds = 5
 	}
+	if c.Listener.ResyncSeconds <= 0 {
+		c.Listener.ResyncSeconds = 60
+	}
+	if c.Listener.OutboxRetentionHours <= 0 {
+		c.Listener.OutboxRetentionHours = 24
+	}
+	if c.Distribution.MaxAgeSeconds <= 0 {
+		c.Distribution.MaxAgeSeconds = 120
+	}
+	if c.Distribution.PollWaitSeconds <= 0 {
+		c.Distribution.PollWaitSeconds = 30
+	}
+	if c.Tracking.LinkTTLSeconds <= 0 {
+		c.Tracking.LinkTTLSeconds = 3600
+	}
+	if c.Events.Sink == "" {
+		c.Events.Sink = "log"
+	}
+	if c.Events.Dir == "" {
+		c.Events.Dir = "events"
+	}
+	if c.Events.MaxFileMB <= 0 {
+		c.Events.MaxFileMB = 100
+	}
+	if c.Events.RotateMinutes <= 0 {
+		c.Events.RotateMinutes = 60
+	}
+	if c.Events.FlushMillis <= 0 {
+		c.Events.FlushMillis = 1000
+	}
+	c.Events.DecisionSampleRate = min(max(c.Events.DecisionSampleRate, 0), 1)
+	if c.Reports.FlushSeconds <= 0 {
+		c.Reports.FlushSeconds = 60
+	}
+	if c.Budgets.ReconcileSeconds <= 0 {
+		c.Budgets.ReconcileSeconds = 10
+	}
+	if c.Auction.Type == "" {
+		c.Auction.Type = "second_price"
+	}
+	if c.Auction.Increment <= 0 {
+		c.Auction.Increment = 0.01
+	}
+	if c.Auction.Slots <= 0 {
+		c.Auction.Slots = 1
+	}
 }
 
 func (c Config) DSN() string {
@@ -83,6 +262,62 @@ func (c Config) DSN() string {
 	)
 }
 
+func (c Config) MaxReplicationLag() time.Duration {
+	return time.Duration(c.Postgres.MaxReplicationLagSeconds) * time.Second
+}
+
+func (c Config) ReplicaCheckInterval() time.Duration {
+	return time.Duration(c.Postgres.ReplicaCheckSeconds) * time.Second
+}
+
+func (c Config) BreakerOpenFor() time.Duration {
+	return time.Duration(c.Postgres.Breaker.OpenSeconds) * time.Second
+}
+
 func (c Config) Backoff() time.Duration {
 	return time.Duration(c.Listener.ReconnectSeconds) * time.Second
 }
+
+func (c Config) Resync() time.Duration {
+	return time.Duration(c.Listener.ResyncSeconds) * time.Second
+}
+
+func (c Config) OutboxRetention() time.Duration {
+	return time.Duration(c.Listener.OutboxRetentionHours) * time.Hour
+}
+
+func (c Config) SnapshotMaxAge() time.Duration {
+	return time.Duration(c.Distribution.MaxAgeSeconds) * time.Second
+}
+
+func (c Config) PollWait() time.Duration {
+	return time.Duration(c.Distribution.PollWaitSeconds) * time.Second
+}
+
+func (c Config) GRPCTimeout() time.Duration {
+	return time.Duration(c.GRPC.TimeoutMillis) * time.Millisecond
+}
+
+func (c Config) LinkTTL() time.Duration {
+	return time.Duration(c.Tracking.LinkTTLSeconds) * time.Second
+}
+
+func (c Config) EventsRotate() time.Duration {
+	return time.Duration(c.Events.RotateMinutes) * time.Minute
+}
+
+func (c Config) EventsFlush() time.Duration {
+	return time.Duration(c.Events.FlushMillis) * time.Millisecond
+}
+
+func (c Config) EventsBlock() time.Duration {
+	return time.Duration(c.Events.BlockMillis) * time.Millisecond
+}
+
+func (c Config) ReportsFlush() time.Duration {
+	return time.Duration(c.Reports.FlushSeconds) * time.Second
+}
+
+func (c Config) BudgetsReconcile() time.Duration {
+	return time.Duration(c.Budgets.ReconcileSeconds) * time.Second
+}
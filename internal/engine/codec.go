@@ -0,0 +1,311 @@
+package engine
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"time"
+
+	"ad-targeting-engine/internal/storage"
+)
+
+// Wire format of an exported snapshot (integers big-endian):
+//
+//	magic "ATES" | format u16 | version u64 | built_at unix-nano u64 |
+//	content hash u64 | payload length u64 | sha256(payload) [32] | payload
+//
+// The payload is the gzip-compressed campaign table: a uvarint count, then
+// per campaign its strings, version, price, markup, creative, landing URL,
+// advertiser, category and budget, then its rules. The publisher settings
+// and the advertisers follow the same way. Indexes are derived data and are rebuilt by the receiver,
+// which is cheaper than shipping them.
+const (
+	snapshotMagic     = "ATES"
+	snapshotFormat    = 7
+	snapshotHeaderLen = 4 + 2 + 8 + 8 + 8 + 8 + sha256.Size
+
+	// maxWireString bounds any single string, so a corrupt length cannot
+	// trigger a huge allocation.
+	maxWireString = 1 << 20
+)
+
+// ErrBadSnapshot is returned for exports that fail format or checksum
+// validation.
+var ErrBadSnapshot = errors.New("bad snapshot encoding")
+
+// SnapshotContentType is the media type of encoded snapshots.
+const SnapshotContentType = "application/vnd.ad-targeting.snapshot"
+
+type encodedSnapshot struct {
+	meta storage.SnapshotMeta
+	data []byte
+}
+
+// EncodedSnapshot returns the current snapshot in wire form with its meta.
+// The encoding is computed once per snapshot version and reused for every
+// follower.
+func (e *DeliveryEngine) EncodedSnapshot() ([]byte, storage.SnapshotMeta, error) {
+	s, meta := e.snap.LoadMeta()
+	if c := e.encoded.Load(); c != nil && c.meta == meta {
+		return c.data, meta, nil
+	}
+	data, err := encodeSnapshot(s, meta)
+	if err != nil {
+		return nil, meta, err
+	}
+	e.encoded.Store(&encodedSnapshot{meta: meta, data: data})
+	return data, meta, nil
+}
+
+// LoadEncoded verifies an exported snapshot and swaps it in, keeping the
+// builder's version, build time and hash.
+func (e *DeliveryEngine) LoadEncoded(data []byte) (storage.SnapshotMeta, error) {
+	cs, pubs, advs, meta, err := decodeSnapshot(data)
+	if err != nil {
+		return meta, err
+	}
+	b := newIndexBuilder()
+	for _, c := range cs {
+		b.add(c)
+	}
+	s := snapshot{idx: b.ix, pubs: pubs, advs: advs}
+	if h := hashSnapshot(s); h != meta.Hash {
+		return meta, fmt.Errorf("%w: content hash %016x, header says %016x", ErrBadSnapshot, h, meta.Hash)
+	}
+	e.snap.StoreMeta(s, meta)
+	return meta, nil
+}
+
+func encodeSnapshot(s snapshot, meta storage.SnapshotMeta) ([]byte, error) {
+	var payload bytes.Buffer
+	zw := gzip.NewWriter(&payload)
+
+	cs := s.idx.Campaigns
+	buf := binary.AppendUvarint(nil, uint64(len(cs)))
+	for _, c := range cs {
+		for _, s := range []string{c.ID, c.Name, c.Image, c.CTA, c.Status} {
+			buf = appendString(buf, s)
+		}
+		buf = binary.AppendVarint(buf, c.Version)
+		buf = binary.BigEndian.AppendUint64(buf, math.Float64bits(c.Price))
+		buf = appendString(buf, c.Markup)
+		buf = appendString(buf, c.Creative)
+		buf = appendString(buf, c.Video.URL)
+		buf = appendString(buf, c.Video.MIME)
+		for _, n := range []int{c.Video.Duration, c.Video.Width, c.Video.Height} {
+			buf = binary.AppendVarint(buf, int64(n))
+		}
+		buf = appendString(buf, c.Landing)
+		buf = appendString(buf, c.Advertiser)
+		buf = appendString(buf, c.Category)
+		buf = binary.AppendVarint(buf, c.Budget.DailyImpressions)
+		buf = binary.AppendVarint(buf, c.Budget.LifetimeImpressions)
+		buf = binary.BigEndian.AppendUint64(buf, math.Float64bits(c.Budget.DailySpend))
+		buf = binary.BigEndian.AppendUint64(buf, math.Float64bits(c.Budget.LifetimeSpend))
+		buf = appendString(buf, c.Budget.Pacing)
+		buf = binary.AppendUvarint(buf, uint64(len(c.Rules)))
+		for _, r := range c.Rules {
+			buf = appendString(buf, r.Dimension)
+			if r.IsInclusion {
+				buf = append(buf, 1)
+			} else {
+				buf = append(buf, 0)
+			}
+			buf = appendStrings(buf, r.Values)
+		}
+		if _, err := zw.Write(buf); err != nil {
+			return nil, fmt.Errorf("compress snapshot: %w", err)
+		}
+		buf = buf[:0]
+	}
+	buf = binary.AppendUvarint(buf, uint64(len(s.pubs)))
+	for _, p := range s.pubs {
+		buf = appendString(buf, p.AppID)
+		buf = binary.AppendVarint(buf, p.Version)
+		buf = binary.BigEndian.AppendUint64(buf, math.Float64bits(p.Floor))
+		buf = appendStrings(buf, p.BlockedAdvertisers)
+		buf = appendStrings(buf, p.BlockedCategories)
+		buf = appendStrings(buf, p.BlockedCampaigns)
+	}
+	buf = binary.AppendUvarint(buf, uint64(len(s.advs)))
+	for _, a := range s.advs {
+		buf = appendString(buf, a.ID)
+		buf = binary.AppendVarint(buf, a.Version)
+		buf = appendString(buf, a.Status)
+		buf = appendStrings(buf, a.BlockedApps)
+	}
+	if _, err := zw.Write(buf); err != nil {
+		return nil, fmt.Errorf("compress snapshot: %w", err)
+	}
+	if err := zw.Close(); err != nil {
+		return nil, fmt.Errorf("compress snapshot: %w", err)
+	}
+
+	sum := sha256.Sum256(payload.Bytes())
+	out := make([]byte, 0, snapshotHeaderLen+payload.Len())
+	out = append(out, snapshotMagic...)
+	out = binary.BigEndian.AppendUint16(out, snapshotFormat)
+	out = binary.BigEndian.AppendUint64(out, meta.Version)
+	out = binary.BigEndian.AppendUint64(out, uint64(meta.BuiltAt.UnixNano()))
+	out = binary.BigEndian.AppendUint64(out, meta.Hash)
+	out = binary.BigEndian.AppendUint64(out, uint64(payload.Len()))
+	out = append(out, sum[:]...)
+	return append(out, payload.Bytes()...), nil
+}
+
+func appendString(buf []byte, s string) []byte {
+	buf = binary.AppendUvarint(buf, uint64(len(s)))
+	return append(buf, s...)
+}
+
+func appendStrings(buf []byte, ss []string) []byte {
+	buf = binary.AppendUvarint(buf, uint64(len(ss)))
+	for _, s := range ss {
+		buf = appendString(buf, s)
+	}
+	return buf
+}
+
+func decodeSnapshot(data []byte) ([]CampaignWithRules, map[string]Publisher, map[string]Advertiser, storage.SnapshotMeta, error) {
+	var meta storage.SnapshotMeta
+	if len(data) < snapshotHeaderLen || string(data[:4]) != snapshotMagic {
+		return nil, nil, nil, meta, fmt.Errorf("%w: not a snapshot", ErrBadSnapshot)
+	}
+	if f := binary.BigEndian.Uint16(data[4:]); f != snapshotFormat {
+		return nil, nil, nil, meta, fmt.Errorf("%w: unsupported format %d", ErrBadSnapshot, f)
+	}
+	meta.Version = binary.BigEndian.Uint64(data[6:])
+	meta.BuiltAt = time.Unix(0, int64(binary.BigEndian.Uint64(data[14:])))
+	meta.Hash = binary.BigEndian.Uint64(data[22:])
+	n := binary.BigEndian.Uint64(data[30:])
+	payload := data[snapshotHeaderLen:]
+	if uint64(len(payload)) != n {
+		return nil, nil, nil, meta, fmt.Errorf("%w: payload is %d bytes, header says %d", ErrBadSnapshot, len(payload), n)
+	}
+	if sum := sha256.Sum256(payload); !bytes.Equal(sum[:], data[38:snapshotHeaderLen]) {
+		return nil, nil, nil, meta, fmt.Errorf("%w: checksum mismatch", ErrBadSnapshot)
+	}
+
+	zr, err := gzip.NewReader(bytes.NewReader(payload))
+	if err != nil {
+		return nil, nil, nil, meta, fmt.Errorf("%w: %v", ErrBadSnapshot, err)
+	}
+	d := wireReader{r: bufio.NewReader(zr)}
+	count := d.count()
+	cs := make([]CampaignWithRules, 0, min(count, 1<<16))
+	for i := uint64(0); i < count && d.err == nil; i++ {
+		c := CampaignWithRules{ID: d.string(), Name: d.string(), Image: d.string(), CTA: d.string(), Status: d.string()}
+		c.Version = d.varint()
+		c.Price = math.Float64frombits(d.uint64())
+		c.Markup = d.string()
+		c.Creative = d.string()
+		c.Video.URL, c.Video.MIME = d.string(), d.string()
+		c.Video.Duration, c.Video.Width, c.Video.Height = int(d.varint()), int(d.varint()), int(d.varint())
+		c.Landing = d.string()
+		c.Advertiser, c.Category = d.string(), d.string()
+		c.Budget.DailyImpressions, c.Budget.LifetimeImpressions = d.varint(), d.varint()
+		c.Budget.DailySpend = math.Float64frombits(d.uint64())
+		c.Budget.LifetimeSpend = math.Float64frombits(d.uint64())
+		c.Budget.Pacing = d.string()
+		nr := d.count()
+		for j := uint64(0); j < nr && d.err == nil; j++ {
+			r := Rule{Dimension: d.string(), IsInclusion: d.byte() == 1}
+			r.Values = d.strings()
+			c.Rules = append(c.Rules, r)
+		}
+		cs = append(cs, c)
+	}
+	np := d.count()
+	pubs := make(map[string]Publisher, min(np, 1<<16))
+	for i := uint64(0); i < np && d.err == nil; i++ {
+		p := Publisher{AppID: d.string(), Version: d.varint(), Floor: math.Float64frombits(d.uint64())}
+		p.BlockedAdvertisers, p.BlockedCategories, p.BlockedCampaigns = d.strings(), d.strings(), d.strings()
+		pubs[p.AppID] = p
+	}
+	na := d.count()
+	advs := make(map[string]Advertiser, min(na, 1<<16))
+	for i := uint64(0); i < na && d.err == nil; i++ {
+		a := Advertiser{ID: d.string(), Version: d.varint(), Status: d.string()}
+		a.BlockedApps = d.strings()
+		advs[a.ID] = a
+	}
+	if d.err != nil {
+		return nil, nil, nil, meta, fmt.Errorf("%w: %v", ErrBadSnapshot, d.err)
+	}
+	return cs, pubs, advs, meta, nil
+}
+
+// wireReader decodes payload primitives, remembering the first error so
+// the decode loop can check once per item.
+type wireReader struct {
+	r   *bufio.Reader
+	err error
+}
+
+func (d *wireReader) count() uint64 {
+	if d.err != nil {
+		return 0
+	}
+	n, err := binary.ReadUvarint(d.r)
+	d.err = err
+	return n
+}
+
+func (d *wireReader) varint() int64 {
+	if d.err != nil {
+		return 0
+	}
+	n, err := binary.ReadVarint(d.r)
+	d.err = err
+	return n
+}
+
+func (d *wireReader) uint64() uint64 {
+	if d.err != nil {
+		return 0
+	}
+	var b [8]byte
+	_, d.err = io.ReadFull(d.r, b[:])
+	return binary.BigEndian.Uint64(b[:])
+}
+
+func (d *wireReader) byte() byte {
+	if d.err != nil {
+		return 0
+	}
+	b, err := d.r.ReadByte()
+	d.err = err
+	return b
+}
+
+func (d *wireReader) strings() []string {
+	n := d.count()
+	var out []string
+	for i := uint64(0); i < n && d.err == nil; i++ {
+		out = append(out, d.string())
+	}
+	return out
+}
+
+func (d *wireReader) string() string {
+	n := d.count()
+	if d.err != nil {
+		return ""
+	}
+	if n > maxWireString {
+		d.err = fmt.Errorf("string of %d bytes", n)
+		return ""
+	}
+	b := make([]byte, n)
+	if _, err := io.ReadFull(d.r, b); err != nil {
+		d.err = err
+		return ""
+	}
+	return string(b)
+}
@@ -0,0 +1,112 @@
+package engine
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"ad-targeting-engine/internal/storage"
+)
+
+func TestEncodedSnapshot_RoundTrip(t *testing.T) {
+	ctx := context.Background()
+	st := seedStore()
+	_, err := st.PutPublisher(ctx, storage.PublisherRow{AppID: "com.gametion.ludokinggame",
+		BlockedCampaigns: []string{"duolingo"}, BlockedCategories: []string{"IAB9"}})
+	require.NoError(t, err)
+	_, err = st.PutPublisher(ctx, storage.PublisherRow{AppID: "com.abc.xyz", Floor: 0.5})
+	require.NoError(t, err)
+	_, err = st.PutAdvertiser(ctx, storage.AdvertiserRow{ID: "spotify-ab", Name: "Spotify", Status: "ACTIVE",
+		BlockedApps: []string{"com.blocked"}})
+	require.NoError(t, err)
+	_, err = st.UpdateCampaign(ctx, storage.CampaignRow{ID: "spotify", Name: "Spotify", Status: "ACTIVE", Version: 1,
+		Landing: "https://spotify.com", Advertiser: "spotify-ab", Category: "IAB1-6"}, false)
+	require.NoError(t, err)
+	builder := NewEngine()
+	require.NoError(t, builder.BuildSnapshot(ctx, st))
+
+	data, meta, err := builder.EncodedSnapshot()
+	require.NoError(t, err)
+	again, _, _ := builder.EncodedSnapshot()
+	assert.Same(t, &data[0], &again[0], "encoding is cached per version")
+
+	follower := NewEngine()
+	got, err := follower.LoadEncoded(data)
+	require.NoError(t, err)
+	assert.Equal(t, meta.Version, got.Version)
+	assert.Equal(t, meta.Hash, follower.Snapshot().Hash)
+	assert.True(t, meta.BuiltAt.Equal(follower.Snapshot().BuiltAt))
+
+	req := MatchRequest{AppID: "com.gametion.ludokinggame", Country: "GERMANY", OS: "android", Debug: true}
+	assert.Equal(t, []string{"subwaysurfer"}, ids(follower.Match(ctx, req)), "publisher settings are shipped")
+	assert.Equal(t, builder.Match(ctx, req), follower.Match(ctx, req))
+	assert.Equal(t, 0.5, follower.Floor("com.abc.xyz"))
+
+	us := MatchRequest{AppID: "com.blocked", Country: "US", OS: "ios"}
+	assert.Empty(t, follower.Match(ctx, us), "advertisers are shipped")
+
+	c, ok := follower.Lookup("spotify")
+	require.True(t, ok)
+	assert.Equal(t, "https://spotify.com", c.Landing)
+	assert.Equal(t, "spotify-ab", c.Advertiser)
+	assert.Equal(t, "IAB1-6", c.Category)
+	_, ok = follower.Lookup("missing")
+	assert.False(t, ok)
+}
+
+func TestEncodedSnapshot_Budget(t *testing.T) {
+	ctx := context.Background()
+	st := seedStore()
+	b := storage.Budget{DailyImpressions: 100, LifetimeImpressions: 5000, DailySpend: 12.5, LifetimeSpend: 400,
+		Pacing: storage.PacingEven}
+	_, err := st.UpdateCampaign(ctx, storage.CampaignRow{ID: "duolingo", Name: "Duolingo", Status: "ACTIVE", Version: 1,
+		Budget: b}, false)
+	require.NoError(t, err)
+	builder := NewEngine()
+	require.NoError(t, builder.BuildSnapshot(ctx, st))
+	data, _, err := builder.EncodedSnapshot()
+	require.NoError(t, err)
+
+	follower := NewEngine()
+	_, err = follower.LoadEncoded(data)
+	require.NoError(t, err)
+	l := capAt{seen: map[string]storage.Budget{}}
+	follower.SetLimiter(l)
+	follower.Match(ctx, MatchRequest{AppID: "com.gametion.ludokinggame", Country: "GERMANY", OS: "android"})
+	assert.Equal(t, b, l.seen["duolingo"], "budgets are shipped")
+	assert.False(t, l.seen["subwaysurfer"].Capped())
+}
+
+func TestLoadEncoded_Rejects(t *testing.T) {
+	builder := NewEngine()
+	require.NoError(t, builder.BuildSnapshot(context.Background(), seedStore()))
+	data, _, err := builder.EncodedSnapshot()
+	require.NoError(t, err)
+
+	corrupt := func(i int) []byte {
+		b := append([]byte(nil), data...)
+		b[i] ^= 0xff
+		return b
+	}
+	tests := []struct {
+		name string
+		data []byte
+	}{
+		{"empty", nil},
+		{"magic", corrupt(0)},
+		{"format", corrupt(5)},
+		{"content hash", corrupt(25)},
+		{"payload", corrupt(len(data) - 5)},
+		{"truncated", data[:len(data)-1]},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			eng := NewEngine()
+			_, err := eng.LoadEncoded(tt.data)
+			assert.ErrorIs(t, err, ErrBadSnapshot)
+			assert.Zero(t, eng.Snapshot().Version, "nothing is swapped in")
+		})
+	}
+}
@@ -5,13 +5,19 @@ import (
 	"fmt"
 	"slices"
 	"strings"
+	"sync/atomic"
+	"time"
 
+	"github.com/rs/zerolog/log"
+
+	"ad-targeting-engine/internal/observability"
 	"ad-targeting-engine/internal/storage"
 )
 
 // Indexes for fast candidate narrowing
 type indexes struct {
 	Campaigns []CampaignWithRules 
+	ByID       map[string]int
 	IncApp     map[string][]int
 	ExcApp     map[string][]int
 	IncOS      map[string][]int
@@ -23,55 +29,296 @@ type indexes struct {
 	AgnosticCountry []int
 }
 
-type snapshot struct{ idx indexes }
+// snapshot is what Match reads: the campaign indexes, the publisher
+// settings by app ID and the advertisers by ID.
+type snapshot struct {
+	idx  indexes
+	pubs map[string]Publisher
+	advs map[string]Advertiser
+}
+
+// Limiter decides whether a matching campaign may serve right now, e.g.
+// because its budget is not spent yet.
+type Limiter interface {
+	Allow(campaignID string, b storage.Budget) bool
+}
 
 // DeliveryEngine exposes read-only, lock-free match operations.
-type DeliveryEngine struct{ snap storage.Snapshot[snapshot] }
+type DeliveryEngine struct {
+	snap    *storage.Snapshot[snapshot]
+	encoded atomic.Pointer[encodedSnapshot] // see EncodedSnapshot
+	limiter Limiter
+}
+
+func NewEngine() *DeliveryEngine {
+	return &DeliveryEngine{snap: storage.NewSnapshot(hashSnapshot)}
+}
+
+// hashSnapshot identifies snapshot content by its campaign ids and versions
+// and those of its publisher settings and advertisers.
+func hashSnapshot(s snapshot) uint64 {
+	var h uint64
+	for _, c := range s.idx.Campaigns {
+		h += storage.CampaignHash(c.ID, c.Version)
+	}
+	for _, p := range s.pubs {
+		h += storage.CampaignHash("publisher:"+p.AppID, p.Version)
+	}
+	for _, a := range s.advs {
+		h += storage.CampaignHash("advertiser:"+a.ID, a.Version)
+	}
+	return h
+}
+
+// SetLimiter makes Match drop campaigns l does not allow. Call it before
+// serving.
+func (e *DeliveryEngine) SetLimiter(l Limiter) { e.limiter = l }
+
+// Snapshot describes the snapshot currently served.
+func (e *DeliveryEngine) Snapshot() storage.SnapshotMeta { return e.snap.Meta() }
+
+// Watch notifies on every snapshot swap; see storage.Snapshot.Watch.
+func (e *DeliveryEngine) Watch(ctx context.Context) <-chan storage.SnapshotMeta {
+	return e.snap.Watch(ctx)
+}
+
+// ReportSnapshots keeps the snapshot gauges current until ctx is done. It
+// subscribes before returning, so no swap after the call is missed.
+func (e *DeliveryEngine) ReportSnapshots(ctx context.Context) {
+	swaps := e.Watch(ctx)
+	go func() {
+		for range swaps {
+			s, meta := e.snap.LoadMeta()
+			observability.SnapshotVersion.Set(float64(meta.Version))
+			observability.SnapshotBuiltAt.Set(float64(meta.BuiltAt.Unix()))
+			observability.SnapshotCampaigns.Set(float64(len(s.idx.Campaigns)))
+			log.Debug().Uint64("version", meta.Version).Str("hash", fmt.Sprintf("%016x", meta.Hash)).
+				Int("campaigns", len(s.idx.Campaigns)).Msg("snapshot published")
+		}
+	}()
+}
 
-func NewEngine() *DeliveryEngine { return &DeliveryEngine{} }
+// BuildSnapshot streams active campaigns+rules into a fresh set of inverted
+// indexes, loads the publisher and advertiser settings and swaps them in. Rows are indexed
+// as they arrive, so the load never holds the full result set next to the
+// indexes built from it.
+func (e *DeliveryEngine) BuildSnapshot(ctx context.Context, st storage.SnapshotSource) error {
+	_, err := e.Rebuild(ctx, st)
+	return err
+}
+
+// Rebuild is BuildSnapshot that also returns the change seq the campaigns
+// were read at, so the caller can replay the outbox from exactly there.
+func (e *DeliveryEngine) Rebuild(ctx context.Context, st storage.SnapshotSource) (int64, error) {
+	start := time.Now()
+	heap := newHeapTracker()
+	b := newIndexBuilder()
+	seq, err := st.StreamActiveCampaigns(ctx, func(r storage.CampaignRow) error {
+		b.add(toCampaign(r))
+		if len(b.ix.Campaigns)%heapSampleEvery == 0 {
+			heap.sample()
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	log.Debug().Int("campaigns", len(b.ix.Campaigns)).Msg("campaigns loaded")
+	pubs, err := loadPublishers(ctx, st)
+	if err != nil {
+		return 0, err
+	}
+	advs, err := loadAdvertisers(ctx, st)
+	if err != nil {
+		return 0, err
+	}
+
+	e.snap.Store(snapshot{idx: b.ix, pubs: pubs, advs: advs})
+	heap.sample()
+	observability.SnapshotBuildSeconds.Observe(time.Since(start).Seconds())
+	observability.SnapshotBuildPeakHeap.Set(float64(heap.peakGrowth()))
+	return seq, nil
+}
 
-// BuildSnapshot loads active campaigns+rules and builds inverted indexes.
-func (e *DeliveryEngine) BuildSnapshot(ctx context.Context, st *storage.Store) error {
-	rows, err := st.LoadActiveCampaigns(ctx)
-	fmt.Printf("Loaded %d campaigns from DB\n", len(rows))
+// Load replaces the snapshot with one built from rows already in memory,
+// e.g. for matching against reconstructed history. It has no publisher or
+// advertiser settings.
+func (e *DeliveryEngine) Load(rows []storage.CampaignRow) {
+	b := newIndexBuilder()
 	for _, r := range rows {
-		fmt.Printf("Campaign %s (%s), rules=%v\n", r.ID, r.Name, r.Rules)
+		b.add(toCampaign(r))
+	}
+	e.snap.Store(snapshot{idx: b.ix})
+}
+
+// ApplyChanges folds a batch of outbox events into the current snapshot.
+// Every campaign touched by evs is reloaded from the store in event order and
+// replaces (or, when no longer active, removes) its previous version. Any
+// publisher or advertiser event reloads all publishers or advertisers.
+func (e *DeliveryEngine) ApplyChanges(ctx context.Context, st storage.SnapshotSource, evs []storage.ChangeEvent) error {
+	var ids []string
+	var publishers, advertisers bool
+	touched := map[string]bool{}
+	for _, ev := range evs {
+		switch ev.EntityType {
+		case "publisher":
+			publishers = true
+			continue
+		case "advertiser":
+			advertisers = true
+			continue
+		}
+		if !touched[ev.CampaignID] {
+			touched[ev.CampaignID] = true
+			ids = append(ids, ev.CampaignID)
+		}
+	}
+	if len(ids) == 0 && !publishers && !advertisers {
+		return nil
 	}
 
+	rows, err := st.LoadActiveCampaignsByID(ctx, ids)
 	if err != nil {
 		return err
 	}
-	// Normalize & build rules
-	var cs []CampaignWithRules
+	fresh := map[string]CampaignWithRules{}
 	for _, r := range rows {
-		c := CampaignWithRules{ID: r.ID, Name: r.Name, Image: r.ImageURL, CTA: r.CTA, Status: r.Status}
-		for _, rr := range r.Rules {
-			vals := make([]string, len(rr.Values))
-			for i, v := range rr.Values {
-				switch strings.ToLower(rr.Dimension) {
-				case "country":
-					vals[i] = strings.ToUpper(strings.TrimSpace(v))
-				default: // appid, os
-					vals[i] = strings.ToLower(strings.TrimSpace(v))
-				}
-			}
-			c.Rules = append(c.Rules, Rule{
-				Dimension:   strings.ToLower(rr.Dimension),
-				IsInclusion: rr.IsInclusion,
-				Values:      vals,
-			})
+		fresh[r.ID] = toCampaign(r)
+	}
+
+	s := e.snap.Load()
+	pubs, advs := s.pubs, s.advs
+	if publishers {
+		if pubs, err = loadPublishers(ctx, st); err != nil {
+			return err
+		}
+	}
+	if advertisers {
+		if advs, err = loadAdvertisers(ctx, st); err != nil {
+			return err
+		}
+	}
+	b := newIndexBuilder()
+	for _, c := range s.idx.Campaigns {
+		if !touched[c.ID] {
+			b.add(c)
+		}
+	}
+	for _, id := range ids {
+		if c, ok := fresh[id]; ok {
+			b.add(c)
 		}
-		cs = append(cs, c)
 	}
 
-	idx := buildIndexes(cs)
-	e.snap.Store(snapshot{idx: idx})
+	e.snap.Store(snapshot{idx: b.ix, pubs: pubs, advs: advs})
 	return nil
 }
 
-func buildIndexes(cs []CampaignWithRules) indexes {
-	ix := indexes{
-		Campaigns:       cs,
+// loadPublishers reads the publisher settings and normalizes them like
+// toCampaign does campaigns.
+func loadPublishers(ctx context.Context, st storage.SnapshotSource) (map[string]Publisher, error) {
+	rows, err := st.LoadPublishers(ctx)
+	if err != nil {
+		return nil, err
+	}
+	pubs := make(map[string]Publisher, len(rows))
+	for _, r := range rows {
+		p := toPublisher(r)
+		pubs[p.AppID] = p
+	}
+	return pubs, nil
+}
+
+func toPublisher(r storage.PublisherRow) Publisher {
+	p := Publisher{AppID: strings.ToLower(strings.TrimSpace(r.AppID)), Version: r.Version, Floor: r.Floor,
+		BlockedAdvertisers: r.BlockedAdvertisers, BlockedCampaigns: r.BlockedCampaigns}
+	for _, c := range r.BlockedCategories {
+		p.BlockedCategories = append(p.BlockedCategories, strings.ToUpper(strings.TrimSpace(c)))
+	}
+	return p
+}
+
+func loadAdvertisers(ctx context.Context, st storage.SnapshotSource) (map[string]Advertiser, error) {
+	rows, err := st.LoadAdvertisers(ctx)
+	if err != nil {
+		return nil, err
+	}
+	advs := make(map[string]Advertiser, len(rows))
+	for _, r := range rows {
+		a := Advertiser{ID: r.ID, Version: r.Version, Status: r.Status}
+		for _, app := range r.BlockedApps {
+			a.BlockedApps = append(a.BlockedApps, strings.ToLower(strings.TrimSpace(app)))
+		}
+		advs[a.ID] = a
+	}
+	return advs, nil
+}
+
+// block returns why a keeps its campaigns out of app, or "" if it does not.
+func (a Advertiser) block(app string) string {
+	switch {
+	case a.Status != "ACTIVE":
+		return ReasonAdvertiserInactive
+	case slices.Contains(a.BlockedApps, app):
+		return ReasonAdvertiserBlockedApp
+	}
+	return ""
+}
+
+// block returns why p keeps c out of its app, or "" if it does not.
+// Blocking a tier-1 category (IAB9) also blocks its subcategories (IAB9-30).
+func (p Publisher) block(c CampaignWithRules) string {
+	tier1, _, _ := strings.Cut(c.Category, "-")
+	switch {
+	case slices.Contains(p.BlockedCampaigns, c.ID):
+		return ReasonBlockedCampaign
+	case c.Advertiser != "" && slices.Contains(p.BlockedAdvertisers, c.Advertiser):
+		return ReasonBlockedAdvertiser
+	case c.Category != "" && (slices.Contains(p.BlockedCategories, c.Category) || slices.Contains(p.BlockedCategories, tier1)):
+		return ReasonBlockedCategory
+	case c.Price < p.Floor:
+		return ReasonBelowFloor
+	}
+	return ""
+}
+
+// Floor is the publisher floor of app in the current snapshot; 0 when it
+// has none.
+func (e *DeliveryEngine) Floor(app string) float64 {
+	return e.snap.Load().pubs[strings.ToLower(strings.TrimSpace(app))].Floor
+}
+
+// toCampaign normalizes a storage row into an engine campaign.
+func toCampaign(r storage.CampaignRow) CampaignWithRules {
+	c := CampaignWithRules{ID: r.ID, Name: r.Name, Image: r.ImageURL, CTA: r.CTA, Status: r.Status, Version: r.Version,
+		Price: r.BidPrice, Markup: r.Markup, Landing: r.Landing, Creative: r.Creative, Video: r.Video, Budget: r.Budget,
+		Advertiser: r.Advertiser, Category: strings.ToUpper(strings.TrimSpace(r.Category))}
+	for _, rr := range r.Rules {
+		vals := make([]string, len(rr.Values))
+		for i, v := range rr.Values {
+			switch strings.ToLower(rr.Dimension) {
+			case "country":
+				vals[i] = strings.ToUpper(strings.TrimSpace(v))
+			default: // appid, os
+				vals[i] = strings.ToLower(strings.TrimSpace(v))
+			}
+		}
+		c.Rules = append(c.Rules, Rule{
+			Dimension:   strings.ToLower(rr.Dimension),
+			IsInclusion: rr.IsInclusion,
+			Values:      vals,
+		})
+	}
+	return c
+}
+
+// indexBuilder builds indexes one campaign at a time, so callers can feed it
+// straight from a stream.
+type indexBuilder struct{ ix indexes }
+
+func newIndexBuilder() *indexBuilder {
+	return &indexBuilder{ix: indexes{
+		ByID:            map[string]int{},
 		IncApp:          map[string][]int{},
 		ExcApp:          map[string][]int{},
 		IncOS:           map[string][]int{},
@@ -81,107 +328,176 @@ func buildIndexes(cs []CampaignWithRules) indexes {
 		AgnosticApp:     []int{},
 		AgnosticOS:      []int{},
 		AgnosticCountry: []int{},
-	}
-	for i, c := range cs {
-		var hasApp, hasOS, hasCountry, hasIncCountry bool
-		for _, r := range c.Rules {
-			switch r.Dimension {
-			case "appid":
-				hasApp = true
-				for _, v := range r.Values {
-					m := ix.IncApp
-					if !r.IsInclusion {
-						m = ix.ExcApp
-					}
-					m[strings.ToLower(v)] = append(m[strings.ToLower(v)], i)
-				}
-			case "os":
-				hasOS = true
-				for _, v := range r.Values {
-					m := ix.IncOS
-					if !r.IsInclusion {
-						m = ix.ExcOS
-					}
-					m[strings.ToLower(v)] = append(m[strings.ToLower(v)], i)
+	}}
+}
+
+func (b *indexBuilder) add(c CampaignWithRules) {
+	ix := &b.ix
+	i := len(ix.Campaigns)
+	ix.Campaigns = append(ix.Campaigns, c)
+	ix.ByID[c.ID] = i
+
+	var hasApp, hasOS, hasCountry, hasIncCountry bool
+	for _, r := range c.Rules {
+		switch r.Dimension {
+		case "appid":
+			hasApp = true
+			for _, v := range r.Values {
+				m := ix.IncApp
+				if !r.IsInclusion {
+					m = ix.ExcApp
 				}
-			case "country":
-				hasCountry = true
-				if r.IsInclusion {
-					hasIncCountry = true
+				m[strings.ToLower(v)] = append(m[strings.ToLower(v)], i)
+			}
+		case "os":
+			hasOS = true
+			for _, v := range r.Values {
+				m := ix.IncOS
+				if !r.IsInclusion {
+					m = ix.ExcOS
 				}
-				for _, v := range r.Values {
-					m := ix.IncCountry
-					if !r.IsInclusion {
-						m = ix.ExcCountry
-					}
-					m[strings.ToUpper(v)] = append(m[strings.ToUpper(v)], i)
+				m[strings.ToLower(v)] = append(m[strings.ToLower(v)], i)
+			}
+		case "country":
+			hasCountry = true
+			if r.IsInclusion {
+				hasIncCountry = true
+			}
+			for _, v := range r.Values {
+				m := ix.IncCountry
+				if !r.IsInclusion {
+					m = ix.ExcCountry
 				}
+				m[strings.ToUpper(v)] = append(m[strings.ToUpper(v)], i)
 			}
 		}
-		if !hasApp {
-			ix.AgnosticApp = append(ix.AgnosticApp, i)
-		}
-		if !hasOS {
-			ix.AgnosticOS = append(ix.AgnosticOS, i)
-		}
-		if hasCountry && !hasIncCountry {
-			ix.AgnosticCountry = append(ix.AgnosticCountry, i)
-		} else if !hasCountry {
-			ix.AgnosticCountry = append(ix.AgnosticCountry, i)
-		}
 	}
-	return ix
+	if !hasApp {
+		ix.AgnosticApp = append(ix.AgnosticApp, i)
+	}
+	if !hasOS {
+		ix.AgnosticOS = append(ix.AgnosticOS, i)
+	}
+	if hasCountry && !hasIncCountry {
+		ix.AgnosticCountry = append(ix.AgnosticCountry, i)
+	} else if !hasCountry {
+		ix.AgnosticCountry = append(ix.AgnosticCountry, i)
+	}
+}
+
+// Lookup returns the campaign with the given id in the current snapshot,
+// whatever its status.
+func (e *DeliveryEngine) Lookup(id string) (Campaign, bool) {
+	ix := e.snap.Load().idx
+	i, ok := ix.ByID[id]
+	if !ok {
+		return Campaign{}, false
+	}
+	c := ix.Campaigns[i]
+	return Campaign{ID: c.ID, Image: c.Image, CTA: c.CTA, Version: c.Version, Name: c.Name, Price: c.Price,
+		Markup: c.Markup, Landing: c.Landing, Creative: c.Creative, Video: c.Video,
+		Advertiser: c.Advertiser, Category: c.Category}, true
 }
 
 // Match returns API campaigns for the given request.
-func (e *DeliveryEngine) Match(_ context.Context, req MatchRequest) []Campaign {
+func (e *DeliveryEngine) Match(ctx context.Context, req MatchRequest) []Campaign {
+	out, _ := e.MatchMeta(ctx, req)
+	return out
+}
+
+// MatchMeta is Match that also describes the snapshot the answer came from.
+func (e *DeliveryEngine) MatchMeta(_ context.Context, req MatchRequest) ([]Campaign, storage.SnapshotMeta) {
 	// normalize request
 	req.AppID = strings.ToLower(strings.TrimSpace(req.AppID))
 	req.OS = strings.ToLower(strings.TrimSpace(req.OS))
 	req.Country = strings.ToUpper(strings.TrimSpace(req.Country))
 
 	// load snapshot
-	s, _ := e.snap.Load()
+	s, meta := e.snap.LoadMeta()
 	ix := s.idx
 
 	// start with ALL campaigns, then narrow down
 	cand := newSet(rangeIndices(len(ix.Campaigns))) // start with all campaigns
-	fmt.Printf("Initial candidates: %v\n", cand.list())
 
 	// Apply AppID rules
 	cand = cand.intersect(newSet(ix.IncApp[req.AppID], ix.AgnosticApp))
-	fmt.Printf("After app: %v\n", cand.list())
 
 	// Apply OS rules
 	cand = cand.intersect(newSet(ix.IncOS[req.OS], ix.AgnosticOS))
-	fmt.Printf("After os: %v\n", cand.list())
 
 	// Apply Country rules
 	cand = cand.intersect(newSet(ix.IncCountry[req.Country], ix.AgnosticCountry))
-	fmt.Printf("After country: %v\n", cand.list())
 
 	// Apply exclusions
 	cand = cand.subtract(ix.ExcApp[req.AppID])
 	cand = cand.subtract(ix.ExcOS[req.OS])
 	cand = cand.subtract(ix.ExcCountry[req.Country])
-	fmt.Printf("After exclusions: %v\n", cand.list())
 
 	// final verification
+	pub, hasPub := s.pubs[req.AppID]
 	var out []Campaign
 	for _, i := range cand.list() {
 		c := ix.Campaigns[i]
-		if c.Status != "ACTIVE" {
+		if c.Status != "ACTIVE" || !matchesAll(c.Rules, req) {
 			continue
 		}
-		if matchesAll(c.Rules, req) {
-			out = append(out, Campaign{ID: c.ID, Image: c.Image, CTA: c.CTA})
+		if adv, ok := s.advs[c.Advertiser]; ok {
+			if reason := adv.block(req.AppID); reason != "" {
+				observability.AdvertiserBlocked.WithLabelValues(reason).Inc()
+				continue
+			}
+		}
+		if hasPub {
+			if reason := pub.block(c); reason != "" {
+				observability.PublisherBlocked.WithLabelValues(reason).Inc()
+				continue
+			}
+		}
+		if e.limiter == nil || e.limiter.Allow(c.ID, c.Budget) {
+			m := Campaign{ID: c.ID, Image: c.Image, CTA: c.CTA, Name: c.Name, Price: c.Price, Markup: c.Markup,
+				Landing: c.Landing, Creative: c.Creative, Video: c.Video, Advertiser: c.Advertiser, Category: c.Category}
+			if req.Debug {
+				m.Version = c.Version
+			}
+			out = append(out, m)
 		}
 	}
 
 	// deterministic order
 	slices.SortFunc(out, func(a, b Campaign) int { return strings.Compare(a.ID, b.ID) })
 
-	fmt.Printf("Final matches: %v\n", out)
+	return out, meta
+}
+
+// Explain says for every campaign in the snapshot (the active ones) whether
+// it serves req and, if not, why. Unlike Match it looks at each campaign's
+// rules rather than the indexes, so it costs a full scan.
+func (e *DeliveryEngine) Explain(_ context.Context, req MatchRequest) []Explanation {
+	req.AppID = strings.ToLower(strings.TrimSpace(req.AppID))
+	req.OS = strings.ToLower(strings.TrimSpace(req.OS))
+	req.Country = strings.ToUpper(strings.TrimSpace(req.Country))
+
+	s := e.snap.Load()
+	pub, hasPub := s.pubs[req.AppID]
+	out := make([]Explanation, 0, len(s.idx.Campaigns))
+	for _, c := range s.idx.Campaigns {
+		adv, hasAdv := s.advs[c.Advertiser]
+		var reason string
+		switch {
+		case !matchesAll(c.Rules, req):
+			reason = ReasonTargeting
+		case hasAdv:
+			reason = adv.block(req.AppID)
+		}
+		if reason == "" && hasPub {
+			reason = pub.block(c)
+		}
+		if reason == "" && e.limiter != nil && !e.limiter.Allow(c.ID, c.Budget) {
+			reason = ReasonBudget
+		}
+		out = append(out, Explanation{CampaignID: c.ID, Matched: reason == "", Reason: reason})
+	}
+	slices.SortFunc(out, func(a, b Explanation) int { return strings.Compare(a.CampaignID, b.CampaignID) })
 	return out
 }
 
@@ -0,0 +1,237 @@
+package engine
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"ad-targeting-engine/internal/storage"
+)
+
+func seedStore() *storage.MemoryStore {
+	return storage.NewMemoryStore(
+		storage.CampaignRow{ID: "spotify", Name: "Spotify", ImageURL: "https://somelink", CTA: "Download", Status: "ACTIVE",
+			Landing: "https://spotify.com", Rules: []storage.RuleRow{{Dimension: "country", IsInclusion: true, Values: []string{"US", "Canada"}}}},
+		storage.CampaignRow{ID: "duolingo", Name: "Duolingo", ImageURL: "https://somelink2", CTA: "Install", Status: "ACTIVE",
+			Rules: []storage.RuleRow{
+				{Dimension: "os", IsInclusion: true, Values: []string{"Android", "iOS"}},
+				{Dimension: "country", IsInclusion: false, Values: []string{"US"}},
+			}},
+		storage.CampaignRow{ID: "subwaysurfer", Name: "Subway Surfer", ImageURL: "https://somelink3", CTA: "Play", Status: "ACTIVE",
+			Rules: []storage.RuleRow{
+				{Dimension: "os", IsInclusion: true, Values: []string{"Android"}},
+				{Dimension: "appid", IsInclusion: true, Values: []string{"com.gametion.ludokinggame"}},
+			}},
+		storage.CampaignRow{ID: "paused", Name: "Paused", Status: "INACTIVE"},
+	)
+}
+
+func ids(cs []Campaign) []string {
+	var out []string
+	for _, c := range cs {
+		out = append(out, c.ID)
+	}
+	return out
+}
+
+func TestBuildSnapshot_Match(t *testing.T) {
+	eng := NewEngine()
+	require.NoError(t, eng.BuildSnapshot(context.Background(), seedStore()))
+
+	tests := []struct {
+		name string
+		req  MatchRequest
+		want []string
+	}{
+		{"us android", MatchRequest{AppID: "com.abc.xyz", Country: "US", OS: "android"}, []string{"spotify"}},
+		{"germany android", MatchRequest{AppID: "com.abc.xyz", Country: "GERMANY", OS: "android"}, []string{"duolingo"}},
+		{"ludo in germany", MatchRequest{AppID: "com.gametion.ludokinggame", Country: "GERMANY", OS: "android"}, []string{"duolingo", "subwaysurfer"}},
+		{"canada web", MatchRequest{AppID: "com.abc.xyz", Country: "CANADA", OS: "web"}, []string{"spotify"}},
+		{"nothing", MatchRequest{AppID: "com.abc.xyz", Country: "GERMANY", OS: "web"}, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, ids(eng.Match(context.Background(), tt.req)))
+		})
+	}
+}
+
+func TestApplyChanges(t *testing.T) {
+	ctx := context.Background()
+	st := seedStore()
+	eng := NewEngine()
+	seq, err := eng.Rebuild(ctx, st)
+	require.NoError(t, err)
+	latest, _ := st.LatestChangeSeq(ctx)
+	require.Equal(t, latest, seq, "the rebuild reports the seq it read at")
+
+	req := MatchRequest{AppID: "com.abc.xyz", Country: "US", OS: "android"}
+	require.Equal(t, []string{"spotify"}, ids(eng.Match(ctx, req)))
+
+	// pause spotify, open duolingo to the US, add a new catch-all campaign
+	_, err = st.UpdateCampaign(ctx, storage.CampaignRow{ID: "spotify", Name: "Spotify", Status: "INACTIVE", Version: 1}, false)
+	require.NoError(t, err)
+	_, err = st.ReplaceRules(ctx, "duolingo", []storage.RuleRow{{Dimension: "os", IsInclusion: true, Values: []string{"android"}}}, 1)
+	require.NoError(t, err)
+	require.NoError(t, st.CreateCampaign(ctx, storage.CampaignRow{ID: "all", Name: "All", Status: "ACTIVE"}))
+
+	evs, err := st.LoadChangeEventsSince(ctx, seq, 1000)
+	require.NoError(t, err)
+	before := eng.Snapshot()
+	require.NoError(t, eng.ApplyChanges(ctx, st, evs))
+	assert.Equal(t, before.Version+1, eng.Snapshot().Version)
+	assert.NotEqual(t, before.Hash, eng.Snapshot().Hash)
+
+	got := eng.Match(ctx, MatchRequest{AppID: req.AppID, Country: req.Country, OS: req.OS, Debug: true})
+	assert.Equal(t, []string{"all", "duolingo"}, ids(got))
+	assert.Equal(t, int64(2), got[1].Version)
+}
+
+// failingStream yields a few campaigns and then fails mid-load.
+type failingStream struct{ *storage.MemoryStore }
+
+func (f failingStream) StreamActiveCampaigns(ctx context.Context, fn func(storage.CampaignRow) error) (int64, error) {
+	_, _ = f.MemoryStore.StreamActiveCampaigns(ctx, func(r storage.CampaignRow) error {
+		if r.ID == "subwaysurfer" {
+			return errors.New("connection reset")
+		}
+		return fn(r)
+	})
+	return 0, errors.New("connection reset")
+}
+
+func TestBuildSnapshot_KeepsSnapshotOnStreamError(t *testing.T) {
+	ctx := context.Background()
+	eng := NewEngine()
+	require.NoError(t, eng.BuildSnapshot(ctx, seedStore()))
+
+	empty := storage.NewMemoryStore()
+	require.Error(t, eng.BuildSnapshot(ctx, failingStream{empty}))
+	require.Error(t, eng.BuildSnapshot(ctx, failingStream{seedStore()}))
+
+	req := MatchRequest{AppID: "com.gametion.ludokinggame", Country: "GERMANY", OS: "android"}
+	assert.Equal(t, []string{"duolingo", "subwaysurfer"}, ids(eng.Match(ctx, req)), "a partial load must not be swapped in")
+}
+
+// capAt allows every campaign but one, and records the budgets it saw.
+type capAt struct {
+	id   string
+	seen map[string]storage.Budget
+}
+
+func (l capAt) Allow(id string, b storage.Budget) bool {
+	l.seen[id] = b
+	return id != l.id
+}
+
+func TestMatch_Limiter(t *testing.T) {
+	ctx := context.Background()
+	st := seedStore()
+	_, err := st.UpdateCampaign(ctx, storage.CampaignRow{ID: "duolingo", Name: "Duolingo", Status: "ACTIVE", Version: 1,
+		Budget: storage.Budget{DailyImpressions: 10}}, false)
+	require.NoError(t, err)
+	eng := NewEngine()
+	require.NoError(t, eng.BuildSnapshot(ctx, st))
+	l := capAt{id: "subwaysurfer", seen: map[string]storage.Budget{}}
+	eng.SetLimiter(l)
+
+	req := MatchRequest{AppID: "com.gametion.ludokinggame", Country: "GERMANY", OS: "android"}
+	assert.Equal(t, []string{"duolingo"}, ids(eng.Match(ctx, req)))
+	assert.Equal(t, int64(10), l.seen["duolingo"].DailyImpressions)
+	assert.NotContains(t, l.seen, "spotify", "only matching campaigns are asked")
+}
+
+// withAdvertisers returns a store holding active advertisers ids, then cs.
+func withAdvertisers(t *testing.T, ids []string, cs ...storage.CampaignRow) *storage.MemoryStore {
+	ctx := context.Background()
+	st := storage.NewMemoryStore()
+	for _, id := range ids {
+		_, err := st.PutAdvertiser(ctx, storage.AdvertiserRow{ID: id, Name: id, Status: "ACTIVE"})
+		require.NoError(t, err)
+	}
+	for _, c := range cs {
+		require.NoError(t, st.CreateCampaign(ctx, c))
+	}
+	return st
+}
+
+func TestPublisher_BlocksAndExplain(t *testing.T) {
+	ctx := context.Background()
+	st := withAdvertisers(t, []string{"acme", "globex", "initech"},
+		storage.CampaignRow{ID: "a", Name: "A", Status: "ACTIVE", BidPrice: 3, Advertiser: "acme", Category: "iab9-30"},
+		storage.CampaignRow{ID: "b", Name: "B", Status: "ACTIVE", BidPrice: 3, Advertiser: "globex", Category: "IAB1"},
+		storage.CampaignRow{ID: "c", Name: "C", Status: "ACTIVE", BidPrice: 1},
+		storage.CampaignRow{ID: "d", Name: "D", Status: "ACTIVE", BidPrice: 5},
+		storage.CampaignRow{ID: "e", Name: "E", Status: "ACTIVE", BidPrice: 5, Advertiser: "initech",
+			Rules: []storage.RuleRow{{Dimension: "os", IsInclusion: true, Values: []string{"ios"}}}},
+		storage.CampaignRow{ID: "f", Name: "F", Status: "INACTIVE"},
+	)
+	eng := NewEngine()
+	require.NoError(t, eng.BuildSnapshot(ctx, st))
+	seq, _ := st.LatestChangeSeq(ctx)
+
+	req := MatchRequest{AppID: "com.x", Country: "US", OS: "android"}
+	require.Equal(t, []string{"a", "b", "c", "d"}, ids(eng.Match(ctx, req)))
+
+	_, err := st.PutPublisher(ctx, storage.PublisherRow{AppID: "com.x", Floor: 2,
+		BlockedAdvertisers: []string{"globex"}, BlockedCategories: []string{"iab9"}, BlockedCampaigns: []string{"d"}})
+	require.NoError(t, err)
+	evs, err := st.LoadChangeEventsSince(ctx, seq, 1000)
+	require.NoError(t, err)
+	require.NoError(t, eng.ApplyChanges(ctx, st, evs))
+
+	assert.Empty(t, eng.Match(ctx, req))
+	assert.Equal(t, []string{"a", "b", "c", "d"}, ids(eng.Match(ctx, MatchRequest{AppID: "com.y", Country: "US", OS: "android"})),
+		"other apps are unaffected")
+	assert.Equal(t, 2.0, eng.Floor("COM.X"))
+	assert.Zero(t, eng.Floor("com.y"))
+
+	assert.Equal(t, []Explanation{
+		{CampaignID: "a", Reason: ReasonBlockedCategory},
+		{CampaignID: "b", Reason: ReasonBlockedAdvertiser},
+		{CampaignID: "c", Reason: ReasonBelowFloor},
+		{CampaignID: "d", Reason: ReasonBlockedCampaign},
+		{CampaignID: "e", Reason: ReasonTargeting},
+	}, eng.Explain(ctx, req), "paused campaigns are not in the snapshot")
+	assert.Contains(t, eng.Explain(ctx, MatchRequest{AppID: "com.x", Country: "US", OS: "ios"}),
+		Explanation{CampaignID: "e", Matched: true})
+}
+
+func TestAdvertiser_Cascades(t *testing.T) {
+	ctx := context.Background()
+	st := withAdvertisers(t, []string{"acme", "globex"},
+		storage.CampaignRow{ID: "a1", Name: "A1", Status: "ACTIVE", Advertiser: "acme"},
+		storage.CampaignRow{ID: "a2", Name: "A2", Status: "ACTIVE", Advertiser: "acme"},
+		storage.CampaignRow{ID: "g1", Name: "G1", Status: "ACTIVE", Advertiser: "globex"},
+		storage.CampaignRow{ID: "n1", Name: "N1", Status: "ACTIVE"},
+	)
+	eng := NewEngine()
+	require.NoError(t, eng.BuildSnapshot(ctx, st))
+	seq, _ := st.LatestChangeSeq(ctx)
+
+	req := MatchRequest{AppID: "com.x", Country: "US", OS: "android"}
+	require.Equal(t, []string{"a1", "a2", "g1", "n1"}, ids(eng.Match(ctx, req)))
+	got, ok := eng.Lookup("a1")
+	require.True(t, ok)
+	assert.Equal(t, "acme", got.Advertiser)
+
+	_, err := st.PutAdvertiser(ctx, storage.AdvertiserRow{ID: "acme", Name: "Acme", Status: "INACTIVE"})
+	require.NoError(t, err)
+	_, err = st.PutAdvertiser(ctx, storage.AdvertiserRow{ID: "globex", Name: "Globex", Status: "ACTIVE", BlockedApps: []string{"COM.X"}})
+	require.NoError(t, err)
+	evs, err := st.LoadChangeEventsSince(ctx, seq, 1000)
+	require.NoError(t, err)
+	require.NoError(t, eng.ApplyChanges(ctx, st, evs))
+
+	assert.Equal(t, []string{"n1"}, ids(eng.Match(ctx, req)))
+	assert.Equal(t, []string{"g1", "n1"}, ids(eng.Match(ctx, MatchRequest{AppID: "com.y", Country: "US", OS: "android"})))
+	assert.Equal(t, []Explanation{
+		{CampaignID: "a1", Reason: ReasonAdvertiserInactive},
+		{CampaignID: "a2", Reason: ReasonAdvertiserInactive},
+		{CampaignID: "g1", Reason: ReasonAdvertiserBlockedApp},
+		{CampaignID: "n1", Matched: true},
+	}, eng.Explain(ctx, req))
+}
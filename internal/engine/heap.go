@@ -0,0 +1,40 @@
+package engine
+
+import "runtime/metrics"
+
+// heapSampleEvery is how many campaigns BuildSnapshot indexes between heap
+// samples. Sampling reads runtime metrics without stopping the world.
+const heapSampleEvery = 1024
+
+const heapMetric = "/memory/classes/heap/objects:bytes"
+
+// heapTracker records the peak heap seen during a rebuild relative to the
+// heap when it started. It measures the whole process, so concurrent request
+// garbage is included; it is meant for spotting order-of-magnitude changes.
+type heapTracker struct {
+	s          []metrics.Sample
+	start, max uint64
+}
+
+func newHeapTracker() *heapTracker {
+	t := &heapTracker{s: []metrics.Sample{{Name: heapMetric}}}
+	t.start = t.read()
+	t.max = t.start
+	return t
+}
+
+func (t *heapTracker) read() uint64 {
+	metrics.Read(t.s)
+	if t.s[0].Value.Kind() != metrics.KindUint64 {
+		return 0
+	}
+	return t.s[0].Value.Uint64()
+}
+
+func (t *heapTracker) sample() {
+	if v := t.read(); v > t.max {
+		t.max = v
+	}
+}
+
+func (t *heapTracker) peakGrowth() uint64 { return t.max - t.start }
@@ -1,10 +1,33 @@
 package engine
 
+import "ad-targeting-engine/internal/storage"
+
 // API-facing campaign
 type Campaign struct {
 	ID    string `json:"cid"`
 	Image string `json:"img"`
 	CTA   string `json:"cta"`
+
+	// Version is the campaign revision that served the request; only set
+	// for debug requests.
+	Version int64 `json:"version,omitempty"`
+
+	// ClearingPrice is the CPM in USD the campaign won its slot at, as set
+	// by the auction.
+	ClearingPrice float64 `json:"price,omitempty"`
+
+	// The rest is for programmatic and video renderers and is not part of
+	// the JSON delivery response.
+	Name     string                `json:"-"`
+	Price    float64               `json:"-"`
+	Markup   string                `json:"-"`
+	Landing  string                `json:"-"`
+	Creative string                `json:"-"`
+	Video    storage.VideoCreative `json:"-"`
+
+	// For competitive separation in the auction.
+	Advertiser string `json:"-"`
+	Category   string `json:"-"`
 }
 
 // Generic rule for one dimension
@@ -16,16 +39,81 @@ type Rule struct {
 }
 
 type CampaignWithRules struct {
-	ID     string
-	Name   string
-	Image  string
-	CTA    string
-	Status string // "ACTIVE" | "INACTIVE"
-	Rules  []Rule
+	ID       string
+	Name     string
+	Image    string
+	CTA      string
+	Status   string // "ACTIVE" | "INACTIVE"
+	Version  int64
+	Price    float64 // bid CPM in USD
+	Markup   string
+	Landing  string // click-through URL
+	Creative string // storage.CreativeBanner or storage.CreativeVideo
+	Video    storage.VideoCreative
+	Budget   storage.Budget
+	Rules    []Rule
+
+	Advertiser string // advertiser ID
+	Category   string // IAB content category, upper-cased
+}
+
+// Advertiser holds the settings that apply to all of an advertiser's
+// campaigns.
+type Advertiser struct {
+	ID          string
+	Version     int64
+	Status      string // "ACTIVE" | "INACTIVE"
+	BlockedApps []string
+}
+
+// Publisher is an app's settings, normalized at snapshot time: the lowest
+// bid it takes and what it blocks.
+type Publisher struct {
+	AppID              string
+	Version            int64
+	Floor              float64
+	BlockedAdvertisers []string
+	BlockedCategories  []string
+	BlockedCampaigns   []string
+}
+
+// Why a campaign does not serve a request, as reported by Explain.
+const (
+	ReasonTargeting            = "targeting"
+	ReasonAdvertiserInactive   = "advertiser_inactive"
+	ReasonAdvertiserBlockedApp = "advertiser_blocked_app"
+	ReasonBlockedCampaign      = "blocked_campaign"
+	ReasonBlockedAdvertiser    = "blocked_advertiser"
+	ReasonBlockedCategory      = "blocked_category"
+	ReasonBelowFloor           = "below_floor"
+	ReasonBudget               = "budget"
+)
+
+// Explanation says whether a campaign serves a request and, if not, why.
+type Explanation struct {
+	CampaignID string `json:"cid"`
+	Matched    bool   `json:"matched"`
+	Reason     string `json:"reason,omitempty"`
 }
 
 type MatchRequest struct {
 	AppID   string // lower-cased at handler
 	Country string // upper-cased at handler
 	OS      string // lower-cased at handler
-}
\ No newline at end of file
+	Debug   bool   // include campaign versions in the result
+	Slots   int    // ad slots to fill; 0 means the auction's default
+
+	// Optional context from POST /v1/delivery. Targeting rules only look at
+	// AppID, Country and OS today; the rest is carried so that new
+	// dimensions and partner adapters do not need another request type.
+	OSVersion   string
+	Make        string
+	Model       string
+	Region      string
+	City        string
+	Lat, Lon    *float64
+	AppCategory string
+	UserID      string
+	Consent     string // e.g. an IAB TCF consent string
+	PlacementID string
+}
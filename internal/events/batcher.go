@@ -0,0 +1,162 @@
+package events
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+
+	"ad-targeting-engine/internal/observability"
+)
+
+var (
+	ErrQueueFull = errors.New("event queue full")
+	ErrClosed    = errors.New("event sink closed")
+)
+
+// BatchConfig tunes a Batcher.
+type BatchConfig struct {
+	QueueSize     int           // events held between Record and the writer
+	BatchSize     int           // most events per Write
+	FlushInterval time.Duration // longest an event waits for its batch to fill
+
+	// Block is how long Record waits for room in a full queue before it
+	// drops the event. Zero drops at once: a slow writer never holds up
+	// requests for longer than this.
+	Block time.Duration
+}
+
+// Batcher is a Sink that queues events and writes them in batches from a
+// goroutine of its own. When the writer falls behind and the queue fills,
+// Record pushes back for up to BatchConfig.Block and then drops the event
+// with ErrQueueFull. Close writes out whatever is queued.
+type Batcher struct {
+	w   Writer
+	cfg BatchConfig
+
+	queue chan Event
+	stop  chan struct{}
+	done  chan struct{}
+
+	mu     sync.RWMutex // held for reading while sending on queue
+	closed bool
+	err    error // of the writer's Close
+}
+
+// NewBatcher starts a batcher writing to w. Non-positive sizes and
+// intervals get defaults.
+func NewBatcher(w Writer, cfg BatchConfig) *Batcher {
+	if cfg.QueueSize <= 0 {
+		cfg.QueueSize = 10_000
+	}
+	if cfg.BatchSize <= 0 {
+		cfg.BatchSize = 500
+	}
+	if cfg.FlushInterval <= 0 {
+		cfg.FlushInterval = time.Second
+	}
+	b := &Batcher{
+		w:     w,
+		cfg:   cfg,
+		queue: make(chan Event, cfg.QueueSize),
+		stop:  make(chan struct{}),
+		done:  make(chan struct{}),
+	}
+	go b.run()
+	return b
+}
+
+func (b *Batcher) Record(ctx context.Context, e Event) error {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	if b.closed {
+		observability.EventsDropped.WithLabelValues("closed").Inc()
+		return ErrClosed
+	}
+	select {
+	case b.queue <- e:
+		return nil
+	default:
+	}
+	if b.cfg.Block > 0 {
+		t := time.NewTimer(b.cfg.Block)
+		defer t.Stop()
+		select {
+		case b.queue <- e:
+			return nil
+		case <-t.C:
+		case <-ctx.Done():
+		}
+	}
+	observability.EventsDropped.WithLabelValues("queue_full").Inc()
+	return ErrQueueFull
+}
+
+// Close stops accepting events, writes out the queue and closes the
+// writer. It returns ctx's error if that takes longer than ctx allows; the
+// flush then carries on in the background.
+func (b *Batcher) Close(ctx context.Context) error {
+	b.mu.Lock()
+	if !b.closed {
+		b.closed = true
+		close(b.stop)
+	}
+	b.mu.Unlock()
+	select {
+	case <-b.done:
+		return b.err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (b *Batcher) run() {
+	defer close(b.done)
+	tick := time.NewTicker(b.cfg.FlushInterval)
+	defer tick.Stop()
+
+	batch := make([]Event, 0, b.cfg.BatchSize)
+	for {
+		select {
+		case e := <-b.queue:
+			if batch = append(batch, e); len(batch) == b.cfg.BatchSize {
+				batch = b.flush(batch)
+			}
+		case <-tick.C:
+			// also on an empty batch, so writers can rotate idle files
+			batch = b.flush(batch)
+		case <-b.stop:
+			// Close holds off new sends, so the queue only drains from here
+			for {
+				select {
+				case e := <-b.queue:
+					if batch = append(batch, e); len(batch) == b.cfg.BatchSize {
+						batch = b.flush(batch)
+					}
+				default:
+					b.flush(batch)
+					if err := b.w.Close(); err != nil {
+						b.err = err
+						log.Error().Err(err).Msg("close event writer")
+					}
+					return
+				}
+			}
+		}
+	}
+}
+
+// flush writes batch and returns it emptied. Failed batches are dropped:
+// the writer is expected to have retried what it could.
+func (b *Batcher) flush(batch []Event) []Event {
+	observability.EventsQueued.Set(float64(len(b.queue)))
+	if err := b.w.Write(context.Background(), batch); err != nil {
+		log.Error().Err(err).Int("events", len(batch)).Msg("write events")
+		observability.EventsDropped.WithLabelValues("write_error").Add(float64(len(batch)))
+	} else {
+		observability.EventsWritten.Add(float64(len(batch)))
+	}
+	return batch[:0]
+}
@@ -0,0 +1,58 @@
+package events
+
+import (
+	"context"
+	"math/rand/v2"
+	"time"
+
+	"github.com/rs/zerolog/log"
+
+	"ad-targeting-engine/internal/engine"
+	"ad-targeting-engine/internal/storage"
+)
+
+// Sampler records delivery decisions for a fraction of requests. A nil
+// Sampler records nothing.
+type Sampler struct {
+	sink  Sink
+	rate  float64
+	every Sink
+	rand  func() float64
+}
+
+// NewSampler records about rate (0 to 1) of the decisions it is given to
+// sink. every, if not nil, gets all of them, for counters that must not be
+// sampled.
+func NewSampler(sink Sink, rate float64, every Sink) *Sampler {
+	return &Sampler{sink: sink, rate: rate, every: every, rand: rand.Float64}
+}
+
+// Decision records the answer to a delivery request. meta is the snapshot
+// that answered it.
+func (s *Sampler) Decision(ctx context.Context, requestID string, req engine.MatchRequest,
+	meta storage.SnapshotMeta, matched []engine.Campaign) {
+	if s == nil {
+		return
+	}
+	sampled := s.rate >= 1 || (s.rate > 0 && s.rand() < s.rate)
+	if !sampled && s.every == nil {
+		return
+	}
+	ids := make([]string, len(matched))
+	for i, c := range matched {
+		ids[i] = c.ID
+	}
+	e := Event{Type: Decision, Time: time.Now().UTC(), RequestID: requestID,
+		App: req.AppID, OS: req.OS, Country: req.Country,
+		Decision: &DecisionDetail{SnapshotVersion: meta.Version, Matched: ids}}
+	if s.every != nil {
+		if err := s.every.Record(ctx, e); err != nil {
+			log.Debug().Err(err).Str("request_id", requestID).Msg("count decision")
+		}
+	}
+	if sampled {
+		if err := s.sink.Record(ctx, e); err != nil {
+			log.Debug().Err(err).Str("request_id", requestID).Msg("record decision")
+		}
+	}
+}
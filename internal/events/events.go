@@ -0,0 +1,89 @@
+// Package events records what happens to served ads: delivery decisions,
+// impressions, clicks and playback progress.
+//
+// Producers call a Sink on the request path. Batcher is the Sink used in
+// production: it queues events and hands them in batches to a Writer, such
+// as FileWriter, off the request path.
+package events
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// Type is the kind of an event.
+type Type string
+
+const (
+	Decision   Type = "decision" // a delivery request was answered
+	Impression Type = "impression"
+	Click      Type = "click"
+	Playback   Type = "playback" // a VAST progress event; Name says which
+)
+
+// Event is one recorded ad event.
+type Event struct {
+	Type       Type      `json:"type"`
+	Time       time.Time `json:"time"`
+	RequestID  string    `json:"request_id"`
+	CampaignID string    `json:"campaign_id,omitempty"`
+	Creative   string    `json:"creative,omitempty"`
+	Name       string    `json:"name,omitempty"`  // playback event, e.g. "midpoint"
+	Price      float64   `json:"price,omitempty"` // CPM in USD the campaign was served at
+
+	// The delivery request the event belongs to.
+	App     string `json:"app,omitempty"`
+	OS      string `json:"os,omitempty"`
+	Country string `json:"country,omitempty"`
+
+	Decision *DecisionDetail `json:"decision,omitempty"` // Decision events only
+}
+
+// DecisionDetail is what a delivery request got.
+type DecisionDetail struct {
+	SnapshotVersion uint64   `json:"snapshot_version"`
+	Matched         []string `json:"matched"`
+}
+
+// Sink receives events. Record is called on the request path and should
+// return quickly.
+type Sink interface {
+	Record(ctx context.Context, e Event) error
+}
+
+// Writer stores batches of events for a Batcher. Write is only called from
+// one goroutine at a time.
+type Writer interface {
+	Write(ctx context.Context, batch []Event) error
+	Close() error
+}
+
+// Tee records every event to each of its sinks in turn.
+type Tee []Sink
+
+func (t Tee) Record(ctx context.Context, e Event) error {
+	var errs []error
+	for _, s := range t {
+		if err := s.Record(ctx, e); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// LogSink writes each event as a log line.
+type LogSink struct{}
+
+func (LogSink) Record(_ context.Context, e Event) error {
+	l := log.Info().Str("type", string(e.Type)).Time("time", e.Time).Str("request_id", e.RequestID).
+		Str("campaign_id", e.CampaignID).Str("creative", e.Creative).Str("name", e.Name).
+		Float64("price", e.Price).Str("app", e.App).Str("os", e.OS).Str("country", e.Country)
+	if d := e.Decision; d != nil {
+		l = l.Uint64("snapshot_version", d.SnapshotVersion).Strs("matched", d.Matched)
+	}
+	l.Msg("ad event")
+	return nil
+}
@@ -0,0 +1,210 @@
+package events
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"ad-targeting-engine/internal/engine"
+	"ad-targeting-engine/internal/storage"
+)
+
+func ev(id string) Event { return Event{Type: Impression, RequestID: id, CampaignID: "c"} }
+
+func ids(es []Event) []string {
+	var out []string
+	for _, e := range es {
+		out = append(out, e.RequestID)
+	}
+	return out
+}
+
+func TestRing(t *testing.T) {
+	r := NewRing(3)
+	require.NoError(t, r.Record(context.Background(), ev("1")))
+	assert.Equal(t, []string{"1"}, ids(r.Events()))
+	require.NoError(t, r.Write(context.Background(), []Event{ev("2"), ev("3"), ev("4")}))
+	assert.Equal(t, []string{"2", "3", "4"}, ids(r.Events()), "oldest first, capped at size")
+	require.NoError(t, r.Record(context.Background(), ev("5")))
+	assert.Equal(t, []string{"3", "4", "5"}, ids(r.Events()))
+}
+
+// blockingWriter records batches and holds every Write until released.
+type blockingWriter struct {
+	mu      sync.Mutex
+	batches [][]Event
+	release chan struct{}
+	closed  bool
+}
+
+func (w *blockingWriter) Write(_ context.Context, batch []Event) error {
+	if len(batch) == 0 {
+		return nil
+	}
+	<-w.release
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.batches = append(w.batches, append([]Event(nil), batch...))
+	return nil
+}
+
+func (w *blockingWriter) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.closed = true
+	return nil
+}
+
+func (w *blockingWriter) written() (n int, batches int) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	for _, b := range w.batches {
+		n += len(b)
+	}
+	return n, len(w.batches)
+}
+
+func TestBatcher(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("batches and flushes on close", func(t *testing.T) {
+		w := &blockingWriter{release: make(chan struct{})}
+		close(w.release)
+		b := NewBatcher(w, BatchConfig{QueueSize: 10, BatchSize: 2, FlushInterval: time.Hour})
+		for _, id := range []string{"1", "2", "3"} {
+			require.NoError(t, b.Record(ctx, ev(id)))
+		}
+		require.Eventually(t, func() bool { n, _ := w.written(); return n == 2 }, time.Second, time.Millisecond,
+			"a full batch is written at once")
+
+		require.NoError(t, b.Close(ctx))
+		n, batches := w.written()
+		assert.Equal(t, 3, n, "the partial batch is flushed on close")
+		assert.Equal(t, 2, batches)
+		assert.True(t, w.closed)
+		assert.ErrorIs(t, b.Record(ctx, ev("4")), ErrClosed)
+	})
+
+	t.Run("flush interval", func(t *testing.T) {
+		w := &blockingWriter{release: make(chan struct{})}
+		close(w.release)
+		b := NewBatcher(w, BatchConfig{BatchSize: 100, FlushInterval: 5 * time.Millisecond})
+		defer b.Close(ctx)
+		require.NoError(t, b.Record(ctx, ev("1")))
+		assert.Eventually(t, func() bool { n, _ := w.written(); return n == 1 }, time.Second, time.Millisecond)
+	})
+
+	t.Run("backpressure", func(t *testing.T) {
+		w := &blockingWriter{release: make(chan struct{})}
+		b := NewBatcher(w, BatchConfig{QueueSize: 1, BatchSize: 1, FlushInterval: time.Hour, Block: 10 * time.Millisecond})
+
+		// the writer holds the first batch, the queue holds the second
+		require.NoError(t, b.Record(ctx, ev("1")))
+		require.Eventually(t, func() bool { return len(b.queue) == 0 }, time.Second, time.Millisecond)
+		require.NoError(t, b.Record(ctx, ev("2")))
+
+		start := time.Now()
+		err := b.Record(ctx, ev("3"))
+		assert.ErrorIs(t, err, ErrQueueFull)
+		assert.GreaterOrEqual(t, time.Since(start), 10*time.Millisecond, "waits for room before dropping")
+
+		cctx, cancel := context.WithCancel(ctx)
+		cancel()
+		assert.True(t, errors.Is(b.Record(cctx, ev("4")), ErrQueueFull), "gives up with the request")
+
+		close(w.release)
+		require.NoError(t, b.Close(ctx))
+		n, _ := w.written()
+		assert.Equal(t, 2, n)
+	})
+}
+
+func readLines(t *testing.T, path string) []Event {
+	t.Helper()
+	f, err := os.Open(path)
+	require.NoError(t, err)
+	defer f.Close()
+	var out []Event
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		var e Event
+		require.NoError(t, json.Unmarshal(sc.Bytes(), &e))
+		out = append(out, e)
+	}
+	require.NoError(t, sc.Err())
+	return out
+}
+
+func TestFileWriter(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	line, err := json.Marshal(ev("1"))
+	require.NoError(t, err)
+
+	// room for two lines per file
+	w, err := NewFileWriter(dir, "events", int64(2*(len(line)+1)), time.Hour)
+	require.NoError(t, err)
+	now := time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC)
+	w.now = func() time.Time { return now }
+
+	require.NoError(t, w.Write(ctx, []Event{ev("1"), ev("2"), ev("3")}))
+	require.NoError(t, w.Write(ctx, nil))
+	now = now.Add(time.Hour)
+	require.NoError(t, w.Write(ctx, nil), "an idle file is closed once it is too old")
+	assert.Nil(t, w.f)
+	require.NoError(t, w.Write(ctx, []Event{ev("4")}))
+	require.NoError(t, w.Close())
+
+	files, err := filepath.Glob(filepath.Join(dir, "events-*.ndjson"))
+	require.NoError(t, err)
+	require.Len(t, files, 3, "rotated by size, then by age")
+	assert.Equal(t, filepath.Join(dir, "events-20261018T120000Z-000001.ndjson"), files[0])
+	assert.Equal(t, []string{"1", "2"}, ids(readLines(t, files[0])))
+	assert.Equal(t, []string{"3"}, ids(readLines(t, files[1])))
+	assert.Equal(t, []string{"4"}, ids(readLines(t, files[2])))
+}
+
+func TestSampler(t *testing.T) {
+	ctx := context.Background()
+	ring := NewRing(10)
+	req := engine.MatchRequest{AppID: "com.a", OS: "ios", Country: "US"}
+	meta := storage.SnapshotMeta{Version: 7}
+	matched := []engine.Campaign{{ID: "a"}, {ID: "b"}}
+
+	var nilSampler *Sampler
+	nilSampler.Decision(ctx, "r0", req, meta, matched)
+
+	every := NewRing(10)
+	s := NewSampler(ring, 0.5, every)
+	draws := []float64{0.7, 0.2}
+	s.rand = func() float64 { d := draws[0]; draws = draws[1:]; return d }
+	s.Decision(ctx, "r1", req, meta, matched)
+	s.Decision(ctx, "r2", req, meta, nil)
+
+	got := ring.Events()
+	require.Len(t, got, 1, "only draws below the rate are recorded")
+	assert.Equal(t, Decision, got[0].Type)
+	assert.Equal(t, "r2", got[0].RequestID)
+	assert.Equal(t, []string{"com.a", "ios", "US"}, []string{got[0].App, got[0].OS, got[0].Country})
+	assert.Equal(t, &DecisionDetail{SnapshotVersion: 7, Matched: []string{}}, got[0].Decision)
+	assert.Equal(t, []string{"r1", "r2"}, ids(every.Events()), "every sees all decisions")
+
+	NewSampler(ring, 1, nil).Decision(ctx, "r3", req, meta, matched)
+	assert.Equal(t, []string{"a", "b"}, ring.Events()[1].Decision.Matched, "rate 1 records everything")
+}
+
+func TestTee(t *testing.T) {
+	a, b := NewRing(1), NewRing(1)
+	require.NoError(t, Tee{a, b}.Record(context.Background(), ev("1")))
+	assert.Equal(t, []string{"1"}, ids(a.Events()))
+	assert.Equal(t, []string{"1"}, ids(b.Events()))
+}
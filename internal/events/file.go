@@ -0,0 +1,110 @@
+package events
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// FileWriter writes events as NDJSON, one JSON object per line, to files
+// in a directory. It starts a new file once the current one would grow past
+// maxBytes or is maxAge old; zero disables either limit. Files are opened
+// on the first event after a rotation, so an idle writer leaves none empty.
+type FileWriter struct {
+	dir      string
+	prefix   string
+	maxBytes int64
+	maxAge   time.Duration
+	now      func() time.Time
+
+	f      *os.File
+	buf    *bufio.Writer
+	size   int64
+	opened time.Time
+	seq    int
+}
+
+// NewFileWriter writes to files named prefix-<UTC time>-<seq>.ndjson in
+// dir, which is created if missing.
+func NewFileWriter(dir, prefix string, maxBytes int64, maxAge time.Duration) (*FileWriter, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("create event dir: %w", err)
+	}
+	return &FileWriter{dir: dir, prefix: prefix, maxBytes: maxBytes, maxAge: maxAge, now: time.Now}, nil
+}
+
+// Write appends batch and flushes it to the OS. An empty batch only
+// rotates a file that is too old.
+func (w *FileWriter) Write(_ context.Context, batch []Event) error {
+	if w.f != nil && w.maxAge > 0 && w.now().Sub(w.opened) >= w.maxAge {
+		if err := w.closeFile(); err != nil {
+			return err
+		}
+	}
+	for _, e := range batch {
+		line, err := json.Marshal(e)
+		if err != nil {
+			return fmt.Errorf("encode event: %w", err)
+		}
+		line = append(line, '\n')
+		if w.f != nil && w.maxBytes > 0 && w.size > 0 && w.size+int64(len(line)) > w.maxBytes {
+			if err := w.closeFile(); err != nil {
+				return err
+			}
+		}
+		if w.f == nil {
+			if err := w.open(); err != nil {
+				return err
+			}
+		}
+		n, err := w.buf.Write(line)
+		w.size += int64(n)
+		if err != nil {
+			return fmt.Errorf("write events: %w", err)
+		}
+	}
+	if w.buf == nil {
+		return nil
+	}
+	if err := w.buf.Flush(); err != nil {
+		return fmt.Errorf("write events: %w", err)
+	}
+	return nil
+}
+
+// Close flushes and closes the current file.
+func (w *FileWriter) Close() error {
+	if w.f == nil {
+		return nil
+	}
+	return w.closeFile()
+}
+
+func (w *FileWriter) open() error {
+	now := w.now()
+	w.seq++
+	name := filepath.Join(w.dir, fmt.Sprintf("%s-%s-%06d.ndjson", w.prefix, now.UTC().Format("20060102T150405Z"), w.seq))
+	f, err := os.OpenFile(name, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return fmt.Errorf("open event file: %w", err)
+	}
+	w.f, w.buf, w.size, w.opened = f, bufio.NewWriter(f), 0, now
+	return nil
+}
+
+func (w *FileWriter) closeFile() error {
+	flushErr := w.buf.Flush()
+	err := w.f.Close()
+	w.f, w.buf = nil, nil
+	if flushErr != nil {
+		return fmt.Errorf("flush event file: %w", flushErr)
+	}
+	if err != nil {
+		return fmt.Errorf("close event file: %w", err)
+	}
+	return nil
+}
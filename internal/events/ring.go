@@ -0,0 +1,55 @@
+package events
+
+import (
+	"context"
+	"sync"
+)
+
+// Ring keeps the last events it was given in memory. It is a Sink and a
+// Writer, for tests and for looking at recent traffic.
+type Ring struct {
+	mu   sync.Mutex
+	buf  []Event
+	next int
+	full bool
+}
+
+func NewRing(size int) *Ring {
+	return &Ring{buf: make([]Event, max(size, 1))}
+}
+
+func (r *Ring) Record(_ context.Context, e Event) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.add(e)
+	return nil
+}
+
+func (r *Ring) Write(_ context.Context, batch []Event) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, e := range batch {
+		r.add(e)
+	}
+	return nil
+}
+
+func (r *Ring) Close() error { return nil }
+
+// Events returns the events held, oldest first.
+func (r *Ring) Events() []Event {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if !r.full {
+		return append([]Event(nil), r.buf[:r.next]...)
+	}
+	return append(append([]Event(nil), r.buf[r.next:]...), r.buf[:r.next]...)
+}
+
+func (r *Ring) add(e Event) {
+	r.buf[r.next] = e
+	r.next++
+	if r.next == len(r.buf) {
+		r.next, r.full = 0, true
+	}
+}
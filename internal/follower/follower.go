@@ -0,0 +1,117 @@
+// Package follower keeps a delivery engine in sync with a builder node's
+// snapshot endpoint, so edge nodes can serve without a database.
+package follower
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	"github.com/rs/zerolog/log"
+
+	"ad-targeting-engine/internal/engine"
+	"ad-targeting-engine/internal/observability"
+)
+
+// maxSnapshotBytes bounds a downloaded snapshot.
+const maxSnapshotBytes = 1 << 30
+
+type Follower struct {
+	url    string
+	token  string
+	wait   time.Duration
+	eng    *engine.DeliveryEngine
+	client *http.Client
+
+	etag     string
+	lastSync atomic.Int64 // unix nanos of the last poll that left us current
+}
+
+// New returns a follower of the snapshot endpoint at url. wait is how long
+// each long-poll may be held open by the builder.
+func New(url, token string, wait time.Duration, eng *engine.DeliveryEngine) *Follower {
+	f := &Follower{
+		url:    url,
+		token:  token,
+		wait:   wait,
+		eng:    eng,
+		client: &http.Client{Timeout: wait + 30*time.Second},
+	}
+	f.lastSync.Store(time.Now().UnixNano())
+	return f
+}
+
+// Run long-polls the builder until ctx is done, backing off on errors.
+func (f *Follower) Run(ctx context.Context) {
+	backoff := time.Second
+	for ctx.Err() == nil {
+		err := f.poll(ctx)
+		observability.FollowerAge.Set(f.Age().Seconds())
+		if err == nil {
+			backoff = time.Second
+			continue
+		}
+		if ctx.Err() != nil {
+			break
+		}
+		observability.FollowerErrors.Inc()
+		log.Error().Err(err).Dur("retry_in", backoff).Dur("age", f.Age()).Msg("snapshot poll")
+		select {
+		case <-ctx.Done():
+		case <-time.After(backoff):
+		}
+		backoff = min(2*backoff, 30*time.Second)
+	}
+	log.Info().Msg("follower stopped")
+}
+
+// Age is how long it has been since the follower last confirmed it serves
+// the builder's current snapshot. It grows from startup until the first
+// successful sync.
+func (f *Follower) Age() time.Duration {
+	return time.Since(time.Unix(0, f.lastSync.Load()))
+}
+
+func (f *Follower) poll(ctx context.Context) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet,
+		fmt.Sprintf("%s?wait=%d", f.url, int(f.wait.Seconds())), nil)
+	if err != nil {
+		return fmt.Errorf("build snapshot request: %w", err)
+	}
+	req.Header.Set("Authorization", "Bearer "+f.token)
+	if f.etag != "" {
+		req.Header.Set("If-None-Match", f.etag)
+	}
+
+	resp, err := f.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("fetch snapshot: %w", err)
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusNotModified:
+		f.lastSync.Store(time.Now().UnixNano())
+		return nil
+	case http.StatusOK:
+	default:
+		return fmt.Errorf("fetch snapshot: builder answered %s", resp.Status)
+	}
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxSnapshotBytes))
+	if err != nil {
+		return fmt.Errorf("read snapshot: %w", err)
+	}
+	meta, err := f.eng.LoadEncoded(data)
+	if err != nil {
+		return fmt.Errorf("load snapshot: %w", err)
+	}
+	f.etag = resp.Header.Get("ETag")
+	f.lastSync.Store(time.Now().UnixNano())
+	log.Info().Uint64("version", meta.Version).Int("bytes", len(data)).
+		Time("built_at", meta.BuiltAt).Msg("snapshot replicated")
+	return nil
+}
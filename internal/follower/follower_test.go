@@ -0,0 +1,66 @@
+package follower
+
+import (
+	"context"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"ad-targeting-engine/internal/api"
+	"ad-targeting-engine/internal/engine"
+	"ad-targeting-engine/internal/storage"
+)
+
+func TestFollower_ReplicatesBuilder(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	st := storage.NewMemoryStore(storage.CampaignRow{ID: "a", Name: "A", Status: "ACTIVE",
+		Rules: []storage.RuleRow{{Dimension: "country", IsInclusion: true, Values: []string{"US"}}}})
+	builder := engine.NewEngine()
+	require.NoError(t, builder.BuildSnapshot(ctx, st))
+
+	srv := httptest.NewServer(api.Router(api.Handlers{
+		Delivery: api.NewDeliveryHandler(builder),
+		Snapshot: api.NewSnapshotHandler(builder, "secret", time.Second),
+	}))
+	defer srv.Close()
+
+	eng := engine.NewEngine()
+	inSync := func() bool {
+		a, b := eng.Snapshot(), builder.Snapshot()
+		return a.Version == b.Version && a.Hash == b.Hash
+	}
+	f := New(srv.URL+"/internal/v1/snapshot", "secret", time.Second, eng)
+	go f.Run(ctx)
+
+	require.Eventually(t, inSync, 2*time.Second, 10*time.Millisecond)
+	req := engine.MatchRequest{Country: "US"}
+	assert.Len(t, eng.Match(ctx, req), 1)
+
+	// the follower is parked in a long-poll; a rebuild wakes it up
+	require.NoError(t, st.CreateCampaign(ctx, storage.CampaignRow{ID: "b", Name: "B", Status: "ACTIVE"}))
+	require.NoError(t, builder.BuildSnapshot(ctx, st))
+	require.Eventually(t, inSync, 2*time.Second, 10*time.Millisecond)
+	assert.Len(t, eng.Match(ctx, req), 2)
+	assert.Less(t, f.Age(), time.Second)
+}
+
+func TestFollower_AgeGrowsWhenBuilderUnreachable(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+
+	srv := httptest.NewServer(api.Router(api.Handlers{
+		Delivery: api.NewDeliveryHandler(engine.NewEngine()),
+		Snapshot: api.NewSnapshotHandler(engine.NewEngine(), "secret", time.Second),
+	}))
+	defer srv.Close()
+
+	f := New(srv.URL+"/internal/v1/snapshot", "wrong", time.Second, engine.NewEngine())
+	f.lastSync.Store(time.Now().Add(-time.Hour).UnixNano())
+	f.Run(ctx)
+	assert.Greater(t, f.Age(), time.Hour, "a rejected poll is not a sync")
+}
@@ -0,0 +1,46 @@
+// Package geo normalizes location codes.
+package geo
+
+import (
+	"strings"
+
+	"golang.org/x/text/language"
+)
+
+// uk is the one alias ISO 3166-1 still reserves for its country (GB). The
+// other codes Canonicalize rewrites, such as DD, BU and ZR, were deleted
+// from the standard.
+var uk = language.MustParseRegion("UK")
+
+// withdrawn holds deleted codes that Canonicalize keeps because the country
+// split and they have no single successor.
+var withdrawn = map[string]bool{"AN": true, "CS": true, "NT": true, "SU": true, "YU": true}
+
+// CountryAlpha2 converts an ISO 3166-1 alpha-2 or alpha-3 code, in any
+// case, to upper-case alpha-2, with UK canonicalized to GB. ok is false for
+// anything that is not an assigned country code, including deleted codes
+// and exceptional reservations without a numeric code, such as UN and AC.
+func CountryAlpha2(code string) (string, bool) {
+	code = strings.TrimSpace(code)
+	if n := len(code); n != 2 && n != 3 || !isLetters(code) {
+		return "", false
+	}
+	r, err := language.ParseRegion(code)
+	if err != nil || !r.IsCountry() {
+		return "", false
+	}
+	c := r.Canonicalize()
+	if c != r && r != uk || c.M49() == 0 || withdrawn[c.String()] {
+		return "", false
+	}
+	return c.String(), true
+}
+
+func isLetters(s string) bool {
+	for _, c := range s {
+		if (c < 'a' || c > 'z') && (c < 'A' || c > 'Z') {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,37 @@
+package geo
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCountryAlpha2(t *testing.T) {
+	tests := []struct {
+		code string
+		want string
+		ok   bool
+	}{
+		{"us", "US", true},
+		{" DEU ", "DE", true},
+		{"GB", "GB", true},
+		{"UK", "GB", true},
+		{"gbr", "GB", true},
+		{"SU", "", false},
+		{"SUN", "", false},
+		{"YU", "", false},
+		{"DD", "", false},
+		{"UN", "", false},
+		{"AC", "", false},
+		{"EU", "", false},
+		{"Germany", "", false},
+		{"", "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.code, func(t *testing.T) {
+			got, ok := CountryAlpha2(tt.code)
+			assert.Equal(t, tt.ok, ok)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
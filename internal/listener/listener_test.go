@@ -0,0 +1,41 @@
+package listener
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"ad-targeting-engine/internal/engine"
+	"ad-targeting-engine/internal/storage"
+)
+
+func TestListenAndRefresh_FollowsWrites(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	st := storage.NewMemoryStore(storage.CampaignRow{ID: "spotify", Name: "Spotify", Status: "ACTIVE"})
+	eng := engine.NewEngine()
+	go ListenAndRefresh(ctx, st, eng, time.Hour, time.Hour)
+
+	req := engine.MatchRequest{AppID: "com.any", Country: "US", OS: "android"}
+	matched := func(want ...string) func() bool {
+		return func() bool {
+			var got []string
+			for _, c := range eng.Match(ctx, req) {
+				got = append(got, c.ID)
+			}
+			return assert.ObjectsAreEqual(want, got)
+		}
+	}
+	require.Eventually(t, matched("spotify"), time.Second, 5*time.Millisecond)
+
+	require.NoError(t, st.CreateCampaign(ctx, storage.CampaignRow{ID: "duolingo", Name: "Duolingo", Status: "ACTIVE",
+		Rules: []storage.RuleRow{{Dimension: "os", IsInclusion: true, Values: []string{"android"}}}}))
+	require.Eventually(t, matched("duolingo", "spotify"), time.Second, 5*time.Millisecond)
+
+	require.NoError(t, st.DeleteCampaign(ctx, "spotify", 1))
+	require.Eventually(t, matched("duolingo"), time.Second, 5*time.Millisecond)
+}
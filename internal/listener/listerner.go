@@ -2,7 +2,6 @@ package listener
 
 import (
 	"context"
-	"math/rand"
 	"time"
 
 	"github.com/rs/zerolog/log"
@@ -11,53 +10,96 @@ import (
 	"ad-targeting-engine/internal/storage"
 )
 
-func ListenAndRefresh(ctx context.Context, st *storage.Store, eng *engine.DeliveryEngine, channel string, baseBackoff time.Duration) {
-	conn, err := st.PgxPool().Acquire(ctx)
+// eventBatch bounds how many outbox rows are read per round trip.
+const eventBatch = 1000
+
+// pruneEvery is how often processed outbox rows are garbage collected.
+const pruneEvery = time.Hour
+
+// ListenAndRefresh keeps eng in sync with the change_events outbox. A
+// subscription wake-up is only a signal: on each one the listener reads
+// every event after the last processed seq and applies them in order. A full
+// resync runs every resync interval so that transactions committing out of
+// seq order are never missed for long, and processed events older than
+// retention are pruned.
+func ListenAndRefresh(ctx context.Context, st storage.Repository, eng *engine.DeliveryEngine, resync, retention time.Duration) {
+	changes, err := st.Subscribe(ctx)
 	if err != nil {
-		log.Error().Err(err).Msg("acquire conn for listen")
+		log.Error().Err(err).Msg("subscribe to changes")
 		return
 	}
-	defer conn.Release()
 
-	if channel == "" {
-		channel = st.ListenChannel()
-	}
-	if _, err = conn.Exec(ctx, "LISTEN "+channel); err != nil {
-		log.Error().Err(err).Str("channel", channel).Msg("listen")
-		return
+	lastSeq, err := fullResync(ctx, st, eng)
+	if err != nil {
+		log.Error().Err(err).Msg("initial snapshot error")
 	}
-	log.Info().Str("channel", channel).Msg("listening for DB changes")
+	lastPrune := time.Now()
+
+	ticker := time.NewTicker(resync)
+	defer ticker.Stop()
 
-	var lastRefresh time.Time
 	for {
 		select {
 		case <-ctx.Done():
 			log.Info().Msg("listener stopped")
 			return
-		default:
-			ntf, err := conn.Conn().WaitForNotification(ctx)
-			if err != nil {
-				backoff := jitter(baseBackoff)
-				log.Error().Err(err).Dur("retry_in", backoff).Msg("notify wait error")
-				time.Sleep(backoff)
-				continue
+		case _, ok := <-changes:
+			if !ok {
+				log.Info().Msg("listener stopped")
+				return
+			}
+			log.Debug().Int64("after_seq", lastSeq).Msg("db change; applying events")
+			if lastSeq, err = catchUp(ctx, st, eng, lastSeq); err != nil {
+				log.Error().Err(err).Msg("apply change events error")
 			}
-			if time.Since(lastRefresh) < 200*time.Millisecond {
-				continue // debounce burst of notifications
+		case <-ticker.C:
+			if seq, err := fullResync(ctx, st, eng); err != nil {
+				log.Error().Err(err).Msg("periodic resync error")
+			} else {
+				lastSeq = seq
 			}
-			lastRefresh = time.Now()
-			log.Info().Str("channel", ntf.Channel).Msg("db change; refreshing snapshot")
-			if err := eng.BuildSnapshot(ctx, st); err != nil {
-				log.Error().Err(err).Msg("refresh snapshot error")
+			if time.Since(lastPrune) >= pruneEvery {
+				lastPrune = time.Now()
+				prune(ctx, st, lastSeq, retention)
 			}
 		}
 	}
 }
 
-func jitter(base time.Duration) time.Duration {
-	if base <= 0 {
-		base = time.Second
+// catchUp applies all outbox events after seq and returns the new high-water
+// mark. On error the returned seq is the last one fully applied.
+func catchUp(ctx context.Context, st storage.Repository, eng *engine.DeliveryEngine, seq int64) (int64, error) {
+	for {
+		evs, err := st.LoadChangeEventsSince(ctx, seq, eventBatch)
+		if err != nil {
+			return seq, err
+		}
+		if len(evs) == 0 {
+			return seq, nil
+		}
+		if err := eng.ApplyChanges(ctx, st, evs); err != nil {
+			return seq, err
+		}
+		seq = evs[len(evs)-1].Seq
+		log.Info().Int("events", len(evs)).Int64("seq", seq).Msg("applied change events")
+		if len(evs) < eventBatch {
+			return seq, nil
+		}
+	}
+}
+
+// fullResync rebuilds the snapshot from scratch. The seq comes from the
+// same transaction as the campaigns, so a lagging replica cannot hand back
+// campaigns older than it; anything committed later is replayed afterwards.
+func fullResync(ctx context.Context, st storage.Repository, eng *engine.DeliveryEngine) (int64, error) {
+	return eng.Rebuild(ctx, st)
+}
+
+func prune(ctx context.Context, st storage.Repository, upTo int64, retention time.Duration) {
+	n, err := st.PruneChangeEvents(ctx, upTo, time.Now().Add(-retention))
+	if err != nil {
+		log.Error().Err(err).Msg("prune change events")
+		return
 	}
-	factor := 0.5 + rand.Float64() // 0.5x–1.5x
-	return time.Duration(float64(base) * factor)
+	log.Info().Int64("deleted", n).Int64("up_to_seq", upTo).Msg("pruned change events")
 }
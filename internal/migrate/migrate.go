@@ -0,0 +1,320 @@
+// Package migrate applies the embedded, versioned SQL migrations and records
+// them in schema_migrations. Runs are serialized across processes with a
+// Postgres advisory lock, and each migration runs in its own transaction.
+package migrate
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"regexp"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/rs/zerolog/log"
+)
+
+// lockKey is the pg_advisory_lock key that serializes migration runs.
+const lockKey int64 = 0x6d6967726174 // "migrat"
+
+var fileRe = regexp.MustCompile(`^(\d+)_([a-z0-9_]+)\.(up|down)\.sql$`)
+
+type Migration struct {
+	Version int
+	Name    string
+	Up      string
+	Down    string
+}
+
+// Status is one migration and whether/when it was applied.
+type Status struct {
+	Version   int
+	Name      string
+	AppliedAt *time.Time
+}
+
+// Load reads NNN_name.up.sql / NNN_name.down.sql pairs from fsys, sorted by
+// version. Every version needs both halves.
+func Load(fsys fs.FS) ([]Migration, error) {
+	entries, err := fs.ReadDir(fsys, ".")
+	if err != nil {
+		return nil, fmt.Errorf("read migrations: %w", err)
+	}
+	byVersion := map[int]*Migration{}
+	for _, e := range entries {
+		m := fileRe.FindStringSubmatch(e.Name())
+		if m == nil {
+			continue
+		}
+		v, _ := strconv.Atoi(m[1])
+		body, err := fs.ReadFile(fsys, e.Name())
+		if err != nil {
+			return nil, fmt.Errorf("read %s: %w", e.Name(), err)
+		}
+		mig, ok := byVersion[v]
+		if !ok {
+			mig = &Migration{Version: v, Name: m[2]}
+			byVersion[v] = mig
+		} else if mig.Name != m[2] {
+			return nil, fmt.Errorf("migration %d has two names: %s and %s", v, mig.Name, m[2])
+		}
+		if m[3] == "up" {
+			mig.Up = string(body)
+		} else {
+			mig.Down = string(body)
+		}
+	}
+
+	out := make([]Migration, 0, len(byVersion))
+	for _, m := range byVersion {
+		if m.Up == "" || m.Down == "" {
+			return nil, fmt.Errorf("migration %03d_%s needs both up and down files", m.Version, m.Name)
+		}
+		out = append(out, *m)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Version < out[j].Version })
+	return out, nil
+}
+
+type Runner struct {
+	pool       *pgxpool.Pool
+	migrations []Migration
+}
+
+func NewRunner(pool *pgxpool.Pool, migrations []Migration) *Runner {
+	return &Runner{pool: pool, migrations: migrations}
+}
+
+// Up applies every pending migration.
+func (r *Runner) Up(ctx context.Context) error {
+	return r.To(ctx, r.latest())
+}
+
+// Down reverts the most recently applied migration.
+func (r *Runner) Down(ctx context.Context) error {
+	return r.withLock(ctx, func(conn *pgxpool.Conn) error {
+		current, err := currentVersion(ctx, conn)
+		if err != nil {
+			return err
+		}
+		if current == 0 {
+			log.Info().Msg("nothing to revert")
+			return nil
+		}
+		return r.migrate(ctx, conn, current, r.previous(current))
+	})
+}
+
+// To migrates up or down until target is the current version. Target 0
+// reverts everything.
+func (r *Runner) To(ctx context.Context, target int) error {
+	if target != 0 && r.find(target) == nil {
+		return fmt.Errorf("unknown migration version %d", target)
+	}
+	return r.withLock(ctx, func(conn *pgxpool.Conn) error {
+		current, err := currentVersion(ctx, conn)
+		if err != nil {
+			return err
+		}
+		return r.migrate(ctx, conn, current, target)
+	})
+}
+
+// Baseline records migrations up to target as applied without running
+// them, to adopt a database whose schema was set up by hand (psql -f)
+// before the runner existed. It refuses databases that already track
+// migrations or have no campaigns table, since there it would only hide
+// missing schema.
+func (r *Runner) Baseline(ctx context.Context, target int) error {
+	if r.find(target) == nil {
+		return fmt.Errorf("unknown migration version %d", target)
+	}
+	return r.withLock(ctx, func(conn *pgxpool.Conn) error {
+		current, err := currentVersion(ctx, conn)
+		if err != nil {
+			return err
+		}
+		if current != 0 {
+			return fmt.Errorf("baseline: migrations are already tracked up to %d; use migrate up", current)
+		}
+		var exists bool
+		if err := conn.QueryRow(ctx, `SELECT to_regclass('campaigns') IS NOT NULL`).Scan(&exists); err != nil {
+			return fmt.Errorf("baseline: look for the schema: %w", err)
+		}
+		if !exists {
+			return errors.New("baseline: no campaigns table, so nothing to adopt; use migrate up")
+		}
+		return pgx.BeginFunc(ctx, conn, func(tx pgx.Tx) error {
+			for _, m := range r.migrations {
+				if m.Version > target {
+					break
+				}
+				if _, err := tx.Exec(ctx, `INSERT INTO schema_migrations (version, name) VALUES ($1, $2)`,
+					m.Version, m.Name); err != nil {
+					return fmt.Errorf("baseline %03d_%s: %w", m.Version, m.Name, err)
+				}
+				log.Info().Int("version", m.Version).Str("name", m.Name).Msg("recorded as applied")
+			}
+			return nil
+		})
+	})
+}
+
+// Status lists every known migration with its applied time, if any.
+func (r *Runner) Status(ctx context.Context) ([]Status, error) {
+	conn, err := r.pool.Acquire(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("acquire conn: %w", err)
+	}
+	defer conn.Release()
+	if err := ensureTable(ctx, conn); err != nil {
+		return nil, err
+	}
+
+	applied := map[int]time.Time{}
+	rows, err := conn.Query(ctx, `SELECT version, applied_at FROM schema_migrations`)
+	if err != nil {
+		return nil, fmt.Errorf("query schema_migrations: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var (
+			v  int
+			at time.Time
+		)
+		if err := rows.Scan(&v, &at); err != nil {
+			return nil, fmt.Errorf("scan schema_migrations: %w", err)
+		}
+		applied[v] = at
+	}
+	if rows.Err() != nil {
+		return nil, rows.Err()
+	}
+
+	out := make([]Status, 0, len(r.migrations))
+	for _, m := range r.migrations {
+		st := Status{Version: m.Version, Name: m.Name}
+		if at, ok := applied[m.Version]; ok {
+			st.AppliedAt = &at
+		}
+		out = append(out, st)
+	}
+	return out, nil
+}
+
+// migrate walks from current to target one migration at a time, each in its
+// own transaction together with its schema_migrations bookkeeping.
+func (r *Runner) migrate(ctx context.Context, conn *pgxpool.Conn, current, target int) error {
+	for _, m := range r.migrations {
+		if m.Version <= current || m.Version > target {
+			continue
+		}
+		log.Info().Int("version", m.Version).Str("name", m.Name).Msg("migrating up")
+		err := pgx.BeginFunc(ctx, conn, func(tx pgx.Tx) error {
+			if _, err := tx.Exec(ctx, m.Up); err != nil {
+				return err
+			}
+			_, err := tx.Exec(ctx, `INSERT INTO schema_migrations (version, name) VALUES ($1, $2)`, m.Version, m.Name)
+			return err
+		})
+		if err != nil {
+			return fmt.Errorf("migration %03d_%s up: %w", m.Version, m.Name, err)
+		}
+	}
+	for i := len(r.migrations) - 1; i >= 0; i-- {
+		m := r.migrations[i]
+		if m.Version > current || m.Version <= target {
+			continue
+		}
+		log.Info().Int("version", m.Version).Str("name", m.Name).Msg("migrating down")
+		err := pgx.BeginFunc(ctx, conn, func(tx pgx.Tx) error {
+			if _, err := tx.Exec(ctx, m.Down); err != nil {
+				return err
+			}
+			_, err := tx.Exec(ctx, `DELETE FROM schema_migrations WHERE version = $1`, m.Version)
+			return err
+		})
+		if err != nil {
+			return fmt.Errorf("migration %03d_%s down: %w", m.Version, m.Name, err)
+		}
+	}
+	return nil
+}
+
+// withLock runs fn on a dedicated connection holding the migration advisory
+// lock, so concurrent deploys never interleave migrations.
+func (r *Runner) withLock(ctx context.Context, fn func(conn *pgxpool.Conn) error) error {
+	conn, err := r.pool.Acquire(ctx)
+	if err != nil {
+		return fmt.Errorf("acquire conn: %w", err)
+	}
+	defer conn.Release()
+
+	if _, err := conn.Exec(ctx, `SELECT pg_advisory_lock($1)`, lockKey); err != nil {
+		return fmt.Errorf("acquire migration lock: %w", err)
+	}
+	defer func() {
+		// background ctx: release even if ctx was cancelled mid-run
+		if _, err := conn.Exec(context.Background(), `SELECT pg_advisory_unlock($1)`, lockKey); err != nil {
+			log.Error().Err(err).Msg("release migration lock")
+		}
+	}()
+
+	if err := ensureTable(ctx, conn); err != nil {
+		return err
+	}
+	return fn(conn)
+}
+
+func ensureTable(ctx context.Context, conn *pgxpool.Conn) error {
+	_, err := conn.Exec(ctx, `
+		CREATE TABLE IF NOT EXISTS schema_migrations (
+			version INT PRIMARY KEY,
+			name TEXT NOT NULL,
+			applied_at TIMESTAMPTZ NOT NULL DEFAULT now()
+		)
+	`)
+	if err != nil {
+		return fmt.Errorf("create schema_migrations: %w", err)
+	}
+	return nil
+}
+
+func currentVersion(ctx context.Context, conn *pgxpool.Conn) (int, error) {
+	var v int
+	err := conn.QueryRow(ctx, `SELECT COALESCE(MAX(version), 0) FROM schema_migrations`).Scan(&v)
+	if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+		return 0, fmt.Errorf("query current version: %w", err)
+	}
+	return v, nil
+}
+
+func (r *Runner) latest() int {
+	if len(r.migrations) == 0 {
+		return 0
+	}
+	return r.migrations[len(r.migrations)-1].Version
+}
+
+func (r *Runner) previous(v int) int {
+	prev := 0
+	for _, m := range r.migrations {
+		if m.Version < v {
+			prev = m.Version
+		}
+	}
+	return prev
+}
+
+func (r *Runner) find(v int) *Migration {
+	for i := range r.migrations {
+		if r.migrations[i].Version == v {
+			return &r.migrations[i]
+		}
+	}
+	return nil
+}
@@ -0,0 +1,72 @@
+package migrate
+
+import (
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"ad-targeting-engine/db/migrations"
+)
+
+func TestLoad(t *testing.T) {
+	tests := []struct {
+		name     string
+		files    fstest.MapFS
+		wantErr  bool
+		wantVers []int
+	}{
+		{
+			name: "sorted pairs",
+			files: fstest.MapFS{
+				"010_b.up.sql":   {Data: []byte("up b")},
+				"010_b.down.sql": {Data: []byte("down b")},
+				"002_a.up.sql":   {Data: []byte("up a")},
+				"002_a.down.sql": {Data: []byte("down a")},
+				"README.md":      {Data: []byte("ignored")},
+			},
+			wantVers: []int{2, 10},
+		},
+		{
+			name: "missing down",
+			files: fstest.MapFS{
+				"001_a.up.sql": {Data: []byte("up")},
+			},
+			wantErr: true,
+		},
+		{
+			name: "name mismatch",
+			files: fstest.MapFS{
+				"001_a.up.sql":   {Data: []byte("up")},
+				"001_b.down.sql": {Data: []byte("down")},
+			},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Load(tt.files)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			var vers []int
+			for _, m := range got {
+				vers = append(vers, m.Version)
+			}
+			assert.Equal(t, tt.wantVers, vers)
+		})
+	}
+}
+
+func TestLoad_Embedded(t *testing.T) {
+	got, err := Load(migrations.FS)
+	require.NoError(t, err)
+	require.NotEmpty(t, got)
+	for i, m := range got {
+		assert.Equal(t, i+1, m.Version, "migrations should be numbered without gaps")
+	}
+}
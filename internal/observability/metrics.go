@@ -31,10 +31,172 @@ var (
 			Help: "Total errors by type",
 		}, []string{"type"},
 	)
+	StorageReads = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "storage_reads_total",
+			Help: "Snapshot reads by the endpoint that served them",
+		}, []string{"endpoint"},
+	)
+	ReplicaHealthy = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "storage_replica_healthy",
+			Help: "1 if the replica is reachable and within the lag threshold",
+		}, []string{"replica"},
+	)
+	ReplicaLag = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "storage_replica_lag_seconds",
+			Help: "Replication lag seen by the last health check",
+		}, []string{"replica"},
+	)
+	BreakerState = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "storage_breaker_state",
+			Help: "Circuit breaker state: 0 closed, 1 half-open, 2 open",
+		}, []string{"breaker"},
+	)
+	SnapshotBuildSeconds = prometheus.NewHistogram(prometheus.HistogramOpts{
+		Name:    "snapshot_build_seconds",
+		Help:    "Duration of full snapshot rebuilds",
+		Buckets: prometheus.ExponentialBuckets(0.01, 2, 12),
+	})
+	SnapshotBuildPeakHeap = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "snapshot_build_peak_heap_bytes",
+		Help: "Peak heap growth over the pre-build heap during the last full rebuild",
+	})
+	SnapshotVersion = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "snapshot_version",
+		Help: "Version of the delivery snapshot currently served",
+	})
+	SnapshotBuiltAt = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "snapshot_built_timestamp_seconds",
+		Help: "Unix time the current delivery snapshot was published",
+	})
+	SnapshotCampaigns = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "snapshot_campaigns",
+		Help: "Campaigns in the current delivery snapshot",
+	})
+	FollowerAge = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "follower_snapshot_age_seconds",
+		Help: "Seconds since the follower last confirmed it serves the builder's snapshot",
+	})
+	FollowerErrors = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "follower_sync_errors_total",
+		Help: "Failed snapshot polls against the builder",
+	})
+	BreakerRejections = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "storage_breaker_rejections_total",
+			Help: "Calls failed fast because the breaker was open",
+		}, []string{"breaker"},
+	)
+	OpenRTBNoBids = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "openrtb_nobids_total",
+			Help: "OpenRTB requests declined, by nbr reason",
+		}, []string{"reason"},
+	)
+	GRPCRequests = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "grpc_requests_total",
+			Help: "Finished gRPC calls by method and status code",
+		}, []string{"method", "code"},
+	)
+	GRPCLatency = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "grpc_request_duration_seconds",
+			Help:    "Unary gRPC call latency seconds",
+			Buckets: prometheus.DefBuckets,
+		}, []string{"method"},
+	)
+	GRPCStreamMessages = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "grpc_stream_messages_received_total",
+			Help: "Messages received on gRPC streams by method",
+		}, []string{"method"},
+	)
+	APIKeyRequests = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "api_key_requests_total",
+			Help: "Authenticated requests by API key id and outcome",
+		}, []string{"key", "outcome"},
+	)
+	APIKeys = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "api_keys_loaded",
+		Help: "Active API keys in the keyring",
+	})
+	TrackingEvents = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "tracking_events_total",
+			Help: "Recorded impression, click and playback events by type",
+		}, []string{"type"},
+	)
+	TrackingRejected = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "tracking_rejected_total",
+			Help: "Tracking links not recorded by link kind and reason",
+		}, []string{"kind", "reason"},
+	)
+	EventsWritten = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "events_written_total",
+		Help: "Events handed to the event writer",
+	})
+	EventsDropped = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "events_dropped_total",
+			Help: "Events lost by reason: queue full, write error or sink closed",
+		}, []string{"reason"},
+	)
+	EventsQueued = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "events_queued",
+		Help: "Events waiting in the event sink queue",
+	})
+	RollupFlushes = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "rollup_flushes_total",
+			Help: "Flushes of hourly campaign stats by outcome",
+		}, []string{"outcome"},
+	)
+	BudgetThrottled = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "budget_throttled_total",
+			Help: "Matching campaigns held back by their caps or pacing",
+		}, []string{"reason"},
+	)
+	Auctions = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "auctions_total",
+			Help: "Delivery auctions by outcome: filled, or below_floor when no bid met the floor",
+		}, []string{"outcome"},
+	)
+	PublisherBlocked = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "publisher_blocked_total",
+			Help: "Matching campaigns kept out of an app by its publisher settings, by reason",
+		}, []string{"reason"},
+	)
+	AdvertiserBlocked = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "advertiser_blocked_total",
+			Help: "Matching campaigns kept out by their advertiser's status or blocked apps, by reason",
+		}, []string{"reason"},
+	)
+	SpendReconciles = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "spend_reconciles_total",
+			Help: "Reconciliations of campaign spend with the database by outcome",
+		}, []string{"outcome"},
+	)
 )
 
 func init() {
-	prometheus.MustRegister(RequestsTotal, Latency, InFlight, RequestErrors)
+	prometheus.MustRegister(RequestsTotal, Latency, InFlight, RequestErrors,
+		StorageReads, ReplicaHealthy, ReplicaLag, BreakerState, BreakerRejections,
+		SnapshotBuildSeconds, SnapshotBuildPeakHeap, SnapshotVersion, SnapshotBuiltAt, SnapshotCampaigns,
+		FollowerAge, FollowerErrors, OpenRTBNoBids, GRPCRequests, GRPCLatency, GRPCStreamMessages,
+		APIKeyRequests, APIKeys, TrackingEvents, TrackingRejected, EventsWritten, EventsDropped, EventsQueued,
+		RollupFlushes, BudgetThrottled, SpendReconciles,
+		Auctions, PublisherBlocked, AdvertiserBlocked)
 }
 
 func MetricsHandler() http.Handler { return promhttp.Handler() }
@@ -0,0 +1,389 @@
+// Package openrtb holds the subset of the OpenRTB 2.6 object model the
+// bidder reads and writes, and the mapping to and from engine requests.
+// Fields we do not use are left out; encoding/json ignores them on input.
+package openrtb
+
+import (
+	"encoding/json"
+	"fmt"
+	"html"
+	"slices"
+	"strings"
+
+	"ad-targeting-engine/internal/engine"
+	"ad-targeting-engine/internal/geo"
+	"ad-targeting-engine/internal/storage"
+	"ad-targeting-engine/internal/vast"
+)
+
+// Version is sent in the x-openrtb-version response header.
+const Version = "2.6"
+
+// Currency is the only currency we bid in.
+const Currency = "USD"
+
+type BidRequest struct {
+	ID     string          `json:"id"`
+	Imp    []Imp           `json:"imp"`
+	Site   *Site           `json:"site,omitempty"`
+	App    *App            `json:"app,omitempty"`
+	Device *Device         `json:"device,omitempty"`
+	User   *User           `json:"user,omitempty"`
+	Test   int             `json:"test,omitempty"`
+	AT     int             `json:"at,omitempty"`
+	TMax   int             `json:"tmax,omitempty"`
+	Cur    []string        `json:"cur,omitempty"`
+	BCat   []string        `json:"bcat,omitempty"`
+	BAdv   []string        `json:"badv,omitempty"`
+	Regs   *Regs           `json:"regs,omitempty"`
+	Ext    json.RawMessage `json:"ext,omitempty"`
+}
+
+type Imp struct {
+	ID          string  `json:"id"`
+	Banner      *Banner `json:"banner,omitempty"`
+	Video       *Video  `json:"video,omitempty"`
+	TagID       string  `json:"tagid,omitempty"`
+	BidFloor    float64 `json:"bidfloor,omitempty"`
+	BidFloorCur string  `json:"bidfloorcur,omitempty"`
+	Instl       int     `json:"instl,omitempty"`
+}
+
+type Banner struct {
+	W      int      `json:"w,omitempty"`
+	H      int      `json:"h,omitempty"`
+	Format []Format `json:"format,omitempty"`
+	Pos    int      `json:"pos,omitempty"`
+}
+
+type Format struct {
+	W int `json:"w,omitempty"`
+	H int `json:"h,omitempty"`
+}
+
+type Video struct {
+	MIMEs       []string `json:"mimes,omitempty"`
+	MinDuration int      `json:"minduration,omitempty"`
+	MaxDuration int      `json:"maxduration,omitempty"`
+	Protocols   []int    `json:"protocols,omitempty"`
+	W           int      `json:"w,omitempty"`
+	H           int      `json:"h,omitempty"`
+}
+
+type App struct {
+	ID        string     `json:"id,omitempty"`
+	Name      string     `json:"name,omitempty"`
+	Bundle    string     `json:"bundle,omitempty"`
+	Domain    string     `json:"domain,omitempty"`
+	StoreURL  string     `json:"storeurl,omitempty"`
+	Cat       []string   `json:"cat,omitempty"`
+	Publisher *Publisher `json:"publisher,omitempty"`
+}
+
+type Site struct {
+	ID        string     `json:"id,omitempty"`
+	Domain    string     `json:"domain,omitempty"`
+	Page      string     `json:"page,omitempty"`
+	Publisher *Publisher `json:"publisher,omitempty"`
+}
+
+type Publisher struct {
+	ID     string `json:"id,omitempty"`
+	Name   string `json:"name,omitempty"`
+	Domain string `json:"domain,omitempty"`
+}
+
+type Device struct {
+	UA         string `json:"ua,omitempty"`
+	IP         string `json:"ip,omitempty"`
+	OS         string `json:"os,omitempty"`
+	OSV        string `json:"osv,omitempty"`
+	Make       string `json:"make,omitempty"`
+	Model      string `json:"model,omitempty"`
+	DeviceType int    `json:"devicetype,omitempty"`
+	IFA        string `json:"ifa,omitempty"`
+	Geo        *Geo   `json:"geo,omitempty"`
+}
+
+// Geo.Country is ISO 3166-1 alpha-3.
+type Geo struct {
+	Lat     *float64 `json:"lat,omitempty"`
+	Lon     *float64 `json:"lon,omitempty"`
+	Country string   `json:"country,omitempty"`
+	Region  string   `json:"region,omitempty"`
+	City    string   `json:"city,omitempty"`
+	ZIP     string   `json:"zip,omitempty"`
+}
+
+type User struct {
+	ID      string `json:"id,omitempty"`
+	Consent string `json:"consent,omitempty"`
+	Geo     *Geo   `json:"geo,omitempty"`
+}
+
+type Regs struct {
+	COPPA     int    `json:"coppa,omitempty"`
+	GDPR      *int   `json:"gdpr,omitempty"`
+	USPrivacy string `json:"us_privacy,omitempty"`
+}
+
+type BidResponse struct {
+	ID      string       `json:"id"`
+	SeatBid []SeatBid    `json:"seatbid,omitempty"`
+	BidID   string       `json:"bidid,omitempty"`
+	Cur     string       `json:"cur,omitempty"`
+	NBR     *NoBidReason `json:"nbr,omitempty"`
+}
+
+type SeatBid struct {
+	Bid  []Bid  `json:"bid"`
+	Seat string `json:"seat,omitempty"`
+}
+
+type Bid struct {
+	ID      string   `json:"id"`
+	ImpID   string   `json:"impid"`
+	Price   float64  `json:"price"`
+	AdM     string   `json:"adm,omitempty"`
+	BURL    string   `json:"burl,omitempty"`
+	AdID    string   `json:"adid,omitempty"`
+	CID     string   `json:"cid,omitempty"`
+	CrID    string   `json:"crid,omitempty"`
+	ADomain []string `json:"adomain,omitempty"`
+	W       int      `json:"w,omitempty"`
+	H       int      `json:"h,omitempty"`
+	MType   int      `json:"mtype,omitempty"`
+}
+
+// Bid.MType values.
+const (
+	MTypeBanner = 1
+	MTypeVideo  = 2
+)
+
+// NoBidReason is the OpenRTB nbr code.
+type NoBidReason int
+
+const (
+	NBRUnknown           NoBidReason = 0
+	NBRTechnicalError    NoBidReason = 1
+	NBRInvalidRequest    NoBidReason = 2
+	NBRKnownSpider       NoBidReason = 3
+	NBRNonHumanTraffic   NoBidReason = 4
+	NBRProxyIP           NoBidReason = 5
+	NBRUnsupportedDevice NoBidReason = 6
+	NBRBlockedPublisher  NoBidReason = 7
+	NBRUnmatchedUser     NoBidReason = 8
+)
+
+func (r NoBidReason) String() string {
+	switch r {
+	case NBRTechnicalError:
+		return "technical_error"
+	case NBRInvalidRequest:
+		return "invalid_request"
+	case NBRKnownSpider:
+		return "known_spider"
+	case NBRNonHumanTraffic:
+		return "non_human_traffic"
+	case NBRProxyIP:
+		return "proxy_ip"
+	case NBRUnsupportedDevice:
+		return "unsupported_device"
+	case NBRBlockedPublisher:
+		return "blocked_publisher"
+	case NBRUnmatchedUser:
+		return "unmatched_user"
+	}
+	return "unknown"
+}
+
+// NoBidError is a request we decline to bid on, with the reason to report.
+type NoBidError struct {
+	Reason NoBidReason
+	Msg    string
+}
+
+func (e *NoBidError) Error() string { return fmt.Sprintf("no bid (%s): %s", e.Reason, e.Msg) }
+
+func noBid(r NoBidReason, format string, args ...any) *NoBidError {
+	return &NoBidError{Reason: r, Msg: fmt.Sprintf(format, args...)}
+}
+
+// NoBid is the response for a declined request.
+func NoBid(id string, r NoBidReason) *BidResponse {
+	return &BidResponse{ID: id, NBR: &r}
+}
+
+// ToMatchRequest maps the request onto an engine match. Only in-app
+// inventory with a known device OS can be served; a geo country, if sent,
+// must be ISO 3166-1 alpha-3 (alpha-2 is tolerated).
+func ToMatchRequest(req *BidRequest) (engine.MatchRequest, *NoBidError) {
+	var m engine.MatchRequest
+	switch {
+	case req.ID == "":
+		return m, noBid(NBRInvalidRequest, "missing id")
+	case len(req.Imp) == 0:
+		return m, noBid(NBRInvalidRequest, "no imp")
+	case len(req.Cur) > 0 && !slices.Contains(req.Cur, Currency):
+		return m, noBid(NBRInvalidRequest, "we only bid in %s", Currency)
+	case req.App == nil || strings.TrimSpace(req.App.Bundle) == "":
+		return m, noBid(NBRInvalidRequest, "only app inventory with app.bundle is supported")
+	case req.Device == nil || strings.TrimSpace(req.Device.OS) == "":
+		return m, noBid(NBRUnsupportedDevice, "missing device.os")
+	}
+
+	m = engine.MatchRequest{
+		AppID:     strings.ToLower(strings.TrimSpace(req.App.Bundle)),
+		OS:        strings.ToLower(strings.TrimSpace(req.Device.OS)),
+		OSVersion: req.Device.OSV,
+		Make:      req.Device.Make,
+		Model:     req.Device.Model,
+	}
+	if len(req.App.Cat) > 0 {
+		m.AppCategory = req.App.Cat[0]
+	}
+	if req.User != nil {
+		m.UserID, m.Consent = req.User.ID, req.User.Consent
+	}
+
+	g := req.Device.Geo
+	if g == nil && req.User != nil {
+		g = req.User.Geo
+	}
+	if g != nil {
+		if g.Country != "" {
+			cc, ok := geo.CountryAlpha2(g.Country)
+			if !ok {
+				return m, noBid(NBRInvalidRequest, "geo.country %q is not ISO 3166-1 alpha-3", g.Country)
+			}
+			m.Country = cc
+		}
+		m.Region, m.City, m.Lat, m.Lon = g.Region, g.City, g.Lat, g.Lon
+	}
+	return m, nil
+}
+
+// Biddable returns the campaigns of cs that can bid on some imp of req,
+// so that the auction's slots go to campaigns able to fill them.
+func Biddable(req *BidRequest, cs []engine.Campaign) []engine.Campaign {
+	var out []engine.Campaign
+	for _, c := range cs {
+		if slices.ContainsFunc(req.Imp, func(imp Imp) bool { return biddable(imp, c) }) {
+			out = append(out, c)
+		}
+	}
+	return out
+}
+
+// biddable reports whether c may bid on imp: imp takes its creative and c's
+// bid clears imp's floor.
+func biddable(imp Imp, c engine.Campaign) bool {
+	if c.Price <= 0 || imp.BidFloorCur != "" && imp.BidFloorCur != Currency || c.Price < imp.BidFloor {
+		return false
+	}
+	return accepts(imp, c)
+}
+
+// BuildResponse turns auction winners into a response with one seatbid per
+// bidding campaign. Winners are taken in order, each on the first imp not
+// yet bid on that it may bid on, so an imp gets at most one bid. The price
+// is the winner's clearing price, raised to the imp's floor; its bid caps
+// both. burl, if not nil, returns the billing notice URL of a campaign's
+// bid on an imp. It returns nil when nothing can be bid.
+func BuildResponse(req *BidRequest, winners []engine.Campaign, burl func(c engine.Campaign, impID string) string) *BidResponse {
+	resp := &BidResponse{ID: req.ID, Cur: Currency}
+	taken := make([]bool, len(req.Imp))
+	for _, c := range winners {
+		for i, imp := range req.Imp {
+			if taken[i] || !biddable(imp, c) {
+				continue
+			}
+			w := c
+			w.ClearingPrice = max(c.ClearingPrice, imp.BidFloor)
+			if w.ClearingPrice <= 0 {
+				continue
+			}
+			b, ok := bid(req.ID, imp, w)
+			if !ok {
+				continue
+			}
+			if burl != nil {
+				b.BURL = burl(w, imp.ID)
+			}
+			taken[i] = true
+			resp.SeatBid = append(resp.SeatBid, SeatBid{Seat: w.ID, Bid: []Bid{b}})
+			break
+		}
+	}
+	if len(resp.SeatBid) == 0 {
+		return nil
+	}
+	return resp
+}
+
+// bid is c's bid on imp at its clearing price. ok is false when its
+// markup cannot be built.
+func bid(requestID string, imp Imp, c engine.Campaign) (Bid, bool) {
+	b := Bid{
+		ID:    imp.ID + "-" + c.ID,
+		ImpID: imp.ID,
+		Price: c.ClearingPrice,
+		AdID:  c.ID,
+		CID:   c.ID,
+		CrID:  c.ID,
+	}
+	if c.Creative == storage.CreativeVideo {
+		adm, err := videoMarkup(requestID, c)
+		if err != nil {
+			return Bid{}, false
+		}
+		b.AdM, b.MType = adm, MTypeVideo
+		b.W, b.H = imp.Video.W, imp.Video.H
+	} else {
+		b.AdM, b.MType = Markup(c), MTypeBanner
+		if imp.Banner != nil {
+			b.W, b.H = imp.Banner.W, imp.Banner.H
+		}
+	}
+	return b, true
+}
+
+// accepts reports whether imp can show c: videos need a video imp that
+// allows their MIME type and duration, banners a banner imp. An imp with
+// neither object is taken as a banner.
+func accepts(imp Imp, c engine.Campaign) bool {
+	if c.Creative != storage.CreativeVideo {
+		return imp.Banner != nil || imp.Video == nil
+	}
+	v := imp.Video
+	switch {
+	case v == nil:
+		return false
+	case len(v.MIMEs) > 0 && !slices.Contains(v.MIMEs, c.Video.MIME):
+		return false
+	case v.MinDuration > 0 && c.Video.Duration < v.MinDuration,
+		v.MaxDuration > 0 && c.Video.Duration > v.MaxDuration:
+		return false
+	}
+	return true
+}
+
+// videoMarkup is the VAST document of a video campaign, for adm.
+func videoMarkup(requestID string, c engine.Campaign) (string, error) {
+	var b strings.Builder
+	if err := vast.Document(requestID, []engine.Campaign{c}, nil).Write(&b); err != nil {
+		return "", err
+	}
+	return b.String(), nil
+}
+
+// Markup is the campaign's creative markup, or a minimal HTML banner built
+// from its image and call to action when it has none.
+func Markup(c engine.Campaign) string {
+	if c.Markup != "" {
+		return c.Markup
+	}
+	return fmt.Sprintf(`<div class="ad"><img src="%s" alt="%s"><span>%s</span></div>`,
+		html.EscapeString(c.Image), html.EscapeString(c.CTA), html.EscapeString(c.CTA))
+}
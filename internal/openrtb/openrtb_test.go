@@ -0,0 +1,189 @@
+package openrtb
+
+import (
+	"context"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"ad-targeting-engine/internal/auction"
+	"ad-targeting-engine/internal/engine"
+	"ad-targeting-engine/internal/storage"
+	"ad-targeting-engine/internal/vast"
+)
+
+func testEngine(t *testing.T) *engine.DeliveryEngine {
+	t.Helper()
+	st := storage.NewMemoryStore(
+		storage.CampaignRow{ID: "weather", Name: "Weather", ImageURL: "https://img/w.png", CTA: "Open & Go", Status: "ACTIVE",
+			BidPrice: 1.25,
+			Rules: []storage.RuleRow{
+				{Dimension: "country", IsInclusion: true, Values: []string{"US"}},
+				{Dimension: "os", IsInclusion: true, Values: []string{"ios"}},
+			}},
+		storage.CampaignRow{ID: "custom", Name: "Custom", Status: "ACTIVE", BidPrice: 2, Markup: "<b>ad</b>",
+			Rules: []storage.RuleRow{{Dimension: "country", IsInclusion: true, Values: []string{"US"}}}},
+		storage.CampaignRow{ID: "cheap", Name: "Cheap", Status: "ACTIVE", BidPrice: 0.1},
+		storage.CampaignRow{ID: "unpriced", Name: "Unpriced", Status: "ACTIVE"},
+		storage.CampaignRow{ID: "trailer", Name: "Trailer", Status: "ACTIVE", BidPrice: 3, Creative: storage.CreativeVideo,
+			Video: storage.VideoCreative{URL: "https://cdn/t.mp4", MIME: "video/mp4", Duration: 15, Width: 640, Height: 480}},
+		storage.CampaignRow{ID: "feature", Name: "Feature", Status: "ACTIVE", BidPrice: 4, Creative: storage.CreativeVideo,
+			Video: storage.VideoCreative{URL: "https://cdn/f.mp4", MIME: "video/mp4", Duration: 90, Width: 640, Height: 480}},
+	)
+	eng := engine.NewEngine()
+	require.NoError(t, eng.BuildSnapshot(context.Background(), st))
+	return eng
+}
+
+func loadSample(t *testing.T, name string) *BidRequest {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join("testdata", name))
+	require.NoError(t, err)
+	var req BidRequest
+	require.NoError(t, json.Unmarshal(data, &req))
+	return &req
+}
+
+func TestSpecSamples(t *testing.T) {
+	eng := testEngine(t)
+
+	tests := []struct {
+		sample    string
+		wantNBR   *NoBidReason
+		wantBids  map[string]float64 // seat -> price
+		wantMType int
+		wantW     int
+	}{
+		// one imp, so one winner
+		{sample: "app_banner.json", wantBids: map[string]float64{"custom": 2}, wantMType: MTypeBanner, wantW: 728},
+		// the feature is too long for the imp's maxduration
+		{sample: "app_video.json", wantBids: map[string]float64{"trailer": 3}, wantMType: MTypeVideo, wantW: 640},
+		{sample: "site_banner.json", wantNBR: ptr(NBRInvalidRequest)},
+		{sample: "site_video.json", wantNBR: ptr(NBRInvalidRequest)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.sample, func(t *testing.T) {
+			req := loadSample(t, tt.sample)
+			m, nb := ToMatchRequest(req)
+			if tt.wantNBR != nil {
+				require.NotNil(t, nb)
+				assert.Equal(t, *tt.wantNBR, nb.Reason)
+				return
+			}
+			require.Nil(t, nb)
+
+			m.Slots = len(req.Imp)
+			a := auction.New(auction.Config{Type: auction.FirstPrice}, nil)
+			resp := BuildResponse(req, a.Run(m, Biddable(req, eng.Match(context.Background(), m))), nil)
+			require.NotNil(t, resp)
+			assert.Equal(t, req.ID, resp.ID)
+			assert.Equal(t, Currency, resp.Cur)
+			got := map[string]float64{}
+			for _, sb := range resp.SeatBid {
+				require.Len(t, sb.Bid, 1)
+				b := sb.Bid[0]
+				assert.Equal(t, req.Imp[0].ID, b.ImpID)
+				assert.Equal(t, "1-"+sb.Seat, b.ID)
+				assert.Equal(t, tt.wantW, b.W)
+				assert.Equal(t, tt.wantMType, b.MType)
+				if b.MType == MTypeVideo {
+					var doc vast.VAST
+					require.NoError(t, xml.Unmarshal([]byte(b.AdM), &doc))
+					require.Len(t, doc.Ads, 1)
+					assert.Equal(t, sb.Seat, doc.Ads[0].ID)
+				} else {
+					assert.NotEmpty(t, b.AdM)
+				}
+				got[sb.Seat] = b.Price
+			}
+			assert.Equal(t, tt.wantBids, got)
+		})
+	}
+}
+
+func TestToMatchRequest(t *testing.T) {
+	req := loadSample(t, "app_banner.json")
+	m, nb := ToMatchRequest(req)
+	require.Nil(t, nb)
+	assert.Equal(t, "12345", m.AppID)
+	assert.Equal(t, "ios", m.OS)
+	assert.Equal(t, "US", m.Country)
+	assert.Equal(t, "6.1", m.OSVersion)
+	assert.Equal(t, "IAB15", m.AppCategory)
+	assert.Equal(t, "Los Angeles", m.City)
+
+	tests := []struct {
+		name   string
+		mutate func(*BidRequest)
+		want   NoBidReason
+	}{
+		{"no id", func(r *BidRequest) { r.ID = "" }, NBRInvalidRequest},
+		{"no imp", func(r *BidRequest) { r.Imp = nil }, NBRInvalidRequest},
+		{"other currency", func(r *BidRequest) { r.Cur = []string{"EUR"} }, NBRInvalidRequest},
+		{"no device os", func(r *BidRequest) { r.Device.OS = "" }, NBRUnsupportedDevice},
+		{"unknown country", func(r *BidRequest) { r.Device.Geo.Country = "XXX" }, NBRInvalidRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := loadSample(t, "app_banner.json")
+			tt.mutate(req)
+			_, nb := ToMatchRequest(req)
+			require.NotNil(t, nb)
+			assert.Equal(t, tt.want, nb.Reason)
+		})
+	}
+}
+
+func TestBuildResponse_FloorsAndMarkup(t *testing.T) {
+	req := &BidRequest{ID: "r", Imp: []Imp{
+		{ID: "low", BidFloor: 0.05},
+		{ID: "high", BidFloor: 1.5},
+		{ID: "eur", BidFloorCur: "EUR"},
+	}}
+	resp := BuildResponse(req, []engine.Campaign{
+		{ID: "a", Image: `https://x/"a".png`, CTA: "<Go>", Price: 1},
+		{ID: "b", Price: 0},
+	}, nil)
+	require.NotNil(t, resp)
+	require.Len(t, resp.SeatBid, 1)
+	require.Len(t, resp.SeatBid[0].Bid, 1)
+	b := resp.SeatBid[0].Bid[0]
+	assert.Equal(t, "low", b.ImpID)
+	assert.Equal(t, `<div class="ad"><img src="https://x/&#34;a&#34;.png" alt="&lt;Go&gt;"><span>&lt;Go&gt;</span></div>`, b.AdM)
+
+	assert.Nil(t, BuildResponse(req, []engine.Campaign{{ID: "a", Price: 0.01}}, nil))
+}
+
+func TestBuildResponse_OneWinnerPerImp(t *testing.T) {
+	req := &BidRequest{ID: "r", Imp: []Imp{
+		{ID: "banner"},
+		{ID: "video", Video: &Video{W: 640, H: 480}},
+		{ID: "floored", BidFloor: 2},
+	}}
+	video := storage.VideoCreative{URL: "https://cdn/v.mp4", MIME: "video/mp4", Duration: 15}
+	resp := BuildResponse(req, []engine.Campaign{
+		{ID: "a", Price: 3, ClearingPrice: 1.5},
+		{ID: "b", Price: 2.5, ClearingPrice: 1},
+		{ID: "v", Price: 1, ClearingPrice: 1, Creative: storage.CreativeVideo, Video: video},
+		{ID: "c", Price: 1, ClearingPrice: 0.5},
+	}, nil)
+	require.NotNil(t, resp)
+
+	got := map[string]string{}
+	for _, sb := range resp.SeatBid {
+		require.Len(t, sb.Bid, 1)
+		b := sb.Bid[0]
+		got[b.ImpID] = fmt.Sprintf("%s@%g", sb.Seat, b.Price)
+	}
+	// b's clearing price is raised to the floor it bid over; c is left
+	// without an imp
+	assert.Equal(t, map[string]string{"banner": "a@1.5", "video": "v@1", "floored": "b@2"}, got)
+}
+
+func ptr[T any](v T) *T { return &v }
@@ -0,0 +1,149 @@
+// Package rollup counts matches, impressions and clicks per hour, campaign,
+// country, OS and app from the event stream, and flushes the counters to
+// campaign_stats_hourly.
+package rollup
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+
+	"ad-targeting-engine/internal/events"
+	"ad-targeting-engine/internal/observability"
+	"ad-targeting-engine/internal/storage"
+)
+
+type key struct {
+	hour       time.Time
+	campaignID string
+	country    string
+	os         string
+	app        string
+}
+
+type counter struct {
+	counts  storage.Counts // since this process started counting the hour
+	flushed storage.Counts // as of the last successful flush
+}
+
+// Aggregator is an events.Sink that keeps running totals in memory. Every
+// flush upserts the totals of the keys that changed, rather than
+// increments, so a flush that fails or is repeated never double counts.
+// Hours are those of the aggregator's clock when an event arrives; an hour
+// is dropped from memory once it is over and flushed.
+type Aggregator struct {
+	st     storage.StatsStore
+	source string
+	now    func() time.Time
+
+	mu       sync.Mutex
+	counters map[key]*counter
+}
+
+// New returns an aggregator flushing to st. source names this process in
+// the table; it must differ between nodes and between restarts.
+func New(st storage.StatsStore, source string) *Aggregator {
+	return &Aggregator{st: st, source: source, now: time.Now, counters: map[key]*counter{}}
+}
+
+// Record counts e. Decisions count a match for each matched campaign;
+// playback events are not counted.
+func (a *Aggregator) Record(_ context.Context, e events.Event) error {
+	var inc storage.Counts
+	switch e.Type {
+	case events.Impression:
+		inc.Impressions = 1
+	case events.Click:
+		inc.Clicks = 1
+	case events.Decision:
+		if e.Decision == nil {
+			return nil
+		}
+		inc.Matches = 1
+	default:
+		return nil
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	k := key{hour: a.now().UTC().Truncate(time.Hour), campaignID: e.CampaignID, country: e.Country, os: e.OS, app: e.App}
+	if e.Type != events.Decision {
+		a.add(k, inc)
+		return nil
+	}
+	for _, id := range e.Decision.Matched {
+		k.campaignID = id
+		a.add(k, inc)
+	}
+	return nil
+}
+
+func (a *Aggregator) add(k key, inc storage.Counts) {
+	c := a.counters[k]
+	if c == nil {
+		c = &counter{}
+		a.counters[k] = c
+	}
+	c.counts.Add(inc)
+}
+
+// Flush upserts the counters that changed since the last flush.
+func (a *Aggregator) Flush(ctx context.Context) error {
+	a.mu.Lock()
+	var rows []storage.HourlyStatRow
+	for k, c := range a.counters {
+		if c.counts != c.flushed {
+			rows = append(rows, storage.HourlyStatRow{Hour: k.hour, CampaignID: k.campaignID, Country: k.country,
+				OS: k.os, App: k.app, Source: a.source, Counts: c.counts})
+		}
+	}
+	a.mu.Unlock()
+
+	if len(rows) > 0 {
+		if err := a.st.UpsertHourlyStats(ctx, rows); err != nil {
+			observability.RollupFlushes.WithLabelValues("error").Inc()
+			return fmt.Errorf("flush rollups: %w", err)
+		}
+		observability.RollupFlushes.WithLabelValues("ok").Inc()
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	for _, r := range rows {
+		k := key{hour: r.Hour, campaignID: r.CampaignID, country: r.Country, os: r.OS, app: r.App}
+		if c := a.counters[k]; c != nil {
+			c.flushed = r.Counts
+		}
+	}
+	current := a.now().UTC().Truncate(time.Hour)
+	for k, c := range a.counters {
+		if k.hour.Before(current) && c.counts == c.flushed {
+			delete(a.counters, k)
+		}
+	}
+	return nil
+}
+
+// Run flushes every interval until ctx is done. Close does the last flush.
+func (a *Aggregator) Run(ctx context.Context, every time.Duration) {
+	t := time.NewTicker(every)
+	defer t.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+			if err := a.Flush(ctx); err != nil {
+				log.Error().Err(err).Msg("rollup flush")
+			}
+		}
+	}
+}
+
+// Close flushes what is left, for shutdown.
+func (a *Aggregator) Close(ctx context.Context) error {
+	return a.Flush(ctx)
+}
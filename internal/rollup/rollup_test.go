@@ -0,0 +1,75 @@
+package rollup
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"ad-targeting-engine/internal/events"
+	"ad-targeting-engine/internal/storage"
+)
+
+// failingStore fails upserts while fail is set.
+type failingStore struct {
+	*storage.MemoryStore
+	fail bool
+}
+
+func (s *failingStore) UpsertHourlyStats(ctx context.Context, rows []storage.HourlyStatRow) error {
+	if s.fail {
+		return errors.New("down")
+	}
+	return s.MemoryStore.UpsertHourlyStats(ctx, rows)
+}
+
+func TestAggregator(t *testing.T) {
+	ctx := context.Background()
+	st := &failingStore{MemoryStore: storage.NewMemoryStore()}
+	a := New(st, "node-1")
+	now := time.Date(2026, 10, 18, 12, 30, 0, 0, time.UTC)
+	a.now = func() time.Time { return now }
+
+	imp := events.Event{Type: events.Impression, CampaignID: "a", Country: "US", OS: "ios", App: "com.x"}
+	click := events.Event{Type: events.Click, CampaignID: "a", Country: "US", OS: "ios", App: "com.x"}
+	decision := events.Event{Type: events.Decision, Country: "US", OS: "ios", App: "com.x",
+		Decision: &events.DecisionDetail{Matched: []string{"a", "b"}}}
+	for _, e := range []events.Event{imp, imp, click, decision, {Type: events.Playback, CampaignID: "a"}} {
+		require.NoError(t, a.Record(ctx, e))
+	}
+
+	query := func() []storage.StatsRow {
+		rows, err := st.QueryStats(ctx, storage.StatsQuery{From: now.Add(-time.Hour), To: now.Add(time.Hour),
+			GroupBy: []string{storage.StatsByCampaign}})
+		require.NoError(t, err)
+		return rows
+	}
+
+	require.NoError(t, a.Flush(ctx))
+	require.NoError(t, a.Flush(ctx), "flushing again is harmless")
+	assert.Equal(t, []storage.StatsRow{
+		{CampaignID: "a", Counts: storage.Counts{Matches: 1, Impressions: 2, Clicks: 1}},
+		{CampaignID: "b", Counts: storage.Counts{Matches: 1}},
+	}, query())
+
+	st.fail = true
+	require.NoError(t, a.Record(ctx, imp))
+	assert.Error(t, a.Flush(ctx))
+	st.fail = false
+	require.NoError(t, a.Flush(ctx))
+	assert.Equal(t, int64(3), query()[0].Impressions, "a failed flush is retried, not lost or doubled")
+
+	now = now.Add(time.Hour)
+	require.NoError(t, a.Flush(ctx))
+	assert.Empty(t, a.counters, "past hours are dropped once flushed")
+	require.NoError(t, a.Record(ctx, imp))
+	require.NoError(t, a.Close(ctx))
+	rows, err := st.QueryStats(ctx, storage.StatsQuery{From: now.Add(-2 * time.Hour), To: now.Add(time.Hour),
+		GroupBy: []string{storage.StatsByHour}})
+	require.NoError(t, err)
+	require.Len(t, rows, 2)
+	assert.Equal(t, int64(1), rows[1].Impressions, "the new hour starts from zero")
+}
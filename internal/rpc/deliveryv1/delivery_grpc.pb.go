@@ -0,0 +1,233 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.4.0
+// - protoc             (unknown)
+// source: delivery/v1/delivery.proto
+
+package deliveryv1
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.62.0 or later.
+const _ = grpc.SupportPackageIsVersion8
+
+const (
+	DeliveryService_Match_FullMethodName       = "/delivery.v1.DeliveryService/Match"
+	DeliveryService_BatchMatch_FullMethodName  = "/delivery.v1.DeliveryService/BatchMatch"
+	DeliveryService_MatchStream_FullMethodName = "/delivery.v1.DeliveryService/MatchStream"
+)
+
+// DeliveryServiceClient is the client API for DeliveryService service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+//
+// DeliveryService is the binary twin of GET/POST /v1/delivery for internal
+// callers. It matches against the same in-memory snapshot.
+type DeliveryServiceClient interface {
+	// Match returns the campaigns eligible for one request.
+	Match(ctx context.Context, in *MatchRequest, opts ...grpc.CallOption) (*MatchResponse, error)
+	// BatchMatch matches up to grpc.max_batch requests in one call.
+	// Responses are in request order.
+	BatchMatch(ctx context.Context, in *BatchMatchRequest, opts ...grpc.CallOption) (*BatchMatchResponse, error)
+	// MatchStream answers each request on a long-lived stream, in order.
+	MatchStream(ctx context.Context, opts ...grpc.CallOption) (DeliveryService_MatchStreamClient, error)
+}
+
+type deliveryServiceClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewDeliveryServiceClient(cc grpc.ClientConnInterface) DeliveryServiceClient {
+	return &deliveryServiceClient{cc}
+}
+
+func (c *deliveryServiceClient) Match(ctx context.Context, in *MatchRequest, opts ...grpc.CallOption) (*MatchResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(MatchResponse)
+	err := c.cc.Invoke(ctx, DeliveryService_Match_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *deliveryServiceClient) BatchMatch(ctx context.Context, in *BatchMatchRequest, opts ...grpc.CallOption) (*BatchMatchResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(BatchMatchResponse)
+	err := c.cc.Invoke(ctx, DeliveryService_BatchMatch_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *deliveryServiceClient) MatchStream(ctx context.Context, opts ...grpc.CallOption) (DeliveryService_MatchStreamClient, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &DeliveryService_ServiceDesc.Streams[0], DeliveryService_MatchStream_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &deliveryServiceMatchStreamClient{ClientStream: stream}
+	return x, nil
+}
+
+type DeliveryService_MatchStreamClient interface {
+	Send(*MatchRequest) error
+	Recv() (*MatchResponse, error)
+	grpc.ClientStream
+}
+
+type deliveryServiceMatchStreamClient struct {
+	grpc.ClientStream
+}
+
+func (x *deliveryServiceMatchStreamClient) Send(m *MatchRequest) error {
+	return x.ClientStream.SendMsg(m)
+}
+
+func (x *deliveryServiceMatchStreamClient) Recv() (*MatchResponse, error) {
+	m := new(MatchResponse)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// DeliveryServiceServer is the server API for DeliveryService service.
+// All implementations must embed UnimplementedDeliveryServiceServer
+// for forward compatibility
+//
+// DeliveryService is the binary twin of GET/POST /v1/delivery for internal
+// callers. It matches against the same in-memory snapshot.
+type DeliveryServiceServer interface {
+	// Match returns the campaigns eligible for one request.
+	Match(context.Context, *MatchRequest) (*MatchResponse, error)
+	// BatchMatch matches up to grpc.max_batch requests in one call.
+	// Responses are in request order.
+	BatchMatch(context.Context, *BatchMatchRequest) (*BatchMatchResponse, error)
+	// MatchStream answers each request on a long-lived stream, in order.
+	MatchStream(DeliveryService_MatchStreamServer) error
+	mustEmbedUnimplementedDeliveryServiceServer()
+}
+
+// UnimplementedDeliveryServiceServer must be embedded to have forward compatible implementations.
+type UnimplementedDeliveryServiceServer struct {
+}
+
+func (UnimplementedDeliveryServiceServer) Match(context.Context, *MatchRequest) (*MatchResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Match not implemented")
+}
+func (UnimplementedDeliveryServiceServer) BatchMatch(context.Context, *BatchMatchRequest) (*BatchMatchResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method BatchMatch not implemented")
+}
+func (UnimplementedDeliveryServiceServer) MatchStream(DeliveryService_MatchStreamServer) error {
+	return status.Errorf(codes.Unimplemented, "method MatchStream not implemented")
+}
+func (UnimplementedDeliveryServiceServer) mustEmbedUnimplementedDeliveryServiceServer() {}
+
+// UnsafeDeliveryServiceServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to DeliveryServiceServer will
+// result in compilation errors.
+type UnsafeDeliveryServiceServer interface {
+	mustEmbedUnimplementedDeliveryServiceServer()
+}
+
+func RegisterDeliveryServiceServer(s grpc.ServiceRegistrar, srv DeliveryServiceServer) {
+	s.RegisterService(&DeliveryService_ServiceDesc, srv)
+}
+
+func _DeliveryService_Match_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(MatchRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(DeliveryServiceServer).Match(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: DeliveryService_Match_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(DeliveryServiceServer).Match(ctx, req.(*MatchRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _DeliveryService_BatchMatch_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(BatchMatchRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(DeliveryServiceServer).BatchMatch(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: DeliveryService_BatchMatch_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(DeliveryServiceServer).BatchMatch(ctx, req.(*BatchMatchRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _DeliveryService_MatchStream_Handler(srv interface{}, stream grpc.ServerStream) error {
+	return srv.(DeliveryServiceServer).MatchStream(&deliveryServiceMatchStreamServer{ServerStream: stream})
+}
+
+type DeliveryService_MatchStreamServer interface {
+	Send(*MatchResponse) error
+	Recv() (*MatchRequest, error)
+	grpc.ServerStream
+}
+
+type deliveryServiceMatchStreamServer struct {
+	grpc.ServerStream
+}
+
+func (x *deliveryServiceMatchStreamServer) Send(m *MatchResponse) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func (x *deliveryServiceMatchStreamServer) Recv() (*MatchRequest, error) {
+	m := new(MatchRequest)
+	if err := x.ServerStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// DeliveryService_ServiceDesc is the grpc.ServiceDesc for DeliveryService service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var DeliveryService_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "delivery.v1.DeliveryService",
+	HandlerType: (*DeliveryServiceServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "Match",
+			Handler:    _DeliveryService_Match_Handler,
+		},
+		{
+			MethodName: "BatchMatch",
+			Handler:    _DeliveryService_BatchMatch_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "MatchStream",
+			Handler:       _DeliveryService_MatchStream_Handler,
+			ServerStreams: true,
+			ClientStreams: true,
+		},
+	},
+	Metadata: "delivery/v1/delivery.proto",
+}
@@ -0,0 +1,171 @@
+// Package rpc serves the delivery engine over gRPC.
+package rpc
+
+//go:generate sh -c "cd ../../proto && buf generate"
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/go-chi/chi/v5/middleware"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"ad-targeting-engine/internal/auction"
+	"ad-targeting-engine/internal/engine"
+	"ad-targeting-engine/internal/events"
+	pb "ad-targeting-engine/internal/rpc/deliveryv1"
+	"ad-targeting-engine/internal/tracking"
+	"ad-targeting-engine/internal/validate"
+)
+
+// DeliveryServer implements DeliveryService on a DeliveryEngine.
+type DeliveryServer struct {
+	pb.UnimplementedDeliveryServiceServer
+
+	eng       *engine.DeliveryEngine
+	maxBatch  int
+	decisions *events.Sampler
+	auction   *auction.Auction
+	tracking  Tracking
+}
+
+// Tracking signs the impression and click links of matched campaigns. Both
+// fields are needed: without a request there is no host to fall back on.
+type Tracking struct {
+	Signer *tracking.Signer
+	Base   string // scheme and host of the tracking endpoints
+}
+
+// NewDeliveryServer serves eng; decisions, if not nil, samples the answers
+// into the event sink, a, if not nil, runs the auction among matches for
+// the slots each request asks for, and tr adds tracking links when set.
+func NewDeliveryServer(eng *engine.DeliveryEngine, maxBatch int, decisions *events.Sampler, a *auction.Auction,
+	tr Tracking) *DeliveryServer {
+	return &DeliveryServer{eng: eng, maxBatch: maxBatch, decisions: decisions, auction: a, tracking: tr}
+}
+
+// NewServer returns a gRPC server with DeliveryService registered and the
+// request ID, deadline and metrics interceptors installed. timeout is the
+// deadline given to unary calls that arrive without one.
+func NewServer(eng *engine.DeliveryEngine, timeout time.Duration, maxBatch int, decisions *events.Sampler,
+	a *auction.Auction, tr Tracking) *grpc.Server {
+	srv := grpc.NewServer(
+		grpc.ChainUnaryInterceptor(unaryMetrics, unaryRequestID, unaryDeadline(timeout)),
+		grpc.ChainStreamInterceptor(streamMetrics, streamRequestID),
+	)
+	pb.RegisterDeliveryServiceServer(srv, NewDeliveryServer(eng, maxBatch, decisions, a, tr))
+	return srv
+}
+
+func (s *DeliveryServer) Match(ctx context.Context, req *pb.MatchRequest) (*pb.MatchResponse, error) {
+	mr, errs := toMatchRequest(req)
+	if len(errs) > 0 {
+		return nil, invalidArgument("", errs)
+	}
+	return s.match(ctx, req.GetRequestId(), mr), nil
+}
+
+func (s *DeliveryServer) BatchMatch(ctx context.Context, req *pb.BatchMatchRequest) (*pb.BatchMatchResponse, error) {
+	if n := len(req.GetRequests()); n > s.maxBatch {
+		return nil, status.Errorf(codes.InvalidArgument, "batch of %d requests exceeds the limit of %d", n, s.maxBatch)
+	}
+	mrs := make([]engine.MatchRequest, len(req.GetRequests()))
+	for i, r := range req.GetRequests() {
+		var errs validate.Errors
+		if mrs[i], errs = toMatchRequest(r); len(errs) > 0 {
+			return nil, invalidArgument(fmt.Sprintf("requests[%d]", i), errs)
+		}
+	}
+	out := &pb.BatchMatchResponse{Responses: make([]*pb.MatchResponse, 0, len(mrs))}
+	for i, mr := range mrs {
+		if err := ctx.Err(); err != nil {
+			return nil, status.FromContextError(err).Err()
+		}
+		out.Responses = append(out.Responses, s.match(ctx, req.GetRequests()[i].GetRequestId(), mr))
+	}
+	return out, nil
+}
+
+// MatchStream answers requests until the client closes its side. An
+// invalid request ends the stream with InvalidArgument.
+func (s *DeliveryServer) MatchStream(stream pb.DeliveryService_MatchStreamServer) error {
+	ctx := stream.Context()
+	for {
+		req, err := stream.Recv()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		mr, errs := toMatchRequest(req)
+		if len(errs) > 0 {
+			return invalidArgument(fmt.Sprintf("request %q", req.GetRequestId()), errs)
+		}
+		if err := stream.Send(s.match(ctx, req.GetRequestId(), mr)); err != nil {
+			return err
+		}
+	}
+}
+
+func (s *DeliveryServer) match(ctx context.Context, requestID string, req engine.MatchRequest) *pb.MatchResponse {
+	matches, meta := s.eng.MatchMeta(ctx, req)
+	s.decisions.Decision(ctx, requestID, req, meta, matches)
+	matches = s.auction.Run(req, matches)
+	resp := &pb.MatchResponse{RequestId: requestID, Campaigns: make([]*pb.Campaign, 0, len(matches))}
+	for _, c := range matches {
+		pc := &pb.Campaign{Id: c.ID, ImageUrl: c.Image, Cta: c.CTA, Version: c.Version, ClearingPrice: c.ClearingPrice}
+		if s.tracking.Signer != nil && s.tracking.Base != "" {
+			rid := requestID
+			if rid == "" {
+				rid = middleware.GetReqID(ctx)
+			}
+			l := s.tracking.Signer.Links(s.tracking.Base, tracking.Claims{RequestID: rid, CampaignID: c.ID,
+				Creative: c.Creative, Price: c.ClearingPrice, App: req.AppID, OS: req.OS, Country: req.Country})
+			pc.ImpressionUrl, pc.ClickUrl = l.Impression(), l.Click()
+		}
+		resp.Campaigns = append(resp.Campaigns, pc)
+	}
+	return resp
+}
+
+// invalidArgument counts errs and turns them into an InvalidArgument
+// status; prefix locates the offending request in batches and streams.
+func invalidArgument(prefix string, errs validate.Errors) error {
+	errs.Observe()
+	msg := errs.Error()
+	if prefix != "" {
+		msg = prefix + ": " + msg
+	}
+	return status.Error(codes.InvalidArgument, msg)
+}
+
+// toMatchRequest applies the same validation as the HTTP delivery endpoints.
+func toMatchRequest(req *pb.MatchRequest) (engine.MatchRequest, validate.Errors) {
+	var errs validate.Errors
+	mr := engine.MatchRequest{
+		AppID:       validate.App("app_id", req.GetAppId(), &errs),
+		OS:          validate.OS("os", req.GetOs(), &errs),
+		Country:     validate.Country("country", req.GetCountry(), &errs),
+		Debug:       req.GetDebug(),
+		Slots:       validate.Slots("slots", int(req.GetSlots()), &errs),
+		OSVersion:   validate.Optional("os_version", req.GetOsVersion(), validate.MaxContextLen, &errs),
+		Make:        validate.Optional("make", req.GetMake(), validate.MaxContextLen, &errs),
+		Model:       validate.Optional("model", req.GetModel(), validate.MaxContextLen, &errs),
+		Region:      validate.Optional("region", req.GetRegion(), validate.MaxContextLen, &errs),
+		City:        validate.Optional("city", req.GetCity(), validate.MaxContextLen, &errs),
+		Lat:         req.Lat,
+		Lon:         req.Lon,
+		AppCategory: validate.Optional("app_category", req.GetAppCategory(), validate.MaxContextLen, &errs),
+		UserID:      validate.Optional("user_id", req.GetUserId(), validate.MaxContextLen, &errs),
+		Consent:     validate.Optional("consent", req.GetConsent(), validate.MaxConsentLen, &errs),
+		PlacementID: validate.Optional("placement_id", req.GetPlacementId(), validate.MaxContextLen, &errs),
+	}
+	validate.Coordinates("lat", "lon", mr.Lat, mr.Lon, &errs)
+	return mr, errs
+}
@@ -0,0 +1,220 @@
+package rpc
+
+import (
+	"context"
+	"net"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+
+	"ad-targeting-engine/internal/auction"
+	"ad-targeting-engine/internal/engine"
+	pb "ad-targeting-engine/internal/rpc/deliveryv1"
+	"ad-targeting-engine/internal/storage"
+	"ad-targeting-engine/internal/tracking"
+)
+
+func newClient(t *testing.T) pb.DeliveryServiceClient {
+	t.Helper()
+	st := storage.NewMemoryStore(
+		storage.CampaignRow{ID: "spotify", Name: "Spotify", ImageURL: "https://img", CTA: "Download", Status: "ACTIVE",
+			Rules: []storage.RuleRow{{Dimension: "country", IsInclusion: true, Values: []string{"US"}}}},
+		storage.CampaignRow{ID: "subway", Name: "Subway", ImageURL: "https://img2", CTA: "Play", Status: "ACTIVE",
+			Rules: []storage.RuleRow{{Dimension: "os", IsInclusion: true, Values: []string{"android"}}}},
+	)
+	return dial(t, st, nil, Tracking{})
+}
+
+// dial serves the campaigns in st, with the auction a and tracking tr, on
+// an in-memory listener.
+func dial(t *testing.T, st *storage.MemoryStore, a *auction.Auction, tr Tracking) pb.DeliveryServiceClient {
+	t.Helper()
+	eng := engine.NewEngine()
+	require.NoError(t, eng.BuildSnapshot(context.Background(), st))
+
+	lis := bufconn.Listen(1 << 20)
+	srv := NewServer(eng, time.Second, 2, nil, a, tr)
+	go func() { _ = srv.Serve(lis) }()
+	t.Cleanup(srv.Stop)
+
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return lis.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+	return pb.NewDeliveryServiceClient(conn)
+}
+
+func ids(resp *pb.MatchResponse) []string {
+	var out []string
+	for _, c := range resp.GetCampaigns() {
+		out = append(out, c.GetId())
+	}
+	return out
+}
+
+func TestMatch(t *testing.T) {
+	client := newClient(t)
+	ctx := metadata.AppendToOutgoingContext(context.Background(), requestIDHeader, "req-1")
+
+	tests := []struct {
+		name     string
+		req      *pb.MatchRequest
+		wantCode codes.Code
+		wantIDs  []string
+	}{
+		{"both match", &pb.MatchRequest{AppId: "com.x", Os: "Android", Country: "us"}, codes.OK, []string{"spotify", "subway"}},
+		{"one match", &pb.MatchRequest{AppId: "com.x", Os: "ios", Country: "US"}, codes.OK, []string{"spotify"}},
+		{"no match", &pb.MatchRequest{AppId: "com.x", Os: "ios", Country: "DE"}, codes.OK, nil},
+		{"missing fields", &pb.MatchRequest{AppId: "com.x"}, codes.InvalidArgument, nil},
+		{"invalid formats", &pb.MatchRequest{AppId: "com.x", Os: "symbian", Country: "XX"}, codes.InvalidArgument, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var header metadata.MD
+			resp, err := client.Match(ctx, tt.req, grpc.Header(&header))
+			require.Equal(t, tt.wantCode, status.Code(err), err)
+			assert.Equal(t, []string{"req-1"}, header.Get(requestIDHeader))
+			if err == nil {
+				assert.Equal(t, tt.wantIDs, ids(resp))
+			}
+		})
+	}
+}
+
+func TestMatchAuction(t *testing.T) {
+	client := dial(t, storage.NewMemoryStore(
+		storage.CampaignRow{ID: "low", ImageURL: "https://img", Status: "ACTIVE", BidPrice: 1},
+		storage.CampaignRow{ID: "high", ImageURL: "https://img", Status: "ACTIVE", BidPrice: 3},
+		storage.CampaignRow{ID: "mid", ImageURL: "https://img", Status: "ACTIVE", BidPrice: 2},
+	), auction.New(auction.Config{Type: auction.SecondPrice, Increment: 0.01}, nil), Tracking{})
+
+	tests := []struct {
+		name       string
+		slots      int32
+		wantCode   codes.Code
+		wantIDs    []string
+		wantPrices []float64
+	}{
+		{"default slots", 0, codes.OK, []string{"high"}, []float64{2.01}},
+		{"two slots", 2, codes.OK, []string{"high", "mid"}, []float64{2.01, 1.01}},
+		{"too many slots", 1000, codes.InvalidArgument, nil, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, err := client.Match(context.Background(),
+				&pb.MatchRequest{AppId: "com.x", Os: "ios", Country: "US", Slots: tt.slots})
+			require.Equal(t, tt.wantCode, status.Code(err), err)
+			if err != nil {
+				return
+			}
+			assert.Equal(t, tt.wantIDs, ids(resp))
+			var prices []float64
+			for _, c := range resp.GetCampaigns() {
+				prices = append(prices, c.GetClearingPrice())
+			}
+			assert.InDeltaSlice(t, tt.wantPrices, prices, 1e-9)
+		})
+	}
+}
+
+func TestMatchTracking(t *testing.T) {
+	signer, err := tracking.NewSigner([]tracking.Key{{ID: "k1", Secret: "s3cret"}}, time.Hour)
+	require.NoError(t, err)
+	client := dial(t, storage.NewMemoryStore(
+		storage.CampaignRow{ID: "spotify", ImageURL: "https://img", Status: "ACTIVE", BidPrice: 2},
+	), auction.New(auction.Config{Type: auction.FirstPrice}, nil), Tracking{Signer: signer, Base: "https://t.example"})
+
+	resp, err := client.Match(context.Background(), &pb.MatchRequest{RequestId: "r1", AppId: "com.x", Os: "ios", Country: "US"})
+	require.NoError(t, err)
+	require.Len(t, resp.GetCampaigns(), 1)
+	c := resp.GetCampaigns()[0]
+
+	u, err := url.Parse(c.GetImpressionUrl())
+	require.NoError(t, err)
+	assert.Equal(t, "https://t.example"+tracking.ImpressionPath, u.Scheme+"://"+u.Host+u.Path)
+	claims, err := signer.Verify(tracking.KindImpression, u.Query())
+	require.NoError(t, err)
+	assert.Equal(t, "r1", claims.RequestID)
+	assert.Equal(t, "spotify", claims.CampaignID)
+	assert.Equal(t, 2.0, claims.Price)
+	assert.True(t, strings.HasPrefix(c.GetClickUrl(), "https://t.example"+tracking.ClickPath+"?"))
+}
+
+func TestBatchMatch(t *testing.T) {
+	client := newClient(t)
+	ctx := context.Background()
+
+	resp, err := client.BatchMatch(ctx, &pb.BatchMatchRequest{Requests: []*pb.MatchRequest{
+		{RequestId: "a", AppId: "com.x", Os: "ios", Country: "US"},
+		{RequestId: "b", AppId: "com.x", Os: "android", Country: "DE"},
+	}})
+	require.NoError(t, err)
+	require.Len(t, resp.GetResponses(), 2)
+	assert.Equal(t, "a", resp.GetResponses()[0].GetRequestId())
+	assert.Equal(t, []string{"spotify"}, ids(resp.GetResponses()[0]))
+	assert.Equal(t, []string{"subway"}, ids(resp.GetResponses()[1]))
+
+	valid := &pb.MatchRequest{AppId: "com.x", Os: "ios", Country: "US"}
+	_, err = client.BatchMatch(ctx, &pb.BatchMatchRequest{Requests: []*pb.MatchRequest{valid, valid, valid}})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err), "over the batch limit")
+	_, err = client.BatchMatch(ctx, &pb.BatchMatchRequest{Requests: []*pb.MatchRequest{valid, {}}})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+}
+
+func TestMatchStream(t *testing.T) {
+	client := newClient(t)
+	stream, err := client.MatchStream(context.Background())
+	require.NoError(t, err)
+
+	for _, r := range []*pb.MatchRequest{
+		{RequestId: "1", AppId: "com.x", Os: "ios", Country: "US"},
+		{RequestId: "2", AppId: "com.x", Os: "android", Country: "US"},
+	} {
+		require.NoError(t, stream.Send(r))
+		resp, err := stream.Recv()
+		require.NoError(t, err)
+		assert.Equal(t, r.GetRequestId(), resp.GetRequestId())
+	}
+	header, err := stream.Header()
+	require.NoError(t, err)
+	assert.Len(t, header.Get(requestIDHeader), 1, "a request id is generated when the caller sends none")
+
+	require.NoError(t, stream.Send(&pb.MatchRequest{RequestId: "3"}))
+	_, err = stream.Recv()
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+}
+
+func TestUnaryDeadline(t *testing.T) {
+	intercept := unaryDeadline(50 * time.Millisecond)
+	var got time.Time
+	handler := func(ctx context.Context, _ any) (any, error) {
+		got, _ = ctx.Deadline()
+		return nil, nil
+	}
+
+	_, err := intercept(context.Background(), nil, &grpc.UnaryServerInfo{}, handler)
+	require.NoError(t, err)
+	assert.WithinDuration(t, time.Now().Add(50*time.Millisecond), got, 50*time.Millisecond)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Hour)
+	defer cancel()
+	_, err = intercept(ctx, nil, &grpc.UnaryServerInfo{}, handler)
+	require.NoError(t, err)
+	assert.WithinDuration(t, time.Now().Add(time.Hour), got, time.Second, "caller deadlines are kept")
+
+	expired, cancel2 := context.WithTimeout(context.Background(), -time.Second)
+	defer cancel2()
+	_, err = intercept(expired, nil, &grpc.UnaryServerInfo{}, handler)
+	assert.Equal(t, codes.DeadlineExceeded, status.Code(err))
+}
@@ -0,0 +1,217 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"sort"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+var (
+	// ErrUnknownAdvertiser is returned for campaign writes naming an
+	// advertiser that does not exist.
+	ErrUnknownAdvertiser = errors.New("unknown advertiser")
+	// ErrAdvertiserInUse is returned when deleting an advertiser that still
+	// owns campaigns.
+	ErrAdvertiserInUse = errors.New("advertiser has campaigns")
+)
+
+// AdvertiserRow is one advertisers row. Its status and blocked apps apply to
+// every campaign it owns.
+type AdvertiserRow struct {
+	ID          string
+	Name        string
+	Status      string // "ACTIVE" | "INACTIVE"
+	BlockedApps []string
+	Version     int64
+	UpdatedAt   time.Time
+}
+
+// AdvertiserStore keeps advertisers. Writes record change events, so they
+// reach the delivery snapshot like campaign writes.
+type AdvertiserStore interface {
+	LoadAdvertisers(ctx context.Context) ([]AdvertiserRow, error)
+	GetAdvertiser(ctx context.Context, id string) (AdvertiserRow, error)
+	// PutAdvertiser creates or replaces a.ID and returns the new version.
+	PutAdvertiser(ctx context.Context, a AdvertiserRow) (int64, error)
+	DeleteAdvertiser(ctx context.Context, id string) error
+}
+
+const advertiserColumns = `id, name, status, blocked_apps, version, updated_at`
+
+// LoadAdvertisers returns every advertiser. Like LoadPublishers it reads
+// from the primary, since it is reloaded whole on change events.
+func (s *Store) LoadAdvertisers(ctx context.Context) ([]AdvertiserRow, error) {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	rows, err := s.pool.Query(ctx, `SELECT `+advertiserColumns+` FROM advertisers ORDER BY id`)
+	if err != nil {
+		return nil, fmt.Errorf("query advertisers: %w", err)
+	}
+	defer rows.Close()
+
+	var out []AdvertiserRow
+	for rows.Next() {
+		var a AdvertiserRow
+		if err := rows.Scan(&a.ID, &a.Name, &a.Status, &a.BlockedApps, &a.Version, &a.UpdatedAt); err != nil {
+			return nil, fmt.Errorf("scan advertiser: %w", err)
+		}
+		out = append(out, a)
+	}
+	if rows.Err() != nil {
+		return nil, rows.Err()
+	}
+	return out, nil
+}
+
+func (s *Store) GetAdvertiser(ctx context.Context, id string) (AdvertiserRow, error) {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	var a AdvertiserRow
+	err := s.pool.QueryRow(ctx, `SELECT `+advertiserColumns+` FROM advertisers WHERE id = $1`, id).
+		Scan(&a.ID, &a.Name, &a.Status, &a.BlockedApps, &a.Version, &a.UpdatedAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return AdvertiserRow{}, fmt.Errorf("advertiser %s: %w", id, ErrNotFound)
+	}
+	if err != nil {
+		return AdvertiserRow{}, fmt.Errorf("query advertiser: %w", err)
+	}
+	return a, nil
+}
+
+func (s *Store) PutAdvertiser(ctx context.Context, a AdvertiserRow) (int64, error) {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	var version int64
+	err := s.pool.QueryRow(ctx, `
+		INSERT INTO advertisers AS a (id, name, status, blocked_apps)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (id) DO UPDATE SET
+			name         = EXCLUDED.name,
+			status       = EXCLUDED.status,
+			blocked_apps = EXCLUDED.blocked_apps,
+			version      = a.version + 1,
+			updated_at   = now()
+		RETURNING version
+	`, a.ID, a.Name, a.Status, nonNil(a.BlockedApps)).Scan(&version)
+	if err != nil {
+		return 0, fmt.Errorf("upsert advertiser: %w", err)
+	}
+	return version, nil
+}
+
+func (s *Store) DeleteAdvertiser(ctx context.Context, id string) error {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	tag, err := s.pool.Exec(ctx, `DELETE FROM advertisers WHERE id = $1`, id)
+	if isForeignKeyViolation(err) {
+		return fmt.Errorf("advertiser %s: %w", id, ErrAdvertiserInUse)
+	}
+	if err != nil {
+		return fmt.Errorf("delete advertiser: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return fmt.Errorf("advertiser %s: %w", id, ErrNotFound)
+	}
+	return nil
+}
+
+// isForeignKeyViolation reports a write that names a missing advertiser,
+// or a delete of one that campaigns still reference.
+func isForeignKeyViolation(err error) bool {
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) && pgErr.Code == "23503"
+}
+
+func (m *MemoryStore) LoadAdvertisers(context.Context) ([]AdvertiserRow, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	out := make([]AdvertiserRow, 0, len(m.advertisers))
+	for _, a := range m.advertisers {
+		out = append(out, a.clone())
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].ID < out[j].ID })
+	return out, nil
+}
+
+func (m *MemoryStore) GetAdvertiser(_ context.Context, id string) (AdvertiserRow, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	a, ok := m.advertisers[id]
+	if !ok {
+		return AdvertiserRow{}, fmt.Errorf("advertiser %s: %w", id, ErrNotFound)
+	}
+	return a.clone(), nil
+}
+
+func (m *MemoryStore) PutAdvertiser(ctx context.Context, a AdvertiserRow) (int64, error) {
+	var version int64
+	err := m.write(ctx, func(tx *memTx) error {
+		op := "INSERT"
+		a = a.clone()
+		a.Version, a.UpdatedAt = 1, tx.now
+		if old, ok := m.advertisers[a.ID]; ok {
+			op, a.Version = "UPDATE", old.Version+1
+		}
+		m.advertisers[a.ID] = a
+		tx.event("advertiser", a.ID, "", op)
+		version = a.Version
+		return nil
+	})
+	return version, err
+}
+
+func (m *MemoryStore) DeleteAdvertiser(ctx context.Context, id string) error {
+	return m.write(ctx, func(tx *memTx) error {
+		if _, ok := m.advertisers[id]; !ok {
+			return fmt.Errorf("advertiser %s: %w", id, ErrNotFound)
+		}
+		for _, mc := range m.campaigns {
+			if mc.row.Advertiser == id {
+				return fmt.Errorf("advertiser %s: %w", id, ErrAdvertiserInUse)
+			}
+		}
+		delete(m.advertisers, id)
+		tx.event("advertiser", id, "", "DELETE")
+		return nil
+	})
+}
+
+// checkAdvertiser mirrors the campaigns.advertiser_id foreign key. The
+// caller holds the write lock.
+func (m *MemoryStore) checkAdvertiser(c CampaignRow) error {
+	if _, ok := m.advertisers[c.Advertiser]; c.Advertiser != "" && !ok {
+		return fmt.Errorf("campaign %s: advertiser %s: %w", c.ID, c.Advertiser, ErrUnknownAdvertiser)
+	}
+	return nil
+}
+
+func (a AdvertiserRow) clone() AdvertiserRow {
+	a.BlockedApps = nonNil(slices.Clone(a.BlockedApps))
+	return a
+}
+
+func (r *BreakerRepository) LoadAdvertisers(ctx context.Context) ([]AdvertiserRow, error) {
+	return guard(r.b, func() ([]AdvertiserRow, error) { return r.Repository.LoadAdvertisers(ctx) })
+}
+
+func (r *BreakerRepository) GetAdvertiser(ctx context.Context, id string) (AdvertiserRow, error) {
+	return guard(r.b, func() (AdvertiserRow, error) { return r.Repository.GetAdvertiser(ctx, id) })
+}
+
+func (r *BreakerRepository) PutAdvertiser(ctx context.Context, a AdvertiserRow) (int64, error) {
+	return guard(r.b, func() (int64, error) { return r.Repository.PutAdvertiser(ctx, a) })
+}
+
+func (r *BreakerRepository) DeleteAdvertiser(ctx context.Context, id string) error {
+	return r.b.Do(func() error { return r.Repository.DeleteAdvertiser(ctx, id) })
+}
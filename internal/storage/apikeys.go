@@ -0,0 +1,139 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+)
+
+// APIKeyRow is one api_keys row. Hash is the hex SHA-256 of the key; the
+// key itself is never stored.
+type APIKeyRow struct {
+	ID            string
+	Name          string
+	Hash          string
+	Scopes        []string
+	RatePerSecond float64 // 0 means unlimited
+	Burst         int     // 0 means one second's worth of RatePerSecond
+	CreatedAt     time.Time
+}
+
+// KeyStore reads and manages API keys.
+type KeyStore interface {
+	// LoadAPIKeys returns every key that has not been revoked.
+	LoadAPIKeys(ctx context.Context) ([]APIKeyRow, error)
+	CreateAPIKey(ctx context.Context, k APIKeyRow) error
+	RevokeAPIKey(ctx context.Context, id string) error
+}
+
+func (s *Store) LoadAPIKeys(ctx context.Context) ([]APIKeyRow, error) {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	rows, err := s.reader().Query(ctx, `
+		SELECT id, name, key_hash, scopes, rate_per_second, burst, created_at
+		FROM api_keys
+		WHERE revoked_at IS NULL
+		ORDER BY id
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("query api keys: %w", err)
+	}
+	defer rows.Close()
+
+	var out []APIKeyRow
+	for rows.Next() {
+		var k APIKeyRow
+		if err := rows.Scan(&k.ID, &k.Name, &k.Hash, &k.Scopes, &k.RatePerSecond, &k.Burst, &k.CreatedAt); err != nil {
+			return nil, fmt.Errorf("scan api key: %w", err)
+		}
+		out = append(out, k)
+	}
+	if rows.Err() != nil {
+		return nil, rows.Err()
+	}
+	return out, nil
+}
+
+func (s *Store) CreateAPIKey(ctx context.Context, k APIKeyRow) error {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	_, err := s.pool.Exec(ctx, `
+		INSERT INTO api_keys (id, name, key_hash, scopes, rate_per_second, burst)
+		VALUES ($1, $2, $3, $4, $5, $6)
+	`, k.ID, k.Name, k.Hash, k.Scopes, k.RatePerSecond, k.Burst)
+	if isUniqueViolation(err) {
+		return fmt.Errorf("api key %s: %w", k.ID, ErrConflict)
+	}
+	if err != nil {
+		return fmt.Errorf("insert api key: %w", err)
+	}
+	return nil
+}
+
+func (s *Store) RevokeAPIKey(ctx context.Context, id string) error {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	tag, err := s.pool.Exec(ctx, `UPDATE api_keys SET revoked_at = now() WHERE id = $1 AND revoked_at IS NULL`, id)
+	if err != nil {
+		return fmt.Errorf("revoke api key: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return fmt.Errorf("api key %s: %w", id, ErrNotFound)
+	}
+	return nil
+}
+
+func (m *MemoryStore) LoadAPIKeys(context.Context) ([]APIKeyRow, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	out := make([]APIKeyRow, 0, len(m.keys))
+	for _, k := range m.keys {
+		k.Scopes = append([]string(nil), k.Scopes...)
+		out = append(out, k)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].ID < out[j].ID })
+	return out, nil
+}
+
+func (m *MemoryStore) CreateAPIKey(_ context.Context, k APIKeyRow) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.keys[k.ID]; ok {
+		return fmt.Errorf("api key %s: %w", k.ID, ErrConflict)
+	}
+	for _, other := range m.keys {
+		if other.Hash == k.Hash {
+			return fmt.Errorf("api key %s: %w", k.ID, ErrConflict)
+		}
+	}
+	k.Scopes = append([]string(nil), k.Scopes...)
+	k.CreatedAt = m.now()
+	m.keys[k.ID] = k
+	return nil
+}
+
+func (m *MemoryStore) RevokeAPIKey(_ context.Context, id string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.keys[id]; !ok {
+		return fmt.Errorf("api key %s: %w", id, ErrNotFound)
+	}
+	delete(m.keys, id)
+	return nil
+}
+
+func (r *BreakerRepository) LoadAPIKeys(ctx context.Context) ([]APIKeyRow, error) {
+	return guard(r.b, func() ([]APIKeyRow, error) { return r.Repository.LoadAPIKeys(ctx) })
+}
+
+func (r *BreakerRepository) CreateAPIKey(ctx context.Context, k APIKeyRow) error {
+	return r.b.Do(func() error { return r.Repository.CreateAPIKey(ctx, k) })
+}
+
+func (r *BreakerRepository) RevokeAPIKey(ctx context.Context, id string) error {
+	return r.b.Do(func() error { return r.Repository.RevokeAPIKey(ctx, id) })
+}
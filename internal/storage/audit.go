@@ -0,0 +1,208 @@
+package storage
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"time"
+)
+
+type actorKey struct{}
+
+// WithActor tags ctx with the identity recorded in campaign_audit for any
+// write made through it.
+func WithActor(ctx context.Context, actor string) context.Context {
+	return context.WithValue(ctx, actorKey{}, actor)
+}
+
+func actorFrom(ctx context.Context) string {
+	a, _ := ctx.Value(actorKey{}).(string)
+	return a
+}
+
+// AuditEntry is one row of campaign_audit. Before is nil for inserts and
+// After is nil for deletes.
+type AuditEntry struct {
+	ID         int64           `json:"id"`
+	EntityType string          `json:"entity_type"`
+	EntityID   string          `json:"entity_id"`
+	CampaignID string          `json:"campaign_id"`
+	Op         string          `json:"op"`
+	Actor      string          `json:"actor"`
+	Before     json.RawMessage `json:"before,omitempty"`
+	After      json.RawMessage `json:"after,omitempty"`
+	ChangedAt  time.Time       `json:"changed_at"`
+}
+
+// ListAudit returns the newest audit entries for a campaign and its rules.
+func (s *Store) ListAudit(ctx context.Context, campaignID string, limit int) ([]AuditEntry, error) {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	rows, err := s.pool.Query(ctx, `
+		SELECT id, entity_type, entity_id, campaign_id, op, actor, before, after, changed_at
+		FROM campaign_audit
+		WHERE campaign_id = $1
+		ORDER BY id DESC
+		LIMIT $2
+	`, campaignID, limit)
+	if err != nil {
+		return nil, fmt.Errorf("query audit: %w", err)
+	}
+	defer rows.Close()
+
+	var out []AuditEntry
+	for rows.Next() {
+		var e AuditEntry
+		if err := rows.Scan(&e.ID, &e.EntityType, &e.EntityID, &e.CampaignID, &e.Op, &e.Actor, &e.Before, &e.After, &e.ChangedAt); err != nil {
+			return nil, fmt.Errorf("scan audit: %w", err)
+		}
+		out = append(out, e)
+	}
+	if rows.Err() != nil {
+		return nil, rows.Err()
+	}
+	return out, nil
+}
+
+// auditCampaign and auditRule mirror to_jsonb(campaigns) and
+// to_jsonb(targeting_rules) as written by the audit trigger.
+type auditCampaign struct {
+	ID       string  `json:"id"`
+	Name     string  `json:"name"`
+	ImageURL *string `json:"image_url"`
+	CTA      *string `json:"cta"`
+	Status   string  `json:"status"`
+	Version  int64   `json:"version"`
+	BidPrice float64 `json:"bid_price"`
+	Markup   *string `json:"markup"`
+	Landing  *string `json:"landing_url"`
+
+	CreativeType  string  `json:"creative_type"`
+	VideoURL      *string `json:"video_url"`
+	VideoMIME     *string `json:"video_mime"`
+	VideoDuration *int    `json:"video_duration"`
+	VideoWidth    *int    `json:"video_width"`
+	VideoHeight   *int    `json:"video_height"`
+
+	DailyImpressionCap    *int64   `json:"daily_impression_cap"`
+	LifetimeImpressionCap *int64   `json:"lifetime_impression_cap"`
+	DailyBudget           *float64 `json:"daily_budget"`
+	LifetimeBudget        *float64 `json:"lifetime_budget"`
+	Pacing                string   `json:"pacing"`
+
+	AdvertiserID *string `json:"advertiser_id"`
+	Category     *string `json:"category"`
+}
+
+type auditRule struct {
+	ID          int64    `json:"id"`
+	CampaignID  string   `json:"campaign_id"`
+	Dimension   string   `json:"dimension"`
+	IsInclusion bool     `json:"is_inclusion"`
+	Values      []string `json:"values"`
+}
+
+// LoadCampaignsAsOf rebuilds every campaign (any status) with its rules as
+// they stood at the given instant, by taking the latest audited state of
+// each campaign and rule at or before it.
+func (s *Store) LoadCampaignsAsOf(ctx context.Context, at time.Time) ([]CampaignRow, error) {
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	rows, err := s.pool.Query(ctx, `
+		SELECT DISTINCT ON (entity_type, entity_id) entity_type, after
+		FROM campaign_audit
+		WHERE changed_at <= $1
+		ORDER BY entity_type, entity_id, id DESC
+	`, at)
+	if err != nil {
+		return nil, fmt.Errorf("query audit as of: %w", err)
+	}
+	defer rows.Close()
+
+	var states []auditState
+	for rows.Next() {
+		var st auditState
+		if err := rows.Scan(&st.entityType, &st.after); err != nil {
+			return nil, fmt.Errorf("scan audit as of: %w", err)
+		}
+		states = append(states, st)
+	}
+	if rows.Err() != nil {
+		return nil, rows.Err()
+	}
+	return campaignsFromAudit(states)
+}
+
+// auditState is the latest audited state of one entity; after is nil when
+// the entity had been deleted.
+type auditState struct {
+	entityType string
+	after      []byte
+}
+
+// campaignsFromAudit assembles campaigns and their rules from the latest
+// audited state of each entity.
+func campaignsFromAudit(states []auditState) ([]CampaignRow, error) {
+	campaigns := map[string]*CampaignRow{}
+	var rules []auditRule
+	for _, st := range states {
+		if st.after == nil {
+			continue // deleted by then
+		}
+		switch st.entityType {
+		case "campaign":
+			var c auditCampaign
+			if err := json.Unmarshal(st.after, &c); err != nil {
+				return nil, fmt.Errorf("decode audited campaign: %w", err)
+			}
+			row := &CampaignRow{ID: c.ID, Name: c.Name, Status: c.Status, Version: c.Version, BidPrice: c.BidPrice,
+				Creative: creativeOrDefault(c.CreativeType)}
+			row.Video = VideoCreative{URL: deref(c.VideoURL), MIME: deref(c.VideoMIME),
+				Duration: deref(c.VideoDuration), Width: deref(c.VideoWidth), Height: deref(c.VideoHeight)}
+			row.Markup, row.Landing = deref(c.Markup), deref(c.Landing)
+			row.Advertiser, row.Category = deref(c.AdvertiserID), deref(c.Category)
+			row.Budget = Budget{DailyImpressions: deref(c.DailyImpressionCap), LifetimeImpressions: deref(c.LifetimeImpressionCap),
+				DailySpend: deref(c.DailyBudget), LifetimeSpend: deref(c.LifetimeBudget), Pacing: pacingOrDefault(c.Pacing)}
+			if c.ImageURL != nil {
+				row.ImageURL = *c.ImageURL
+			}
+			if c.CTA != nil {
+				row.CTA = *c.CTA
+			}
+			campaigns[c.ID] = row
+		case "targeting_rule":
+			var r auditRule
+			if err := json.Unmarshal(st.after, &r); err != nil {
+				return nil, fmt.Errorf("decode audited rule: %w", err)
+			}
+			rules = append(rules, r)
+		}
+	}
+
+	sort.Slice(rules, func(i, j int) bool { return rules[i].ID < rules[j].ID })
+	for _, r := range rules {
+		c, ok := campaigns[r.CampaignID]
+		if !ok {
+			continue
+		}
+		c.Rules = append(c.Rules, canonicalRule(RuleRow{Dimension: r.Dimension, IsInclusion: r.IsInclusion, Values: r.Values}))
+	}
+
+	out := make([]CampaignRow, 0, len(campaigns))
+	for _, c := range campaigns {
+		out = append(out, *c)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].ID < out[j].ID })
+	return out, nil
+}
+
+func deref[T any](p *T) T {
+	var v T
+	if p != nil {
+		v = *p
+	}
+	return v
+}
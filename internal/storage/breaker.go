@@ -0,0 +1,247 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+
+	"ad-targeting-engine/internal/observability"
+)
+
+// ErrBreakerOpen is returned without touching the database while the
+// breaker is open.
+var ErrBreakerOpen = errors.New("circuit breaker open")
+
+type BreakerState int
+
+const (
+	BreakerClosed BreakerState = iota
+	BreakerHalfOpen
+	BreakerOpen
+)
+
+func (s BreakerState) String() string {
+	switch s {
+	case BreakerClosed:
+		return "closed"
+	case BreakerHalfOpen:
+		return "half-open"
+	case BreakerOpen:
+		return "open"
+	}
+	return "unknown"
+}
+
+// Breaker is a closed/open/half-open circuit breaker. After threshold
+// consecutive failures it opens and fails calls fast for openFor; then a
+// single probe is let through (half-open) and its outcome closes or re-opens
+// the breaker.
+type Breaker struct {
+	name      string
+	threshold int
+	openFor   time.Duration
+	now       func() time.Time
+
+	mu       sync.Mutex
+	state    BreakerState
+	failures int
+	openedAt time.Time
+	probing  bool
+}
+
+func NewBreaker(name string, threshold int, openFor time.Duration) *Breaker {
+	if threshold <= 0 {
+		threshold = 1
+	}
+	b := &Breaker{name: name, threshold: threshold, openFor: openFor, now: time.Now}
+	observability.BreakerState.WithLabelValues(name).Set(float64(BreakerClosed))
+	return b
+}
+
+// State returns the current state.
+func (b *Breaker) State() BreakerState {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.state
+}
+
+// Do runs fn unless the breaker is open and records its outcome.
+func (b *Breaker) Do(fn func() error) error {
+	if err := b.allow(); err != nil {
+		return err
+	}
+	err := fn()
+	b.record(err)
+	return err
+}
+
+func (b *Breaker) allow() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch b.state {
+	case BreakerOpen:
+		if b.now().Sub(b.openedAt) < b.openFor {
+			observability.BreakerRejections.WithLabelValues(b.name).Inc()
+			return ErrBreakerOpen
+		}
+		b.transition(BreakerHalfOpen, nil)
+		b.probing = true
+	case BreakerHalfOpen:
+		if b.probing {
+			observability.BreakerRejections.WithLabelValues(b.name).Inc()
+			return ErrBreakerOpen
+		}
+		b.probing = true
+	}
+	return nil
+}
+
+func (b *Breaker) record(err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	failed := isBreakerFailure(err)
+	switch b.state {
+	case BreakerClosed:
+		if !failed {
+			b.failures = 0
+			return
+		}
+		b.failures++
+		if b.failures >= b.threshold {
+			b.transition(BreakerOpen, err)
+		}
+	case BreakerHalfOpen:
+		b.probing = false
+		if failed {
+			b.transition(BreakerOpen, err)
+		} else {
+			b.transition(BreakerClosed, nil)
+		}
+	}
+}
+
+// transition must be called with mu held.
+func (b *Breaker) transition(to BreakerState, cause error) {
+	from := b.state
+	b.state = to
+	switch to {
+	case BreakerOpen:
+		b.openedAt = b.now()
+	case BreakerClosed:
+		b.failures = 0
+	}
+	observability.BreakerState.WithLabelValues(b.name).Set(float64(to))
+
+	ev := log.Warn()
+	if to == BreakerClosed {
+		ev = log.Info()
+	}
+	ev.Err(cause).Str("breaker", b.name).Str("from", from.String()).Str("to", to.String()).
+		Int("failures", b.failures).Msg("circuit breaker transition")
+}
+
+// isBreakerFailure reports whether err says something about database
+// health. Domain outcomes and caller cancellations do not; timeouts do.
+func isBreakerFailure(err error) bool {
+	switch {
+	case err == nil,
+		errors.Is(err, ErrNotFound),
+		errors.Is(err, ErrConflict),
+		errors.Is(err, ErrVersionConflict),
+		errors.Is(err, ErrUnknownAdvertiser),
+		errors.Is(err, ErrAdvertiserInUse),
+		errors.Is(err, context.Canceled):
+		return false
+	}
+	return true
+}
+
+// BreakerRepository runs every call of the wrapped Repository through a
+// Breaker, so a slow or down database costs callers nothing while the
+// breaker is open. Subscribe is passed through: it is one long-lived call
+// with its own reconnect logic.
+type BreakerRepository struct {
+	Repository
+	b *Breaker
+}
+
+func NewBreakerRepository(r Repository, b *Breaker) *BreakerRepository {
+	return &BreakerRepository{Repository: r, b: b}
+}
+
+func (r *BreakerRepository) Breaker() *Breaker { return r.b }
+
+func guard[T any](b *Breaker, fn func() (T, error)) (T, error) {
+	var out T
+	err := b.Do(func() error {
+		var err error
+		out, err = fn()
+		return err
+	})
+	return out, err
+}
+
+func (r *BreakerRepository) LoadActiveCampaigns(ctx context.Context) ([]CampaignRow, error) {
+	return guard(r.b, func() ([]CampaignRow, error) { return r.Repository.LoadActiveCampaigns(ctx) })
+}
+
+func (r *BreakerRepository) StreamActiveCampaigns(ctx context.Context, fn func(CampaignRow) error) (int64, error) {
+	return guard(r.b, func() (int64, error) { return r.Repository.StreamActiveCampaigns(ctx, fn) })
+}
+
+func (r *BreakerRepository) LoadActiveCampaignsByID(ctx context.Context, ids []string) ([]CampaignRow, error) {
+	return guard(r.b, func() ([]CampaignRow, error) { return r.Repository.LoadActiveCampaignsByID(ctx, ids) })
+}
+
+func (r *BreakerRepository) ListCampaigns(ctx context.Context) ([]CampaignRow, error) {
+	return guard(r.b, func() ([]CampaignRow, error) { return r.Repository.ListCampaigns(ctx) })
+}
+
+func (r *BreakerRepository) GetCampaign(ctx context.Context, id string) (CampaignRow, error) {
+	return guard(r.b, func() (CampaignRow, error) { return r.Repository.GetCampaign(ctx, id) })
+}
+
+func (r *BreakerRepository) ListAudit(ctx context.Context, campaignID string, limit int) ([]AuditEntry, error) {
+	return guard(r.b, func() ([]AuditEntry, error) { return r.Repository.ListAudit(ctx, campaignID, limit) })
+}
+
+func (r *BreakerRepository) LoadCampaignsAsOf(ctx context.Context, at time.Time) ([]CampaignRow, error) {
+	return guard(r.b, func() ([]CampaignRow, error) { return r.Repository.LoadCampaignsAsOf(ctx, at) })
+}
+
+func (r *BreakerRepository) CreateCampaign(ctx context.Context, c CampaignRow) error {
+	return r.b.Do(func() error { return r.Repository.CreateCampaign(ctx, c) })
+}
+
+func (r *BreakerRepository) UpdateCampaign(ctx context.Context, c CampaignRow, replaceRules bool) (int64, error) {
+	return guard(r.b, func() (int64, error) { return r.Repository.UpdateCampaign(ctx, c, replaceRules) })
+}
+
+func (r *BreakerRepository) ReplaceRules(ctx context.Context, id string, rules []RuleRow, expectedVersion int64) (int64, error) {
+	return guard(r.b, func() (int64, error) { return r.Repository.ReplaceRules(ctx, id, rules, expectedVersion) })
+}
+
+func (r *BreakerRepository) DeleteCampaign(ctx context.Context, id string, expectedVersion int64) error {
+	return r.b.Do(func() error { return r.Repository.DeleteCampaign(ctx, id, expectedVersion) })
+}
+
+func (r *BreakerRepository) ImportCampaigns(ctx context.Context, cs []CampaignRow) (ImportResult, error) {
+	return guard(r.b, func() (ImportResult, error) { return r.Repository.ImportCampaigns(ctx, cs) })
+}
+
+func (r *BreakerRepository) LatestChangeSeq(ctx context.Context) (int64, error) {
+	return guard(r.b, func() (int64, error) { return r.Repository.LatestChangeSeq(ctx) })
+}
+
+func (r *BreakerRepository) LoadChangeEventsSince(ctx context.Context, after int64, limit int) ([]ChangeEvent, error) {
+	return guard(r.b, func() ([]ChangeEvent, error) { return r.Repository.LoadChangeEventsSince(ctx, after, limit) })
+}
+
+func (r *BreakerRepository) PruneChangeEvents(ctx context.Context, upTo int64, olderThan time.Time) (int64, error) {
+	return guard(r.b, func() (int64, error) { return r.Repository.PruneChangeEvents(ctx, upTo, olderThan) })
+}
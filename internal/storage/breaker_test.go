@@ -0,0 +1,88 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBreaker_Transitions(t *testing.T) {
+	now := time.Unix(0, 0)
+	b := NewBreaker("test", 2, 10*time.Second)
+	b.now = func() time.Time { return now }
+
+	down := errors.New("connection refused")
+	fail := func() error { return down }
+	ok := func() error { return nil }
+
+	// domain errors do not count towards the threshold
+	assert.ErrorIs(t, b.Do(func() error { return ErrNotFound }), ErrNotFound)
+	assert.ErrorIs(t, b.Do(fail), down)
+	assert.Equal(t, BreakerClosed, b.State())
+	assert.ErrorIs(t, b.Do(fail), down)
+	require.Equal(t, BreakerOpen, b.State())
+
+	called := false
+	assert.ErrorIs(t, b.Do(func() error { called = true; return nil }), ErrBreakerOpen)
+	assert.False(t, called, "open breaker must not call through")
+
+	// after openFor one probe goes through; a failing probe re-opens
+	now = now.Add(10 * time.Second)
+	assert.ErrorIs(t, b.Do(fail), down)
+	assert.Equal(t, BreakerOpen, b.State())
+	assert.ErrorIs(t, b.Do(ok), ErrBreakerOpen)
+
+	// a successful probe closes it again
+	now = now.Add(10 * time.Second)
+	assert.NoError(t, b.Do(ok))
+	assert.Equal(t, BreakerClosed, b.State())
+}
+
+func TestBreaker_SingleProbe(t *testing.T) {
+	now := time.Unix(0, 0)
+	b := NewBreaker("test", 1, time.Second)
+	b.now = func() time.Time { return now }
+	_ = b.Do(func() error { return errors.New("down") })
+	now = now.Add(time.Second)
+
+	release := make(chan struct{})
+	probing := make(chan struct{})
+	done := make(chan error)
+	go func() {
+		done <- b.Do(func() error { close(probing); <-release; return nil })
+	}()
+	<-probing
+
+	assert.Equal(t, BreakerHalfOpen, b.State())
+	assert.ErrorIs(t, b.Do(func() error { return nil }), ErrBreakerOpen, "only one probe while half-open")
+	close(release)
+	assert.NoError(t, <-done)
+	assert.Equal(t, BreakerClosed, b.State())
+}
+
+func TestBreakerRepository_FailsFast(t *testing.T) {
+	mem := NewMemoryStore(CampaignRow{ID: "a", Name: "A", Status: "ACTIVE"})
+	b := NewBreaker("test", 1, time.Minute)
+	repo := NewBreakerRepository(mem, b)
+
+	rows, err := repo.LoadActiveCampaigns(context.Background())
+	require.NoError(t, err)
+	assert.Len(t, rows, 1)
+
+	// a deadline is a health signal, cancellation by the caller is not
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_ = b.Do(func() error { return ctx.Err() })
+	assert.Equal(t, BreakerClosed, b.State())
+	_ = b.Do(func() error { return context.DeadlineExceeded })
+	require.Equal(t, BreakerOpen, b.State())
+
+	_, err = repo.LoadActiveCampaigns(context.Background())
+	assert.ErrorIs(t, err, ErrBreakerOpen)
+	_, err = repo.GetCampaign(context.Background(), "a")
+	assert.ErrorIs(t, err, ErrBreakerOpen)
+}
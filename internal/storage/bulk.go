@@ -0,0 +1,323 @@
+package storage
+
+import (
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// CSVHeader is the column layout of the bulk CSV format: one row per rule,
+// campaign fields repeated on each. A campaign without rules is a single row
+// with an empty dimension. Values are separated by CSVValueSep. Files
+// written before the pricing, video, landing_url, budget or advertiser
+// columns existed are still accepted.
+var CSVHeader = []string{"id", "name", "image_url", "cta", "status", "dimension", "include", "values",
+	"bid_price", "markup", "creative_type", "video_url", "video_mime", "video_duration", "video_width", "video_height",
+	"landing_url", "daily_impression_cap", "lifetime_impression_cap", "daily_budget", "lifetime_budget", "pacing",
+	"advertiser_id", "category"}
+
+// csvLegacyColumns are the column counts of older layouts: before
+// bid_price and markup, before the video columns, before landing_url,
+// before the budget columns and before advertiser_id and category.
+var csvLegacyColumns = []int{8, 10, 16, 17, 22}
+
+const CSVValueSep = "|"
+
+// CSVRow is one raw, unvalidated line of a bulk CSV file.
+type CSVRow struct {
+	Line       int
+	ID         string
+	Name       string
+	ImageURL   string
+	CTA        string
+	Status     string
+	Dimension  string
+	Include    string
+	Values     []string
+	BidPrice   string
+	Markup     string
+	Creative   string
+	Video      [5]string // url, mime, duration, width, height
+	Landing    string
+	Budget     [5]string // daily and lifetime impression caps, daily and lifetime budget, pacing
+	Advertiser string
+	Category   string
+}
+
+// ImportResult summarizes an applied import.
+type ImportResult struct {
+	Created int `json:"created"`
+	Updated int `json:"updated"`
+	Rules   int `json:"rules"`
+}
+
+// ReadCampaignsCSV parses a bulk CSV file. It only checks the shape of the
+// file; field validation is up to the caller so errors can be reported per
+// row.
+func ReadCampaignsCSV(r io.Reader) ([]CSVRow, error) {
+	cr := csv.NewReader(r)
+	cr.TrimLeadingSpace = true // FieldsPerRecord 0: every row must match the header
+
+	header, err := cr.Read()
+	if err != nil {
+		return nil, fmt.Errorf("read csv header: %w", err)
+	}
+	if len(header) != len(CSVHeader) && !slices.Contains(csvLegacyColumns, len(header)) {
+		return nil, fmt.Errorf("csv header: %d columns, want %d", len(header), len(CSVHeader))
+	}
+	for i, h := range header {
+		if !strings.EqualFold(strings.TrimSpace(h), CSVHeader[i]) {
+			return nil, fmt.Errorf("csv header: column %d is %q, want %q", i+1, h, CSVHeader[i])
+		}
+	}
+
+	var out []CSVRow
+	for {
+		rec, err := cr.Read()
+		if errors.Is(err, io.EOF) {
+			return out, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("read csv: %w", err)
+		}
+		line, _ := cr.FieldPos(0)
+		row := CSVRow{
+			Line:      line,
+			ID:        rec[0],
+			Name:      rec[1],
+			ImageURL:  rec[2],
+			CTA:       rec[3],
+			Status:    rec[4],
+			Dimension: rec[5],
+			Include:   rec[6],
+		}
+		if rec[7] != "" {
+			row.Values = strings.Split(rec[7], CSVValueSep)
+		}
+		if len(rec) > 8 {
+			row.BidPrice, row.Markup = rec[8], rec[9]
+		}
+		if len(rec) > 10 {
+			row.Creative = rec[10]
+			copy(row.Video[:], rec[11:16])
+		}
+		if len(rec) > 16 {
+			row.Landing = rec[16]
+		}
+		if len(rec) > 17 {
+			copy(row.Budget[:], rec[17:22])
+		}
+		if len(rec) > 22 {
+			row.Advertiser, row.Category = rec[22], rec[23]
+		}
+		out = append(out, row)
+	}
+}
+
+// WriteCampaignsCSV writes cs in the bulk CSV format.
+func WriteCampaignsCSV(w io.Writer, cs []CampaignRow) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write(CSVHeader); err != nil {
+		return err
+	}
+	for _, c := range cs {
+		base := []string{c.ID, c.Name, c.ImageURL, c.CTA, c.Status}
+		tail := []string{strconv.FormatFloat(c.BidPrice, 'f', -1, 64), c.Markup, creativeOrDefault(c.Creative),
+			c.Video.URL, c.Video.MIME, csvInt(c.Video.Duration), csvInt(c.Video.Width), csvInt(c.Video.Height),
+			c.Landing, csvInt(int(c.Budget.DailyImpressions)), csvInt(int(c.Budget.LifetimeImpressions)),
+			csvFloat(c.Budget.DailySpend), csvFloat(c.Budget.LifetimeSpend), pacingOrDefault(c.Budget.Pacing),
+			c.Advertiser, c.Category}
+		if len(c.Rules) == 0 {
+			if err := cw.Write(append(append(base, "", "", ""), tail...)); err != nil {
+				return err
+			}
+			continue
+		}
+		for _, r := range c.Rules {
+			rec := append(append([]string{}, base...), r.Dimension, strconv.FormatBool(r.IsInclusion),
+				strings.Join(r.Values, CSVValueSep))
+			rec = append(rec, tail...)
+			if err := cw.Write(rec); err != nil {
+				return err
+			}
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// csvInt writes zero as an empty cell.
+func csvInt(n int) string {
+	if n == 0 {
+		return ""
+	}
+	return strconv.Itoa(n)
+}
+
+// csvFloat writes zero as an empty cell.
+func csvFloat(f float64) string {
+	if f == 0 {
+		return ""
+	}
+	return strconv.FormatFloat(f, 'f', -1, 64)
+}
+
+// ImportCampaigns upserts cs and replaces their rule sets in one
+// transaction. Rows are streamed into temp tables with COPY and merged with
+// set-based statements, so the cost is a handful of round trips regardless
+// of size. Campaigns not mentioned in cs are left alone.
+func (s *Store) ImportCampaigns(ctx context.Context, cs []CampaignRow) (ImportResult, error) {
+	var res ImportResult
+	err := s.inTx(ctx, func(ctx context.Context, tx pgx.Tx) error {
+		_, err := tx.Exec(ctx, `
+			CREATE TEMP TABLE import_campaigns (
+				id VARCHAR(50) PRIMARY KEY,
+				name VARCHAR(255) NOT NULL,
+				image_url TEXT,
+				cta TEXT,
+				status TEXT NOT NULL,
+				bid_price NUMERIC(12, 4) NOT NULL,
+				markup TEXT,
+				creative_type TEXT NOT NULL,
+				video_url TEXT,
+				video_mime TEXT,
+				video_duration INT,
+				video_width INT,
+				video_height INT,
+				landing_url TEXT,
+				daily_impression_cap BIGINT,
+				lifetime_impression_cap BIGINT,
+				daily_budget NUMERIC(14, 4),
+				lifetime_budget NUMERIC(14, 4),
+				pacing TEXT NOT NULL,
+				advertiser_id TEXT,
+				category TEXT
+			) ON COMMIT DROP;
+			CREATE TEMP TABLE import_rules (
+				campaign_id VARCHAR(50) NOT NULL,
+				dimension TEXT NOT NULL,
+				is_inclusion BOOLEAN NOT NULL,
+				values TEXT[] NOT NULL
+			) ON COMMIT DROP;
+		`)
+		if err != nil {
+			return fmt.Errorf("create import tables: %w", err)
+		}
+
+		var rules [][]any
+		for _, c := range cs {
+			for _, r := range c.Rules {
+				dim, ok := dimensionColumn[strings.ToLower(r.Dimension)]
+				if !ok {
+					return fmt.Errorf("campaign %s: unknown dimension %q", c.ID, r.Dimension)
+				}
+				rules = append(rules, []any{c.ID, dim, r.IsInclusion, r.Values})
+			}
+		}
+
+		_, err = tx.CopyFrom(ctx, pgx.Identifier{"import_campaigns"},
+			[]string{"id", "name", "image_url", "cta", "status", "bid_price", "markup",
+				"creative_type", "video_url", "video_mime", "video_duration", "video_width", "video_height",
+				"landing_url", "daily_impression_cap", "lifetime_impression_cap", "daily_budget", "lifetime_budget",
+				"pacing", "advertiser_id", "category"},
+			pgx.CopyFromSlice(len(cs), func(i int) ([]any, error) {
+				c := cs[i]
+				return []any{c.ID, c.Name, c.ImageURL, c.CTA, c.Status, c.BidPrice, nullIfZero(c.Markup),
+					creativeOrDefault(c.Creative), nullIfZero(c.Video.URL), nullIfZero(c.Video.MIME),
+					nullIfZero(c.Video.Duration), nullIfZero(c.Video.Width), nullIfZero(c.Video.Height),
+					nullIfZero(c.Landing), nullIfZero(c.Budget.DailyImpressions), nullIfZero(c.Budget.LifetimeImpressions),
+					nullIfZero(c.Budget.DailySpend), nullIfZero(c.Budget.LifetimeSpend), pacingOrDefault(c.Budget.Pacing),
+					nullIfZero(c.Advertiser), nullIfZero(c.Category)}, nil
+			}))
+		if err != nil {
+			return fmt.Errorf("copy campaigns: %w", err)
+		}
+		if _, err = tx.CopyFrom(ctx, pgx.Identifier{"import_rules"},
+			[]string{"campaign_id", "dimension", "is_inclusion", "values"},
+			pgx.CopyFromRows(rules)); err != nil {
+			return fmt.Errorf("copy rules: %w", err)
+		}
+
+		// xmax = 0 only for freshly inserted rows
+		rows, err := tx.Query(ctx, `
+			INSERT INTO campaigns (id, name, image_url, cta, status, bid_price, markup,
+			                       creative_type, video_url, video_mime, video_duration, video_width, video_height,
+			                       landing_url, daily_impression_cap, lifetime_impression_cap, daily_budget,
+			                       lifetime_budget, pacing, advertiser_id, category)
+			SELECT id, name, image_url, cta, status, bid_price, markup,
+			       creative_type, video_url, video_mime, video_duration, video_width, video_height,
+			       landing_url, daily_impression_cap, lifetime_impression_cap, daily_budget,
+			       lifetime_budget, pacing, advertiser_id, category
+			FROM import_campaigns
+			ON CONFLICT (id) DO UPDATE
+			SET name = EXCLUDED.name, image_url = EXCLUDED.image_url,
+			    cta = EXCLUDED.cta, status = EXCLUDED.status,
+			    bid_price = EXCLUDED.bid_price, markup = EXCLUDED.markup,
+			    creative_type = EXCLUDED.creative_type, video_url = EXCLUDED.video_url,
+			    video_mime = EXCLUDED.video_mime, video_duration = EXCLUDED.video_duration,
+			    video_width = EXCLUDED.video_width, video_height = EXCLUDED.video_height,
+			    landing_url = EXCLUDED.landing_url,
+			    daily_impression_cap = EXCLUDED.daily_impression_cap,
+			    lifetime_impression_cap = EXCLUDED.lifetime_impression_cap,
+			    daily_budget = EXCLUDED.daily_budget, lifetime_budget = EXCLUDED.lifetime_budget,
+			    pacing = EXCLUDED.pacing, advertiser_id = EXCLUDED.advertiser_id,
+			    category = EXCLUDED.category
+			RETURNING (xmax = 0)
+		`)
+		if err != nil {
+			return fmt.Errorf("upsert campaigns: %w", err)
+		}
+		// a missing advertiser surfaces once the rows are read
+		for rows.Next() {
+			var inserted bool
+			if err := rows.Scan(&inserted); err != nil {
+				rows.Close()
+				return fmt.Errorf("scan upsert: %w", err)
+			}
+			if inserted {
+				res.Created++
+			} else {
+				res.Updated++
+			}
+		}
+		rows.Close()
+		if isForeignKeyViolation(rows.Err()) {
+			return fmt.Errorf("upsert campaigns: %w", ErrUnknownAdvertiser)
+		}
+		if rows.Err() != nil {
+			return fmt.Errorf("upsert campaigns: %w", rows.Err())
+		}
+
+		if _, err := tx.Exec(ctx, `
+			DELETE FROM targeting_rules WHERE campaign_id IN (SELECT id FROM import_campaigns)
+		`); err != nil {
+			return fmt.Errorf("delete replaced rules: %w", err)
+		}
+		tag, err := tx.Exec(ctx, `
+			INSERT INTO targeting_rules (campaign_id, dimension, is_inclusion, values)
+			SELECT campaign_id, dimension, is_inclusion, values FROM import_rules
+		`)
+		if err != nil {
+			return fmt.Errorf("insert imported rules: %w", err)
+		}
+		res.Rules = int(tag.RowsAffected())
+		return nil
+	})
+	return res, err
+}
+
+// nullIfZero maps the zero value to NULL for COPY.
+func nullIfZero[T comparable](v T) *T {
+	var zero T
+	if v == zero {
+		return nil
+	}
+	return &v
+}
@@ -1,43 +1,35 @@
 package storage
 
-import (
-	"sync"
-	"sync/atomic"
-)
+import "context"
 
+// Cache holds the campaign list of the legacy server as a Snapshot, so
+// readers never block on a refresh.
 type Cache struct {
-	mu        sync.RWMutex
-	campaigns []CampaignRow
+	snap *Snapshot[[]CampaignRow]
 }
 
 func NewCache() *Cache {
-	return &Cache{}
+	return &Cache{snap: NewSnapshot(hashRows)}
 }
 
 func (c *Cache) GetCampaigns() []CampaignRow {
-	c.mu.RLock()
-	defer c.mu.RUnlock()
-	return append([]CampaignRow(nil), c.campaigns...)
+	return append([]CampaignRow(nil), c.snap.Load()...)
 }
 
 func (c *Cache) UpdateCampaigns(campaigns []CampaignRow) {
-	c.mu.Lock()
-	defer c.mu.Unlock()
-	c.campaigns = campaigns
+	c.snap.Store(campaigns)
 }
 
-type Snapshot[T any] struct{ v atomic.Value }
+// Meta describes the cached campaign list.
+func (c *Cache) Meta() SnapshotMeta { return c.snap.Meta() }
 
+// Watch notifies on every refresh; see Snapshot.Watch.
+func (c *Cache) Watch(ctx context.Context) <-chan SnapshotMeta { return c.snap.Watch(ctx) }
 
-func (s *Snapshot[T]) Load() (zero T, _ T) {
-	v := s.v.Load()
-	if v == nil {
-		var z T
-		return z, z
+func hashRows(rows []CampaignRow) uint64 {
+	var h uint64
+	for _, r := range rows {
+		h += CampaignHash(r.ID, r.Version)
 	}
-	return v.(T), v.(T)
-}
-
-func (s *Snapshot[T]) Store(v T) {
-	s.v.Store(v)
+	return h
 }
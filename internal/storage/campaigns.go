@@ -0,0 +1,252 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+var (
+	ErrNotFound        = errors.New("not found")
+	ErrConflict        = errors.New("already exists")
+	ErrVersionConflict = errors.New("version conflict")
+)
+
+// dimensionColumn maps the canonical lower-case dimension to the value stored
+// in targeting_rules.dimension.
+var dimensionColumn = map[string]string{
+	"country": "Country",
+	"os":      "OS",
+	"appid":   "AppID",
+}
+
+// canonicalRule returns r as it is read back: the dimension lower-case and
+// the values in the form requests are matched in, country codes upper-case
+// and app IDs and operating systems lower-case.
+func canonicalRule(r RuleRow) RuleRow {
+	dim := strings.ToLower(r.Dimension)
+	vals := make([]string, len(r.Values))
+	for i, v := range r.Values {
+		if dim == "country" {
+			vals[i] = strings.ToUpper(v)
+		} else {
+			vals[i] = strings.ToLower(v)
+		}
+	}
+	return RuleRow{Dimension: dim, IsInclusion: r.IsInclusion, Values: vals}
+}
+
+// IsDimension reports whether d is a known targeting dimension.
+func IsDimension(d string) bool {
+	_, ok := dimensionColumn[strings.ToLower(d)]
+	return ok
+}
+
+// ListCampaigns loads every campaign, regardless of status, with its rules.
+func (s *Store) ListCampaigns(ctx context.Context) ([]CampaignRow, error) {
+	return s.loadCampaigns(ctx, s.pool, "")
+}
+
+// GetCampaign loads one campaign by id, regardless of status.
+func (s *Store) GetCampaign(ctx context.Context, id string) (CampaignRow, error) {
+	rows, err := s.loadCampaigns(ctx, s.pool, `WHERE c.id = $1`, id)
+	if err != nil {
+		return CampaignRow{}, err
+	}
+	if len(rows) == 0 {
+		return CampaignRow{}, ErrNotFound
+	}
+	return rows[0], nil
+}
+
+// CreateCampaign inserts c and its rules in one transaction. New campaigns
+// start at version 1.
+func (s *Store) CreateCampaign(ctx context.Context, c CampaignRow) error {
+	return s.inTx(ctx, func(ctx context.Context, tx pgx.Tx) error {
+		_, err := tx.Exec(ctx, `
+			INSERT INTO campaigns (id, name, image_url, cta, status, bid_price, markup,
+			                       creative_type, video_url, video_mime, video_duration, video_width, video_height,
+			                       landing_url, daily_impression_cap, lifetime_impression_cap, daily_budget, lifetime_budget,
+			                       pacing, advertiser_id, category)
+			VALUES ($1, $2, $3, $4, $5, $6, NULLIF($7, ''),
+			        $8, NULLIF($9, ''), NULLIF($10, ''), NULLIF($11, 0), NULLIF($12, 0), NULLIF($13, 0),
+			        NULLIF($14, ''), NULLIF($15, 0), NULLIF($16, 0), NULLIF($17, 0), NULLIF($18, 0),
+			        $19, NULLIF($20, ''), NULLIF($21, ''))
+		`, c.ID, c.Name, c.ImageURL, c.CTA, c.Status, c.BidPrice, c.Markup, creativeOrDefault(c.Creative),
+			c.Video.URL, c.Video.MIME, c.Video.Duration, c.Video.Width, c.Video.Height, c.Landing,
+			c.Budget.DailyImpressions, c.Budget.LifetimeImpressions, c.Budget.DailySpend, c.Budget.LifetimeSpend,
+			pacingOrDefault(c.Budget.Pacing), c.Advertiser, c.Category)
+		if err != nil {
+			if isUniqueViolation(err) {
+				return ErrConflict
+			}
+			if isForeignKeyViolation(err) {
+				return fmt.Errorf("campaign %s: advertiser %s: %w", c.ID, c.Advertiser, ErrUnknownAdvertiser)
+			}
+			return fmt.Errorf("insert campaign: %w", err)
+		}
+		return insertRules(ctx, tx, c.ID, c.Rules)
+	})
+}
+
+// UpdateCampaign overwrites the campaign fields of c, provided the stored
+// version still equals c.Version. When replaceRules is set its rules are
+// replaced as well, in the same transaction. It returns the new version.
+func (s *Store) UpdateCampaign(ctx context.Context, c CampaignRow, replaceRules bool) (int64, error) {
+	var version int64
+	err := s.inTx(ctx, func(ctx context.Context, tx pgx.Tx) error {
+		err := tx.QueryRow(ctx, `
+			UPDATE campaigns SET name = $2, image_url = $3, cta = $4, status = $5,
+			       bid_price = $7, markup = NULLIF($8, ''), creative_type = $9,
+			       video_url = NULLIF($10, ''), video_mime = NULLIF($11, ''), video_duration = NULLIF($12, 0),
+			       video_width = NULLIF($13, 0), video_height = NULLIF($14, 0), landing_url = NULLIF($15, ''),
+			       daily_impression_cap = NULLIF($16, 0), lifetime_impression_cap = NULLIF($17, 0),
+			       daily_budget = NULLIF($18, 0), lifetime_budget = NULLIF($19, 0), pacing = $20,
+			       advertiser_id = NULLIF($21, ''), category = NULLIF($22, ''),
+			       version = version + 1
+			WHERE id = $1 AND version = $6
+			RETURNING version
+		`, c.ID, c.Name, c.ImageURL, c.CTA, c.Status, c.Version, c.BidPrice, c.Markup, creativeOrDefault(c.Creative),
+			c.Video.URL, c.Video.MIME, c.Video.Duration, c.Video.Width, c.Video.Height, c.Landing,
+			c.Budget.DailyImpressions, c.Budget.LifetimeImpressions, c.Budget.DailySpend, c.Budget.LifetimeSpend,
+			pacingOrDefault(c.Budget.Pacing), c.Advertiser, c.Category).Scan(&version)
+		if errors.Is(err, pgx.ErrNoRows) {
+			return missingOrConflict(ctx, tx, c.ID)
+		}
+		if isForeignKeyViolation(err) {
+			return fmt.Errorf("campaign %s: advertiser %s: %w", c.ID, c.Advertiser, ErrUnknownAdvertiser)
+		}
+		if err != nil {
+			return fmt.Errorf("update campaign: %w", err)
+		}
+		if !replaceRules {
+			return nil
+		}
+		return replaceRulesTx(ctx, tx, c.ID, c.Rules)
+	})
+	return version, err
+}
+
+// ReplaceRules swaps the full rule set of campaign id for rules, provided the
+// campaign is still at expectedVersion, and returns the bumped version.
+func (s *Store) ReplaceRules(ctx context.Context, id string, rules []RuleRow, expectedVersion int64) (int64, error) {
+	var version int64
+	err := s.inTx(ctx, func(ctx context.Context, tx pgx.Tx) error {
+		var err error
+		if version, err = bumpVersion(ctx, tx, id, expectedVersion); err != nil {
+			return err
+		}
+		return replaceRulesTx(ctx, tx, id, rules)
+	})
+	return version, err
+}
+
+// DeleteCampaign removes a campaign at expectedVersion; its rules go with it
+// via ON DELETE CASCADE.
+func (s *Store) DeleteCampaign(ctx context.Context, id string, expectedVersion int64) error {
+	return s.inTx(ctx, func(ctx context.Context, tx pgx.Tx) error {
+		tag, err := tx.Exec(ctx, `DELETE FROM campaigns WHERE id = $1 AND version = $2`, id, expectedVersion)
+		if err != nil {
+			return fmt.Errorf("delete campaign: %w", err)
+		}
+		if tag.RowsAffected() == 0 {
+			return missingOrConflict(ctx, tx, id)
+		}
+		return nil
+	})
+}
+
+// bumpVersion locks the campaign row and advances its version, so rule-only
+// writes are serialized and versioned like campaign writes.
+func bumpVersion(ctx context.Context, tx pgx.Tx, id string, expected int64) (int64, error) {
+	var version int64
+	err := tx.QueryRow(ctx, `
+		UPDATE campaigns SET version = version + 1
+		WHERE id = $1 AND version = $2
+		RETURNING version
+	`, id, expected).Scan(&version)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return 0, missingOrConflict(ctx, tx, id)
+	}
+	if err != nil {
+		return 0, fmt.Errorf("bump campaign version: %w", err)
+	}
+	return version, nil
+}
+
+// missingOrConflict tells apart the two reasons a versioned write can match
+// no row.
+func missingOrConflict(ctx context.Context, tx pgx.Tx, id string) error {
+	var exists bool
+	if err := tx.QueryRow(ctx, `SELECT EXISTS (SELECT 1 FROM campaigns WHERE id = $1)`, id).Scan(&exists); err != nil {
+		return fmt.Errorf("check campaign: %w", err)
+	}
+	if !exists {
+		return ErrNotFound
+	}
+	return ErrVersionConflict
+}
+
+func replaceRulesTx(ctx context.Context, tx pgx.Tx, id string, rules []RuleRow) error {
+	if _, err := tx.Exec(ctx, `DELETE FROM targeting_rules WHERE campaign_id = $1`, id); err != nil {
+		return fmt.Errorf("delete rules: %w", err)
+	}
+	return insertRules(ctx, tx, id, rules)
+}
+
+func insertRules(ctx context.Context, tx pgx.Tx, id string, rules []RuleRow) error {
+	for _, r := range rules {
+		dim, ok := dimensionColumn[strings.ToLower(r.Dimension)]
+		if !ok {
+			return fmt.Errorf("unknown dimension %q", r.Dimension)
+		}
+		_, err := tx.Exec(ctx, `
+			INSERT INTO targeting_rules (campaign_id, dimension, is_inclusion, values)
+			VALUES ($1, $2, $3, $4)
+		`, id, dim, r.IsInclusion, r.Values)
+		if err != nil {
+			return fmt.Errorf("insert rule: %w", err)
+		}
+	}
+	return nil
+}
+
+func (s *Store) inTx(ctx context.Context, fn func(context.Context, pgx.Tx) error) error {
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+	return pgx.BeginFunc(ctx, s.pool, func(tx pgx.Tx) error {
+		if actor := actorFrom(ctx); actor != "" {
+			// transaction-local, read by the campaign_audit trigger
+			if _, err := tx.Exec(ctx, `SELECT set_config('app.actor', $1, true)`, actor); err != nil {
+				return fmt.Errorf("set audit actor: %w", err)
+			}
+		}
+		return fn(ctx, tx)
+	})
+}
+
+func isUniqueViolation(err error) bool {
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) && pgErr.Code == "23505"
+}
+
+// creativeOrDefault treats an unset creative type as a banner.
+func creativeOrDefault(t string) string {
+	if t == "" {
+		return CreativeBanner
+	}
+	return t
+}
+
+// pacingOrDefault treats unset pacing as PacingASAP.
+func pacingOrDefault(p string) string {
+	if p == "" {
+		return PacingASAP
+	}
+	return p
+}
@@ -0,0 +1,153 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/rs/zerolog/log"
+)
+
+// ChangeEvent is one row of the change_events outbox, written by triggers in
+// the same transaction as the campaign or rule mutation it describes.
+type ChangeEvent struct {
+	Seq        int64
+	EntityType string // "campaign" | "targeting_rule"
+	EntityID   string
+	CampaignID string
+	Op         string // "INSERT" | "UPDATE" | "DELETE"
+	CreatedAt  time.Time
+}
+
+// LatestChangeSeq returns the highest outbox sequence number, or 0 when the
+// outbox is empty. A full resync does not use it: it takes the seq from
+// StreamActiveCampaigns, which reads it in the same transaction as the
+// campaigns.
+func (s *Store) LatestChangeSeq(ctx context.Context) (int64, error) {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	var seq int64
+	if err := s.reader().QueryRow(ctx, `SELECT COALESCE(MAX(seq), 0) FROM change_events`).Scan(&seq); err != nil {
+		return 0, fmt.Errorf("query latest change seq: %w", err)
+	}
+	return seq, nil
+}
+
+// LoadChangeEventsSince returns up to limit events with seq > after, in
+// sequence order.
+func (s *Store) LoadChangeEventsSince(ctx context.Context, after int64, limit int) ([]ChangeEvent, error) {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	rows, err := s.pool.Query(ctx, `
+		SELECT seq, entity_type, entity_id, campaign_id, op, created_at
+		FROM change_events
+		WHERE seq > $1
+		ORDER BY seq
+		LIMIT $2
+	`, after, limit)
+	if err != nil {
+		return nil, fmt.Errorf("query change events: %w", err)
+	}
+	defer rows.Close()
+
+	var out []ChangeEvent
+	for rows.Next() {
+		var ev ChangeEvent
+		if err := rows.Scan(&ev.Seq, &ev.EntityType, &ev.EntityID, &ev.CampaignID, &ev.Op, &ev.CreatedAt); err != nil {
+			return nil, fmt.Errorf("scan change event: %w", err)
+		}
+		out = append(out, ev)
+	}
+	if rows.Err() != nil {
+		return nil, rows.Err()
+	}
+	return out, nil
+}
+
+// PruneChangeEvents deletes processed events (seq <= upTo) older than
+// olderThan and returns the number of rows removed.
+func (s *Store) PruneChangeEvents(ctx context.Context, upTo int64, olderThan time.Time) (int64, error) {
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	tag, err := s.pool.Exec(ctx, `
+		DELETE FROM change_events
+		WHERE seq <= $1 AND created_at < $2
+	`, upTo, olderThan)
+	if err != nil {
+		return 0, fmt.Errorf("prune change events: %w", err)
+	}
+	return tag.RowsAffected(), nil
+}
+
+// Subscribe LISTENs on the change channel over a dedicated connection and
+// turns each NOTIFY into a wake-up. A dropped connection is re-established
+// with jittered backoff, followed by one wake-up so the reader catches up on
+// anything it missed while disconnected.
+func (s *Store) Subscribe(ctx context.Context) (<-chan struct{}, error) {
+	conn, err := s.listen(ctx)
+	if err != nil {
+		return nil, err
+	}
+	log.Info().Str("channel", s.ListenChannel()).Msg("listening for DB changes")
+
+	out := make(chan struct{}, 1)
+	go func() {
+		defer close(out)
+		for {
+			if conn == nil {
+				backoff := jitter(s.backoff)
+				select {
+				case <-ctx.Done():
+					return
+				case <-time.After(backoff):
+				}
+				if conn, err = s.listen(ctx); err != nil {
+					log.Error().Err(err).Dur("retry_in", backoff).Msg("re-listen")
+					continue
+				}
+				wake(out)
+			}
+
+			_, err := conn.Conn().WaitForNotification(ctx)
+			if ctx.Err() != nil {
+				conn.Release()
+				return
+			}
+			if err != nil {
+				log.Error().Err(err).Msg("notify wait error")
+				_ = conn.Conn().Close(context.Background())
+				conn.Release()
+				conn = nil
+				continue
+			}
+			wake(out)
+		}
+	}()
+	return out, nil
+}
+
+func (s *Store) listen(ctx context.Context) (*pgxpool.Conn, error) {
+	conn, err := s.pool.Acquire(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("acquire conn for listen: %w", err)
+	}
+	if _, err := conn.Exec(ctx, "LISTEN "+pgx.Identifier{s.ListenChannel()}.Sanitize()); err != nil {
+		conn.Release()
+		return nil, fmt.Errorf("listen %s: %w", s.ListenChannel(), err)
+	}
+	return conn, nil
+}
+
+func jitter(base time.Duration) time.Duration {
+	if base <= 0 {
+		base = time.Second
+	}
+	factor := 0.5 + rand.Float64() // 0.5x–1.5x
+	return time.Duration(float64(base) * factor)
+}
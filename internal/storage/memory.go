@@ -0,0 +1,483 @@
+package storage
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// MemoryStore is a thread-safe, in-process Repository for tests and local
+// runs. It mirrors the Postgres behaviour the rest of the service relies on:
+// versions bump on every write, each write appends change events and audit
+// entries the way the triggers do, and subscribers get one wake-up per
+// write, like one NOTIFY per transaction.
+type MemoryStore struct {
+	mu          sync.RWMutex
+	now         func() time.Time
+	campaigns   map[string]*memCampaign
+	events      []ChangeEvent
+	audit       []AuditEntry
+	seq         int64
+	auditID     int64
+	nextRuleID  int64
+	subs        map[chan struct{}]struct{}
+	keys        map[string]APIKeyRow
+	stats       map[HourlyStatRow]Counts // keys have zero Counts
+	spend       map[spendKey]Spend
+	publishers  map[string]PublisherRow
+	advertisers map[string]AdvertiserRow
+}
+
+type memCampaign struct {
+	row   CampaignRow // Rules unused; see rules
+	rules []memRule
+}
+
+// memRule is a targeting_rules row; Dimension is stored in column form
+// ("Country") and values as written, like the table.
+type memRule struct {
+	ID          int64
+	CampaignID  string
+	Dimension   string
+	IsInclusion bool
+	Values      []string
+}
+
+// NewMemoryStore returns a store holding cs, which are inserted as regular
+// writes (so they also appear in the change feed and audit log).
+func NewMemoryStore(cs ...CampaignRow) *MemoryStore {
+	m := &MemoryStore{
+		now:         time.Now,
+		campaigns:   map[string]*memCampaign{},
+		subs:        map[chan struct{}]struct{}{},
+		keys:        map[string]APIKeyRow{},
+		stats:       map[HourlyStatRow]Counts{},
+		spend:       map[spendKey]Spend{},
+		publishers:  map[string]PublisherRow{},
+		advertisers: map[string]AdvertiserRow{},
+	}
+	for _, c := range cs {
+		if err := m.CreateCampaign(context.Background(), c); err != nil {
+			panic(fmt.Errorf("seed memory store: %w", err))
+		}
+	}
+	return m
+}
+
+// SetClock replaces the clock used to stamp events and audit entries.
+func (m *MemoryStore) SetClock(now func() time.Time) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.now = now
+}
+
+func (m *MemoryStore) LoadActiveCampaigns(context.Context) ([]CampaignRow, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.collect(func(c *memCampaign) bool { return c.row.Status == "ACTIVE" }), nil
+}
+
+func (m *MemoryStore) StreamActiveCampaigns(_ context.Context, fn func(CampaignRow) error) (int64, error) {
+	m.mu.RLock()
+	seq := m.seq
+	rows := m.collect(func(c *memCampaign) bool { return c.row.Status == "ACTIVE" })
+	m.mu.RUnlock()
+	for _, r := range rows {
+		if err := fn(r); err != nil {
+			return 0, err
+		}
+	}
+	return seq, nil
+}
+
+func (m *MemoryStore) LoadActiveCampaignsByID(_ context.Context, ids []string) ([]CampaignRow, error) {
+	want := map[string]bool{}
+	for _, id := range ids {
+		want[id] = true
+	}
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.collect(func(c *memCampaign) bool { return c.row.Status == "ACTIVE" && want[c.row.ID] }), nil
+}
+
+func (m *MemoryStore) ListCampaigns(context.Context) ([]CampaignRow, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.collect(func(*memCampaign) bool { return true }), nil
+}
+
+func (m *MemoryStore) GetCampaign(_ context.Context, id string) (CampaignRow, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	c, ok := m.campaigns[id]
+	if !ok {
+		return CampaignRow{}, ErrNotFound
+	}
+	return c.toRow(), nil
+}
+
+func (m *MemoryStore) CreateCampaign(ctx context.Context, c CampaignRow) error {
+	if err := checkDimensions(c.Rules); err != nil {
+		return err
+	}
+	return m.write(ctx, func(tx *memTx) error {
+		if _, ok := m.campaigns[c.ID]; ok {
+			return ErrConflict
+		}
+		if err := m.checkAdvertiser(c); err != nil {
+			return err
+		}
+		tx.insertCampaign(c)
+		return nil
+	})
+}
+
+func (m *MemoryStore) UpdateCampaign(ctx context.Context, c CampaignRow, replaceRules bool) (int64, error) {
+	if replaceRules {
+		if err := checkDimensions(c.Rules); err != nil {
+			return 0, err
+		}
+	}
+	var version int64
+	err := m.write(ctx, func(tx *memTx) error {
+		mc, err := m.expect(c.ID, c.Version)
+		if err != nil {
+			return err
+		}
+		if err := m.checkAdvertiser(c); err != nil {
+			return err
+		}
+		version = tx.updateCampaign(mc, c)
+		if replaceRules {
+			tx.replaceRules(mc, c.Rules)
+		}
+		return nil
+	})
+	return version, err
+}
+
+func (m *MemoryStore) ReplaceRules(ctx context.Context, id string, rules []RuleRow, expectedVersion int64) (int64, error) {
+	if err := checkDimensions(rules); err != nil {
+		return 0, err
+	}
+	var version int64
+	err := m.write(ctx, func(tx *memTx) error {
+		mc, err := m.expect(id, expectedVersion)
+		if err != nil {
+			return err
+		}
+		version = tx.updateCampaign(mc, mc.row)
+		tx.replaceRules(mc, rules)
+		return nil
+	})
+	return version, err
+}
+
+func (m *MemoryStore) DeleteCampaign(ctx context.Context, id string, expectedVersion int64) error {
+	return m.write(ctx, func(tx *memTx) error {
+		mc, err := m.expect(id, expectedVersion)
+		if err != nil {
+			return err
+		}
+		tx.deleteCampaign(mc)
+		return nil
+	})
+}
+
+func (m *MemoryStore) ImportCampaigns(ctx context.Context, cs []CampaignRow) (ImportResult, error) {
+	for _, c := range cs {
+		if err := checkDimensions(c.Rules); err != nil {
+			return ImportResult{}, fmt.Errorf("campaign %s: %w", c.ID, err)
+		}
+	}
+	var res ImportResult
+	err := m.write(ctx, func(tx *memTx) error {
+		for _, c := range cs {
+			if err := m.checkAdvertiser(c); err != nil {
+				return err
+			}
+		}
+		for _, c := range cs {
+			if mc, ok := m.campaigns[c.ID]; ok {
+				tx.updateCampaign(mc, c)
+				tx.replaceRules(mc, c.Rules)
+				res.Updated++
+			} else {
+				tx.insertCampaign(c)
+				res.Created++
+			}
+			res.Rules += len(c.Rules)
+		}
+		return nil
+	})
+	return res, err
+}
+
+func (m *MemoryStore) ListAudit(_ context.Context, campaignID string, limit int) ([]AuditEntry, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	var out []AuditEntry
+	for i := len(m.audit) - 1; i >= 0 && len(out) < limit; i-- {
+		if m.audit[i].CampaignID == campaignID {
+			out = append(out, m.audit[i])
+		}
+	}
+	return out, nil
+}
+
+func (m *MemoryStore) LoadCampaignsAsOf(_ context.Context, at time.Time) ([]CampaignRow, error) {
+	type key struct{ typ, id string }
+
+	m.mu.RLock()
+	latest := map[key]int{}
+	var order []key
+	for i, e := range m.audit {
+		if e.ChangedAt.After(at) {
+			continue
+		}
+		k := key{e.EntityType, e.EntityID}
+		if _, ok := latest[k]; !ok {
+			order = append(order, k)
+		}
+		latest[k] = i
+	}
+	states := make([]auditState, 0, len(order))
+	for _, k := range order {
+		states = append(states, auditState{entityType: k.typ, after: m.audit[latest[k]].After})
+	}
+	m.mu.RUnlock()
+
+	return campaignsFromAudit(states)
+}
+
+func (m *MemoryStore) LatestChangeSeq(context.Context) (int64, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.seq, nil
+}
+
+func (m *MemoryStore) LoadChangeEventsSince(_ context.Context, after int64, limit int) ([]ChangeEvent, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	i := sort.Search(len(m.events), func(i int) bool { return m.events[i].Seq > after })
+	end := min(i+limit, len(m.events))
+	return append([]ChangeEvent(nil), m.events[i:end]...), nil
+}
+
+func (m *MemoryStore) PruneChangeEvents(_ context.Context, upTo int64, olderThan time.Time) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	kept := m.events[:0]
+	var n int64
+	for _, e := range m.events {
+		if e.Seq <= upTo && e.CreatedAt.Before(olderThan) {
+			n++
+			continue
+		}
+		kept = append(kept, e)
+	}
+	m.events = kept
+	return n, nil
+}
+
+func (m *MemoryStore) Subscribe(ctx context.Context) (<-chan struct{}, error) {
+	ch := make(chan struct{}, 1)
+	m.mu.Lock()
+	m.subs[ch] = struct{}{}
+	m.mu.Unlock()
+
+	go func() {
+		<-ctx.Done()
+		m.mu.Lock()
+		delete(m.subs, ch)
+		m.mu.Unlock()
+		close(ch)
+	}()
+	return ch, nil
+}
+
+// write runs fn as one "transaction": under the write lock, with a single
+// timestamp, and with a wake-up sent to subscribers afterwards if anything
+// changed. fn must check everything that can fail before mutating.
+func (m *MemoryStore) write(ctx context.Context, fn func(tx *memTx) error) error {
+	m.mu.Lock()
+	tx := &memTx{m: m, now: m.now(), actor: actorFrom(ctx)}
+	if tx.actor == "" {
+		tx.actor = "memory"
+	}
+	err := fn(tx)
+	if err == nil && tx.changed {
+		for ch := range m.subs {
+			wake(ch)
+		}
+	}
+	m.mu.Unlock()
+	return err
+}
+
+// expect returns campaign id if it is at the expected version.
+func (m *MemoryStore) expect(id string, version int64) (*memCampaign, error) {
+	mc, ok := m.campaigns[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	if mc.row.Version != version {
+		return nil, ErrVersionConflict
+	}
+	return mc, nil
+}
+
+// collect returns matching campaigns ordered by id, normalized the same way
+// Store.loadCampaigns returns them.
+func (m *MemoryStore) collect(keep func(*memCampaign) bool) []CampaignRow {
+	var out []CampaignRow
+	for _, c := range m.campaigns {
+		if keep(c) {
+			out = append(out, c.toRow())
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].ID < out[j].ID })
+	return out
+}
+
+func (c *memCampaign) toRow() CampaignRow {
+	row := c.row
+	row.Rules = nil
+	for _, r := range c.rules {
+		row.Rules = append(row.Rules, canonicalRule(RuleRow{Dimension: r.Dimension, IsInclusion: r.IsInclusion, Values: r.Values}))
+	}
+	return row
+}
+
+func checkDimensions(rules []RuleRow) error {
+	for _, r := range rules {
+		if !IsDimension(r.Dimension) {
+			return fmt.Errorf("unknown dimension %q", r.Dimension)
+		}
+	}
+	return nil
+}
+
+// memTx applies mutations and records them the way the change_events and
+// campaign_audit triggers would.
+type memTx struct {
+	m       *MemoryStore
+	now     time.Time
+	actor   string
+	changed bool
+}
+
+func (tx *memTx) insertCampaign(c CampaignRow) {
+	mc := &memCampaign{row: c}
+	mc.row.Rules = nil
+	mc.row.Version = 1
+	mc.row.Creative = creativeOrDefault(c.Creative)
+	mc.row.Budget.Pacing = pacingOrDefault(c.Budget.Pacing)
+	tx.m.campaigns[c.ID] = mc
+	tx.record("campaign", c.ID, c.ID, "INSERT", nil, campaignJSON(mc.row))
+	tx.insertRules(mc, c.Rules)
+}
+
+func (tx *memTx) updateCampaign(mc *memCampaign, c CampaignRow) int64 {
+	before := campaignJSON(mc.row)
+	mc.row.Name, mc.row.ImageURL, mc.row.CTA, mc.row.Status = c.Name, c.ImageURL, c.CTA, c.Status
+	mc.row.BidPrice, mc.row.Markup, mc.row.Landing = c.BidPrice, c.Markup, c.Landing
+	mc.row.Creative, mc.row.Video = creativeOrDefault(c.Creative), c.Video
+	mc.row.Budget = c.Budget
+	mc.row.Budget.Pacing = pacingOrDefault(c.Budget.Pacing)
+	mc.row.Advertiser, mc.row.Category = c.Advertiser, c.Category
+	mc.row.Version++
+	tx.record("campaign", mc.row.ID, mc.row.ID, "UPDATE", before, campaignJSON(mc.row))
+	return mc.row.Version
+}
+
+func (tx *memTx) deleteCampaign(mc *memCampaign) {
+	for _, r := range mc.rules {
+		tx.record("targeting_rule", strconv.FormatInt(r.ID, 10), mc.row.ID, "DELETE", r, nil)
+	}
+	delete(tx.m.campaigns, mc.row.ID)
+	tx.record("campaign", mc.row.ID, mc.row.ID, "DELETE", campaignJSON(mc.row), nil)
+}
+
+func (tx *memTx) replaceRules(mc *memCampaign, rules []RuleRow) {
+	for _, r := range mc.rules {
+		tx.record("targeting_rule", strconv.FormatInt(r.ID, 10), mc.row.ID, "DELETE", r, nil)
+	}
+	mc.rules = nil
+	tx.insertRules(mc, rules)
+}
+
+func (tx *memTx) insertRules(mc *memCampaign, rules []RuleRow) {
+	for _, r := range rules {
+		tx.m.nextRuleID++
+		mr := memRule{
+			ID:          tx.m.nextRuleID,
+			CampaignID:  mc.row.ID,
+			Dimension:   dimensionColumn[strings.ToLower(r.Dimension)],
+			IsInclusion: r.IsInclusion,
+			Values:      append([]string(nil), r.Values...),
+		}
+		mc.rules = append(mc.rules, mr)
+		tx.record("targeting_rule", strconv.FormatInt(mr.ID, 10), mc.row.ID, "INSERT", nil, mr)
+	}
+}
+
+func (tx *memTx) record(entityType, entityID, campaignID, op string, before, after any) {
+	tx.event(entityType, entityID, campaignID, op)
+	tx.m.auditID++
+	tx.m.audit = append(tx.m.audit, AuditEntry{
+		ID:         tx.m.auditID,
+		EntityType: entityType,
+		EntityID:   entityID,
+		CampaignID: campaignID,
+		Op:         op,
+		Actor:      tx.actor,
+		Before:     toJSON(before),
+		After:      toJSON(after),
+		ChangedAt:  tx.now,
+	})
+}
+
+// event appends a change event without an audit entry, like the triggers
+// on tables that are not audited.
+func (tx *memTx) event(entityType, entityID, campaignID, op string) {
+	tx.changed = true
+	tx.m.seq++
+	tx.m.events = append(tx.m.events, ChangeEvent{
+		Seq:        tx.m.seq,
+		EntityType: entityType,
+		EntityID:   entityID,
+		CampaignID: campaignID,
+		Op:         op,
+		CreatedAt:  tx.now,
+	})
+}
+
+// campaignJSON renders a campaign like to_jsonb(campaigns).
+func campaignJSON(c CampaignRow) auditCampaign {
+	image, cta := c.ImageURL, c.CTA
+	ac := auditCampaign{ID: c.ID, Name: c.Name, ImageURL: &image, CTA: &cta, Status: c.Status, Version: c.Version,
+		BidPrice: c.BidPrice, CreativeType: c.Creative, VideoURL: nullIfZero(c.Video.URL), VideoMIME: nullIfZero(c.Video.MIME),
+		VideoDuration: nullIfZero(c.Video.Duration), VideoWidth: nullIfZero(c.Video.Width), VideoHeight: nullIfZero(c.Video.Height),
+		Markup: nullIfZero(c.Markup), Landing: nullIfZero(c.Landing),
+		DailyImpressionCap: nullIfZero(c.Budget.DailyImpressions), LifetimeImpressionCap: nullIfZero(c.Budget.LifetimeImpressions),
+		DailyBudget: nullIfZero(c.Budget.DailySpend), LifetimeBudget: nullIfZero(c.Budget.LifetimeSpend), Pacing: c.Budget.Pacing,
+		AdvertiserID: nullIfZero(c.Advertiser), Category: nullIfZero(c.Category)}
+	return ac
+}
+
+func toJSON(v any) json.RawMessage {
+	if v == nil {
+		return nil
+	}
+	if r, ok := v.(memRule); ok {
+		v = auditRule{ID: r.ID, CampaignID: r.CampaignID, Dimension: r.Dimension, IsInclusion: r.IsInclusion, Values: r.Values}
+	}
+	b, _ := json.Marshal(v)
+	return b
+}
@@ -0,0 +1,197 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"sort"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// PublisherRow is one publisher_settings row: what an app lets serve in it.
+type PublisherRow struct {
+	AppID              string
+	Floor              float64 // minimum bid CPM in USD; 0 means none
+	BlockedAdvertisers []string
+	BlockedCategories  []string // IAB categories; a tier-1 category blocks its subcategories
+	BlockedCampaigns   []string
+	Version            int64
+	UpdatedAt          time.Time
+}
+
+// PublisherStore keeps publisher_settings. Writes record change events, so
+// they reach the delivery snapshot like campaign writes.
+type PublisherStore interface {
+	LoadPublishers(ctx context.Context) ([]PublisherRow, error)
+	GetPublisher(ctx context.Context, appID string) (PublisherRow, error)
+	// PutPublisher creates or replaces the settings of p.AppID and returns
+	// the new version.
+	PutPublisher(ctx context.Context, p PublisherRow) (int64, error)
+	DeletePublisher(ctx context.Context, appID string) error
+}
+
+const publisherColumns = `app_id, floor::float8, blocked_advertisers, blocked_categories, blocked_campaigns, version, updated_at`
+
+// LoadPublishers returns every app's settings. The table is small and is
+// reloaded whole when change events name it, so it is always read from the
+// primary the events came from.
+func (s *Store) LoadPublishers(ctx context.Context) ([]PublisherRow, error) {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	rows, err := s.pool.Query(ctx, `SELECT `+publisherColumns+` FROM publisher_settings ORDER BY app_id`)
+	if err != nil {
+		return nil, fmt.Errorf("query publishers: %w", err)
+	}
+	defer rows.Close()
+
+	var out []PublisherRow
+	for rows.Next() {
+		var p PublisherRow
+		if err := rows.Scan(&p.AppID, &p.Floor, &p.BlockedAdvertisers, &p.BlockedCategories, &p.BlockedCampaigns,
+			&p.Version, &p.UpdatedAt); err != nil {
+			return nil, fmt.Errorf("scan publisher: %w", err)
+		}
+		out = append(out, p)
+	}
+	if rows.Err() != nil {
+		return nil, rows.Err()
+	}
+	return out, nil
+}
+
+func (s *Store) GetPublisher(ctx context.Context, appID string) (PublisherRow, error) {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	var p PublisherRow
+	err := s.pool.QueryRow(ctx, `SELECT `+publisherColumns+` FROM publisher_settings WHERE app_id = $1`, appID).
+		Scan(&p.AppID, &p.Floor, &p.BlockedAdvertisers, &p.BlockedCategories, &p.BlockedCampaigns, &p.Version, &p.UpdatedAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return PublisherRow{}, fmt.Errorf("publisher %s: %w", appID, ErrNotFound)
+	}
+	if err != nil {
+		return PublisherRow{}, fmt.Errorf("query publisher: %w", err)
+	}
+	return p, nil
+}
+
+func (s *Store) PutPublisher(ctx context.Context, p PublisherRow) (int64, error) {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	var version int64
+	err := s.pool.QueryRow(ctx, `
+		INSERT INTO publisher_settings AS p (app_id, floor, blocked_advertisers, blocked_categories, blocked_campaigns)
+		VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (app_id) DO UPDATE SET
+			floor               = EXCLUDED.floor,
+			blocked_advertisers = EXCLUDED.blocked_advertisers,
+			blocked_categories  = EXCLUDED.blocked_categories,
+			blocked_campaigns   = EXCLUDED.blocked_campaigns,
+			version             = p.version + 1,
+			updated_at          = now()
+		RETURNING version
+	`, p.AppID, p.Floor, nonNil(p.BlockedAdvertisers), nonNil(p.BlockedCategories), nonNil(p.BlockedCampaigns)).Scan(&version)
+	if err != nil {
+		return 0, fmt.Errorf("upsert publisher: %w", err)
+	}
+	return version, nil
+}
+
+func (s *Store) DeletePublisher(ctx context.Context, appID string) error {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	tag, err := s.pool.Exec(ctx, `DELETE FROM publisher_settings WHERE app_id = $1`, appID)
+	if err != nil {
+		return fmt.Errorf("delete publisher: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return fmt.Errorf("publisher %s: %w", appID, ErrNotFound)
+	}
+	return nil
+}
+
+// nonNil turns a nil list into an empty one for NOT NULL array columns.
+func nonNil(s []string) []string {
+	if s == nil {
+		return []string{}
+	}
+	return s
+}
+
+func (m *MemoryStore) LoadPublishers(context.Context) ([]PublisherRow, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	out := make([]PublisherRow, 0, len(m.publishers))
+	for _, p := range m.publishers {
+		out = append(out, p.clone())
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].AppID < out[j].AppID })
+	return out, nil
+}
+
+func (m *MemoryStore) GetPublisher(_ context.Context, appID string) (PublisherRow, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	p, ok := m.publishers[appID]
+	if !ok {
+		return PublisherRow{}, fmt.Errorf("publisher %s: %w", appID, ErrNotFound)
+	}
+	return p.clone(), nil
+}
+
+func (m *MemoryStore) PutPublisher(ctx context.Context, p PublisherRow) (int64, error) {
+	var version int64
+	err := m.write(ctx, func(tx *memTx) error {
+		op := "INSERT"
+		p = p.clone()
+		p.Version, p.UpdatedAt = 1, tx.now
+		if old, ok := m.publishers[p.AppID]; ok {
+			op, p.Version = "UPDATE", old.Version+1
+		}
+		m.publishers[p.AppID] = p
+		tx.event("publisher", p.AppID, "", op)
+		version = p.Version
+		return nil
+	})
+	return version, err
+}
+
+func (m *MemoryStore) DeletePublisher(ctx context.Context, appID string) error {
+	return m.write(ctx, func(tx *memTx) error {
+		if _, ok := m.publishers[appID]; !ok {
+			return fmt.Errorf("publisher %s: %w", appID, ErrNotFound)
+		}
+		delete(m.publishers, appID)
+		tx.event("publisher", appID, "", "DELETE")
+		return nil
+	})
+}
+
+func (p PublisherRow) clone() PublisherRow {
+	p.BlockedAdvertisers = nonNil(slices.Clone(p.BlockedAdvertisers))
+	p.BlockedCategories = nonNil(slices.Clone(p.BlockedCategories))
+	p.BlockedCampaigns = nonNil(slices.Clone(p.BlockedCampaigns))
+	return p
+}
+
+func (r *BreakerRepository) LoadPublishers(ctx context.Context) ([]PublisherRow, error) {
+	return guard(r.b, func() ([]PublisherRow, error) { return r.Repository.LoadPublishers(ctx) })
+}
+
+func (r *BreakerRepository) GetPublisher(ctx context.Context, appID string) (PublisherRow, error) {
+	return guard(r.b, func() (PublisherRow, error) { return r.Repository.GetPublisher(ctx, appID) })
+}
+
+func (r *BreakerRepository) PutPublisher(ctx context.Context, p PublisherRow) (int64, error) {
+	return guard(r.b, func() (int64, error) { return r.Repository.PutPublisher(ctx, p) })
+}
+
+func (r *BreakerRepository) DeletePublisher(ctx context.Context, appID string) error {
+	return r.b.Do(func() error { return r.Repository.DeletePublisher(ctx, appID) })
+}
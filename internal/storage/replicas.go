@@ -0,0 +1,135 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"sync/atomic"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/rs/zerolog/log"
+
+	"ad-targeting-engine/internal/observability"
+)
+
+const primaryEndpoint = "primary"
+
+// replica is one read replica and the result of its last health check.
+type replica struct {
+	name    string // host:port, used as the metrics label
+	pool    *pgxpool.Pool
+	healthy atomic.Bool
+}
+
+// reader returns the pool snapshot reads should use: the first healthy
+// replica in configured order, or the primary when none is. The choice is
+// sticky rather than round-robin so that consecutive reads of one rebuild
+// (seq, then campaigns) normally see the same replica.
+func (s *Store) reader() *pgxpool.Pool {
+	for _, r := range s.replicas {
+		if r.healthy.Load() {
+			observability.StorageReads.WithLabelValues(r.name).Inc()
+			return r.pool
+		}
+	}
+	observability.StorageReads.WithLabelValues(primaryEndpoint).Inc()
+	return s.pool
+}
+
+// ReadEndpoint names the endpoint reader would pick right now.
+func (s *Store) ReadEndpoint() string {
+	for _, r := range s.replicas {
+		if r.healthy.Load() {
+			return r.name
+		}
+	}
+	return primaryEndpoint
+}
+
+func newReplica(ctx context.Context, dsn string, maxConns int32) (*replica, error) {
+	poolCfg, err := pgxpool.ParseConfig(dsn)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse replica DSN: %w", err)
+	}
+	poolCfg.MaxConns = maxConns
+	poolCfg.HealthCheckPeriod = time.Minute
+
+	pool, err := pgxpool.NewWithConfig(ctx, poolCfg)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create replica pool: %w", err)
+	}
+	return &replica{name: replicaName(dsn), pool: pool}, nil
+}
+
+// monitorReplicas checks every replica each interval until ctx is done.
+func (s *Store) monitorReplicas(ctx context.Context, every time.Duration) {
+	t := time.NewTicker(every)
+	defer t.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+			s.checkReplicas(ctx)
+		}
+	}
+}
+
+// receiverSilence is how long a WAL receiver may go without a message from
+// the primary before it counts as stuck. An idle primary still sends a
+// keepalive every wal_sender_timeout/2, 30s by default.
+const receiverSilence = time.Minute
+
+// checkReplicas marks a replica healthy when it answers, is in recovery, its
+// WAL receiver is streaming and has heard from the primary recently, and its
+// replay lag is within maxLag. Lag is zero when everything received has
+// been replayed, so an idle primary does not make replicas look stale; the
+// receiver check covers the replica that has replayed everything because
+// it receives nothing. Reading the receiver needs pg_read_all_stats.
+func (s *Store) checkReplicas(ctx context.Context) {
+	for _, r := range s.replicas {
+		cctx, cancel := context.WithTimeout(ctx, 2*time.Second)
+		var (
+			inRecovery, streaming bool
+			lag, silence          float64
+		)
+		err := r.pool.QueryRow(cctx, `
+			SELECT pg_is_in_recovery(),
+			       CASE WHEN pg_last_wal_receive_lsn() = pg_last_wal_replay_lsn() THEN 0
+			            ELSE COALESCE(EXTRACT(EPOCH FROM now() - pg_last_xact_replay_timestamp()), 0)
+			       END,
+			       COALESCE((SELECT status = 'streaming' FROM pg_stat_wal_receiver), false),
+			       COALESCE((SELECT EXTRACT(EPOCH FROM now() - last_msg_receipt_time) FROM pg_stat_wal_receiver), -1)
+		`).Scan(&inRecovery, &lag, &streaming, &silence)
+		cancel()
+
+		receiving := streaming && silence >= 0 && time.Duration(silence*float64(time.Second)) <= receiverSilence
+		healthy := err == nil && inRecovery && receiving && time.Duration(lag*float64(time.Second)) <= s.maxLag
+		if was := r.healthy.Swap(healthy); was != healthy {
+			ev := log.Warn()
+			if healthy {
+				ev = log.Info()
+			}
+			ev.Err(err).Str("replica", r.name).Bool("healthy", healthy).Bool("in_recovery", inRecovery).
+				Bool("streaming", streaming).Float64("receipt_age_seconds", silence).
+				Float64("lag_seconds", lag).Str("read_endpoint", s.ReadEndpoint()).Msg("replica health changed")
+		}
+
+		observability.ReplicaLag.WithLabelValues(r.name).Set(lag)
+		if healthy {
+			observability.ReplicaHealthy.WithLabelValues(r.name).Set(1)
+		} else {
+			observability.ReplicaHealthy.WithLabelValues(r.name).Set(0)
+		}
+	}
+}
+
+// replicaName turns a DSN into a credential-free label.
+func replicaName(dsn string) string {
+	u, err := url.Parse(dsn)
+	if err != nil || u.Host == "" {
+		return "replica"
+	}
+	return u.Host
+}
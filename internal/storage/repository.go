@@ -0,0 +1,78 @@
+package storage
+
+import (
+	"context"
+	"time"
+)
+
+// CampaignReader is the read side of campaign storage.
+type CampaignReader interface {
+	LoadActiveCampaigns(ctx context.Context) ([]CampaignRow, error)
+	// StreamActiveCampaigns calls fn for each active campaign in id order
+	// and stops at the first error fn returns. It returns the highest
+	// change seq read in the same snapshot as the campaigns.
+	StreamActiveCampaigns(ctx context.Context, fn func(CampaignRow) error) (int64, error)
+	LoadActiveCampaignsByID(ctx context.Context, ids []string) ([]CampaignRow, error)
+	ListCampaigns(ctx context.Context) ([]CampaignRow, error)
+	GetCampaign(ctx context.Context, id string) (CampaignRow, error)
+	ListAudit(ctx context.Context, campaignID string, limit int) ([]AuditEntry, error)
+	LoadCampaignsAsOf(ctx context.Context, at time.Time) ([]CampaignRow, error)
+}
+
+// SnapshotSource is what delivery snapshots are built from: active
+// campaigns and the advertiser and publisher settings they are filtered by.
+type SnapshotSource interface {
+	CampaignReader
+	LoadPublishers(ctx context.Context) ([]PublisherRow, error)
+	LoadAdvertisers(ctx context.Context) ([]AdvertiserRow, error)
+}
+
+// CampaignWriter is the write side. Every write is atomic and, like the
+// Postgres triggers, records change events and audit entries.
+type CampaignWriter interface {
+	CreateCampaign(ctx context.Context, c CampaignRow) error
+	UpdateCampaign(ctx context.Context, c CampaignRow, replaceRules bool) (int64, error)
+	ReplaceRules(ctx context.Context, id string, rules []RuleRow, expectedVersion int64) (int64, error)
+	DeleteCampaign(ctx context.Context, id string, expectedVersion int64) error
+	ImportCampaigns(ctx context.Context, cs []CampaignRow) (ImportResult, error)
+}
+
+// ChangeFeed exposes the change_events outbox and wake-ups when it grows.
+type ChangeFeed interface {
+	LatestChangeSeq(ctx context.Context) (int64, error)
+	LoadChangeEventsSince(ctx context.Context, after int64, limit int) ([]ChangeEvent, error)
+	PruneChangeEvents(ctx context.Context, upTo int64, olderThan time.Time) (int64, error)
+
+	// Subscribe returns a channel that receives a value whenever new change
+	// events may be available. Signals are coalesced, so a slow reader sees
+	// one pending wake-up rather than a backlog. The channel is closed when
+	// ctx is done.
+	Subscribe(ctx context.Context) (<-chan struct{}, error)
+}
+
+// Repository is the full campaign store: *Store for Postgres and
+// *MemoryStore for tests and local runs.
+type Repository interface {
+	CampaignReader
+	CampaignWriter
+	ChangeFeed
+	KeyStore
+	StatsStore
+	SpendStore
+	PublisherStore
+	AdvertiserStore
+}
+
+var (
+	_ Repository = (*Store)(nil)
+	_ Repository = (*MemoryStore)(nil)
+)
+
+// wake does a non-blocking send on a 1-buffered channel, coalescing
+// signals the reader has not picked up yet.
+func wake(ch chan struct{}) {
+	select {
+	case ch <- struct{}{}:
+	default:
+	}
+}
@@ -0,0 +1,130 @@
+package storage
+
+import (
+	"context"
+	"encoding/binary"
+	"hash/fnv"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// SnapshotMeta describes one published snapshot value.
+type SnapshotMeta struct {
+	Version uint64    // starts at 1 and increases on every Store
+	BuiltAt time.Time // when the value was stored
+	Hash    uint64    // content hash; equal contents hash equal across builds
+}
+
+type snapshotEntry[T any] struct {
+	value T
+	meta  SnapshotMeta
+}
+
+// Snapshot holds an immutable value that is replaced wholesale. Loads are a
+// single atomic pointer read; stores are serialized, stamp the value with
+// SnapshotMeta and notify watchers. Values must not be mutated once stored.
+type Snapshot[T any] struct {
+	cur  atomic.Pointer[snapshotEntry[T]]
+	hash func(T) uint64
+
+	mu       sync.Mutex
+	watchers map[chan SnapshotMeta]struct{}
+}
+
+// NewSnapshot returns an empty snapshot. hash computes the content hash of
+// stored values; nil leaves SnapshotMeta.Hash at zero.
+func NewSnapshot[T any](hash func(T) uint64) *Snapshot[T] {
+	return &Snapshot[T]{hash: hash}
+}
+
+// Load returns the current value, or the zero value before the first Store.
+func (s *Snapshot[T]) Load() T {
+	v, _ := s.LoadMeta()
+	return v
+}
+
+// LoadMeta returns the current value together with its metadata, read
+// atomically. Meta is zero before the first Store.
+func (s *Snapshot[T]) LoadMeta() (T, SnapshotMeta) {
+	if e := s.cur.Load(); e != nil {
+		return e.value, e.meta
+	}
+	var zero T
+	return zero, SnapshotMeta{}
+}
+
+// Meta returns the metadata of the current value.
+func (s *Snapshot[T]) Meta() SnapshotMeta {
+	_, m := s.LoadMeta()
+	return m
+}
+
+// Store publishes v as the next version and returns its metadata.
+func (s *Snapshot[T]) Store(v T) SnapshotMeta {
+	var h uint64
+	if s.hash != nil {
+		h = s.hash(v)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	meta := SnapshotMeta{Version: s.Meta().Version + 1, BuiltAt: time.Now(), Hash: h}
+	s.publish(v, meta)
+	return meta
+}
+
+// StoreMeta publishes v with metadata produced elsewhere, e.g. by the
+// builder a follower replicates, instead of stamping its own.
+func (s *Snapshot[T]) StoreMeta(v T, meta SnapshotMeta) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.publish(v, meta)
+}
+
+// publish must be called with mu held.
+func (s *Snapshot[T]) publish(v T, meta SnapshotMeta) {
+	s.cur.Store(&snapshotEntry[T]{value: v, meta: meta})
+	for ch := range s.watchers {
+		// keep only the newest meta: drop an unread one, then send
+		select {
+		case <-ch:
+		default:
+		}
+		ch <- meta
+	}
+}
+
+// Watch returns a channel that receives the metadata of every value stored
+// after the call. Notifications are coalesced: a slow watcher sees the most
+// recent version rather than a backlog. The channel is closed when ctx is
+// done.
+func (s *Snapshot[T]) Watch(ctx context.Context) <-chan SnapshotMeta {
+	ch := make(chan SnapshotMeta, 1)
+	s.mu.Lock()
+	if s.watchers == nil {
+		s.watchers = map[chan SnapshotMeta]struct{}{}
+	}
+	s.watchers[ch] = struct{}{}
+	s.mu.Unlock()
+
+	go func() {
+		<-ctx.Done()
+		s.mu.Lock()
+		delete(s.watchers, ch)
+		close(ch)
+		s.mu.Unlock()
+	}()
+	return ch
+}
+
+// CampaignHash hashes one campaign by id and version. Snapshot hash funcs
+// sum it over their campaigns, which makes the result independent of order.
+func CampaignHash(id string, version int64) uint64 {
+	h := fnv.New64a()
+	_, _ = h.Write([]byte(id))
+	var b [8]byte
+	binary.BigEndian.PutUint64(b[:], uint64(version))
+	_, _ = h.Write(b[:])
+	return h.Sum64()
+}
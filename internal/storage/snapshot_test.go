@@ -0,0 +1,55 @@
+package storage
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSnapshot_StoreLoad(t *testing.T) {
+	s := NewSnapshot(hashRows)
+
+	v, meta := s.LoadMeta()
+	assert.Nil(t, v)
+	assert.Zero(t, meta)
+
+	a := []CampaignRow{{ID: "a", Version: 1}, {ID: "b", Version: 3}}
+	m1 := s.Store(a)
+	assert.Equal(t, uint64(1), m1.Version)
+	assert.False(t, m1.BuiltAt.IsZero())
+	assert.Equal(t, a, s.Load())
+
+	// same content in another order hashes the same; a version bump does not
+	m2 := s.Store([]CampaignRow{{ID: "b", Version: 3}, {ID: "a", Version: 1}})
+	assert.Equal(t, uint64(2), m2.Version)
+	assert.Equal(t, m1.Hash, m2.Hash)
+	m3 := s.Store([]CampaignRow{{ID: "a", Version: 2}, {ID: "b", Version: 3}})
+	assert.NotEqual(t, m1.Hash, m3.Hash)
+	assert.Equal(t, m3, s.Meta())
+}
+
+func TestSnapshot_Watch(t *testing.T) {
+	s := NewSnapshot[int](nil)
+	ctx, cancel := context.WithCancel(context.Background())
+	w := s.Watch(ctx)
+
+	s.Store(1)
+	assert.Equal(t, uint64(1), (<-w).Version)
+
+	// an unread notification is replaced by the newest one
+	s.Store(2)
+	s.Store(3)
+	assert.Equal(t, uint64(3), (<-w).Version)
+	select {
+	case m := <-w:
+		t.Fatalf("unexpected notification %+v", m)
+	default:
+	}
+
+	cancel()
+	_, ok := <-w
+	require.False(t, ok, "channel closes when ctx is done")
+	s.Store(4) // must not block or panic without watchers
+}
@@ -0,0 +1,172 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// Pacing modes. ASAP serves until a cap is hit; even spreads the daily caps
+// over the UTC day.
+const (
+	PacingASAP = "asap"
+	PacingEven = "even"
+)
+
+// Budget caps a campaign's delivery. Zero caps are unlimited. Spend is in
+// USD, at the campaign's CPM per impression.
+type Budget struct {
+	DailyImpressions    int64
+	LifetimeImpressions int64
+	DailySpend          float64
+	LifetimeSpend       float64
+	Pacing              string // PacingASAP or PacingEven
+}
+
+// Capped reports whether any cap is set.
+func (b Budget) Capped() bool {
+	return b.DailyImpressions > 0 || b.LifetimeImpressions > 0 || b.DailySpend > 0 || b.LifetimeSpend > 0
+}
+
+// Spend is delivery counted against a budget. Micros are millionths of a
+// USD, so counters stay integers.
+type Spend struct {
+	Impressions int64
+	Micros      int64
+}
+
+func (s Spend) Add(o Spend) Spend {
+	return Spend{Impressions: s.Impressions + o.Impressions, Micros: s.Micros + o.Micros}
+}
+
+func (s Spend) Sub(o Spend) Spend {
+	return Spend{Impressions: s.Impressions - o.Impressions, Micros: s.Micros - o.Micros}
+}
+
+// SpendRow is one source's running totals for a campaign and UTC day in
+// campaign_spend.
+type SpendRow struct {
+	Day        time.Time // midnight UTC
+	CampaignID string
+	Source     string
+	Spend
+}
+
+// SpendTotals is a campaign's delivery over all sources.
+type SpendTotals struct {
+	Day      Spend // on the day asked for
+	Lifetime Spend
+}
+
+// SpendStore keeps campaign_spend.
+type SpendStore interface {
+	// UpsertSpend writes rows, replacing a source's earlier totals for the
+	// same day and campaign unless they were higher.
+	UpsertSpend(ctx context.Context, rows []SpendRow) error
+	// LoadSpend returns the totals of every campaign that has delivered.
+	LoadSpend(ctx context.Context, day time.Time) (map[string]SpendTotals, error)
+}
+
+func (s *Store) UpsertSpend(ctx context.Context, rows []SpendRow) error {
+	if len(rows) == 0 {
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	days := make([]time.Time, len(rows))
+	campaigns, sources := make([]string, len(rows)), make([]string, len(rows))
+	imps, micros := make([]int64, len(rows)), make([]int64, len(rows))
+	for i, r := range rows {
+		days[i], campaigns[i], sources[i], imps[i], micros[i] = r.Day, r.CampaignID, r.Source, r.Impressions, r.Micros
+	}
+	_, err := s.pool.Exec(ctx, `
+		INSERT INTO campaign_spend AS s (day, campaign_id, source, impressions, spend_micros)
+		SELECT (d AT TIME ZONE 'UTC')::date, c, src, i, m
+		FROM unnest($1::timestamptz[], $2::text[], $3::text[], $4::bigint[], $5::bigint[]) AS u(d, c, src, i, m)
+		ON CONFLICT (day, campaign_id, source) DO UPDATE SET
+			impressions  = GREATEST(s.impressions, EXCLUDED.impressions),
+			spend_micros = GREATEST(s.spend_micros, EXCLUDED.spend_micros),
+			updated_at   = now()
+	`, days, campaigns, sources, imps, micros)
+	if err != nil {
+		return fmt.Errorf("upsert spend: %w", err)
+	}
+	return nil
+}
+
+func (s *Store) LoadSpend(ctx context.Context, day time.Time) (map[string]SpendTotals, error) {
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	// primary: totals must include the upsert that was just made
+	rows, err := s.pool.Query(ctx, `
+		SELECT campaign_id,
+		       COALESCE(sum(impressions) FILTER (WHERE day = $1), 0)::bigint,
+		       COALESCE(sum(spend_micros) FILTER (WHERE day = $1), 0)::bigint,
+		       sum(impressions)::bigint, sum(spend_micros)::bigint
+		FROM campaign_spend
+		GROUP BY campaign_id
+	`, day.UTC().Format(time.DateOnly))
+	if err != nil {
+		return nil, fmt.Errorf("query spend: %w", err)
+	}
+	defer rows.Close()
+
+	out := map[string]SpendTotals{}
+	for rows.Next() {
+		var (
+			id string
+			t  SpendTotals
+		)
+		if err := rows.Scan(&id, &t.Day.Impressions, &t.Day.Micros, &t.Lifetime.Impressions, &t.Lifetime.Micros); err != nil {
+			return nil, fmt.Errorf("scan spend: %w", err)
+		}
+		out[id] = t
+	}
+	if rows.Err() != nil {
+		return nil, rows.Err()
+	}
+	return out, nil
+}
+
+type spendKey struct {
+	day        time.Time
+	campaignID string
+	source     string
+}
+
+func (m *MemoryStore) UpsertSpend(_ context.Context, rows []SpendRow) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, r := range rows {
+		k := spendKey{day: r.Day.UTC().Truncate(24 * time.Hour), campaignID: r.CampaignID, source: r.Source}
+		old := m.spend[k]
+		m.spend[k] = Spend{Impressions: max(old.Impressions, r.Impressions), Micros: max(old.Micros, r.Micros)}
+	}
+	return nil
+}
+
+func (m *MemoryStore) LoadSpend(_ context.Context, day time.Time) (map[string]SpendTotals, error) {
+	day = day.UTC().Truncate(24 * time.Hour)
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	out := map[string]SpendTotals{}
+	for k, s := range m.spend {
+		t := out[k.campaignID]
+		t.Lifetime = t.Lifetime.Add(s)
+		if k.day.Equal(day) {
+			t.Day = t.Day.Add(s)
+		}
+		out[k.campaignID] = t
+	}
+	return out, nil
+}
+
+func (r *BreakerRepository) UpsertSpend(ctx context.Context, rows []SpendRow) error {
+	return r.b.Do(func() error { return r.Repository.UpsertSpend(ctx, rows) })
+}
+
+func (r *BreakerRepository) LoadSpend(ctx context.Context, day time.Time) (map[string]SpendTotals, error) {
+	return guard(r.b, func() (map[string]SpendTotals, error) { return r.Repository.LoadSpend(ctx, day) })
+}
@@ -0,0 +1,277 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+)
+
+// HourlyStatRow is one source's running totals for an hour, campaign and
+// request dimensions in campaign_stats_hourly.
+type HourlyStatRow struct {
+	Hour       time.Time // truncated to the hour, UTC
+	CampaignID string
+	Country    string
+	OS         string
+	App        string
+	Source     string // the node that counted
+	Counts
+}
+
+type Counts struct {
+	Matches     int64
+	Impressions int64
+	Clicks      int64
+}
+
+func (c *Counts) Add(o Counts) {
+	c.Matches += o.Matches
+	c.Impressions += o.Impressions
+	c.Clicks += o.Clicks
+}
+
+// Dimensions reports can be grouped by.
+const (
+	StatsByHour     = "hour"
+	StatsByDay      = "day"
+	StatsByCampaign = "campaign"
+	StatsByCountry  = "country"
+	StatsByOS       = "os"
+	StatsByApp      = "app"
+)
+
+// StatsGroups lists the valid StatsQuery.GroupBy values.
+var StatsGroups = []string{StatsByHour, StatsByDay, StatsByCampaign, StatsByCountry, StatsByOS, StatsByApp}
+
+// StatsQuery selects hours in [From, To) and sums them by GroupBy. Empty
+// filters match everything.
+type StatsQuery struct {
+	From, To    time.Time
+	CampaignIDs []string
+	Country     string
+	OS          string
+	App         string
+	GroupBy     []string // of StatsGroups; hour and day are exclusive
+}
+
+// StatsRow is one group of a report. Fields not grouped by are zero; Hour
+// holds the day when grouping by day.
+type StatsRow struct {
+	Hour       time.Time
+	CampaignID string
+	Country    string
+	OS         string
+	App        string
+	Counts
+}
+
+// StatsStore keeps campaign_stats_hourly.
+type StatsStore interface {
+	// UpsertHourlyStats writes rows, replacing a source's earlier totals for
+	// the same key unless they were higher. Re-sending rows is harmless.
+	UpsertHourlyStats(ctx context.Context, rows []HourlyStatRow) error
+	QueryStats(ctx context.Context, q StatsQuery) ([]StatsRow, error)
+}
+
+func (s *Store) UpsertHourlyStats(ctx context.Context, rows []HourlyStatRow) error {
+	if len(rows) == 0 {
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	hours := make([]time.Time, len(rows))
+	campaigns, countries, oses, apps, sources := make([]string, len(rows)), make([]string, len(rows)),
+		make([]string, len(rows)), make([]string, len(rows)), make([]string, len(rows))
+	matches, imps, clicks := make([]int64, len(rows)), make([]int64, len(rows)), make([]int64, len(rows))
+	for i, r := range rows {
+		hours[i], campaigns[i], countries[i], oses[i], apps[i], sources[i] =
+			r.Hour, r.CampaignID, r.Country, r.OS, r.App, r.Source
+		matches[i], imps[i], clicks[i] = r.Matches, r.Impressions, r.Clicks
+	}
+	// GREATEST keeps a late, stale flush from lowering totals
+	_, err := s.pool.Exec(ctx, `
+		INSERT INTO campaign_stats_hourly AS s
+			(hour, campaign_id, country, os, app, source, matches, impressions, clicks)
+		SELECT * FROM unnest($1::timestamptz[], $2::text[], $3::text[], $4::text[], $5::text[], $6::text[],
+			$7::bigint[], $8::bigint[], $9::bigint[])
+		ON CONFLICT (hour, campaign_id, country, os, app, source) DO UPDATE SET
+			matches     = GREATEST(s.matches, EXCLUDED.matches),
+			impressions = GREATEST(s.impressions, EXCLUDED.impressions),
+			clicks      = GREATEST(s.clicks, EXCLUDED.clicks),
+			updated_at  = now()
+	`, hours, campaigns, countries, oses, apps, sources, matches, imps, clicks)
+	if err != nil {
+		return fmt.Errorf("upsert hourly stats: %w", err)
+	}
+	return nil
+}
+
+// statsColumns are the SQL expressions of the StatsGroups.
+var statsColumns = map[string]string{
+	StatsByHour:     "hour",
+	StatsByDay:      "date_trunc('day', hour AT TIME ZONE 'UTC') AT TIME ZONE 'UTC'",
+	StatsByCampaign: "campaign_id",
+	StatsByCountry:  "country",
+	StatsByOS:       "os",
+	StatsByApp:      "app",
+}
+
+func (s *Store) QueryStats(ctx context.Context, q StatsQuery) ([]StatsRow, error) {
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	args := []any{q.From, q.To}
+	where := []string{"hour >= $1", "hour < $2"}
+	if len(q.CampaignIDs) > 0 {
+		args = append(args, q.CampaignIDs)
+		where = append(where, fmt.Sprintf("campaign_id = ANY($%d)", len(args)))
+	}
+	for col, v := range map[string]string{"country": q.Country, "os": q.OS, "app": q.App} {
+		if v != "" {
+			args = append(args, v)
+			where = append(where, fmt.Sprintf("%s = $%d", col, len(args)))
+		}
+	}
+	var sel, groups []string
+	for i, g := range q.GroupBy {
+		col, ok := statsColumns[g]
+		if !ok {
+			return nil, fmt.Errorf("unknown stats group %q", g)
+		}
+		sel = append(sel, col)
+		groups = append(groups, fmt.Sprint(i+1))
+	}
+	sql := "SELECT " + strings.Join(append(sel, "sum(matches)::bigint", "sum(impressions)::bigint", "sum(clicks)::bigint"), ", ") +
+		" FROM campaign_stats_hourly WHERE " + strings.Join(where, " AND ")
+	if len(groups) > 0 {
+		sql += " GROUP BY " + strings.Join(groups, ", ") + " ORDER BY " + strings.Join(groups, ", ")
+	} else {
+		sql += " HAVING count(*) > 0"
+	}
+
+	rows, err := s.reader().Query(ctx, sql, args...)
+	if err != nil {
+		return nil, fmt.Errorf("query stats: %w", err)
+	}
+	defer rows.Close()
+
+	var out []StatsRow
+	for rows.Next() {
+		var r StatsRow
+		dest := make([]any, 0, len(q.GroupBy)+3)
+		for _, g := range q.GroupBy {
+			dest = append(dest, r.field(g))
+		}
+		dest = append(dest, &r.Matches, &r.Impressions, &r.Clicks)
+		if err := rows.Scan(dest...); err != nil {
+			return nil, fmt.Errorf("scan stats: %w", err)
+		}
+		r.Hour = r.Hour.UTC()
+		out = append(out, r)
+	}
+	if rows.Err() != nil {
+		return nil, rows.Err()
+	}
+	return out, nil
+}
+
+// field points at the StatsRow field that holds group g.
+func (r *StatsRow) field(g string) any {
+	switch g {
+	case StatsByHour, StatsByDay:
+		return &r.Hour
+	case StatsByCampaign:
+		return &r.CampaignID
+	case StatsByCountry:
+		return &r.Country
+	case StatsByOS:
+		return &r.OS
+	default:
+		return &r.App
+	}
+}
+
+func (m *MemoryStore) UpsertHourlyStats(_ context.Context, rows []HourlyStatRow) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, r := range rows {
+		r.Hour = r.Hour.UTC()
+		k := r
+		k.Counts = Counts{}
+		old := m.stats[k]
+		m.stats[k] = Counts{
+			Matches:     max(old.Matches, r.Matches),
+			Impressions: max(old.Impressions, r.Impressions),
+			Clicks:      max(old.Clicks, r.Clicks),
+		}
+	}
+	return nil
+}
+
+func (m *MemoryStore) QueryStats(_ context.Context, q StatsQuery) ([]StatsRow, error) {
+	for _, g := range q.GroupBy {
+		if !slices.Contains(StatsGroups, g) {
+			return nil, fmt.Errorf("unknown stats group %q", g)
+		}
+	}
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	groups := map[StatsRow]*Counts{}
+	for k, c := range m.stats {
+		switch {
+		case k.Hour.Before(q.From) || !k.Hour.Before(q.To),
+			len(q.CampaignIDs) > 0 && !slices.Contains(q.CampaignIDs, k.CampaignID),
+			q.Country != "" && k.Country != q.Country,
+			q.OS != "" && k.OS != q.OS,
+			q.App != "" && k.App != q.App:
+			continue
+		}
+		var g StatsRow
+		for _, by := range q.GroupBy {
+			switch by {
+			case StatsByHour:
+				g.Hour = k.Hour
+			case StatsByDay:
+				g.Hour = k.Hour.Truncate(24 * time.Hour)
+			case StatsByCampaign:
+				g.CampaignID = k.CampaignID
+			case StatsByCountry:
+				g.Country = k.Country
+			case StatsByOS:
+				g.OS = k.OS
+			case StatsByApp:
+				g.App = k.App
+			}
+		}
+		if groups[g] == nil {
+			groups[g] = &Counts{}
+		}
+		groups[g].Add(c)
+	}
+	out := make([]StatsRow, 0, len(groups))
+	for g, c := range groups {
+		g.Counts = *c
+		out = append(out, g)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		a, b := out[i], out[j]
+		if !a.Hour.Equal(b.Hour) {
+			return a.Hour.Before(b.Hour)
+		}
+		return strings.Join([]string{a.CampaignID, a.Country, a.OS, a.App}, "\x00") <
+			strings.Join([]string{b.CampaignID, b.Country, b.OS, b.App}, "\x00")
+	})
+	return out, nil
+}
+
+func (r *BreakerRepository) UpsertHourlyStats(ctx context.Context, rows []HourlyStatRow) error {
+	return r.b.Do(func() error { return r.Repository.UpsertHourlyStats(ctx, rows) })
+}
+
+func (r *BreakerRepository) QueryStats(ctx context.Context, q StatsQuery) ([]StatsRow, error) {
+	return guard(r.b, func() ([]StatsRow, error) { return r.Repository.QueryStats(ctx, q) })
+}
@@ -0,0 +1,123 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+const defaultLoadPageSize = 1000
+
+// StreamActiveCampaigns calls fn for every active campaign, in id order.
+// Campaigns are read in keyset pages, each followed by the rules of just
+// that page, so memory held here is bounded by one page no matter how many
+// campaigns exist. All pages are read in one REPEATABLE READ transaction
+// and therefore see a single consistent database snapshot. The highest
+// change seq is the first read of that transaction, so the seq returned is
+// exactly the one the campaigns are as of, whichever endpoint served them.
+func (s *Store) StreamActiveCampaigns(ctx context.Context, fn func(CampaignRow) error) (int64, error) {
+	tx, err := s.reader().BeginTx(ctx, pgx.TxOptions{IsoLevel: pgx.RepeatableRead, AccessMode: pgx.ReadOnly})
+	if err != nil {
+		return 0, fmt.Errorf("begin stream: %w", err)
+	}
+	defer func() { _ = tx.Rollback(context.Background()) }()
+
+	var seq int64
+	if err := tx.QueryRow(ctx, `SELECT COALESCE(MAX(seq), 0) FROM change_events`).Scan(&seq); err != nil {
+		return 0, fmt.Errorf("query stream change seq: %w", err)
+	}
+
+	size := s.pageSize
+	if size <= 0 {
+		size = defaultLoadPageSize
+	}
+	after := ""
+	for {
+		page, err := loadCampaignPage(ctx, tx, after, size)
+		if err != nil {
+			return 0, err
+		}
+		for _, c := range page {
+			if err := fn(c); err != nil {
+				return 0, err
+			}
+		}
+		if len(page) < size {
+			return seq, nil
+		}
+		after = page[len(page)-1].ID
+	}
+}
+
+// loadCampaignPage reads up to size active campaigns with id > after, plus
+// their rules.
+func loadCampaignPage(ctx context.Context, tx pgx.Tx, after string, size int) ([]CampaignRow, error) {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	rows, err := tx.Query(ctx, `
+		SELECT id, name, COALESCE(image_url, ''), COALESCE(cta, ''), status, version,
+		       bid_price::float8, COALESCE(markup, ''), COALESCE(landing_url, ''), `+videoColumns("")+`,
+		       `+budgetColumns("")+`, COALESCE(advertiser_id, ''), COALESCE(category, '')
+		FROM campaigns
+		WHERE status = 'ACTIVE' AND id > $1
+		ORDER BY id
+		LIMIT $2
+	`, after, size)
+	if err != nil {
+		return nil, fmt.Errorf("query campaign page: %w", err)
+	}
+	page := make([]CampaignRow, 0, size)
+	for rows.Next() {
+		var c CampaignRow
+		if err := rows.Scan(&c.ID, &c.Name, &c.ImageURL, &c.CTA, &c.Status, &c.Version, &c.BidPrice, &c.Markup, &c.Landing,
+			&c.Creative, &c.Video.URL, &c.Video.MIME, &c.Video.Duration, &c.Video.Width, &c.Video.Height,
+			&c.Budget.DailyImpressions, &c.Budget.LifetimeImpressions, &c.Budget.DailySpend, &c.Budget.LifetimeSpend,
+			&c.Budget.Pacing, &c.Advertiser, &c.Category); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("scan campaign: %w", err)
+		}
+		page = append(page, c)
+	}
+	rows.Close()
+	if rows.Err() != nil {
+		return nil, rows.Err()
+	}
+	if len(page) == 0 {
+		return nil, nil
+	}
+
+	ids := make([]string, len(page))
+	pos := make(map[string]int, len(page))
+	for i, c := range page {
+		ids[i] = c.ID
+		pos[c.ID] = i
+	}
+	rows, err = tx.Query(ctx, `
+		SELECT campaign_id, dimension, is_inclusion, values
+		FROM targeting_rules
+		WHERE campaign_id = ANY($1)
+		ORDER BY campaign_id, id
+	`, ids)
+	if err != nil {
+		return nil, fmt.Errorf("query rules: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var (
+			id string
+			r  RuleRow
+		)
+		if err := rows.Scan(&id, &r.Dimension, &r.IsInclusion, &r.Values); err != nil {
+			return nil, fmt.Errorf("scan rule: %w", err)
+		}
+		i := pos[id]
+		page[i].Rules = append(page[i].Rules, canonicalRule(r))
+	}
+	if rows.Err() != nil {
+		return nil, rows.Err()
+	}
+	return page, nil
+}
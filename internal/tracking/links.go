@@ -0,0 +1,82 @@
+// Package tracking builds the links ad renderers call back when a creative
+// is shown, played or clicked, and verifies them when they come back. Links
+// are signed with HMAC-SHA256 and expire, so they cannot be forged or
+// replayed indefinitely.
+package tracking
+
+import (
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// Paths of the tracking endpoints, relative to the tracking base URL.
+const (
+	ImpressionPath = "/t/imp"
+	ClickPath      = "/t/click"
+	EventPath      = "/t/event"
+)
+
+// Link kinds; each is bound into the signature so a link for one endpoint
+// is rejected by the others.
+const (
+	KindImpression = "imp"
+	KindClick      = "click"
+	KindEvent      = "event"
+)
+
+// VideoEvents are the VAST linear progress events we track.
+var VideoEvents = []string{"start", "firstQuartile", "midpoint", "thirdQuartile", "complete"}
+
+// Query parameters of a tracking link.
+const (
+	paramRequest   = "rid"
+	paramCampaign  = "cid"
+	paramCreative  = "cr"
+	paramEvent     = "e"
+	paramApp       = "app"
+	paramOS        = "os"
+	paramCountry   = "geo"
+	paramPrice     = "p"
+	paramTimestamp = "ts"
+	paramKey       = "kid"
+	paramSignature = "sig"
+)
+
+// Links builds the signed tracking URLs of one campaign served for one
+// request. All links carry the same timestamp.
+type Links struct {
+	signer *Signer
+	base   string
+	claims Claims
+}
+
+func (l Links) Impression() string { return l.build(ImpressionPath, KindImpression, "") }
+
+func (l Links) Click() string { return l.build(ClickPath, KindClick, "") }
+
+// Event is the link for a named playback event, such as "midpoint".
+func (l Links) Event(name string) string { return l.build(EventPath, KindEvent, name) }
+
+func (l Links) build(path, kind, event string) string {
+	c := l.claims
+	c.Kind, c.Event = kind, event
+	q := url.Values{}
+	q.Set(paramRequest, c.RequestID)
+	q.Set(paramCampaign, c.CampaignID)
+	q.Set(paramCreative, c.Creative)
+	for k, v := range map[string]string{paramApp: c.App, paramOS: c.OS, paramCountry: c.Country,
+		paramPrice: formatPrice(c.Price)} {
+		if v != "" {
+			q.Set(k, v)
+		}
+	}
+	if event != "" {
+		q.Set(paramEvent, event)
+	}
+	q.Set(paramTimestamp, strconv.FormatInt(c.Issued.Unix(), 10))
+	key := l.signer.keys[0]
+	q.Set(paramKey, key.ID)
+	q.Set(paramSignature, sign(key.Secret, c))
+	return strings.TrimRight(l.base, "/") + path + "?" + q.Encode()
+}
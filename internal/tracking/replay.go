@@ -0,0 +1,54 @@
+package tracking
+
+import (
+	"crypto/sha256"
+	"sync"
+	"time"
+)
+
+// ReplayGuard remembers the links it has accepted for as long as they are
+// valid, so each one is counted once. It keeps two generations of at least
+// ttl each and drops the older one wholesale, which bounds memory to the
+// links seen within two TTLs without per-entry expiry. It is per process:
+// a link replayed against another node is not detected.
+type ReplayGuard struct {
+	ttl time.Duration
+	now func() time.Time
+
+	mu        sync.Mutex
+	cur, prev map[[16]byte]struct{}
+	started   time.Time // of cur
+}
+
+func NewReplayGuard(ttl time.Duration) *ReplayGuard {
+	g := &ReplayGuard{ttl: ttl, now: time.Now}
+	g.cur, g.prev = map[[16]byte]struct{}{}, map[[16]byte]struct{}{}
+	g.started = g.now()
+	return g
+}
+
+// First reports whether the link with signature sig is seen for the first
+// time, and remembers it.
+func (g *ReplayGuard) First(sig string) bool {
+	sum := sha256.Sum256([]byte(sig))
+	var id [16]byte
+	copy(id[:], sum[:])
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if now := g.now(); now.Sub(g.started) >= g.ttl {
+		g.prev, g.cur = g.cur, map[[16]byte]struct{}{}
+		if now.Sub(g.started) >= 2*g.ttl {
+			g.prev = map[[16]byte]struct{}{} // idle for a while: everything in cur has expired too
+		}
+		g.started = now
+	}
+	if _, ok := g.cur[id]; ok {
+		return false
+	}
+	if _, ok := g.prev[id]; ok {
+		return false
+	}
+	g.cur[id] = struct{}{}
+	return true
+}
@@ -0,0 +1,174 @@
+package tracking
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// Errors returned by Verify. ErrExpired comes with valid claims: the link is
+// genuine but too old.
+var (
+	ErrMalformed    = errors.New("malformed tracking link")
+	ErrBadSignature = errors.New("bad tracking link signature")
+	ErrExpired      = errors.New("tracking link expired")
+)
+
+// clockSkew is how far in the future a link's timestamp may be, to allow
+// for clocks that differ between the node that signed it and the one
+// verifying it.
+const clockSkew = time.Minute
+
+// Key is one HMAC key. Keys are identified in links by ID so that they can
+// be rotated without breaking links already handed out.
+type Key struct {
+	ID     string
+	Secret string
+}
+
+// Claims are the signed contents of a link.
+type Claims struct {
+	Kind       string // KindImpression, KindClick or KindEvent
+	RequestID  string
+	CampaignID string
+	Creative   string
+	Event      string  // playback event of KindEvent links
+	Price      float64 // CPM in USD the campaign was served at
+	Issued     time.Time
+
+	// The request the campaign was served for, as validated by delivery.
+	App     string
+	OS      string
+	Country string
+
+	// Signature is the link's signature, set by Verify; it identifies the
+	// link for ReplayGuard.
+	Signature string
+}
+
+// Signer signs links with its first key and accepts any of its keys, so a
+// key is rotated by adding the new one in front and removing the old one
+// once the links it signed have expired.
+type Signer struct {
+	keys []Key
+	byID map[string]Key
+	ttl  time.Duration
+	now  func() time.Time
+}
+
+// NewSigner returns a signer for keys, whose links expire after ttl.
+func NewSigner(keys []Key, ttl time.Duration) (*Signer, error) {
+	if len(keys) == 0 {
+		return nil, errors.New("tracking: at least one signing key is required")
+	}
+	s := &Signer{keys: keys, byID: map[string]Key{}, ttl: ttl, now: time.Now}
+	for _, k := range keys {
+		if k.ID == "" || k.Secret == "" {
+			return nil, errors.New("tracking: keys need an id and a secret")
+		}
+		if _, dup := s.byID[k.ID]; dup {
+			return nil, fmt.Errorf("tracking: duplicate key id %q", k.ID)
+		}
+		s.byID[k.ID] = k
+	}
+	return s, nil
+}
+
+// EphemeralKey returns a random key for nodes without configured keys.
+// Links it signs cannot be verified by other nodes or after a restart.
+func EphemeralKey() (Key, error) {
+	var b [32]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		return Key{}, fmt.Errorf("generate tracking key: %w", err)
+	}
+	return Key{ID: "ephemeral", Secret: hex.EncodeToString(b[:])}, nil
+}
+
+// TTL is how long links stay valid.
+func (s *Signer) TTL() time.Duration { return s.ttl }
+
+// Links returns the links of one campaign served for one request, which c
+// describes; Kind, Event and Issued are filled in. base is the scheme and
+// host of the tracking endpoints.
+func (s *Signer) Links(base string, c Claims) Links {
+	c.Issued = s.now().Truncate(time.Second)
+	return Links{signer: s, base: base, claims: c}
+}
+
+// Verify checks the query of a link of the given kind and returns its
+// claims. On ErrExpired the claims are valid but the link is too old.
+func (s *Signer) Verify(kind string, q url.Values) (Claims, error) {
+	ts, err := strconv.ParseInt(q.Get(paramTimestamp), 10, 64)
+	if err != nil || q.Get(paramRequest) == "" || q.Get(paramCampaign) == "" || q.Get(paramSignature) == "" {
+		return Claims{}, ErrMalformed
+	}
+	if kind == KindEvent && q.Get(paramEvent) == "" {
+		return Claims{}, ErrMalformed
+	}
+	var price float64
+	if p := q.Get(paramPrice); p != "" {
+		if price, err = strconv.ParseFloat(p, 64); err != nil || price < 0 {
+			return Claims{}, ErrMalformed
+		}
+	}
+	c := Claims{
+		Kind:       kind,
+		RequestID:  q.Get(paramRequest),
+		CampaignID: q.Get(paramCampaign),
+		Creative:   q.Get(paramCreative),
+		Event:      q.Get(paramEvent),
+		Price:      price,
+		Issued:     time.Unix(ts, 0),
+		App:        q.Get(paramApp),
+		OS:         q.Get(paramOS),
+		Country:    q.Get(paramCountry),
+	}
+	key, ok := s.byID[q.Get(paramKey)]
+	if !ok {
+		return Claims{}, ErrBadSignature
+	}
+	if !hmac.Equal([]byte(sign(key.Secret, c)), []byte(q.Get(paramSignature))) {
+		return Claims{}, ErrBadSignature
+	}
+	c.Signature = q.Get(paramSignature)
+	now := s.now()
+	if c.Issued.After(now.Add(clockSkew)) {
+		return Claims{}, ErrBadSignature
+	}
+	if now.Sub(c.Issued) > s.ttl {
+		return c, ErrExpired
+	}
+	return c, nil
+}
+
+// sign MACs the claims, length-prefixing every field so that no two
+// different claims encode the same.
+func sign(secret string, c Claims) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	for _, f := range []string{c.Kind, c.RequestID, c.CampaignID, c.Creative, c.Event, c.App, c.OS, c.Country,
+		formatPrice(c.Price)} {
+		var n [binary.MaxVarintLen64]byte
+		mac.Write(n[:binary.PutUvarint(n[:], uint64(len(f)))])
+		mac.Write([]byte(f))
+	}
+	var ts [8]byte
+	binary.BigEndian.PutUint64(ts[:], uint64(c.Issued.Unix()))
+	mac.Write(ts[:])
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// formatPrice is the canonical form of a price in links; zero is empty.
+func formatPrice(p float64) string {
+	if p == 0 {
+		return ""
+	}
+	return strconv.FormatFloat(p, 'f', -1, 64)
+}
@@ -0,0 +1,106 @@
+package tracking
+
+import (
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func query(t *testing.T, link string) url.Values {
+	t.Helper()
+	u, err := url.Parse(link)
+	require.NoError(t, err)
+	return u.Query()
+}
+
+func TestNewSigner(t *testing.T) {
+	_, err := NewSigner(nil, time.Hour)
+	assert.Error(t, err)
+	_, err = NewSigner([]Key{{ID: "a"}}, time.Hour)
+	assert.Error(t, err, "empty secret")
+	_, err = NewSigner([]Key{{ID: "a", Secret: "x"}, {ID: "a", Secret: "y"}}, time.Hour)
+	assert.Error(t, err, "duplicate id")
+}
+
+func TestSigner_Verify(t *testing.T) {
+	now := time.Unix(1_000_000, 0)
+	s, err := NewSigner([]Key{{ID: "new", Secret: "s2"}, {ID: "old", Secret: "s1"}}, time.Hour)
+	require.NoError(t, err)
+	s.now = func() time.Time { return now }
+	l := s.Links("https://t.example/", Claims{RequestID: "req1", CampaignID: "c1", Creative: "video",
+		Price: 2.5, App: "com.a", OS: "ios", Country: "US"})
+
+	q := query(t, l.Event("midpoint"))
+	assert.Equal(t, "new", q.Get(paramKey), "the first key signs")
+	c, err := s.Verify(KindEvent, q)
+	require.NoError(t, err)
+	assert.Equal(t, Claims{Kind: KindEvent, RequestID: "req1", CampaignID: "c1", Creative: "video", Event: "midpoint",
+		Price: 2.5, Issued: now, App: "com.a", OS: "ios", Country: "US", Signature: q.Get(paramSignature)}, c)
+
+	_, err = s.Verify(KindImpression, query(t, l.Impression()))
+	assert.NoError(t, err)
+	_, err = s.Verify(KindImpression, query(t, l.Click()))
+	assert.ErrorIs(t, err, ErrBadSignature, "kinds are not interchangeable")
+
+	old, err := NewSigner([]Key{{ID: "old", Secret: "s1"}}, time.Hour)
+	require.NoError(t, err)
+	old.now = s.now
+	_, err = s.Verify(KindClick, query(t, old.Links("https://t", Claims{RequestID: "r", CampaignID: "c"}).Click()))
+	assert.NoError(t, err, "links of the previous key verify during rotation")
+
+	tests := []struct {
+		name   string
+		modify func(q url.Values)
+		want   error
+	}{
+		{"tampered campaign", func(q url.Values) { q.Set(paramCampaign, "c2") }, ErrBadSignature},
+		{"tampered country", func(q url.Values) { q.Set(paramCountry, "DE") }, ErrBadSignature},
+		{"tampered price", func(q url.Values) { q.Set(paramPrice, "0.01") }, ErrBadSignature},
+		{"bad price", func(q url.Values) { q.Set(paramPrice, "free") }, ErrMalformed},
+		{"tampered timestamp", func(q url.Values) { q.Set(paramTimestamp, "1000001") }, ErrBadSignature},
+		{"unknown key", func(q url.Values) { q.Set(paramKey, "gone") }, ErrBadSignature},
+		{"no signature", func(q url.Values) { q.Del(paramSignature) }, ErrMalformed},
+		{"bad timestamp", func(q url.Values) { q.Set(paramTimestamp, "soon") }, ErrMalformed},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			q := query(t, l.Impression())
+			tt.modify(q)
+			_, err := s.Verify(KindImpression, q)
+			assert.ErrorIs(t, err, tt.want)
+		})
+	}
+
+	q = query(t, l.Impression())
+	now = now.Add(time.Hour + time.Second)
+	c, err = s.Verify(KindImpression, q)
+	assert.ErrorIs(t, err, ErrExpired)
+	assert.Equal(t, "c1", c.CampaignID, "expired links still carry their claims")
+
+	now = time.Unix(1_000_000, 0).Add(-2 * clockSkew)
+	_, err = s.Verify(KindImpression, q)
+	assert.ErrorIs(t, err, ErrBadSignature, "links from the future")
+}
+
+func TestReplayGuard(t *testing.T) {
+	now := time.Unix(0, 0)
+	g := NewReplayGuard(time.Minute)
+	g.now, g.started = func() time.Time { return now }, now
+
+	assert.True(t, g.First("a"))
+	assert.False(t, g.First("a"))
+
+	now = now.Add(time.Minute)
+	assert.True(t, g.First("b"))
+	assert.False(t, g.First("a"), "the previous generation is still checked")
+
+	now = now.Add(time.Minute)
+	assert.True(t, g.First("a"), "forgotten after two generations")
+	assert.False(t, g.First("b"))
+
+	now = now.Add(10 * time.Minute)
+	assert.True(t, g.First("b"), "an idle guard forgets everything")
+}
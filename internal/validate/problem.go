@@ -0,0 +1,38 @@
+package validate
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+// ProblemContentType is the RFC 7807 media type.
+const ProblemContentType = "application/problem+json"
+
+// ProblemType identifies validation failures in Problem.Type.
+const ProblemType = "urn:ad-targeting-engine:problem:invalid-request"
+
+// Problem is an RFC 7807 problem details body, extended with the
+// individual field errors.
+type Problem struct {
+	Type     string `json:"type"`
+	Title    string `json:"title"`
+	Status   int    `json:"status"`
+	Detail   string `json:"detail,omitempty"`
+	Instance string `json:"instance,omitempty"`
+	Errors   Errors `json:"errors,omitempty"`
+}
+
+// WriteProblem answers 400 with errs as problem+json and counts them.
+func WriteProblem(w http.ResponseWriter, r *http.Request, errs Errors) {
+	errs.Observe()
+	w.Header().Set("Content-Type", ProblemContentType)
+	w.WriteHeader(http.StatusBadRequest)
+	_ = json.NewEncoder(w).Encode(Problem{
+		Type:     ProblemType,
+		Title:    "Invalid request",
+		Status:   http.StatusBadRequest,
+		Detail:   errs.Error(),
+		Instance: r.URL.Path,
+		Errors:   errs,
+	})
+}
@@ -0,0 +1,171 @@
+// Package validate checks and normalizes delivery request parameters for
+// every serving path (HTTP GET and POST, the legacy server and gRPC), so
+// they accept and reject the same inputs with the same error codes.
+package validate
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"ad-targeting-engine/internal/geo"
+	"ad-targeting-engine/internal/observability"
+)
+
+// Error codes. They are part of the API: clients may switch on them, so
+// existing codes must not change meaning.
+const (
+	CodeRequired           = "required"
+	CodeTooLong            = "too_long"
+	CodeInvalidCountry     = "invalid_country"
+	CodeUnknownOS          = "unknown_os"
+	CodeInvalidBundleID    = "invalid_bundle_id"
+	CodeInvalidCoordinates = "invalid_coordinates"
+	CodeMalformedBody      = "malformed_body"
+	CodeInvalidSlots       = "invalid_slots"
+)
+
+// Length limits of delivery parameters.
+const (
+	MaxAppLen     = 255
+	MaxOSLen      = 32
+	MaxContextLen = 128
+	MaxConsentLen = 4096
+	MaxSlots      = 10
+)
+
+// FieldError is one rejected field.
+type FieldError struct {
+	Field  string `json:"field"`
+	Code   string `json:"code"`
+	Detail string `json:"detail"`
+}
+
+// Errors collects the field errors of one request; empty means valid.
+type Errors []FieldError
+
+func (e Errors) Error() string {
+	parts := make([]string, len(e))
+	for i, fe := range e {
+		parts[i] = fmt.Sprintf("%s: %s", fe.Field, fe.Detail)
+	}
+	return strings.Join(parts, "; ")
+}
+
+// Add records a field error.
+func (e *Errors) Add(field, code, detail string) {
+	*e = append(*e, FieldError{Field: field, Code: code, Detail: detail})
+}
+
+// Observe counts each field error in RequestErrors under its code.
+func (e Errors) Observe() {
+	for _, fe := range e {
+		observability.RequestErrors.WithLabelValues(fe.Code).Inc()
+	}
+}
+
+// knownOS are the operating systems targeting rules can name, lower-case.
+var knownOS = map[string]bool{
+	"android": true, "ios": true, "ipados": true, "tvos": true, "watchos": true,
+	"macos": true, "windows": true, "linux": true, "chromeos": true, "fireos": true,
+	"harmonyos": true, "tizen": true, "webos": true, "roku": true, "kaios": true, "web": true,
+}
+
+// bundleID matches reverse-DNS bundle IDs and package names (com.foo.bar)
+// and numeric store IDs, with or without an "id" prefix (id628677149).
+var bundleID = regexp.MustCompile(`^(?:[a-z0-9][a-z0-9_-]*(?:\.[a-z0-9_-]+)+|(?:id)?[0-9]{1,15})$`)
+
+// IABCategory matches IAB content categories, tier 1 (IAB9) or tier 2
+// (IAB9-30), upper-cased. Campaign categories, publisher blocks and
+// auction exclusions all use it.
+var IABCategory = regexp.MustCompile(`^IAB[0-9]+(-[0-9]+)?$`)
+
+// App checks a required bundle ID and returns it lower-cased.
+func App(field, v string, errs *Errors) string {
+	v = strings.ToLower(strings.TrimSpace(v))
+	switch {
+	case v == "":
+		errs.Add(field, CodeRequired, "is required")
+	case len(v) > MaxAppLen:
+		errs.Add(field, CodeTooLong, fmt.Sprintf("must be at most %d characters", MaxAppLen))
+	case !bundleID.MatchString(v):
+		errs.Add(field, CodeInvalidBundleID, "must be a bundle ID such as com.example.app or a numeric store ID")
+	}
+	return v
+}
+
+// OS checks a required operating system and returns it lower-cased.
+func OS(field, v string, errs *Errors) string {
+	v = strings.ToLower(strings.TrimSpace(v))
+	switch {
+	case v == "":
+		errs.Add(field, CodeRequired, "is required")
+	case len(v) > MaxOSLen:
+		errs.Add(field, CodeTooLong, fmt.Sprintf("must be at most %d characters", MaxOSLen))
+	case !knownOS[v]:
+		errs.Add(field, CodeUnknownOS, "is not a known operating system")
+	}
+	return v
+}
+
+// Country checks a required ISO 3166-1 alpha-2 or alpha-3 code and returns
+// it as upper-case alpha-2, the form targeting rules use.
+func Country(field, v string, errs *Errors) string {
+	v = strings.TrimSpace(v)
+	if v == "" {
+		errs.Add(field, CodeRequired, "is required")
+		return ""
+	}
+	cc, ok := geo.CountryAlpha2(v)
+	if !ok {
+		errs.Add(field, CodeInvalidCountry, "must be an ISO 3166-1 alpha-2 or alpha-3 country code")
+		return strings.ToUpper(v)
+	}
+	return cc
+}
+
+// Optional trims an optional free-form value and checks its length.
+func Optional(field, v string, max int, errs *Errors) string {
+	v = strings.TrimSpace(v)
+	if len(v) > max {
+		errs.Add(field, CodeTooLong, fmt.Sprintf("must be at most %d characters", max))
+	}
+	return v
+}
+
+// Slots checks an optional slot count; 0 means the default.
+func Slots(field string, n int, errs *Errors) int {
+	if n < 0 || n > MaxSlots {
+		errs.Add(field, CodeInvalidSlots, fmt.Sprintf("must be between 1 and %d", MaxSlots))
+	}
+	return n
+}
+
+// SlotsParam is Slots for a query parameter.
+func SlotsParam(field, v string, errs *Errors) int {
+	if v = strings.TrimSpace(v); v == "" {
+		return 0
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil || n == 0 {
+		errs.Add(field, CodeInvalidSlots, fmt.Sprintf("must be between 1 and %d", MaxSlots))
+		return 0
+	}
+	return Slots(field, n, errs)
+}
+
+// Coordinates checks that lat and lon come together and are in range. A
+// missing half is reported on its own field.
+func Coordinates(latField, lonField string, lat, lon *float64, errs *Errors) {
+	switch {
+	case lat == nil && lon != nil:
+		errs.Add(latField, CodeInvalidCoordinates, "must be sent together with "+lonField)
+	case lat != nil && lon == nil:
+		errs.Add(lonField, CodeInvalidCoordinates, "must be sent together with "+latField)
+	case lat != nil && (*lat < -90 || *lat > 90):
+		errs.Add(latField, CodeInvalidCoordinates, "must be between -90 and 90")
+	case lon != nil && (*lon < -180 || *lon > 180):
+		errs.Add(lonField, CodeInvalidCoordinates, "must be between -180 and 180")
+	}
+}
@@ -0,0 +1,114 @@
+package validate
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"ad-targeting-engine/internal/observability"
+)
+
+func code(errs Errors) string {
+	if len(errs) == 0 {
+		return ""
+	}
+	return errs[0].Code
+}
+
+func TestApp(t *testing.T) {
+	tests := []struct {
+		in, want, wantCode string
+	}{
+		{"com.Spotify.Music", "com.spotify.music", ""},
+		{" com.king.candy_crush-saga ", "com.king.candy_crush-saga", ""},
+		{"id324684580", "id324684580", ""},
+		{"324684580", "324684580", ""},
+		{"", "", CodeRequired},
+		{"spotify", "spotify", CodeInvalidBundleID},
+		{"com..x", "com..x", CodeInvalidBundleID},
+		{"com.x/y", "com.x/y", CodeInvalidBundleID},
+		{"com." + strings.Repeat("a", MaxAppLen), "", CodeTooLong},
+	}
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			var errs Errors
+			got := App("app", tt.in, &errs)
+			assert.Equal(t, tt.wantCode, code(errs))
+			if tt.want != "" {
+				assert.Equal(t, tt.want, got)
+			}
+		})
+	}
+}
+
+func TestOSAndCountry(t *testing.T) {
+	var errs Errors
+	assert.Equal(t, "ios", OS("os", "iOS", &errs))
+	assert.Equal(t, "US", Country("country", "us", &errs))
+	assert.Equal(t, "DE", Country("country", "DEU", &errs))
+	assert.Empty(t, errs)
+
+	OS("os", "symbian", &errs)
+	OS("os", "", &errs)
+	Country("country", "germany", &errs)
+	Country("country", "XX", &errs)
+	var codes []string
+	for _, fe := range errs {
+		codes = append(codes, fe.Code)
+	}
+	assert.Equal(t, []string{CodeUnknownOS, CodeRequired, CodeInvalidCountry, CodeInvalidCountry}, codes)
+}
+
+func TestCoordinates(t *testing.T) {
+	f := func(v float64) *float64 { return &v }
+	tests := []struct {
+		name      string
+		lat, lon  *float64
+		wantField string
+	}{
+		{"none", nil, nil, ""},
+		{"both", f(52.5), f(13.4), ""},
+		{"lat only", f(1), nil, "lon"},
+		{"lon only", nil, f(1), "lat"},
+		{"lat out of range", f(91), f(0), "lat"},
+		{"lon out of range", f(0), f(-181), "lon"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var errs Errors
+			Coordinates("lat", "lon", tt.lat, tt.lon, &errs)
+			if tt.wantField == "" {
+				assert.Empty(t, errs)
+				return
+			}
+			require.Len(t, errs, 1)
+			assert.Equal(t, tt.wantField, errs[0].Field)
+			assert.Equal(t, CodeInvalidCoordinates, errs[0].Code)
+		})
+	}
+}
+
+func TestWriteProblem(t *testing.T) {
+	before := testutil.ToFloat64(observability.RequestErrors.WithLabelValues(CodeUnknownOS))
+
+	var errs Errors
+	OS("device.os", "symbian", &errs)
+	w := httptest.NewRecorder()
+	WriteProblem(w, httptest.NewRequest("POST", "/v1/delivery", nil), errs)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Equal(t, ProblemContentType, w.Header().Get("Content-Type"))
+	var p Problem
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &p))
+	assert.Equal(t, ProblemType, p.Type)
+	assert.Equal(t, http.StatusBadRequest, p.Status)
+	assert.Equal(t, "/v1/delivery", p.Instance)
+	assert.Equal(t, errs, p.Errors)
+	assert.Equal(t, before+1, testutil.ToFloat64(observability.RequestErrors.WithLabelValues(CodeUnknownOS)))
+}
@@ -0,0 +1,167 @@
+// Package vast renders video campaigns as IAB VAST 4.2 documents.
+package vast
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+
+	"ad-targeting-engine/internal/engine"
+	"ad-targeting-engine/internal/storage"
+	"ad-targeting-engine/internal/tracking"
+)
+
+const (
+	Version     = "4.2"
+	Namespace   = "http://www.iab.com/VAST"
+	ContentType = "application/xml"
+
+	adSystem = "ad-targeting-engine"
+)
+
+type VAST struct {
+	XMLName xml.Name `xml:"VAST"`
+	Version string   `xml:"version,attr"`
+	XMLNS   string   `xml:"xmlns,attr"`
+	Ads     []Ad     `xml:"Ad"`
+}
+
+type Ad struct {
+	ID     string `xml:"id,attr"`
+	InLine InLine `xml:"InLine"`
+}
+
+type InLine struct {
+	AdSystem    AdSystem     `xml:"AdSystem"`
+	AdTitle     string       `xml:"AdTitle"`
+	AdServingID string       `xml:"AdServingId"`
+	Impressions []Impression `xml:"Impression"`
+	Pricing     *Pricing     `xml:"Pricing,omitempty"`
+	Creatives   []Creative   `xml:"Creatives>Creative"`
+}
+
+// Pricing is the price an ad was won at.
+type Pricing struct {
+	Model    string `xml:"model,attr"`
+	Currency string `xml:"currency,attr"`
+	Value    string `xml:",chardata"`
+}
+
+type AdSystem struct {
+	Name string `xml:",chardata"`
+}
+
+type Impression struct {
+	ID  string `xml:"id,attr,omitempty"`
+	URL string `xml:",cdata"`
+}
+
+type Creative struct {
+	ID            string        `xml:"id,attr"`
+	AdID          string        `xml:"adId,attr"`
+	UniversalAdID UniversalAdID `xml:"UniversalAdId"`
+	Linear        Linear        `xml:"Linear"`
+}
+
+type UniversalAdID struct {
+	IDRegistry string `xml:"idRegistry,attr"`
+	Value      string `xml:",chardata"`
+}
+
+type Linear struct {
+	Duration       string      `xml:"Duration"`
+	TrackingEvents []Tracking  `xml:"TrackingEvents>Tracking"`
+	VideoClicks    VideoClicks `xml:"VideoClicks"`
+	MediaFiles     []MediaFile `xml:"MediaFiles>MediaFile"`
+}
+
+type Tracking struct {
+	Event string `xml:"event,attr"`
+	URL   string `xml:",cdata"`
+}
+
+type VideoClicks struct {
+	ClickTracking []ClickTracking `xml:"ClickTracking"`
+}
+
+type ClickTracking struct {
+	ID  string `xml:"id,attr,omitempty"`
+	URL string `xml:",cdata"`
+}
+
+type MediaFile struct {
+	Delivery string `xml:"delivery,attr"`
+	Type     string `xml:"type,attr"`
+	Width    int    `xml:"width,attr"`
+	Height   int    `xml:"height,attr"`
+	URL      string `xml:",cdata"`
+}
+
+// Document renders the video campaigns among cs as an ad buffet, skipping
+// everything else. links returns the tracking links of a campaign; nil
+// leaves out impression, progress and click tracking. With no video
+// campaign the result is an empty VAST document, which players treat as no
+// ad.
+func Document(requestID string, cs []engine.Campaign, links func(c engine.Campaign) tracking.Links) *VAST {
+	v := &VAST{Version: Version, XMLNS: Namespace}
+	for _, c := range cs {
+		if c.Creative != storage.CreativeVideo {
+			continue
+		}
+		lin := Linear{
+			Duration: Duration(time.Duration(c.Video.Duration) * time.Second),
+			MediaFiles: []MediaFile{{
+				Delivery: "progressive",
+				Type:     c.Video.MIME,
+				Width:    c.Video.Width,
+				Height:   c.Video.Height,
+				URL:      c.Video.URL,
+			}},
+		}
+		inline := InLine{
+			AdSystem:    AdSystem{Name: adSystem},
+			AdTitle:     c.Name,
+			AdServingID: requestID + "-" + c.ID,
+		}
+		if c.ClearingPrice > 0 {
+			inline.Pricing = &Pricing{Model: "CPM", Currency: "USD", Value: strconv.FormatFloat(c.ClearingPrice, 'f', -1, 64)}
+		}
+		if links != nil {
+			l := links(c)
+			inline.Impressions = []Impression{{URL: l.Impression()}}
+			lin.VideoClicks.ClickTracking = []ClickTracking{{URL: l.Click()}}
+			for _, e := range tracking.VideoEvents {
+				lin.TrackingEvents = append(lin.TrackingEvents, Tracking{Event: e, URL: l.Event(e)})
+			}
+		}
+		inline.Creatives = []Creative{{
+			ID:            c.ID,
+			AdID:          c.ID,
+			UniversalAdID: UniversalAdID{IDRegistry: "unknown", Value: "unknown"},
+			Linear:        lin,
+		}}
+		v.Ads = append(v.Ads, Ad{ID: c.ID, InLine: inline})
+	}
+	return v
+}
+
+// Duration formats d as VAST's HH:MM:SS.mmm.
+func Duration(d time.Duration) string {
+	ms := d.Milliseconds()
+	return fmt.Sprintf("%02d:%02d:%02d.%03d", ms/3_600_000, ms/60_000%60, ms/1000%60, ms%1000)
+}
+
+// Write encodes v with an XML declaration.
+func (v *VAST) Write(w io.Writer) error {
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(v); err != nil {
+		return fmt.Errorf("encode vast: %w", err)
+	}
+	return enc.Close()
+}
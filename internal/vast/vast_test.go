@@ -0,0 +1,88 @@
+package vast
+
+import (
+	"bytes"
+	"encoding/xml"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"ad-targeting-engine/internal/engine"
+	"ad-targeting-engine/internal/storage"
+	"ad-targeting-engine/internal/tracking"
+)
+
+func TestDocument(t *testing.T) {
+	cs := []engine.Campaign{
+		{ID: "banner", Creative: storage.CreativeBanner, Image: "https://img"},
+		{ID: "promo", Name: "Promo & Co", ClearingPrice: 2.5, Creative: storage.CreativeVideo, Video: storage.VideoCreative{
+			URL: "https://cdn/promo.mp4?a=1&b=2", MIME: "video/mp4", Duration: 95, Width: 1280, Height: 720}},
+	}
+	signer, err := tracking.NewSigner([]tracking.Key{{ID: "k1", Secret: "s"}}, time.Hour)
+	require.NoError(t, err)
+	doc := Document("req1", cs, func(c engine.Campaign) tracking.Links {
+		return signer.Links("https://t.example/", tracking.Claims{RequestID: "req1", CampaignID: c.ID, Creative: c.Creative})
+	})
+	verify := func(link, path, kind string) tracking.Claims {
+		t.Helper()
+		u, err := url.Parse(link)
+		require.NoError(t, err)
+		assert.Equal(t, "https://t.example"+path, u.Scheme+"://"+u.Host+u.Path)
+		c, err := signer.Verify(kind, u.Query())
+		require.NoError(t, err)
+		assert.Equal(t, "req1", c.RequestID)
+		assert.Equal(t, "promo", c.CampaignID)
+		assert.Equal(t, storage.CreativeVideo, c.Creative)
+		return c
+	}
+
+	var buf bytes.Buffer
+	require.NoError(t, doc.Write(&buf))
+	out := buf.String()
+	assert.True(t, strings.HasPrefix(out, xml.Header))
+	assert.Contains(t, out, `<VAST version="4.2" xmlns="http://www.iab.com/VAST">`)
+	assert.Contains(t, out, `<![CDATA[https://cdn/promo.mp4?a=1&b=2]]>`)
+
+	var got VAST
+	require.NoError(t, xml.Unmarshal(buf.Bytes(), &got))
+	require.Len(t, got.Ads, 1, "banner campaigns are skipped")
+	ad := got.Ads[0]
+	assert.Equal(t, "promo", ad.ID)
+	assert.Equal(t, "Promo & Co", ad.InLine.AdTitle)
+	assert.Equal(t, "req1-promo", ad.InLine.AdServingID)
+	assert.Equal(t, &Pricing{Model: "CPM", Currency: "USD", Value: "2.5"}, ad.InLine.Pricing)
+	verify(ad.InLine.Impressions[0].URL, tracking.ImpressionPath, tracking.KindImpression)
+
+	lin := ad.InLine.Creatives[0].Linear
+	assert.Equal(t, "00:01:35.000", lin.Duration)
+	assert.Equal(t, MediaFile{Delivery: "progressive", Type: "video/mp4", Width: 1280, Height: 720,
+		URL: "https://cdn/promo.mp4?a=1&b=2"}, lin.MediaFiles[0])
+	require.Len(t, lin.TrackingEvents, len(tracking.VideoEvents))
+	assert.Equal(t, "midpoint", lin.TrackingEvents[2].Event)
+	assert.Equal(t, "midpoint", verify(lin.TrackingEvents[2].URL, tracking.EventPath, tracking.KindEvent).Event)
+	verify(lin.VideoClicks.ClickTracking[0].URL, tracking.ClickPath, tracking.KindClick)
+}
+
+func TestDocument_NoTracking(t *testing.T) {
+	cs := []engine.Campaign{{ID: "promo", Creative: storage.CreativeVideo, Video: storage.VideoCreative{URL: "https://cdn/p.mp4"}}}
+	got := Document("req1", cs, nil)
+	require.Len(t, got.Ads, 1)
+	assert.Empty(t, got.Ads[0].InLine.Impressions)
+	assert.Empty(t, got.Ads[0].InLine.Creatives[0].Linear.TrackingEvents)
+	assert.Empty(t, got.Ads[0].InLine.Creatives[0].Linear.VideoClicks.ClickTracking)
+}
+
+func TestDocument_Empty(t *testing.T) {
+	var buf bytes.Buffer
+	require.NoError(t, Document("r", nil, nil).Write(&buf))
+	assert.Equal(t, xml.Header+`<VAST version="4.2" xmlns="http://www.iab.com/VAST"></VAST>`, buf.String())
+}
+
+func TestDuration(t *testing.T) {
+	assert.Equal(t, "00:00:30.000", Duration(30*time.Second))
+	assert.Equal(t, "01:02:03.450", Duration(time.Hour+2*time.Minute+3450*time.Millisecond))
+}